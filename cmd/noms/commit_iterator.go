@@ -7,19 +7,24 @@ package main
 import (
 	"fmt"
 
+	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 )
 
 type CommitIterator struct {
 	db       datas.Database
 	branches branchList
+	missing  hash.HashSet
 }
 
 // Initialize a new CommitIterator with the first commit to be printed.
 func NewCommitIterator(db datas.Database, commit types.Struct) *CommitIterator {
 	cr := types.NewRef(commit)
-	return &CommitIterator{db: db, branches: branchList{branch{cr: cr, commit: commit}}}
+	missing, err := db.MissingParents()
+	d.CheckErrorNoUsage(err)
+	return &CommitIterator{db: db, branches: branchList{branch{cr: cr, commit: commit}}, missing: missing}
 }
 
 // Returns information about the next commit to be printed. LogNode contains enough contextual
@@ -46,10 +51,17 @@ func (iter *CommitIterator) Next() (LogNode, bool) {
 	iter.branches = iter.branches.RemoveBranches(branchIndexes[1:])
 
 	// If this commit has parents, then a branch is splitting. Create a branch for each of the parents
-	// and splice that into the iterators list of branches.
+	// and splice that into the iterators list of branches. Parents that the database is missing, because
+	// only part of the history was pulled, end their branch here.
 	branches := branchList{}
-	parents := commitRefsFromSet(br.commit.Get(datas.ParentsField).(types.Set))
-	for _, p := range parents {
+	parents := []types.Ref{}
+	missingParents := []types.Ref{}
+	for _, p := range commitRefsFromSet(br.commit.Get(datas.ParentsField).(types.Set)) {
+		if iter.missing.Has(p.TargetHash()) {
+			missingParents = append(missingParents, p)
+			continue
+		}
+		parents = append(parents, p)
 		b := branch{cr: p, commit: iter.db.ReadValue(p.TargetHash()).(types.Struct)}
 		branches = append(branches, b)
 	}
@@ -73,6 +85,7 @@ func (iter *CommitIterator) Next() (LogNode, bool) {
 		newCols:          newCols,
 		foldedCols:       foldedCols,
 		lastCommit:       iter.branches.IsEmpty(),
+		missingParents:   missingParents,
 	}
 	return node, true
 }
@@ -86,12 +99,23 @@ type LogNode struct {
 	newCols          []int        // col to start using '\' in graph
 	foldedCols       []int        // cols with common ancestors, that will get folded together
 	lastCommit       bool         // this is the last commit that will be returned by iterator
+	missingParents   []types.Ref  // parents of commit that aren't in the database, so aren't returned by iterator
 }
 
 func (n LogNode) String() string {
 	return fmt.Sprintf("cr: %s(%d), startingColCount: %d, endingColCount: %d, col: %d, newCols: %v, foldedCols: %v, expanding: %t, shrunk: %t, shrinking: %t", n.cr.TargetHash().String()[0:9], n.cr.Height(), n.startingColCount, n.endingColCount, n.col, n.newCols, n.foldedCols, n.Expanding(), n.Shrunk(), n.Shrinking())
 }
 
+// True if parent is a parent of this commit that isn't in the database
+func (n LogNode) IsMissingParent(parent types.Ref) bool {
+	for _, p := range n.missingParents {
+		if p.Equals(parent) {
+			return true
+		}
+	}
+	return false
+}
+
 // True if this commit's graph will expand to show an additional branch
 func (n LogNode) Expanding() bool {
 	return n.startingColCount < n.endingColCount
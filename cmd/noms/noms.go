@@ -16,13 +16,22 @@ import (
 )
 
 var commands = []*util.Command{
+	nomsBlame,
+	nomsBundle,
+	nomsCommit,
 	nomsDiff,
 	nomsDs,
+	nomsGC,
+	nomsIndex,
 	nomsLog,
+	nomsMerge,
+	nomsQuery,
+	nomsRecompress,
 	nomsServe,
 	nomsShow,
 	nomsSync,
 	nomsVersion,
+	nomsWatch,
 }
 
 var actions = []string{
@@ -0,0 +1,305 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/outputpager"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var nomsBlame = &util.Command{
+	Run:       runBlame,
+	UsageLine: "blame [options] <dataset> <path>",
+	Short:     "Shows which commits last changed a value",
+	Long:      "Walks the history of <dataset> backwards from its head to find the commit that introduced the current value at <path>, e.g. .rows[1234].price, or . for the whole value. If the value is a List, Map, Set or Struct, each element, entry or field is annotated with the commit that introduced its current value instead. Each line shows the commit, its date and author, followed by the meta of every commit shown.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset and path arguments.",
+	Flags:     setupBlameFlags,
+	Nargs:     2,
+}
+
+func setupBlameFlags() *flag.FlagSet {
+	blameFlagSet := flag.NewFlagSet("blame", flag.ExitOnError)
+	outputpager.RegisterOutputpagerFlags(blameFlagSet)
+	spec.RegisterDatabaseFlags(blameFlagSet)
+	return blameFlagSet
+}
+
+func runBlame(args []string) int {
+	ds, err := spec.GetDataset(args[0])
+	d.CheckError(err)
+	defer ds.Database().Close()
+
+	path := types.Path{}
+	if pathStr := args[1]; pathStr != "." {
+		if pathStr != "" && strings.IndexByte(".[@", pathStr[0]) < 0 {
+			pathStr = "." + pathStr
+		}
+		path, err = types.ParsePath(pathStr)
+		d.CheckError(err)
+	}
+
+	head, ok := ds.MaybeHead()
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("Dataset %s has no head", ds.ID()))
+	}
+	lines, ok := blame(ds.Database(), head, path)
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("No value at %s in the head of %s", path, ds.ID()))
+	}
+
+	pgr := outputpager.Start()
+	defer pgr.Stop()
+	writeBlame(pgr.Writer, lines)
+	return 0
+}
+
+// blameLine attributes an element of the blamed value to the commit that introduced it. label is empty when the value is blamed as a whole, or is an element of a Set.
+type blameLine struct {
+	label  string
+	value  types.Value
+	commit types.Struct
+}
+
+// blameEntry is an element of the blamed value that hasn't been attributed to a commit yet. key is where the element is found in the commit being examined, which for a List may differ from its index in the head.
+type blameEntry struct {
+	line int
+	key  types.Value
+}
+
+// blame returns a line for each element of the value at path in head, or a single line for the value itself if it isn't a List, Map, Set or Struct. The commit graph is walked from head towards its roots, and each element is carried back to a parent for as long as its value there is the same. Where the blamed value is unchanged between a commit and its parent, all its elements are carried back without looking at them.
+func blame(db datas.Database, head types.Struct, path types.Path) (lines []blameLine, ok bool) {
+	value := blameValue(db, head, path)
+	if value == nil {
+		return nil, false
+	}
+
+	entries := []blameEntry{}
+	add := func(key types.Value, label string, v types.Value) {
+		entries = append(entries, blameEntry{len(lines), key})
+		lines = append(lines, blameLine{label: label, value: v})
+	}
+	switch value := value.(type) {
+	case types.List:
+		value.IterAll(func(v types.Value, i uint64) {
+			add(types.Number(i), fmt.Sprintf("[%d]", i), v)
+		})
+	case types.Map:
+		value.IterAll(func(k, v types.Value) {
+			add(k, "["+blameKey(k)+"]", v)
+		})
+	case types.Set:
+		value.IterAll(func(v types.Value) {
+			add(v, "", v)
+		})
+	case types.Struct:
+		value.Type().Desc.(types.StructDesc).IterFields(func(name string, t *types.Type) {
+			add(types.String(name), "."+name, value.Get(name))
+		})
+	default:
+		add(nil, "", value)
+	}
+
+	pending := map[hash.Hash][]blameEntry{head.Hash(): entries}
+	iter := NewCommitIterator(db, head)
+	for ln, ok := iter.Next(); ok && len(pending) > 0; ln, ok = iter.Next() {
+		h := ln.commit.Hash()
+		entries, found := pending[h]
+		if !found {
+			continue
+		}
+		delete(pending, h)
+
+		current := blameValue(db, ln.commit, path)
+		for _, p := range commitRefsFromSet(ln.commit.Get(datas.ParentsField).(types.Set)) {
+			if len(entries) == 0 {
+				break
+			}
+			// If the parent wasn't pulled, whatever is left is blamed on this commit.
+			if ln.IsMissingParent(p) {
+				continue
+			}
+			parent := db.ReadValue(p.TargetHash()).(types.Struct)
+			var carried []blameEntry
+			carried, entries = carryBlame(current, blameValue(db, parent, path), entries)
+			if len(carried) > 0 {
+				pending[p.TargetHash()] = append(pending[p.TargetHash()], carried...)
+			}
+		}
+		for _, e := range entries {
+			lines[e.line].commit = ln.commit
+		}
+	}
+	return lines, true
+}
+
+func blameValue(db datas.Database, commit types.Struct, path types.Path) (v types.Value) {
+	path.ResolveEach(commit.Get(datas.ValueField), db, func(r types.Value) bool {
+		v = r
+		return true
+	})
+	return
+}
+
+// carryBlame splits entries of current into those that have the same value in parent, translated to where they are found in parent, and those that don't.
+func carryBlame(current, parent types.Value, entries []blameEntry) (carried, remaining []blameEntry) {
+	if parent == nil {
+		return nil, entries
+	}
+	if current.Equals(parent) {
+		return entries, nil
+	}
+	if current.Type().Kind() != parent.Type().Kind() {
+		return nil, entries
+	}
+
+	var changes func(cc chan<- types.ValueChanged, sc <-chan struct{})
+	switch current := current.(type) {
+	case types.List:
+		return carryListBlame(current, parent.(types.List), entries)
+	case types.Map:
+		changes = func(cc chan<- types.ValueChanged, sc <-chan struct{}) {
+			current.Diff(parent.(types.Map), cc, sc)
+		}
+	case types.Set:
+		changes = func(cc chan<- types.ValueChanged, sc <-chan struct{}) {
+			current.Diff(parent.(types.Set), cc, sc)
+		}
+	case types.Struct:
+		changes = func(cc chan<- types.ValueChanged, sc <-chan struct{}) {
+			current.Diff(parent.(types.Struct), cc, sc)
+		}
+	default:
+		return nil, entries
+	}
+
+	changeChan := make(chan types.ValueChanged)
+	go func() {
+		changes(changeChan, nil)
+		close(changeChan)
+	}()
+	changed := map[hash.Hash]bool{}
+	for c := range changeChan {
+		changed[c.V.Hash()] = true
+	}
+	for _, e := range entries {
+		if e.key != nil && !changed[e.key.Hash()] {
+			carried = append(carried, e)
+		} else {
+			remaining = append(remaining, e)
+		}
+	}
+	return
+}
+
+// carryListBlame maps the indices of entries in current to their indices in parent. Elements that fall within a splice are new in current and can't be carried back.
+func carryListBlame(current, parent types.List, entries []blameEntry) (carried, remaining []blameEntry) {
+	spliceChan := make(chan types.Splice)
+	go func() {
+		current.Diff(parent, spliceChan, nil)
+		close(spliceChan)
+	}()
+
+	// Splices are in ascending order. Each one maps to [start, start+SpAdded) in current, and shifts the elements after it by SpAdded-SpRemoved.
+	type span struct {
+		start, end uint64
+		shift      int64
+	}
+	spans := []span{}
+	shift := int64(0)
+	for sp := range spliceChan {
+		start := uint64(int64(sp.SpAt) + shift)
+		shift += int64(sp.SpAdded) - int64(sp.SpRemoved)
+		spans = append(spans, span{start, start + sp.SpAdded, shift})
+	}
+
+	for _, e := range entries {
+		idx := uint64(e.key.(types.Number))
+		newInCurrent, delta := false, int64(0)
+		for _, s := range spans {
+			if idx < s.start {
+				break
+			}
+			if idx < s.end {
+				newInCurrent = true
+				break
+			}
+			delta = s.shift
+		}
+		if newInCurrent {
+			remaining = append(remaining, e)
+		} else {
+			carried = append(carried, blameEntry{e.line, types.Number(int64(idx) - delta)})
+		}
+	}
+	return
+}
+
+func writeBlame(w io.Writer, lines []blameLine) {
+	commits := []types.Struct{}
+	seen := map[hash.Hash]bool{}
+	for _, l := range lines {
+		meta := datas.GetCommitMeta(l.commit)
+		date, author := "-", "-"
+		if !meta.Date.IsZero() {
+			date = meta.Date.UTC().Format("2006-01-02")
+		}
+		if meta.Author != "" {
+			author = meta.Author
+		}
+		fmt.Fprintf(w, "%s (%s %s) ", l.commit.Hash(), date, author)
+		if l.label != "" {
+			fmt.Fprintf(w, "%s: ", l.label)
+		}
+		fmt.Fprintln(w, blameSummary(l.value))
+
+		if h := l.commit.Hash(); !seen[h] {
+			seen[h] = true
+			commits = append(commits, l.commit)
+		}
+	}
+
+	for _, c := range commits {
+		fmt.Fprintf(w, "\ncommit %s\n", c.Hash())
+		meta, ok := c.MaybeGet(datas.MetaField)
+		if !ok {
+			continue
+		}
+		desc := meta.(types.Struct).Type().Desc.(types.StructDesc)
+		maxLen := 0
+		desc.IterFields(func(name string, t *types.Type) {
+			maxLen = max(maxLen, len(name))
+		})
+		desc.IterFields(func(name string, t *types.Type) {
+			fmt.Fprintf(w, "%-*s %s\n", maxLen+1, strings.Title(name)+":", types.EncodedValue(meta.(types.Struct).Get(name)))
+		})
+	}
+}
+
+// blameKey returns how k is written in a path index: primitives are written out, and other values are identified by their hash.
+func blameKey(k types.Value) string {
+	switch k.(type) {
+	case types.Bool, types.Number, types.String:
+		return types.EncodedIndexValue(k)
+	}
+	return "#" + k.Hash().String()
+}
+
+// blameSummary returns v on one line: primitives are written out, and other values are identified by their hash.
+func blameSummary(v types.Value) string {
+	switch v.(type) {
+	case types.Bool, types.Number, types.String:
+		return types.EncodedValue(v)
+	}
+	return "#" + v.Hash().String()
+}
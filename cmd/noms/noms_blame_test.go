@@ -0,0 +1,124 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsBlame(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsBlameTestSuite{})
+}
+
+type nomsBlameTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+var blameDate = time.Date(2016, 9, 1, 12, 0, 0, 0, time.UTC)
+
+func (s *nomsBlameTestSuite) commit(ds dataset.Dataset, v types.Value, author string) (dataset.Dataset, string) {
+	ds, err := ds.Commit(v, dataset.CommitOptions{}.WithMeta(datas.CommitMeta{Author: author, Date: blameDate}))
+	s.NoError(err)
+	return ds, ds.Head().Hash().String()
+}
+
+func (s *nomsBlameTestSuite) blameLines(args ...string) []string {
+	out, _ := s.Run(main, append([]string{"blame"}, args...))
+	return strings.Split(strings.SplitN(out, "\n\n", 2)[0], "\n")
+}
+
+func (s *nomsBlameTestSuite) TestBlameList() {
+	db, err := spec.GetDatabase(spec.CreateDatabaseSpecString("ldb", s.LdbDir))
+	s.NoError(err)
+	ds := dataset.NewDataset(db, "ds")
+	row := func(price float64) types.Struct {
+		return types.NewStruct("Row", types.StructData{"price": types.Number(price)})
+	}
+
+	ds, h1 := s.commit(ds, types.NewStruct("Data", types.StructData{
+		"rows": types.NewList(row(1), row(2), row(3)),
+	}), "alice")
+	ds, h2 := s.commit(ds, types.NewStruct("Data", types.StructData{
+		"rows": types.NewList(row(1), row(20), row(3)),
+	}), "bob")
+	ds, h3 := s.commit(ds, types.NewStruct("Data", types.StructData{
+		"rows": types.NewList(row(1), row(20), row(3), row(4)),
+	}), "carol")
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "ds")
+	lines := s.blameLines(dsSpec, ".rows[1].price")
+	s.Equal([]string{h2 + " (2016-09-01 bob) 20"}, lines)
+
+	lines = s.blameLines(dsSpec, "rows[0].price")
+	s.Equal([]string{h1 + " (2016-09-01 alice) 1"}, lines)
+
+	// Inserting a row changes the values at the paths of the rows after it, but not the rows themselves.
+	ds, h4 := s.commit(ds, types.NewStruct("Data", types.StructData{
+		"rows": types.NewList(row(0), row(1), row(20), row(3), row(4)),
+	}), "dave")
+	ds.Database().Close()
+
+	lines = s.blameLines(dsSpec, ".rows[2].price")
+	s.Equal([]string{h4 + " (2016-09-01 dave) 20"}, lines)
+
+	lines = s.blameLines(dsSpec, ".rows")
+	s.Len(lines, 5)
+	for i, h := range []string{h4, h1, h2, h1, h3} {
+		s.True(strings.HasPrefix(lines[i], fmt.Sprintf("%s (2016-09-01 ", h)), lines[i])
+		s.Contains(lines[i], fmt.Sprintf(") [%d]: #", i))
+	}
+
+	out, _ := s.Run(main, []string{"blame", dsSpec, ".rows[0]"})
+	s.Contains(out, "\ncommit "+h4+"\nAuthor: \"dave\"\nDate:   \"2016-09-01T12:00:00Z\"\n")
+}
+
+func (s *nomsBlameTestSuite) TestBlameMapAcrossMerge() {
+	db, err := spec.GetDatabase(spec.CreateDatabaseSpecString("ldb", s.LdbDir))
+	s.NoError(err)
+	ds := dataset.NewDataset(db, "ds")
+
+	ds, h1 := s.commit(ds, types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(2)), "alice")
+	base := ds.HeadRef()
+	ds, h2 := s.commit(ds, types.NewMap(types.String("a"), types.Number(10), types.String("b"), types.Number(2)), "bob")
+	left := ds.HeadRef()
+
+	other := dataset.NewDataset(ds.Database(), "other")
+	other, err = other.Commit(types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(2), types.String("c"), types.Number(3)), dataset.CommitOptions{Parents: types.NewSet(base)}.WithMeta(datas.CommitMeta{Author: "carol", Date: blameDate}))
+	s.NoError(err)
+	h3 := other.Head().Hash().String()
+
+	ds = dataset.NewDataset(other.Database(), "ds")
+	ds, err = ds.Commit(types.NewMap(types.String("a"), types.Number(10), types.String("b"), types.Number(2), types.String("c"), types.Number(3)), dataset.CommitOptions{Parents: types.NewSet(left, other.HeadRef())}.WithMeta(datas.CommitMeta{Author: "erin", Date: blameDate}))
+	s.NoError(err)
+	ds.Database().Close()
+
+	lines := s.blameLines(spec.CreateValueSpecString("ldb", s.LdbDir, "ds"), ".")
+	s.Equal([]string{
+		h2 + ` (2016-09-01 bob) ["a"]: 10`,
+		h1 + ` (2016-09-01 alice) ["b"]: 2`,
+		h3 + ` (2016-09-01 carol) ["c"]: 3`,
+	}, lines)
+}
+
+func (s *nomsBlameTestSuite) TestBlameMissing() {
+	db, err := spec.GetDatabase(spec.CreateDatabaseSpecString("ldb", s.LdbDir))
+	s.NoError(err)
+	ds, _ := s.commit(dataset.NewDataset(db, "ds"), types.Number(1), "alice")
+	ds.Database().Close()
+
+	s.Panics(func() { s.Run(main, []string{"blame", spec.CreateValueSpecString("ldb", s.LdbDir, "ds"), ".foo"}) })
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var (
+	bundleDataset     string
+	bundleConcurrency int
+)
+
+var nomsBundle = &util.Command{
+	Run:       runBundle,
+	UsageLine: "bundle create [options] <path> <file>",
+	Short:     "Writes a value and everything it refers to into a single file",
+	Long:      "Writes every chunk reachable from the value at <path> to <file>, which can then be read as a database with a bundle:<file> spec. If <path> is a commit, such as the head of a dataset, the bundle holds it and its history as the head of a dataset; otherwise the value is bundled as the head of a new commit. The dataset is named after the one in <path>, or with --dataset.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the path argument.",
+	Flags:     setupBundleFlags,
+	Nargs:     3,
+}
+
+func setupBundleFlags() *flag.FlagSet {
+	bundleFlagSet := flag.NewFlagSet("bundle", flag.ExitOnError)
+	bundleFlagSet.StringVar(&bundleDataset, "dataset", "", "name of the dataset in the bundle (defaults to the dataset of <path>)")
+	bundleFlagSet.IntVar(&bundleConcurrency, "p", 64, "parallelism")
+	spec.RegisterDatabaseFlags(bundleFlagSet)
+	return bundleFlagSet
+}
+
+func runBundle(args []string) int {
+	if args[0] != "create" {
+		d.CheckError(fmt.Errorf("Unknown bundle command %s, expected create", args[0]))
+	}
+	pathSpec, file := args[1], args[2]
+
+	dsName := bundleDataset
+	if dsName == "" {
+		if parts := strings.SplitN(pathSpec, "::", 2); len(parts) == 2 {
+			if p, err := spec.NewAbsolutePath(parts[1]); err == nil {
+				dsName = p.Dataset()
+			}
+		}
+	}
+	if dsName == "" {
+		d.CheckErrorNoUsage(fmt.Errorf("%s doesn't name a dataset, so --dataset is required", pathSpec))
+	}
+
+	db, value, err := spec.GetPath(pathSpec)
+	d.CheckError(err)
+	defer db.Close()
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", pathSpec))
+	}
+
+	head, ok := value.(types.Struct)
+	if !ok || !datas.IsCommitType(head.Type()) {
+		head = datas.NewCommit(value, types.NewSet(), types.EmptyStruct)
+	}
+
+	count, err := datas.WriteBundle(db, dsName, head, file, bundleConcurrency)
+	d.CheckErrorNoUsage(err)
+	fi, err := os.Stat(file)
+	d.CheckErrorNoUsage(err)
+	fmt.Printf("Wrote %d chunks (%s) to %s as dataset %s\n", count, humanize.Bytes(uint64(fi.Size())), file, dsName)
+	return 0
+}
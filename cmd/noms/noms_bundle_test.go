@@ -0,0 +1,86 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsBundle(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsBundleTestSuite{})
+}
+
+type nomsBundleTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsBundleTestSuite) TestBundleDataset() {
+	ds := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "ds")
+	ds, err := ds.CommitValue(types.String("first"))
+	s.NoError(err)
+	ds, err = ds.CommitValue(types.String("second"))
+	s.NoError(err)
+	ds.Database().Close()
+
+	bundle := path.Join(s.TempDir, "ds.nbundle")
+	out, _ := s.Run(main, []string{"bundle", "create", spec.CreateValueSpecString("ldb", s.LdbDir, "ds"), bundle})
+	s.True(strings.HasPrefix(out, "Wrote 3 chunks ("), out)
+	s.True(strings.HasSuffix(out, ") to "+bundle+" as dataset ds\n"), out)
+
+	out, _ = s.Run(main, []string{"show", "bundle:" + bundle + "::ds.value"})
+	s.Equal("\"second\"\n", out)
+	out, _ = s.Run(main, []string{"log", "--oneline", "bundle:" + bundle + "::ds"})
+	s.Equal(2, strings.Count(out, "\n"), out)
+
+	ldb2dir := path.Join(s.TempDir, "ldb2")
+	s.Run(main, []string{"sync", "bundle:" + bundle + "::ds", spec.CreateValueSpecString("ldb", ldb2dir, "imported")})
+	dest := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(ldb2dir, "", 1, false)), "imported")
+	defer dest.Database().Close()
+	s.True(types.String("second").Equals(dest.HeadValue()))
+}
+
+func (s *nomsBundleTestSuite) TestBundleValue() {
+	ds := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "ds")
+	ds, err := ds.CommitValue(types.NewStruct("S", types.StructData{"list": types.NewList(types.Number(1), types.Number(2))}))
+	s.NoError(err)
+	headHash := ds.Head().Hash()
+	ds.Database().Close()
+
+	bundle := path.Join(s.TempDir, "list.nbundle")
+	hashSpec := spec.CreateHashSpecString("ldb", s.LdbDir, headHash) + ".value.list"
+	s.Panics(func() {
+		s.Run(main, []string{"bundle", "create", hashSpec, bundle})
+	})
+	out, _ := s.Run(main, []string{"bundle", "create", "--dataset", "list", hashSpec, bundle})
+	s.Contains(out, "as dataset list")
+
+	out, _ = s.Run(main, []string{"show", "bundle:" + bundle + "::list.value"})
+	s.Equal("List<Number>([\n  1,\n  2,\n])\n", out)
+}
+
+func (s *nomsBundleTestSuite) TestBundleReadOnly() {
+	ds := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "ds")
+	ds, err := ds.CommitValue(types.Number(42))
+	s.NoError(err)
+	ds.Database().Close()
+
+	bundle := path.Join(s.TempDir, "ds.nbundle")
+	s.Run(main, []string{"bundle", "create", spec.CreateValueSpecString("ldb", s.LdbDir, "ds"), bundle})
+	s.Panics(func() {
+		s.Run(main, []string{"commit", "43", "bundle:" + bundle + "::ds"})
+	})
+}
@@ -0,0 +1,101 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var (
+	commitMessage string
+	commitAuthor  string
+	commitDate    string
+	commitMeta    metaFlag
+)
+
+var nomsCommit = &util.Command{
+	Run:       runCommit,
+	UsageLine: "commit [options] <absolute-path> <dataset>",
+	Short:     "Commits an existing value to a dataset",
+	Long:      "Commits the value at <absolute-path>, which must already be in the database of <dataset>, as the new head of <dataset>. The commit's meta records the author, date and message, along with any --meta key/value pairs.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the absolute-path and dataset arguments.",
+	Flags:     setupCommitFlags,
+	Nargs:     2,
+}
+
+func setupCommitFlags() *flag.FlagSet {
+	commitFlagSet := flag.NewFlagSet("commit", flag.ExitOnError)
+	commitMeta = metaFlag{}
+	commitFlagSet.StringVar(&commitMessage, "m", "", "commit message")
+	commitFlagSet.StringVar(&commitAuthor, "author", os.Getenv("NOMS_AUTHOR"), "author of the commit (defaults to $NOMS_AUTHOR)")
+	commitFlagSet.StringVar(&commitDate, "date", "", fmt.Sprintf(`date of the commit in ISO 8601 format ("%s"). By default, the current date is used.`, datas.MetaDateFormat))
+	commitFlagSet.Var(&commitMeta, "meta", "additional meta data as key=value; may be repeated")
+	spec.RegisterDatabaseFlags(commitFlagSet)
+	return commitFlagSet
+}
+
+func runCommit(args []string) int {
+	path, err := spec.NewAbsolutePath(args[0])
+	d.CheckError(err)
+
+	meta := datas.CommitMeta{Author: commitAuthor, Message: commitMessage, Extra: commitMeta.data}
+	if commitDate != "" {
+		meta.Date, err = datas.ParseMetaDate(commitDate)
+		d.CheckError(err)
+	}
+
+	ds, err := spec.GetDataset(args[1])
+	d.CheckError(err)
+	defer ds.Database().Close()
+
+	value := path.Resolve(ds.Database())
+	if value == nil {
+		d.CheckErrorNoUsage(fmt.Errorf("Object not found: %s", args[0]))
+	}
+
+	ds, err = ds.Commit(value, dataset.CommitOptions{}.WithMeta(meta))
+	d.CheckErrorNoUsage(err)
+
+	fmt.Println(ds.HeadRef().TargetHash())
+	return 0
+}
+
+// metaFlag collects repeated key=value flags into the extra fields of a commit meta.
+type metaFlag struct {
+	data types.StructData
+}
+
+func (f *metaFlag) String() string {
+	pairs := []string{}
+	for k, v := range f.data {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", k, v.(types.String)))
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (f *metaFlag) Set(str string) error {
+	idx := strings.Index(str, "=")
+	if idx <= 0 {
+		return fmt.Errorf("Invalid meta %q, expected key=value", str)
+	}
+	key := str[:idx]
+	if !types.IsValidStructFieldName(key) {
+		return fmt.Errorf("Invalid meta key %q", key)
+	}
+	if f.data == nil {
+		f.data = types.StructData{}
+	}
+	f.data[key] = types.String(str[idx+1:])
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsCommit(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsCommitTestSuite{})
+}
+
+type nomsCommitTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsCommitTestSuite) TestCommit() {
+	db, err := spec.GetDatabase(spec.CreateDatabaseSpecString("ldb", s.LdbDir))
+	s.NoError(err)
+	ds := dataset.NewDataset(db, "src")
+	ds, err = ds.CommitValue(types.NewStruct("Data", types.StructData{
+		"rows": types.NewList(types.Number(1), types.Number(2)),
+	}))
+	s.NoError(err)
+	db = ds.Database()
+	db.Close()
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "dest")
+	out, _ := s.Run(main, []string{"commit", "src.value.rows", dsSpec, "-m", "copy rows", "--author", "bob", "--date", "2016-09-01T12:00:00Z", "--meta", "source=src", "--meta", "reviewer=carol"})
+
+	db, err = spec.GetDatabase(spec.CreateDatabaseSpecString("ldb", s.LdbDir))
+	s.NoError(err)
+	defer db.Close()
+	head := db.Head("dest")
+	s.Equal(head.Hash().String(), strings.TrimSpace(out))
+	s.True(types.NewList(types.Number(1), types.Number(2)).Equals(head.Get(datas.ValueField)))
+
+	meta := datas.GetCommitMeta(head)
+	s.Equal("bob", meta.Author)
+	s.Equal("copy rows", meta.Message)
+	s.True(time.Date(2016, 9, 1, 12, 0, 0, 0, time.UTC).Equal(meta.Date))
+	s.Equal(types.StructData{"source": types.String("src"), "reviewer": types.String("carol")}, meta.Extra)
+}
+
+func (s *nomsCommitTestSuite) TestCommitMissingValue() {
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "dest")
+	s.Panics(func() { s.Run(main, []string{"commit", "nothing.value", dsSpec}) })
+}
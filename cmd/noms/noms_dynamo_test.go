@@ -0,0 +1,109 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestDynamo(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsDynamoTestSuite{})
+}
+
+// nomsDynamoTestSuite runs noms commands against dynamo specs, served by the fake DynamoDB of the chunks package.
+type nomsDynamoTestSuite struct {
+	clienttest.ClientTestSuite
+	server *httptest.Server
+}
+
+func (s *nomsDynamoTestSuite) SetupSuite() {
+	s.ClientTestSuite.SetupSuite()
+	os.Setenv("AWS_ACCESS_KEY_ID", "key")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+}
+
+func (s *nomsDynamoTestSuite) TearDownSuite() {
+	os.Unsetenv("AWS_ACCESS_KEY_ID")
+	os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	s.ClientTestSuite.TearDownSuite()
+}
+
+func (s *nomsDynamoTestSuite) SetupTest() {
+	s.server = httptest.NewServer(chunks.NewFakeDynamoServer(s.Assert()))
+}
+
+func (s *nomsDynamoTestSuite) TearDownTest() {
+	s.server.Close()
+}
+
+func (s *nomsDynamoTestSuite) dbSpec(namespace string) string {
+	return fmt.Sprintf("dynamo://table/%s?endpoint=%s", namespace, url.QueryEscape(s.server.URL))
+}
+
+func (s *nomsDynamoTestSuite) TestDsAndShow() {
+	ds, err := spec.GetDataset(s.dbSpec("ns") + "::foo")
+	s.NoError(err)
+	ds, err = ds.CommitValue(types.String("hello"))
+	s.NoError(err)
+	ds.Database().Close()
+
+	out, _ := s.Run(main, []string{"ds", s.dbSpec("ns")})
+	s.Equal("foo\n", out)
+	out, _ = s.Run(main, []string{"show", s.dbSpec("ns") + "::foo.value"})
+	s.Equal("\"hello\"\n", out)
+
+	out, _ = s.Run(main, []string{"ds", s.dbSpec("other")})
+	s.Equal("", out)
+}
+
+func (s *nomsDynamoTestSuite) TestSync() {
+	source := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "foo")
+	source, err := source.CommitValue(types.NewList(types.Number(1), types.Number(2)))
+	s.NoError(err)
+	source, err = source.CommitValue(types.NewList(types.Number(1), types.Number(2), types.Number(3)))
+	s.NoError(err)
+	sourceHead := source.HeadRef().TargetHash()
+	source.Database().Close()
+
+	// ldb -> dynamo
+	s.Run(main, []string{"sync", spec.CreateValueSpecString("ldb", s.LdbDir, "foo"), s.dbSpec("ns") + "::bar"})
+	out, _ := s.Run(main, []string{"log", "--oneline", s.dbSpec("ns") + "::bar"})
+	s.Contains(out, sourceHead.String())
+
+	// dynamo -> ldb
+	ldb2dir := path.Join(s.TempDir, "ldb2")
+	s.Run(main, []string{"sync", s.dbSpec("ns") + "::bar", spec.CreateValueSpecString("ldb", ldb2dir, "baz")})
+	dest := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(ldb2dir, "", 1, false)), "baz")
+	s.Equal(sourceHead, dest.HeadRef().TargetHash())
+	dest.Database().Close()
+}
+
+func (s *nomsDynamoTestSuite) TestCommit() {
+	db, err := spec.GetDatabase(s.dbSpec("ns"))
+	s.NoError(err)
+	r := db.WriteValue(types.String("committed"))
+	db.Close()
+
+	s.Run(main, []string{"commit", "-m", "from dynamo", "#" + r.TargetHash().String(), s.dbSpec("ns") + "::foo"})
+	out, _ := s.Run(main, []string{"show", s.dbSpec("ns") + "::foo.value"})
+	s.Equal("\"committed\"\n", out)
+	out, _ = s.Run(main, []string{"show", s.dbSpec("ns") + "::foo.meta.message"})
+	s.Equal("\"from dynamo\"\n", out)
+}
@@ -0,0 +1,46 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var gcConcurrency int
+
+var nomsGC = &util.Command{
+	Run:       runGC,
+	UsageLine: "gc [options] <database>",
+	Short:     "Deletes chunks that are no longer reachable from any dataset",
+	Long:      "Walks the commit graph of every dataset in <database>, then deletes all chunks that were not visited. Only ldb databases are supported.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Flags:     setupGCFlags,
+	Nargs:     1,
+}
+
+func setupGCFlags() *flag.FlagSet {
+	gcFlagSet := flag.NewFlagSet("gc", flag.ExitOnError)
+	gcFlagSet.IntVar(&gcConcurrency, "p", 64, "parallelism")
+	spec.RegisterDatabaseFlags(gcFlagSet)
+	return gcFlagSet
+}
+
+func runGC(args []string) int {
+	cs, err := spec.GetChunkStore(args[0])
+	d.CheckError(err)
+	defer cs.Close()
+
+	stats, err := datas.CollectGarbage(cs, gcConcurrency)
+	d.CheckErrorNoUsage(err)
+
+	fmt.Printf("Reclaimed %s (%d chunks)\n", humanize.Bytes(stats.BytesReclaimed), stats.ChunksDeleted)
+	return 0
+}
@@ -0,0 +1,53 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestGC(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsGCTestSuite{})
+}
+
+type nomsGCTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsGCTestSuite) TestGC() {
+	cs := chunks.NewLevelDBStore(s.LdbDir, "", 1, false)
+	db := datas.NewDatabase(cs)
+	kept := dataset.NewDataset(db, "kept")
+	kept, err := kept.CommitValue(types.String("kept"))
+	s.NoError(err)
+	deleted := dataset.NewDataset(kept.Database(), "deleted")
+	deleted, err = deleted.CommitValue(types.String("deleted"))
+	s.NoError(err)
+	deletedRef := deleted.HeadRef()
+	_, err = deleted.Database().Delete("deleted")
+	s.NoError(err)
+	db.Close()
+
+	out, _ := s.Run(main, []string{"gc", spec.CreateDatabaseSpecString("ldb", s.LdbDir)})
+	s.True(strings.HasPrefix(out, "Reclaimed "), out)
+	s.False(strings.HasSuffix(out, "(0 chunks)\n"), out)
+
+	cs = chunks.NewLevelDBStore(s.LdbDir, "", 1, false)
+	db = datas.NewDatabase(cs)
+	defer db.Close()
+	s.False(cs.Has(deletedRef.TargetHash()))
+	s.True(types.String("kept").Equals(db.Head("kept").Get(datas.ValueField)))
+}
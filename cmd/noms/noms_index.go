@@ -0,0 +1,55 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/index"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var indexBy string
+
+var nomsIndex = &util.Command{
+	Run:       runIndex,
+	UsageLine: "index create <dataset> --by <path>",
+	Short:     "Builds and updates secondary indexes of datasets",
+	Long:      "Indexes the rows of <dataset>, whose head value must be a Map or Set of structs, by the value found at <path> within each row, e.g. --by .address.city. The index is a Map from those values to the Set of Refs to the rows having them, and is committed to the dataset <dataset>/index/<path>, together with the source commit it reflects. If the index already exists, only the changes made to <dataset> since that commit are applied.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.",
+	Flags:     setupIndexFlags,
+	Nargs:     2,
+}
+
+func setupIndexFlags() *flag.FlagSet {
+	indexFlagSet := flag.NewFlagSet("index", flag.ExitOnError)
+	indexFlagSet.StringVar(&indexBy, "by", "", "path within each row to index by")
+	spec.RegisterDatabaseFlags(indexFlagSet)
+	return indexFlagSet
+}
+
+func runIndex(args []string) int {
+	if args[0] != "create" {
+		d.CheckError(fmt.Errorf("Unknown index command: %s", args[0]))
+	}
+	if indexBy == "" {
+		d.CheckError(fmt.Errorf("Missing --by path"))
+	}
+	by, err := types.ParsePath(indexBy)
+	d.CheckError(err)
+
+	source, err := spec.GetDataset(args[1])
+	d.CheckError(err)
+	defer source.Database().Close()
+
+	ix, ds, err := index.Update(source.Database(), source.ID(), by)
+	d.CheckErrorNoUsage(err)
+
+	fmt.Printf("Indexed %s by %s into %s (%d values)\n", source.ID(), by, ds.ID(), ix.Entries.Len())
+	return 0
+}
@@ -0,0 +1,70 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/index"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsIndex(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsIndexTestSuite{})
+}
+
+type nomsIndexTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsIndexTestSuite) TestCreate() {
+	cs := chunks.NewLevelDBStore(s.LdbDir, "", 1, false)
+	ds := dataset.NewDataset(datas.NewDatabase(cs), "rows")
+	row := func(id float64, status string) types.Value {
+		return types.NewStruct("Row", types.StructData{"id": types.Number(id), "status": types.String(status)})
+	}
+	ds, err := ds.CommitValue(types.NewMap(
+		types.Number(1), row(1, "ok"),
+		types.Number(2), row(2, "failed"),
+		types.Number(3), row(3, "ok"),
+	))
+	s.NoError(err)
+	ds.Database().Close()
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "rows")
+	out, _ := s.Run(main, []string{"index", "create", dsSpec, "--by", ".status"})
+	s.Equal("Indexed rows by .status into rows/index/status (2 values)\n", out)
+
+	cs = chunks.NewLevelDBStore(s.LdbDir, "", 1, false)
+	db := datas.NewDatabase(cs)
+	defer db.Close()
+	ix, ok, err := index.Read(db, "rows", types.Path{types.NewFieldPath("status")})
+	s.NoError(err)
+	s.True(ok)
+	s.False(ix.Stale(db, "rows"))
+	s.Equal(uint64(2), ix.Lookup(types.String("ok")).Len())
+	s.Equal(uint64(1), ix.Lookup(types.String("failed")).Len())
+}
+
+func (s *nomsIndexTestSuite) TestCreateErrors() {
+	cs := chunks.NewLevelDBStore(s.LdbDir, "", 1, false)
+	ds := dataset.NewDataset(datas.NewDatabase(cs), "rows")
+	ds, err := ds.CommitValue(types.NewList(types.Number(1)))
+	s.NoError(err)
+	ds.Database().Close()
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "rows")
+	s.Panics(func() {
+		s.Run(main, []string{"index", "create", dsSpec, "--by", ".status"})
+	})
+}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"math"
 	"strings"
+	"time"
 
 	"github.com/attic-labs/noms/cmd/noms/diff"
 	"github.com/attic-labs/noms/cmd/util"
@@ -31,6 +32,10 @@ var (
 	oneline    bool
 	showGraph  bool
 	showValue  bool
+	logAuthor  string
+	logSince   string
+	logUntil   string
+	logGrep    string
 )
 
 const parallelism = 16
@@ -52,6 +57,10 @@ func setupLogFlags() *flag.FlagSet {
 	logFlagSet.BoolVar(&oneline, "oneline", false, "show a summary of each commit on a single line")
 	logFlagSet.BoolVar(&showGraph, "graph", false, "show ascii-based commit hierarcy on left side of output")
 	logFlagSet.BoolVar(&showValue, "show-value", false, "show commit value rather than diff information -- this is temporary")
+	logFlagSet.StringVar(&logAuthor, "author", "", "only show commits by this author")
+	logFlagSet.StringVar(&logSince, "since", "", "only show commits dated at or after this date (ISO 8601 date or date and time)")
+	logFlagSet.StringVar(&logUntil, "until", "", "only show commits dated before the end of this date (ISO 8601 date or date and time)")
+	logFlagSet.StringVar(&logGrep, "grep", "", "only show commits whose message contains this string")
 	outputpager.RegisterOutputpagerFlags(logFlagSet)
 	return logFlagSet
 }
@@ -74,6 +83,9 @@ func runLog(args []string) int {
 		d.CheckError(fmt.Errorf("%s does not reference a Commit object", args[0]))
 	}
 
+	filter, err := newLogFilter(logAuthor, logSince, logUntil, logGrep)
+	d.CheckError(err)
+
 	iter := NewCommitIterator(database, origCommit)
 	displayed := 0
 	if maxCommits <= 0 {
@@ -89,6 +101,9 @@ func runLog(args []string) int {
 
 	go func() {
 		for ln, ok := iter.Next(); ok && displayed < maxCommits; ln, ok = iter.Next() {
+			if !filter.matches(ln.commit) {
+				continue
+			}
 			inChan <- ln
 			displayed++
 		}
@@ -104,6 +119,57 @@ func runLog(args []string) int {
 	return 0
 }
 
+// logFilter selects the commits to show by their meta. Zero fields match every commit.
+type logFilter struct {
+	author       string
+	since, until time.Time
+	grep         string
+}
+
+func newLogFilter(author, since, until, grep string) (f logFilter, err error) {
+	f.author, f.grep = author, grep
+	if since != "" {
+		if f.since, err = parseLogDate(since, false); err != nil {
+			return
+		}
+	}
+	if until != "" {
+		f.until, err = parseLogDate(until, true)
+	}
+	return
+}
+
+// parseLogDate parses either a date, such as 2016-09-01, or a date and time in datas.MetaDateFormat. If end is true, a date by itself means the end of that day.
+func parseLogDate(str string, end bool) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", str); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return datas.ParseMetaDate(str)
+}
+
+func (f logFilter) matches(commit types.Struct) bool {
+	if f == (logFilter{}) {
+		return true
+	}
+	meta := datas.GetCommitMeta(commit)
+	if f.author != "" && meta.Author != f.author {
+		return false
+	}
+	if f.grep != "" && !strings.Contains(meta.Message, f.grep) {
+		return false
+	}
+	if !f.since.IsZero() && (meta.Date.IsZero() || meta.Date.Before(f.since)) {
+		return false
+	}
+	if !f.until.IsZero() && (meta.Date.IsZero() || !meta.Date.Before(f.until)) {
+		return false
+	}
+	return true
+}
+
 // Prints the information for one commit in the log, including ascii graph on left side of commits if
 // -graph arg is true.
 func printCommit(node LogNode, w io.Writer, db datas.Database) (err error) {
@@ -131,12 +197,12 @@ func printCommit(node LogNode, w io.Writer, db datas.Database) (err error) {
 	if len(parents) > 1 {
 		pstrings := make([]string, len(parents))
 		for i, p := range parents {
-			pstrings[i] = p.TargetHash().String()
+			pstrings[i] = parentString(node, p)
 		}
 		parentLabel = "Merge"
 		parentValue = strings.Join(pstrings, " ")
 	} else if len(parents) == 1 {
-		parentValue = parents[0].TargetHash().String()
+		parentValue = parentString(node, parents[0])
 	}
 
 	if oneline {
@@ -167,6 +233,14 @@ func printCommit(node LogNode, w io.Writer, db datas.Database) (err error) {
 	return
 }
 
+// parentString returns the hash of parent, noting if it's missing from the database because only part of the history was pulled.
+func parentString(node LogNode, parent types.Ref) string {
+	if node.IsMissingParent(parent) {
+		return parent.TargetHash().String() + " (not pulled)"
+	}
+	return parent.TargetHash().String()
+}
+
 // Generates ascii graph chars to display on the left side of the commit info if -graph arg is true.
 func genGraph(node LogNode, lineno int) string {
 	if !showGraph {
@@ -260,7 +334,8 @@ func writeDiffLines(node LogNode, db datas.Database, maxLines, lineno int, w io.
 	if parents.Len() > 0 {
 		parent = parents.First()
 	}
-	if parent == nil {
+	// There's nothing to diff against if the parent wasn't pulled.
+	if parent == nil || node.IsMissingParent(parent.(types.Ref)) {
 		_, err = fmt.Fprint(mlw, "\n")
 		return 1, err
 	}
@@ -6,9 +6,12 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/dataset"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
@@ -143,6 +146,41 @@ func (s *nomsLogTestSuite) TestEmptyCommit() {
 	test.EqualsIgnoreHashes(s.T(), metaRes2, res)
 }
 
+func (s *nomsLogTestSuite) TestFilters() {
+	db, err := spec.GetDatabase(spec.CreateDatabaseSpecString("ldb", s.LdbDir))
+	s.NoError(err)
+
+	ds := dataset.NewDataset(db, "filtered")
+	commit := func(v, author, date, message string) string {
+		t, err := time.Parse(time.RFC3339, date)
+		s.NoError(err)
+		ds, err = ds.Commit(types.String(v), dataset.CommitOptions{}.WithMeta(datas.CommitMeta{Author: author, Date: t, Message: message}))
+		s.NoError(err)
+		return ds.Head().Hash().String()
+	}
+	h1 := commit("1", "alice", "2016-08-01T10:00:00Z", "import data")
+	h2 := commit("2", "bob", "2016-09-01T10:00:00Z", "fix typo")
+	h3 := commit("3", "alice", "2016-09-02T10:00:00Z", "fix import")
+	ds.Database().Close()
+
+	dsSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "filtered")
+	test := func(args []string, expected ...string) {
+		res, _ := s.Run(main, append([]string{"log", "--oneline", dsSpec}, args...))
+		shown := []string{}
+		for _, line := range strings.Split(strings.TrimSpace(res), "\n") {
+			shown = append(shown, strings.Fields(line)[0])
+		}
+		s.Equal(expected, shown, "%v", args)
+	}
+	test([]string{}, h3, h2, h1)
+	test([]string{"--author", "alice"}, h3, h1)
+	test([]string{"--grep", "fix"}, h3, h2)
+	test([]string{"--since", "2016-09-01"}, h3, h2)
+	test([]string{"--until", "2016-09-01"}, h2, h1)
+	test([]string{"--since", "2016-09-01T12:00:00Z", "--author", "alice"}, h3)
+	test([]string{"--author", "alice", "-n", "1"}, h3)
+}
+
 func (s *nomsLogTestSuite) TestNomsGraph1() {
 	str := spec.CreateDatabaseSpecString("ldb", s.LdbDir)
 	db, err := spec.GetDatabase(str)
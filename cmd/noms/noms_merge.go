@@ -0,0 +1,65 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/merge"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var nomsMerge = &util.Command{
+	Run:       runMerge,
+	UsageLine: "merge <database> <left-dataset> <right-dataset> <parent-dataset>",
+	Short:     "Merges the heads of two datasets",
+	Long:      "Performs a three-way merge of the head values of <left-dataset> and <right-dataset>, using the value of their most recent common ancestor commit as the base. Non-overlapping changes are combined automatically; conflicting changes are reported by path and nothing is written. On success, the merged value is committed to <parent-dataset> with both heads as parents.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Flags:     setupMergeFlags,
+	Nargs:     4,
+}
+
+func setupMergeFlags() *flag.FlagSet {
+	return flag.NewFlagSet("merge", flag.ExitOnError)
+}
+
+func runMerge(args []string) int {
+	db, err := spec.GetDatabase(args[0])
+	d.CheckError(err)
+	defer db.Close()
+
+	leftRef := mustHeadRef(db, args[1])
+	rightRef := mustHeadRef(db, args[2])
+	parentDataset := dataset.NewDataset(db, args[3])
+
+	var parentValue types.Value
+	if ancestorRef, ok := merge.FindCommonAncestor(leftRef, rightRef, db); ok {
+		parentValue = ancestorRef.TargetValue(db).(types.Struct).Get(datas.ValueField)
+	}
+
+	left := db.Head(args[1]).Get(datas.ValueField)
+	right := db.Head(args[2]).Get(datas.ValueField)
+	merged, err := merge.ThreeWay(left, right, parentValue)
+	d.CheckErrorNoUsage(err)
+
+	parentDataset, err = parentDataset.Commit(merged, dataset.CommitOptions{Parents: types.NewSet(leftRef, rightRef)})
+	d.CheckErrorNoUsage(err)
+
+	fmt.Println(parentDataset.HeadRef().TargetHash())
+	return 0
+}
+
+func mustHeadRef(db datas.Database, datasetID string) types.Ref {
+	r, ok := db.MaybeHeadRef(datasetID)
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("Dataset %s has no head", datasetID))
+	}
+	return r
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestMerge(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsMergeTestSuite{})
+}
+
+type nomsMergeTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsMergeTestSuite) setupDatasets(dir string, leftValue, rightValue types.Value) {
+	db := datas.NewDatabase(chunks.NewLevelDBStore(dir, "", 1, false))
+	defer db.Close()
+
+	parent := types.NewMap(types.String("num"), types.Number(42), types.String("str"), types.String("foobar"))
+	base := dataset.NewDataset(db, "left")
+	base, err := base.CommitValue(parent)
+	s.NoError(err)
+	right := dataset.NewDataset(base.Database(), "right")
+	right, err = right.Commit(rightValue, dataset.CommitOptions{Parents: types.NewSet(base.HeadRef())})
+	s.NoError(err)
+	left := dataset.NewDataset(right.Database(), "left")
+	_, err = left.CommitValue(leftValue)
+	s.NoError(err)
+}
+
+func (s *nomsMergeTestSuite) TestMerge() {
+	dir := s.LdbDir + "/merge"
+	parent := types.NewMap(types.String("num"), types.Number(42), types.String("str"), types.String("foobar"))
+	s.setupDatasets(dir, parent.Set(types.String("num"), types.Number(43)), parent.Set(types.String("str2"), types.String("baz")))
+
+	out, _ := s.Run(main, []string{"merge", spec.CreateDatabaseSpecString("ldb", dir), "left", "right", "merged"})
+
+	db := datas.NewDatabase(chunks.NewLevelDBStore(dir, "", 1, false))
+	defer db.Close()
+	merged := dataset.NewDataset(db, "merged")
+	s.Equal(merged.HeadRef().TargetHash().String()+"\n", out)
+
+	expected := types.NewMap(types.String("num"), types.Number(43), types.String("str"), types.String("foobar"), types.String("str2"), types.String("baz"))
+	s.True(expected.Equals(merged.HeadValue()))
+	parents := merged.Head().Get(datas.ParentsField).(types.Set)
+	s.True(parents.Has(db.HeadRef("left")))
+	s.True(parents.Has(db.HeadRef("right")))
+}
+
+func (s *nomsMergeTestSuite) TestMergeConflict() {
+	dir := s.LdbDir + "/conflict"
+	parent := types.NewMap(types.String("num"), types.Number(42), types.String("str"), types.String("foobar"))
+	s.setupDatasets(dir, parent.Set(types.String("num"), types.Number(43)), parent.Set(types.String("num"), types.Number(44)))
+
+	defer func() {
+		err := recover()
+		s.Equal(exitError{-1}, err)
+
+		db := datas.NewDatabase(chunks.NewLevelDBStore(dir, "", 1, false))
+		defer db.Close()
+		_, ok := db.MaybeHead("merged")
+		s.False(ok)
+	}()
+
+	s.Run(main, []string{"merge", spec.CreateDatabaseSpecString("ldb", dir), "left", "right", "merged"})
+}
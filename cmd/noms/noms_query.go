@@ -0,0 +1,88 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/query"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/outputpager"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var queryJSON bool
+
+var nomsQuery = &util.Command{
+	Run:       runQuery,
+	UsageLine: "query [options] <object> <query>",
+	Short:     "Filters, projects and counts the rows of a collection",
+	Long: `Runs <query> against the rows of <object>, which must be a List, Map or Set (typically of structs), or a commit whose value is one. Results are written one per line as they are found.
+
+A query is made up of the following optional clauses, in this order:
+
+  where <predicate>     keep only the rows matching <predicate>
+  select <path>, ...    output only the given fields of each row
+  count                 output the number of rows
+  group by <path>       output the number of rows for each value at <path>
+  limit <n>             output at most <n> results
+
+Only one of select, count and group by may be given. Predicates compare the value at a path within a row to a string, number or boolean using =, !=, <, <=, > or >=, or test for a value with <path> exists, and can be combined using and, or, not and parentheses. For example:
+
+  noms query ldb:/data::jobs 'where .status = "failed" count'
+  noms query ldb:/data::jobs 'where .retries > 3 and not .owner exists select .id limit 10'
+  noms query ldb:/data::jobs 'group by .status'
+
+See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object argument.`,
+	Flags: setupQueryFlags,
+	Nargs: 2,
+}
+
+func setupQueryFlags() *flag.FlagSet {
+	queryFlagSet := flag.NewFlagSet("query", flag.ExitOnError)
+	queryFlagSet.BoolVar(&queryJSON, "json", false, "output results as JSON, one per line")
+	outputpager.RegisterOutputpagerFlags(queryFlagSet)
+	return queryFlagSet
+}
+
+func runQuery(args []string) int {
+	q, err := query.Parse(args[1])
+	d.CheckError(err)
+
+	database, value, err := spec.GetPath(args[0])
+	d.CheckErrorNoUsage(err)
+	defer database.Close()
+
+	if value == nil {
+		fmt.Fprintf(os.Stderr, "Object not found: %s\n", args[0])
+		return 0
+	}
+	if datas.IsCommitType(value.Type()) {
+		value = value.(types.Struct).Get(datas.ValueField)
+	}
+
+	pgr := outputpager.Start()
+	defer pgr.Stop()
+
+	err = q.Run(value, func(result types.Value) bool {
+		if queryJSON {
+			err = query.WriteJSON(pgr.Writer, result)
+		} else {
+			err = types.WriteEncodedValue(pgr.Writer, result)
+			if err == nil {
+				_, err = fmt.Fprintln(pgr.Writer)
+			}
+		}
+		// Stop reading once the output has gone away, e.g. because the pager was closed.
+		return err != nil
+	})
+	d.CheckErrorNoUsage(err)
+	return 0
+}
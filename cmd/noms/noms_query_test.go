@@ -0,0 +1,60 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsQuery(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsQueryTestSuite{})
+}
+
+type nomsQueryTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsQueryTestSuite) setup() string {
+	cs := chunks.NewLevelDBStore(s.LdbDir, "", 1, false)
+	ds := dataset.NewDataset(datas.NewDatabase(cs), "jobs")
+	job := func(id int, status string) types.Value {
+		return types.NewStruct("Job", types.StructData{"id": types.Number(id), "status": types.String(status)})
+	}
+	ds, err := ds.CommitValue(types.NewList(job(1, "ok"), job(2, "failed"), job(3, "failed")))
+	s.NoError(err)
+	ds.Database().Close()
+	return spec.CreateValueSpecString("ldb", s.LdbDir, "jobs")
+}
+
+func (s *nomsQueryTestSuite) TestQuery() {
+	dsSpec := s.setup()
+
+	out, _ := s.Run(main, []string{"query", dsSpec, `where .status = "failed" count`})
+	s.Equal("2\n", out)
+
+	out, _ = s.Run(main, []string{"query", dsSpec + ".value", `where .status = "failed" select .id`})
+	s.Equal("Job {\n  id: 2,\n}\nJob {\n  id: 3,\n}\n", out)
+
+	out, _ = s.Run(main, []string{"query", "--json", dsSpec, `group by .status`})
+	s.Equal("{\"count\":2,\"status\":\"failed\"}\n{\"count\":1,\"status\":\"ok\"}\n", out)
+}
+
+func (s *nomsQueryTestSuite) TestQueryNotACollection() {
+	dsSpec := s.setup()
+
+	s.Panics(func() {
+		s.Run(main, []string{"query", dsSpec + ".value[0]", `count`})
+	})
+}
@@ -0,0 +1,55 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/spec"
+	humanize "github.com/dustin/go-humanize"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var recompressCodec chunks.ChunkCodec
+
+var nomsRecompress = &util.Command{
+	Run:       runRecompress,
+	UsageLine: "recompress [options] <database>",
+	Short:     "Re-encodes all chunks in a database with a compression codec",
+	Long:      "Re-encodes every chunk in <database> that isn't already encoded with the given codec, including chunks written by versions of noms that predate codecs, then reports the space saved. Only ldb databases are supported.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Flags:     setupRecompressFlags,
+	Nargs:     1,
+}
+
+func setupRecompressFlags() *flag.FlagSet {
+	recompressFlagSet := flag.NewFlagSet("recompress", flag.ExitOnError)
+	recompressCodec = chunks.SnappyCodec
+	recompressFlagSet.Var(&chunks.ChunkCodecFlag{Codec: &recompressCodec}, "codec", "codec to encode chunks with")
+	spec.RegisterDatabaseFlags(recompressFlagSet)
+	return recompressFlagSet
+}
+
+func runRecompress(args []string) int {
+	cs, err := spec.GetChunkStore(args[0])
+	d.CheckError(err)
+	defer cs.Close()
+
+	rc, ok := cs.(chunks.Recompressor)
+	if !ok {
+		d.CheckErrorNoUsage(fmt.Errorf("Database %s can't be recompressed", args[0]))
+	}
+	stats := rc.Recompress(recompressCodec)
+
+	fmt.Printf("Recompressed %d of %d chunks with %s\n", stats.Recompressed, stats.Chunks, recompressCodec.Name())
+	if stats.BytesAfter <= stats.BytesBefore {
+		fmt.Printf("Saved %s (%s -> %s)\n", humanize.Bytes(stats.BytesBefore-stats.BytesAfter), humanize.Bytes(stats.BytesBefore), humanize.Bytes(stats.BytesAfter))
+	} else {
+		fmt.Printf("Grew by %s (%s -> %s)\n", humanize.Bytes(stats.BytesAfter-stats.BytesBefore), humanize.Bytes(stats.BytesBefore), humanize.Bytes(stats.BytesAfter))
+	}
+	return 0
+}
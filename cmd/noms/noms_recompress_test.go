@@ -0,0 +1,59 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestRecompress(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsRecompressTestSuite{})
+}
+
+type nomsRecompressTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsRecompressTestSuite) TestRecompress() {
+	ds := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "ds")
+	ds, err := ds.CommitValue(types.String(strings.Repeat("compressible ", 1000)))
+	s.NoError(err)
+	ds.Database().Close()
+	dbSpec := spec.CreateDatabaseSpecString("ldb", s.LdbDir)
+
+	out, _ := s.Run(main, []string{"recompress", "--codec", "none", dbSpec})
+	lines := strings.Split(out, "\n")
+	s.Equal("Recompressed 2 of 2 chunks with none", lines[0])
+	s.True(strings.HasPrefix(lines[1], "Grew by "), out)
+
+	out, _ = s.Run(main, []string{"recompress", dbSpec})
+	lines = strings.Split(out, "\n")
+	s.Equal("Recompressed 2 of 2 chunks with snappy", lines[0])
+	s.True(strings.HasPrefix(lines[1], "Saved "), out)
+
+	out, _ = s.Run(main, []string{"recompress", dbSpec})
+	s.True(strings.HasPrefix(out, "Recompressed 0 of 2 chunks with snappy\nSaved 0 B "), out)
+
+	ds = dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "ds")
+	defer ds.Database().Close()
+	s.True(types.String(strings.Repeat("compressible ", 1000)).Equals(ds.HeadValue()))
+}
+
+func (s *nomsRecompressTestSuite) TestRecompressUnsupported() {
+	s.Panics(func() {
+		s.Run(main, []string{"recompress", "mem"})
+	})
+}
@@ -5,42 +5,133 @@
 package main
 
 import (
+	"errors"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/util/metrics"
 	"github.com/attic-labs/noms/go/util/profile"
 	flag "github.com/tsuru/gnuflag"
 )
 
 var (
-	port int
+	port          int
+	serveStdio    bool
+	accessConfig  string
+	serveSocket   string
+	serveCert     string
+	serveKey      string
+	serveClientCA string
+	serveMetrics  bool
+	serveUpstream string
+	serveCache    string
+	serveReadOnly bool
+	serveRootTTL  time.Duration
 )
 
 var nomsServe = &util.Command{
 	Run:       runServe,
-	UsageLine: "serve [options] <database>",
+	UsageLine: "serve [options] <database>\n       serve [options] --upstream <url> --cache <database>",
 	Short:     "Serves a Noms database over HTTP",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
+	Long:      "With --cert and --key, the server only accepts HTTPS connections, and with --client-ca as well, clients must present a certificate signed by one of the given CAs. Clients reaching an https spec read the CAs to trust, and the certificate and key to present, from the files named by the NOMS_TLS_CA, NOMS_TLS_CERT and NOMS_TLS_KEY environment variables.\n\nWith --socket, the server listens on a unix domain socket instead of a port, which local clients reach with a unix:<socket> spec. This lets several processes on one machine share a LevelDB database, which otherwise only one process can open at a time.\n\nWith --access-config, clients can only read and write the datasets that the bearer tokens they send are allowed to, as listed in the given JSON file. See AccessControl in https://github.com/attic-labs/noms/blob/master/go/datas/access_control.go for its format.\n\nValues can also be read as JSON, a page at a time, by GETting /value/<path>, where path is a dataset or a hash (as %23<hash>) followed by an optional path into the value. See HandleValue in https://github.com/attic-labs/noms/blob/master/go/spec/value_handler.go for the details.\n\nWith --upstream, the server is a replica of the database served at the given http(s) URL, e.g. by another noms serve, for clients too far from it to read it quickly. Chunks are read from the database given by --cache, and those it doesn't have are fetched from upstream and added to it, so it only ever grows. The upstream root is read at most once every --root-ttl. Writes are forwarded upstream, with the client's credentials, unless --read-only is given, in which case they're refused. An access_token in the URL is sent with the replica's own reads.\n\nWith --stdio, requests are read from stdin and responses written to stdout instead, which is how ssh:// specs reach databases on other hosts.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the database argument.",
 	Flags:     setupServeFlags,
-	Nargs:     1,
+	Nargs:     0,
 }
 
 func setupServeFlags() *flag.FlagSet {
 	serveFlagSet := flag.NewFlagSet("serve", flag.ExitOnError)
 	serveFlagSet.IntVar(&port, "port", 8000, "port to listen on for HTTP requests")
+	serveFlagSet.BoolVar(&serveStdio, "stdio", false, "serve a single client over stdin and stdout rather than listening on a port")
+	serveFlagSet.StringVar(&serveSocket, "socket", "", "path of a unix domain socket to listen on rather than a port")
+	serveFlagSet.StringVar(&serveCert, "cert", "", "PEM file holding the server's TLS certificate")
+	serveFlagSet.StringVar(&serveKey, "key", "", "PEM file holding the private key of --cert")
+	serveFlagSet.StringVar(&serveClientCA, "client-ca", "", "PEM file holding the CAs that clients' certificates must be signed by (by default, clients don't need certificates)")
+	serveFlagSet.StringVar(&accessConfig, "access-config", "", "JSON file mapping bearer tokens to the datasets they can read and write (by default, anyone can read and write anything)")
+	serveFlagSet.StringVar(&serveUpstream, "upstream", "", "http(s) URL of a database to serve a replica of, rather than serving <database>")
+	serveFlagSet.StringVar(&serveCache, "cache", "", "database to cache the chunks of --upstream in")
+	serveFlagSet.BoolVar(&serveReadOnly, "read-only", false, "refuse writes to --upstream rather than forwarding them")
+	serveFlagSet.DurationVar(&serveRootTTL, "root-ttl", time.Second, "how long the root of --upstream is cached for")
+	serveFlagSet.BoolVar(&serveMetrics, "metrics", false, "record metrics of requests and of the database, and serve them at /metrics in the Prometheus text format")
 	spec.RegisterDatabaseFlags(serveFlagSet)
 	return serveFlagSet
 }
 
 func runServe(args []string) int {
-	cs, err := spec.GetChunkStore(args[0])
-	d.CheckError(err)
+	if (serveCert == "") != (serveKey == "") {
+		d.CheckErrorNoUsage(errors.New("--cert and --key must be given together"))
+	}
+	if serveClientCA != "" && serveCert == "" {
+		d.CheckErrorNoUsage(errors.New("--client-ca needs --cert and --key"))
+	}
+	if serveSocket != "" && serveCert != "" {
+		d.CheckErrorNoUsage(errors.New("Connections to --socket are local, so they can't use TLS"))
+	}
+	if serveStdio && (serveSocket != "" || serveCert != "") {
+		d.CheckErrorNoUsage(errors.New("--stdio can't be used with --socket or --cert"))
+	}
+	if serveUpstream == "" && (serveCache != "" || serveReadOnly) {
+		d.CheckErrorNoUsage(errors.New("--cache and --read-only need --upstream"))
+	}
+	if serveUpstream != "" && (serveCache == "" || len(args) != 0) {
+		d.CheckErrorNoUsage(errors.New("--upstream needs --cache, and no database"))
+	}
+	if serveUpstream == "" && len(args) != 1 {
+		d.CheckErrorNoUsage(errors.New("Expected a database to serve"))
+	}
+
+	var reg *metrics.Registry
+	if serveMetrics {
+		reg = metrics.NewRegistry()
+	}
+	var cs chunks.ChunkStore
+	var rs *datas.ReplicaStore
+	var err error
+	if serveUpstream != "" {
+		cs, err = spec.GetChunkStore(serveCache)
+		d.CheckErrorNoUsage(err)
+		// The replica's cache is instrumented rather than the replica itself, whose reads the server batches, which it can't through an InstrumentedStore. Misses in the cache are the reads that go upstream.
+		if reg != nil {
+			cs = chunks.NewInstrumentedStore(cs, reg, "cache")
+		}
+		rs, err = spec.GetReplicaStore(serveUpstream, cs, serveRootTTL)
+		d.CheckErrorNoUsage(err)
+		rs.ReadOnly = serveReadOnly
+		cs = rs
+	} else {
+		cs, err = spec.GetChunkStore(args[0])
+		d.CheckError(err)
+		if reg != nil {
+			cs = chunks.NewInstrumentedStore(cs, reg, "database")
+		}
+	}
+
 	server := datas.NewRemoteDatabaseServer(cs, port)
+	server.Metrics = reg
+	if rs != nil {
+		server.WriteHandler = rs.WriteHandler()
+	}
+	server.Socket = serveSocket
+	server.ValueHandler = spec.HandleValue
+	if serveCert != "" {
+		server.TLSConfig, err = datas.NewServerTLSConfig(serveCert, serveKey, serveClientCA)
+		d.CheckErrorNoUsage(err)
+	}
+	if accessConfig != "" {
+		server.AccessControl, err = datas.LoadAccessControl(accessConfig)
+		d.CheckErrorNoUsage(err)
+	}
+
+	if serveStdio {
+		server.RunStdio(os.Stdin, os.Stdout)
+		return 0
+	}
 
 	// Shutdown server gracefully so that profile may be written
 	c := make(chan os.Signal, 1)
@@ -0,0 +1,130 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+const sshHelperEnv = "NOMS_TEST_SSH_HELPER"
+
+// TestSSHHelperProcess isn't a real test. It stands in for ssh in the tests below: it's run with the arguments ssh would get, and runs the noms command they contain locally.
+func TestSSHHelperProcess(t *testing.T) {
+	if os.Getenv(sshHelperEnv) == "" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	// Like ssh, have the shell split the command after "--" and the host into arguments.
+	out, err := exec.Command("sh", "-c", `printf '%s\000' `+strings.Join(args[2:], " ")).Output()
+	d.PanicIfError(err)
+	os.Args = strings.Split(strings.TrimSuffix(string(out), "\000"), "\000")
+	main()
+	os.Exit(0)
+}
+
+func TestNomsServe(t *testing.T) {
+	d.UtilExiter = testExiter{}
+	suite.Run(t, &nomsServeTestSuite{})
+}
+
+type nomsServeTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsServeTestSuite) SetupTest() {
+	os.Setenv("NOMS_SSH_COMMAND", "env "+sshHelperEnv+"=1 "+os.Args[0]+" -test.run=^TestSSHHelperProcess$ --")
+}
+
+func (s *nomsServeTestSuite) TearDownTest() {
+	os.Unsetenv("NOMS_SSH_COMMAND")
+}
+
+func (s *nomsServeTestSuite) TestSyncOverSSH() {
+	source := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "src")
+	source, err := source.CommitValue(types.Number(42))
+	s.NoError(err)
+	source.Database().Close()
+	remote := "ssh://localhost" + s.LdbDir
+
+	out, _ := s.Run(main, []string{"show", remote + "::src.value"})
+	s.Equal("42\n", out)
+
+	ldb2dir := path.Join(s.TempDir, "ldb2")
+	s.Run(main, []string{"sync", remote + "::src", spec.CreateValueSpecString("ldb", ldb2dir, "dest")})
+	dest := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(ldb2dir, "", 1, false)), "dest")
+	s.True(types.Number(42).Equals(dest.HeadValue()))
+	dest, err = dest.CommitValue(types.Number(43))
+	s.NoError(err)
+	dest.Database().Close()
+
+	s.Run(main, []string{"sync", spec.CreateValueSpecString("ldb", ldb2dir, "dest"), remote + "::src"})
+	source = dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "src")
+	defer source.Database().Close()
+	s.True(types.Number(43).Equals(source.HeadValue()))
+}
+
+func (s *nomsServeTestSuite) TestSSHPathWithSpace() {
+	dir := path.Join(s.TempDir, "my db")
+	source := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(dir, "", 1, false)), "src")
+	source, err := source.CommitValue(types.Number(42))
+	s.NoError(err)
+	source.Database().Close()
+
+	out, _ := s.Run(main, []string{"show", "ssh://localhost" + dir + "::src.value"})
+	s.Equal("42\n", out)
+}
+
+func (s *nomsServeTestSuite) TestSSHFailure() {
+	// A file can't be opened as a database, so the server fails to start.
+	notADir := path.Join(s.TempDir, "file")
+	s.NoError(ioutil.WriteFile(notADir, []byte("not a database"), 0644))
+	s.Panics(func() {
+		s.Run(main, []string{"show", "ssh://localhost" + notADir + "::src"})
+	})
+}
+
+func (s *nomsServeTestSuite) TestServeFlagValidation() {
+	for _, args := range [][]string{
+		{"--cert", "server.crt"},
+		{"--client-ca", "ca.crt"},
+		{"--socket", "noms.sock", "--cert", "server.crt", "--key", "server.key"},
+		{"--stdio", "--socket", "noms.sock"},
+		{"--cache", "mem"},
+		{"--read-only"},
+		{"--upstream", "http://localhost:8000", "--cache", "mem"},
+	} {
+		s.Panics(func() {
+			s.Run(main, append(append([]string{"serve"}, args...), spec.CreateDatabaseSpecString("ldb", s.LdbDir)))
+		}, "%v", args)
+	}
+
+	// Replicas are given their cache instead of a database, and an upstream that's served over http.
+	for _, args := range [][]string{
+		{},
+		{"--upstream", "http://localhost:8000"},
+		{"--upstream", spec.CreateDatabaseSpecString("ldb", s.LdbDir), "--cache", "mem"},
+	} {
+		s.Panics(func() {
+			s.Run(main, append([]string{"serve"}, args...))
+		}, "%v", args)
+	}
+}
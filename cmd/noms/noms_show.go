@@ -20,7 +20,7 @@ var nomsShow = &util.Command{
 	Run:       runShow,
 	UsageLine: "show <object>",
 	Short:     "Shows a serialization of a Noms object",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object argument.",
+	Long:      "If the path in <object> addresses several values, e.g. because it contains a slice or a wildcard, each of them is shown.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object argument.",
 	Flags:     setupShowFlags,
 	Nargs:     1,
 }
@@ -32,11 +32,11 @@ func setupShowFlags() *flag.FlagSet {
 }
 
 func runShow(args []string) int {
-	database, value, err := spec.GetPath(args[0])
+	database, values, err := spec.GetPathValues(args[0])
 	d.CheckErrorNoUsage(err)
 	defer database.Close()
 
-	if value == nil {
+	if len(values) == 0 {
 		fmt.Fprintf(os.Stderr, "Object not found: %s\n", args[0])
 		return 0
 	}
@@ -44,7 +44,9 @@ func runShow(args []string) int {
 	pgr := outputpager.Start()
 	defer pgr.Stop()
 
-	types.WriteEncodedValueWithTags(pgr.Writer, value)
-	fmt.Fprintln(pgr.Writer)
+	for _, value := range values {
+		types.WriteEncodedValueWithTags(pgr.Writer, value)
+		fmt.Fprintln(pgr.Writer)
+	}
 	return 0
 }
@@ -66,6 +66,9 @@ func (s *nomsShowTestSuite) TestNomsShow() {
 	res, _ = s.Run(main, []string{"show", str1})
 	s.Equal(res4, res)
 
+	res, _ = s.Run(main, []string{"show", str + ".value@target[1:]"})
+	s.Equal("2\n\"elem3\"\n", res)
+
 	_ = writeTestData(str, s1)
 	res, _ = s.Run(main, []string{"show", str})
 	test.EqualsIgnoreHashes(s.T(), res5, res)
@@ -7,11 +7,14 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/attic-labs/noms/cmd/util"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/profile"
@@ -21,14 +24,16 @@ import (
 )
 
 var (
-	p int
+	p                 int
+	syncDepth         int
+	syncCheckpointDir string
 )
 
 var nomsSync = &util.Command{
 	Run:       runSync,
 	UsageLine: "sync [options] <source-object> <dest-dataset>",
 	Short:     "Moves datasets between or within databases",
-	Long:      "See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object and dataset arguments.",
+	Long:      "Progress is saved under --checkpoint-dir as the sync goes, so that if it's interrupted, running it again with the same arguments carries on from where it stopped. The saved progress is removed once the sync is done. Unless --checkpoint-dir is given, progress is saved only for syncs from or to a remote database (http, https, unix or ssh), in a directory under the user cache directory, such as ~/.cache/noms/sync on Linux.\n\nWith --depth, only the most recent <depth> commits of the history of <source-object> are synced, and their missing parents are recorded in the destination database, so that commands such as log stop there. Syncing again with a greater depth, or with none, fills in more of the history.\n\nSee Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the object and dataset arguments.",
 	Flags:     setupSyncFlags,
 	Nargs:     2,
 }
@@ -36,6 +41,8 @@ var nomsSync = &util.Command{
 func setupSyncFlags() *flag.FlagSet {
 	syncFlagSet := flag.NewFlagSet("sync", flag.ExitOnError)
 	syncFlagSet.IntVar(&p, "p", 512, "parallelism")
+	syncFlagSet.IntVar(&syncDepth, "depth", 0, "number of commits of history to sync (0 for all)")
+	syncFlagSet.StringVar(&syncCheckpointDir, "checkpoint-dir", defaultCheckpointDir(), "directory to save the progress of syncs in, so that interrupted ones can be resumed; by default, only syncs from or to a remote database are saved (none if empty)")
 	spec.RegisterDatabaseFlags(syncFlagSet)
 	profile.RegisterProfileFlags(syncFlagSet)
 	return syncFlagSet
@@ -55,6 +62,12 @@ func runSync(args []string) int {
 	d.CheckError(err)
 	defer sinkDataset.Database().Close()
 
+	// Each pair of source and destination has its own checkpoint, so that syncs of different datasets don't disturb each other. Nothing is saved to it unless there's something to pull. Local syncs are quick to redo, so they're only checkpointed if asked to.
+	var cp *datas.PullCheckpoint
+	if syncCheckpointDir != "" && (syncCheckpointDir != defaultCheckpointDir() || isRemote(sourceStore) || isRemote(sinkDataset.Database())) {
+		cp = datas.NewPullCheckpoint(filepath.Join(syncCheckpointDir, hash.FromData([]byte(args[0]+"\n"+args[1])).String()))
+	}
+
 	start := time.Now()
 	progressCh := make(chan datas.PullProgress)
 	lastProgressCh := make(chan datas.PullProgress)
@@ -83,7 +96,7 @@ func runSync(args []string) int {
 	nonFF := false
 	err = d.Try(func() {
 		defer profile.MaybeStartProfile().Stop()
-		sinkDataset.Pull(sourceStore, sourceRef, p, progressCh)
+		sinkDataset.ResumablePull(sourceStore, sourceRef, syncDepth, p, progressCh, cp)
 
 		var err error
 		sinkDataset, err = sinkDataset.FastForward(sourceRef)
@@ -97,6 +110,9 @@ func runSync(args []string) int {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if cp != nil {
+		d.CheckErrorNoUsage(cp.Remove())
+	}
 
 	close(progressCh)
 	if last := <-lastProgressCh; last.DoneCount > 0 {
@@ -107,10 +123,29 @@ func runSync(args []string) int {
 	} else {
 		fmt.Println(args[1], "is up to date.")
 	}
+	if cp != nil && cp.Resumed().DoneCount > 0 {
+		resumed := cp.Resumed()
+		fmt.Printf("Resumed an interrupted sync, skipping %d chunks (%s) already synced\n", resumed.DoneCount, humanize.Bytes(resumed.DoneBytes))
+	}
 
 	return 0
 }
 
+// defaultCheckpointDir returns the directory in the user's cache directory that syncs save their progress in, or "" if there isn't one.
+func defaultCheckpointDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "noms", "sync")
+}
+
+// isRemote returns whether db is served by another process, over HTTP, a socket or ssh.
+func isRemote(db datas.Database) bool {
+	_, ok := db.(*datas.RemoteDatabaseClient)
+	return ok
+}
+
 func bytesPerSec(prog datas.PullProgress, start time.Time) string {
 	bps := float64(prog.DoneBytes) / float64(time.Since(start).Seconds())
 	return humanize.Bytes(uint64(bps))
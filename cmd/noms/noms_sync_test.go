@@ -5,6 +5,7 @@
 package main
 
 import (
+	"os"
 	"path"
 	"testing"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/noms/go/util/clienttest"
@@ -90,3 +92,61 @@ func (s *nomsSyncTestSuite) TestRewind() {
 	s.True(types.Number(42).Equals(dest.HeadValue()))
 	dest.Database().Close()
 }
+
+func (s *nomsSyncTestSuite) TestSyncDepth() {
+	source := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "foo")
+	commits := []types.Ref{}
+	for i := 0; i < 3; i++ {
+		var err error
+		source, err = source.CommitValue(types.Number(i))
+		s.NoError(err)
+		commits = append(commits, source.HeadRef())
+	}
+	source.Database().Close()
+
+	sourceSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "foo")
+	ldb2dir := path.Join(s.TempDir, "ldb2")
+	sinkDatasetSpec := spec.CreateValueSpecString("ldb", ldb2dir, "bar")
+	missing := func() hash.HashSet {
+		db := datas.NewDatabase(chunks.NewLevelDBStore(ldb2dir, "", 1, false))
+		defer db.Close()
+		missing, err := db.MissingParents()
+		s.NoError(err)
+		return missing
+	}
+
+	s.Run(main, []string{"sync", "--depth", "1", sourceSpec, sinkDatasetSpec})
+	s.Equal(hash.HashSet{commits[1].TargetHash(): struct{}{}}, missing())
+	out, _ := s.Run(main, []string{"log", sinkDatasetSpec})
+	s.Contains(out, commits[2].TargetHash().String())
+	s.Contains(out, commits[1].TargetHash().String()+" (not pulled)")
+	s.NotContains(out, commits[0].TargetHash().String())
+
+	s.Run(main, []string{"sync", "--depth", "2", sourceSpec, sinkDatasetSpec})
+	s.Equal(hash.HashSet{commits[0].TargetHash(): struct{}{}}, missing())
+
+	s.Run(main, []string{"sync", sourceSpec, sinkDatasetSpec})
+	s.Empty(missing())
+	out, _ = s.Run(main, []string{"log", sinkDatasetSpec})
+	s.Contains(out, commits[0].TargetHash().String())
+	s.NotContains(out, "not pulled")
+}
+
+func (s *nomsSyncTestSuite) TestSyncRemovesCheckpoint() {
+	source := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "foo")
+	source, err := source.CommitValue(types.Number(42))
+	s.NoError(err)
+	source.Database().Close()
+
+	sourceSpec := spec.CreateValueSpecString("ldb", s.LdbDir, "foo")
+	sinkDatasetSpec := spec.CreateValueSpecString("ldb", path.Join(s.TempDir, "ldb2"), "bar")
+	cpDir := path.Join(s.TempDir, "checkpoints")
+	s.Run(main, []string{"sync", "--checkpoint-dir", cpDir, sourceSpec, sinkDatasetSpec})
+
+	// The sync was done before it was due to save its progress, so it didn't leave anything on disk, not even the checkpoint directory. Nor does one with nothing to pull.
+	_, err = os.Stat(cpDir)
+	s.True(os.IsNotExist(err))
+	s.Run(main, []string{"sync", "--checkpoint-dir", cpDir, sourceSpec, sinkDatasetSpec})
+	_, err = os.Stat(cpDir)
+	s.True(os.IsNotExist(err))
+}
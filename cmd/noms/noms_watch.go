@@ -0,0 +1,66 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/attic-labs/noms/cmd/noms/diff"
+	"github.com/attic-labs/noms/cmd/util"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	flag "github.com/tsuru/gnuflag"
+)
+
+var (
+	watchDiff  bool
+	watchCount int
+)
+
+var nomsWatch = &util.Command{
+	Run:       runWatch,
+	UsageLine: "watch [options] <dataset>",
+	Short:     "Prints the head of a dataset each time it changes",
+	Long:      "Prints the hash of the head commit of the dataset, if it has one, and then that of each new head as the dataset is committed to, until interrupted. Commits are noticed whether they're made in this process or another, and over http or a unix socket they're noticed as soon as the server sees them. See Spelling Objects at https://github.com/attic-labs/noms/blob/master/doc/spelling.md for details on the dataset argument.",
+	Flags:     setupWatchFlags,
+	Nargs:     1,
+}
+
+func setupWatchFlags() *flag.FlagSet {
+	watchFlagSet := flag.NewFlagSet("watch", flag.ExitOnError)
+	watchFlagSet.BoolVar(&watchDiff, "diff", false, "after each new head, print the difference between its value and that of the previous head")
+	watchFlagSet.IntVar(&watchCount, "n", 0, "exit after printing this many heads (0 to keep watching)")
+	return watchFlagSet
+}
+
+func runWatch(args []string) int {
+	ds, err := spec.GetDataset(args[0])
+	d.CheckErrorNoUsage(err)
+	db := ds.Database()
+	defer db.Close()
+
+	var last types.Value
+	heads := db.Watch(ds.ID())
+	for i := 0; watchCount == 0 || i < watchCount; i++ {
+		r, ok := <-heads
+		if !ok {
+			d.CheckErrorNoUsage(fmt.Errorf("Lost connection to %s", args[0]))
+		}
+		fmt.Println(r.TargetHash().String())
+
+		if !watchDiff {
+			continue
+		}
+		value := r.TargetValue(db).(types.Struct).Get(datas.ValueField)
+		if last != nil {
+			d.PanicIfError(diff.Diff(os.Stdout, last, value))
+		}
+		last = value
+	}
+	return 0
+}
@@ -0,0 +1,79 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/spec"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/clienttest"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestNomsWatch(t *testing.T) {
+	suite.Run(t, &nomsWatchTestSuite{})
+}
+
+type nomsWatchTestSuite struct {
+	clienttest.ClientTestSuite
+}
+
+func (s *nomsWatchTestSuite) TestWatchPrintsHead() {
+	ds := dataset.NewDataset(datas.NewDatabase(chunks.NewLevelDBStore(s.LdbDir, "", 1, false)), "ds")
+	ds, err := ds.CommitValue(types.Number(42))
+	s.NoError(err)
+	head := ds.HeadRef().TargetHash()
+	ds.Database().Close()
+
+	out, _ := s.Run(main, []string{"watch", "-n", "1", spec.CreateValueSpecString("ldb", s.LdbDir, "ds")})
+	s.Equal(head.String()+"\n", out)
+}
+
+func (s *nomsWatchTestSuite) TestWatchDiff() {
+	server := datas.NewRemoteDatabaseServer(chunks.NewMemoryStore(), 0)
+	ready := make(chan struct{})
+	server.Ready = func() { close(ready) }
+	go server.Run()
+	defer server.Stop()
+	<-ready
+	url := fmt.Sprintf("http://localhost:%d", server.Port())
+
+	// Keep committing until the watch has seen two heads, since there's no telling when it starts.
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ds := dataset.NewDataset(datas.NewRemoteDatabase(url, ""), "ds")
+		defer ds.Database().Close()
+		for i := 0; ; i++ {
+			var err error
+			ds, err = ds.CommitValue(types.NewMap(types.String("n"), types.Number(i)))
+			s.NoError(err)
+			select {
+			case <-done:
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+		}
+	}()
+	out, _ := s.Run(main, []string{"watch", "--diff", "-n", "2", url + "::ds"})
+	close(done)
+	<-stopped
+
+	lines := strings.Split(out, "\n")
+	_, ok := hash.MaybeParse(lines[0])
+	s.True(ok, out)
+	_, ok = hash.MaybeParse(lines[1])
+	s.True(ok, out)
+	s.Contains(out, `-   "n": `)
+	s.Contains(out, `+   "n": `)
+}
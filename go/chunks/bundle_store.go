@@ -0,0 +1,199 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// A bundle is a single file holding a snapshot of a database: a table (see table.go) of its chunks, followed by a trailer recording its root and version. The trailer is laid out as:
+//
+//	version    the noms version the bundle was written with
+//	root       the digest of the root chunk (20 bytes)
+//	length     the length of the version (uint32)
+//	magic      bundleMagic
+//
+// Like a table file's, the trailer is at the end of the file, so a bundle can be written in one pass.
+const (
+	bundleMagic       = "NOMSBDL1"
+	bundleTrailerSize = hash.ByteLen + 4 + len(bundleMagic) // Not counting the version.
+)
+
+var ErrBundleReadOnly = errors.New("Bundles are read-only")
+
+// BundleStore is a read-only ChunkStore backed by a bundle file. Its index and bloom filter are held in memory, and chunk data is read from the file as needed.
+type BundleStore struct {
+	path    string
+	t       *table
+	root    hash.Hash
+	version string
+}
+
+// NewBundleStore opens the bundle at path.
+func NewBundleStore(path string) *BundleStore {
+	f, err := os.Open(path)
+	d.PanicIfError(err)
+	bs, err := readBundle(f, path)
+	if err != nil {
+		f.Close()
+	}
+	d.PanicIfError(err)
+	return bs
+}
+
+func readBundle(f *os.File, path string) (*BundleStore, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := fi.Size()
+	if size < int64(bundleTrailerSize) {
+		return nil, fmt.Errorf("%s is not a bundle", path)
+	}
+	trailer := make([]byte, bundleTrailerSize)
+	if _, err = f.ReadAt(trailer, size-int64(bundleTrailerSize)); err != nil {
+		return nil, err
+	}
+	if string(trailer[hash.ByteLen+4:]) != bundleMagic {
+		return nil, fmt.Errorf("%s is not a bundle", path)
+	}
+	versionLen := int64(binary.BigEndian.Uint32(trailer[hash.ByteLen:]))
+	tableSize := size - int64(bundleTrailerSize) - versionLen
+	if tableSize < 0 {
+		return nil, fmt.Errorf("Bundle %s is corrupt", path)
+	}
+	version := make([]byte, versionLen)
+	if _, err = f.ReadAt(version, tableSize); err != nil {
+		return nil, err
+	}
+
+	t, err := readTable(f, tableSize, path)
+	if err != nil {
+		return nil, err
+	}
+	var digest hash.Digest
+	copy(digest[:], trailer)
+	return &BundleStore{path, t, hash.New(digest), string(version)}, nil
+}
+
+func (bs *BundleStore) Get(h hash.Hash) Chunk {
+	c, _ := bs.t.get(h)
+	return c
+}
+
+func (bs *BundleStore) Has(h hash.Hash) bool {
+	return bs.t.has(h)
+}
+
+func (bs *BundleStore) Version() string {
+	return bs.version
+}
+
+func (bs *BundleStore) Root() hash.Hash {
+	return bs.root
+}
+
+func (bs *BundleStore) Put(c Chunk) {
+	d.PanicIfError(ErrBundleReadOnly)
+}
+
+func (bs *BundleStore) PutMany(chunks []Chunk) BackpressureError {
+	// Databases flush their pending writes when they're closed, even if there are none.
+	if len(chunks) > 0 {
+		d.PanicIfError(ErrBundleReadOnly)
+	}
+	return nil
+}
+
+func (bs *BundleStore) UpdateRoot(current, last hash.Hash) bool {
+	d.PanicIfError(ErrBundleReadOnly)
+	return false
+}
+
+func (bs *BundleStore) Close() error {
+	return bs.t.close()
+}
+
+// BundleWriter writes a new bundle. Chunks can be added in any order, and concurrently. The bundle is written to a temporary file, which is only moved into place by Finish().
+type BundleWriter struct {
+	path  string
+	f     *os.File
+	mu    sync.Mutex
+	tw    *tableWriter
+	added map[hash.Hash]bool
+}
+
+// NewBundleWriter starts writing a bundle to path.
+func NewBundleWriter(path string) (*BundleWriter, error) {
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+"-")
+	if err != nil {
+		return nil, err
+	}
+	return &BundleWriter{path: path, f: f, tw: newTableWriter(f), added: map[hash.Hash]bool{}}, nil
+}
+
+// Add adds c to the bundle, unless it has already been added.
+func (bw *BundleWriter) Add(c Chunk) error {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	if bw.added[c.Hash()] {
+		return nil
+	}
+	bw.added[c.Hash()] = true
+	return bw.tw.add(c.Hash(), SnappyCodec.Encode(c.Data()))
+}
+
+// Count returns the number of chunks added so far.
+func (bw *BundleWriter) Count() int {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	return len(bw.added)
+}
+
+// Finish writes the index and trailer of the bundle, with root as its root, and moves the bundle into place.
+func (bw *BundleWriter) Finish(root hash.Hash) (err error) {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+	defer func() {
+		if err != nil {
+			bw.f.Close()
+			os.Remove(bw.f.Name())
+		}
+	}()
+
+	if _, err = bw.tw.finish(); err != nil {
+		return
+	}
+	trailer := append([]byte(constants.NomsVersion), root.DigestSlice()...)
+	trailer = append(trailer, make([]byte, 4)...)
+	binary.BigEndian.PutUint32(trailer[len(trailer)-4:], uint32(len(constants.NomsVersion)))
+	trailer = append(trailer, bundleMagic...)
+	if _, err = bw.f.Write(trailer); err != nil {
+		return
+	}
+	if err = bw.f.Sync(); err != nil {
+		return
+	}
+	if err = bw.f.Close(); err != nil {
+		return
+	}
+	return os.Rename(bw.f.Name(), bw.path)
+}
+
+// Abort discards the bundle.
+func (bw *BundleWriter) Abort() {
+	bw.f.Close()
+	os.Remove(bw.f.Name())
+}
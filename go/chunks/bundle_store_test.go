@@ -0,0 +1,90 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+)
+
+func writeTestBundle(assert *assert.Assertions, path string, root hash.Hash, chunks ...Chunk) {
+	bw, err := NewBundleWriter(path)
+	assert.NoError(err)
+	for _, c := range chunks {
+		assert.NoError(bw.Add(c))
+	}
+	assert.NoError(bw.Finish(root))
+}
+
+func TestBundleStore(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.nbundle")
+
+	c1, c2, c3 := NewChunk([]byte("abc")), NewChunk([]byte("def")), NewChunk([]byte("ghi"))
+	writeTestBundle(assert, path, c2.Hash(), c3, c1, c2, c1)
+
+	bs := NewBundleStore(path)
+	defer bs.Close()
+	assert.Equal(c2.Hash(), bs.Root())
+	assert.Equal(constants.NomsVersion, bs.Version())
+	assertInputInStore("abc", c1.Hash(), bs, assert)
+	assertInputInStore("def", c2.Hash(), bs, assert)
+	assertInputInStore("ghi", c3.Hash(), bs, assert)
+	missing := NewChunk([]byte("missing")).Hash()
+	assert.False(bs.Has(missing))
+	assert.True(bs.Get(missing).IsEmpty())
+
+	assert.Panics(func() {
+		bs.Put(NewChunk([]byte("jkl")))
+	})
+	assert.Panics(func() {
+		bs.UpdateRoot(c1.Hash(), bs.Root())
+	})
+
+	// Only the finished bundle is left behind.
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(err)
+	assert.Len(files, 1)
+}
+
+func TestBundleStoreEmpty(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "empty.nbundle")
+
+	writeTestBundle(assert, path, hash.Hash{})
+	bs := NewBundleStore(path)
+	defer bs.Close()
+	assert.True(bs.Root().IsEmpty())
+}
+
+func TestBundleStoreNotABundle(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "table")
+	name, err := writeTable(dir, []Chunk{NewChunk([]byte("abc"))})
+	assert.NoError(err)
+	assert.NoError(os.Rename(filepath.Join(dir, name), path))
+	assert.Panics(func() {
+		NewBundleStore(path)
+	})
+	assert.Panics(func() {
+		NewBundleStore(filepath.Join(dir, "missing"))
+	})
+}
@@ -0,0 +1,118 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/golang/snappy"
+)
+
+// ChunkCodec compresses the data of chunks for storage.
+type ChunkCodec interface {
+	// Name is how the codec is chosen on the command line, e.g. "snappy".
+	Name() string
+
+	// ID identifies the codec in the header of each value it encodes, so it must never change once data has been written with it.
+	ID() byte
+
+	Encode(data []byte) []byte
+	Decode(encoded []byte) ([]byte, error)
+}
+
+var (
+	NoneCodec   ChunkCodec = noneCodec{}
+	SnappyCodec ChunkCodec = snappyCodec{}
+
+	codecsByID   = map[byte]ChunkCodec{}
+	codecsByName = map[string]ChunkCodec{}
+)
+
+func init() {
+	RegisterChunkCodec(NoneCodec)
+	RegisterChunkCodec(SnappyCodec)
+}
+
+// RegisterChunkCodec makes c available for reading values written with it and to be chosen by name. It panics if another codec already uses its ID or name.
+func RegisterChunkCodec(c ChunkCodec) {
+	d.PanicIfTrue(codecsByID[c.ID()] != nil, "Codec ID %d is already registered", c.ID())
+	d.PanicIfTrue(codecsByName[c.Name()] != nil, "Codec %s is already registered", c.Name())
+	codecsByID[c.ID()] = c
+	codecsByName[c.Name()] = c
+}
+
+// ChunkCodecByName returns the registered codec with the given name.
+func ChunkCodecByName(name string) (ChunkCodec, error) {
+	if c, ok := codecsByName[name]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("Unknown codec %s, expected one of %v", name, chunkCodecNames())
+}
+
+func chunkCodecNames() []string {
+	names := []string{}
+	for name := range codecsByName {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Stored chunk values start with a header: chunkValueMarker followed by the ID of the codec the rest of the value is encoded with. Values written before codecs existed have no header and are snappy-encoded. The marker tells the two apart, since a snappy-encoded value starts with the varint length of the data, which is only zero for an empty chunk, and that is encoded as the single byte 0.
+const (
+	chunkValueMarker     = 0
+	chunkValueHeaderSize = 2
+)
+
+// encodeChunkValue returns the value to store for data, encoded with codec.
+func encodeChunkValue(codec ChunkCodec, data []byte) []byte {
+	return append([]byte{chunkValueMarker, codec.ID()}, codec.Encode(data)...)
+}
+
+// decodeChunkValue returns the data stored in value, and the codec it was encoded with.
+func decodeChunkValue(value []byte) ([]byte, ChunkCodec, error) {
+	if !hasChunkValueHeader(value) {
+		data, err := snappy.Decode(nil, value)
+		return data, SnappyCodec, err
+	}
+	codec, ok := codecsByID[value[1]]
+	if !ok {
+		return nil, nil, fmt.Errorf("Chunk encoded with unknown codec %d", value[1])
+	}
+	data, err := codec.Decode(value[chunkValueHeaderSize:])
+	return data, codec, err
+}
+
+func hasChunkValueHeader(value []byte) bool {
+	return len(value) >= chunkValueHeaderSize && value[0] == chunkValueMarker
+}
+
+type noneCodec struct{}
+
+func (noneCodec) Name() string { return "none" }
+func (noneCodec) ID() byte     { return 0 }
+
+func (noneCodec) Encode(data []byte) []byte {
+	return data
+}
+
+func (noneCodec) Decode(encoded []byte) ([]byte, error) {
+	return encoded, nil
+}
+
+type snappyCodec struct{}
+
+func (snappyCodec) Name() string { return "snappy" }
+func (snappyCodec) ID() byte     { return 1 }
+
+func (snappyCodec) Encode(data []byte) []byte {
+	return snappy.Encode(nil, data)
+}
+
+func (snappyCodec) Decode(encoded []byte) ([]byte, error) {
+	return snappy.Decode(nil, encoded)
+}
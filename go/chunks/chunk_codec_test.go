@@ -0,0 +1,55 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+	"github.com/golang/snappy"
+)
+
+func TestChunkValueRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	for _, codec := range []ChunkCodec{NoneCodec, SnappyCodec} {
+		for _, data := range [][]byte{{}, {0}, []byte("abcabcabcabc")} {
+			value := encodeChunkValue(codec, data)
+			assert.True(hasChunkValueHeader(value))
+			decoded, c, err := decodeChunkValue(value)
+			assert.NoError(err)
+			assert.Equal(codec, c)
+			assert.Equal(data, append([]byte{}, decoded...))
+		}
+	}
+}
+
+func TestChunkValueLegacy(t *testing.T) {
+	assert := assert.New(t)
+	for _, data := range [][]byte{{}, {0}, []byte("abcabcabcabc")} {
+		value := snappy.Encode(nil, data)
+		assert.False(hasChunkValueHeader(value))
+		decoded, c, err := decodeChunkValue(value)
+		assert.NoError(err)
+		assert.Equal(SnappyCodec, c)
+		assert.Equal(data, append([]byte{}, decoded...))
+	}
+}
+
+func TestChunkValueUnknownCodec(t *testing.T) {
+	_, _, err := decodeChunkValue([]byte{chunkValueMarker, 200, 1, 2, 3})
+	assert.Error(t, err)
+}
+
+func TestChunkCodecByName(t *testing.T) {
+	assert := assert.New(t)
+	c, err := ChunkCodecByName("snappy")
+	assert.NoError(err)
+	assert.Equal(SnappyCodec, c)
+	_, err = ChunkCodecByName("bogus")
+	assert.Error(err)
+	assert.Panics(func() {
+		RegisterChunkCodec(noneCodec{})
+	})
+}
@@ -55,6 +55,30 @@ type ChunkSink interface {
 	io.Closer
 }
 
+// GarbageCollector is implemented by ChunkStores that can delete chunks which are no longer reachable. A collection is bracketed by BeginGC() and Sweep(): any chunk Put in between is never swept, so writers may keep working while the caller determines which chunks are reachable. Implementations may spare chunks Put shortly before BeginGC() too, since writers usually Put the chunks of their values before the commit that makes them reachable.
+type GarbageCollector interface {
+	// BeginGC starts recording the hashes of chunks that are Put, so that Sweep() can spare them.
+	BeginGC()
+
+	// Sweep deletes every chunk that is neither in keep nor was Put since BeginGC() was called, and ends the collection. It returns the number of chunks deleted and the number of stored bytes they occupied.
+	Sweep(keep hash.HashSet) (count, bytes uint64)
+
+	// AbortGC ends the collection begun by BeginGC() without deleting anything, e.g. because determining which chunks are reachable failed. It does nothing if no collection is in progress.
+	AbortGC()
+}
+
+// Recompressor is implemented by ChunkStores that can re-encode the chunks they hold with a different ChunkCodec.
+type Recompressor interface {
+	// Recompress re-encodes every chunk that isn't already encoded with codec.
+	Recompress(codec ChunkCodec) RecompressStats
+}
+
+// RecompressStats describes the work done by Recompress(). The byte counts are the sizes of the stored chunk values, not including any overhead of the store.
+type RecompressStats struct {
+	Chunks, Recompressed    uint64
+	BytesBefore, BytesAfter uint64
+}
+
 // BackpressureError is a slice of hash.Hash that indicates some chunks could not be Put(). Caller is free to try to Put them again later.
 type BackpressureError hash.HashSlice
 
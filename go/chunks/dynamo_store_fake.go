@@ -6,10 +6,16 @@ package chunks
 
 import (
 	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/testify/assert"
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/private/protocol/json/jsonutil"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
 )
 
@@ -179,3 +185,64 @@ func (m *lowCapFakeDDB) BatchGetItem(input *dynamodb.BatchGetItemInput) (*dynamo
 	}
 	return out, nil
 }
+
+// fakeDDBServer serves the DynamoDB API over HTTP from a fakeDDB, so that code which only takes database specs can be tested against a DynamoStore.
+type fakeDDBServer struct {
+	mu  sync.Mutex
+	ddb *fakeDDB
+}
+
+// NewFakeDynamoServer returns an http.Handler serving the subset of the DynamoDB JSON API used by DynamoStore from an in-memory fake, for use with httptest.NewServer(). Point a dynamo spec at it with its endpoint parameter.
+func NewFakeDynamoServer(a *assert.Assertions) http.Handler {
+	return &fakeDDBServer{ddb: createFakeDDB(a)}
+}
+
+func (s *fakeDDBServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var out interface{}
+	var err error
+	decode := func(in interface{}) bool {
+		err = jsonutil.UnmarshalJSON(in, r.Body)
+		return err == nil
+	}
+	switch op := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810."); op {
+	case "BatchGetItem":
+		in := &dynamodb.BatchGetItemInput{}
+		if decode(in) {
+			out, err = s.ddb.BatchGetItem(in)
+		}
+	case "BatchWriteItem":
+		in := &dynamodb.BatchWriteItemInput{}
+		if decode(in) {
+			out, err = s.ddb.BatchWriteItem(in)
+		}
+	case "GetItem":
+		in := &dynamodb.GetItemInput{}
+		if decode(in) {
+			out, err = s.ddb.GetItem(in)
+		}
+	case "PutItem":
+		in := &dynamodb.PutItemInput{}
+		if decode(in) {
+			out, err = s.ddb.PutItem(in)
+		}
+	default:
+		err = mockAWSError("UnknownOperationException")
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	if err != nil {
+		code := "SerializationException"
+		if awsErr, ok := err.(awserr.Error); ok {
+			code = awsErr.Code()
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"__type":"com.amazonaws.dynamodb.v20120810#%s","message":%q}`, code, err.Error())
+		return
+	}
+	body, err := jsonutil.BuildJSON(out)
+	s.ddb.assert.NoError(err)
+	w.Write(body)
+}
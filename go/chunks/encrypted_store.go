@@ -0,0 +1,193 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// An encrypted chunk is stored as:
+//
+//	format    1 byte, encryptedChunkFormat
+//	key ID    4 bytes, identifying the key it was encrypted with
+//	nonce     12 bytes
+//	sealed    the chunk data sealed with AES-GCM, with the digest of the chunk's hash as additional data
+//
+// Sealing the hash along with the data means that a chunk can't be passed off as another one without the key.
+const (
+	encryptedChunkFormat     = 1
+	encryptionKeyIDSize      = 4
+	encryptedChunkHeaderSize = 1 + encryptionKeyIDSize
+)
+
+// EncryptedStore is a ChunkStore that encrypts the data of chunks with AES-GCM before passing them on to another ChunkStore. Chunks are still addressed by the hash of their plaintext, and the root isn't encrypted.
+//
+// New chunks are encrypted with the current key of an EncryptionKeys, and chunks encrypted with any of its keys can be read. So to rotate keys, make a new key current while keeping the old one around to read existing chunks. Chunks are only ever encrypted once, so to stop relying on an old key altogether, copy the data into a new database, e.g. with noms sync.
+type EncryptedStore struct {
+	cs   ChunkStore
+	keys *EncryptionKeys
+}
+
+// NewEncryptedStore returns an EncryptedStore that stores its chunks in cs, encrypted with keys.
+func NewEncryptedStore(cs ChunkStore, keys *EncryptionKeys) *EncryptedStore {
+	return &EncryptedStore{cs, keys}
+}
+
+func (s *EncryptedStore) Get(h hash.Hash) Chunk {
+	c := s.cs.Get(h)
+	if c.IsEmpty() {
+		return c
+	}
+	data, err := s.keys.open(h, c.Data())
+	d.Chk.NoError(err)
+	return NewChunkWithHash(h, data)
+}
+
+func (s *EncryptedStore) Has(h hash.Hash) bool {
+	return s.cs.Has(h)
+}
+
+func (s *EncryptedStore) Version() string {
+	return s.cs.Version()
+}
+
+func (s *EncryptedStore) Put(c Chunk) {
+	s.cs.Put(s.encrypt(c))
+}
+
+func (s *EncryptedStore) PutMany(chunks []Chunk) BackpressureError {
+	encrypted := make([]Chunk, len(chunks))
+	for i, c := range chunks {
+		encrypted[i] = s.encrypt(c)
+	}
+	return s.cs.PutMany(encrypted)
+}
+
+func (s *EncryptedStore) encrypt(c Chunk) Chunk {
+	return NewChunkWithHash(c.Hash(), s.keys.seal(c.Hash(), c.Data()))
+}
+
+func (s *EncryptedStore) Root() hash.Hash {
+	return s.cs.Root()
+}
+
+func (s *EncryptedStore) UpdateRoot(current, last hash.Hash) bool {
+	return s.cs.UpdateRoot(current, last)
+}
+
+func (s *EncryptedStore) Close() error {
+	return s.cs.Close()
+}
+
+// EncryptionKeys is a set of AES keys. The first one, the current key, is used to encrypt, and any of them to decrypt.
+type EncryptionKeys struct {
+	keys []encryptionKey
+}
+
+type encryptionKey struct {
+	id   [encryptionKeyIDSize]byte
+	aead cipher.AEAD
+}
+
+// NewEncryptionKeys returns the set of the given AES-128, AES-192 or AES-256 keys, the first of which is the current key.
+func NewEncryptionKeys(keys ...[]byte) (*EncryptionKeys, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("No encryption keys given")
+	}
+	ek := &EncryptionKeys{}
+	ids := map[[encryptionKeyIDSize]byte]bool{}
+	for _, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		// Keys are identified by a prefix of their hash, so that they don't need to be named.
+		k := encryptionKey{aead: aead}
+		sum := sha256.Sum256(key)
+		copy(k.id[:], sum[:])
+		if ids[k.id] {
+			return nil, fmt.Errorf("Duplicate encryption key")
+		}
+		ids[k.id] = true
+		ek.keys = append(ek.keys, k)
+	}
+	return ek, nil
+}
+
+// ParseEncryptionKeys parses base64-encoded keys separated by whitespace or commas. Lines starting with # are ignored. The first key is the current key.
+func ParseEncryptionKeys(text string) (*EncryptionKeys, error) {
+	keys := [][]byte{}
+	for _, line := range strings.Split(text, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, field := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' }) {
+			key, err := base64.StdEncoding.DecodeString(field)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid encryption key, expected base64: %s", err)
+			}
+			keys = append(keys, key)
+		}
+	}
+	return NewEncryptionKeys(keys...)
+}
+
+// ReadEncryptionKeyFile reads keys from path in the format accepted by ParseEncryptionKeys.
+func ReadEncryptionKeyFile(path string) (*EncryptionKeys, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEncryptionKeys(string(data))
+}
+
+func (ek *EncryptionKeys) seal(h hash.Hash, data []byte) []byte {
+	k := ek.keys[0]
+	nonceSize := k.aead.NonceSize()
+	out := make([]byte, encryptedChunkHeaderSize+nonceSize, encryptedChunkHeaderSize+nonceSize+len(data)+k.aead.Overhead())
+	out[0] = encryptedChunkFormat
+	copy(out[1:], k.id[:])
+	nonce := out[encryptedChunkHeaderSize:]
+	_, err := io.ReadFull(rand.Reader, nonce)
+	d.Chk.NoError(err)
+	return k.aead.Seal(out, nonce, data, h.DigestSlice())
+}
+
+func (ek *EncryptionKeys) open(h hash.Hash, sealed []byte) ([]byte, error) {
+	if len(sealed) < encryptedChunkHeaderSize || sealed[0] != encryptedChunkFormat {
+		return nil, fmt.Errorf("Chunk %s is not encrypted", h)
+	}
+	for _, k := range ek.keys {
+		if !bytes.Equal(k.id[:], sealed[1:encryptedChunkHeaderSize]) {
+			continue
+		}
+		rest := sealed[encryptedChunkHeaderSize:]
+		if len(rest) < k.aead.NonceSize() {
+			return nil, fmt.Errorf("Chunk %s is corrupt", h)
+		}
+		data, err := k.aead.Open(nil, rest[:k.aead.NonceSize()], rest[k.aead.NonceSize():], h.DigestSlice())
+		if err != nil {
+			return nil, fmt.Errorf("Chunk %s failed to decrypt: %s", h, err)
+		}
+		return data, nil
+	}
+	return nil, fmt.Errorf("Chunk %s is encrypted with an unknown key", h)
+}
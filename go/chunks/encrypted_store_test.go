@@ -0,0 +1,116 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestEncryptedStoreTestSuite(t *testing.T) {
+	suite.Run(t, &EncryptedStoreTestSuite{})
+}
+
+type EncryptedStoreTestSuite struct {
+	ChunkStoreTestSuite
+	backing *MemoryStore
+}
+
+func testEncryptionKeys(keys ...string) *EncryptionKeys {
+	raw := [][]byte{}
+	for _, k := range keys {
+		raw = append(raw, bytes.Repeat([]byte(k), 32/len(k)))
+	}
+	ek, err := NewEncryptionKeys(raw...)
+	if err != nil {
+		panic(err)
+	}
+	return ek
+}
+
+func (suite *EncryptedStoreTestSuite) SetupTest() {
+	suite.backing = NewMemoryStore()
+	suite.Store = NewEncryptedStore(suite.backing, testEncryptionKeys("a"))
+}
+
+func (suite *EncryptedStoreTestSuite) TearDownTest() {
+	suite.Store.Close()
+}
+
+func (suite *EncryptedStoreTestSuite) TestDataIsEncrypted() {
+	c := NewChunk([]byte("secret data"))
+	suite.Store.Put(c)
+	stored := suite.backing.Get(c.Hash())
+	suite.False(stored.IsEmpty())
+	suite.False(bytes.Contains(stored.Data(), c.Data()))
+
+	// The same chunk is encrypted with a different nonce each time.
+	other := NewEncryptedStore(NewMemoryStore(), testEncryptionKeys("a"))
+	other.Put(c)
+	suite.NotEqual(stored.Data(), other.cs.Get(c.Hash()).Data())
+}
+
+func (suite *EncryptedStoreTestSuite) TestKeyRotation() {
+	c1, c2 := NewChunk([]byte("abc")), NewChunk([]byte("def"))
+	suite.Store.Put(c1)
+
+	rotated := NewEncryptedStore(suite.backing, testEncryptionKeys("b", "a"))
+	rotated.Put(c2)
+	assertInputInStore("abc", c1.Hash(), rotated, suite.Assert())
+	assertInputInStore("def", c2.Hash(), rotated, suite.Assert())
+
+	// Without the old key, only the chunk encrypted with the new one can be read.
+	newOnly := NewEncryptedStore(suite.backing, testEncryptionKeys("b"))
+	assertInputInStore("def", c2.Hash(), newOnly, suite.Assert())
+	suite.Panics(func() {
+		newOnly.Get(c1.Hash())
+	})
+}
+
+func (suite *EncryptedStoreTestSuite) TestTampering() {
+	c1, c2 := NewChunk([]byte("abc")), NewChunk([]byte("def"))
+	suite.Store.Put(c1)
+	suite.Store.Put(c2)
+
+	// A chunk can't be swapped for another one...
+	suite.backing.Put(NewChunkWithHash(c1.Hash(), suite.backing.Get(c2.Hash()).Data()))
+	suite.Panics(func() {
+		suite.Store.Get(c1.Hash())
+	})
+
+	// ...or modified.
+	data := append([]byte{}, suite.backing.Get(c2.Hash()).Data()...)
+	data[len(data)-1] ^= 1
+	suite.backing.Put(NewChunkWithHash(c2.Hash(), data))
+	suite.Panics(func() {
+		suite.Store.Get(c2.Hash())
+	})
+}
+
+func TestParseEncryptionKeys(t *testing.T) {
+	assert := assert.New(t)
+	k1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+	k2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, 16))
+
+	ek, err := ParseEncryptionKeys("# current key first\n" + k1 + "\n" + k2 + "\n")
+	assert.NoError(err)
+	assert.Len(ek.keys, 2)
+	ek, err = ParseEncryptionKeys(k1 + "," + k2)
+	assert.NoError(err)
+	assert.Len(ek.keys, 2)
+
+	_, err = ParseEncryptionKeys("")
+	assert.Error(err)
+	_, err = ParseEncryptionKeys("not base64!")
+	assert.Error(err)
+	_, err = ParseEncryptionKeys(base64.StdEncoding.EncodeToString([]byte("too short")))
+	assert.Error(err)
+	_, err = ParseEncryptionKeys(k1 + " " + k1)
+	assert.Error(err)
+}
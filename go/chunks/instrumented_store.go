@@ -0,0 +1,100 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"time"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/util/metrics"
+)
+
+// InstrumentedStore is a ChunkStore that records metrics of the calls made to another ChunkStore: how many calls of each kind there are and how long they take, how many bytes are read and written, and how many Get() and Has() calls find their chunk. The metrics are labelled with a name for the store, so that several stores can share a Registry, e.g. the cache and backing store of a ReadThroughStore, whose hits are then cache hits.
+type InstrumentedStore struct {
+	cs    ChunkStore
+	name  string
+	calls metrics.Counter
+	hits  metrics.Counter
+	bytes metrics.Counter
+	times metrics.Histogram
+}
+
+// NewInstrumentedStore returns an InstrumentedStore that passes calls on to cs, and records them in reg as those of the store called name.
+func NewInstrumentedStore(cs ChunkStore, reg *metrics.Registry, name string) *InstrumentedStore {
+	return &InstrumentedStore{
+		cs,
+		name,
+		reg.Counter("noms_chunkstore_calls_total", "Number of calls to a ChunkStore, by method.", "store", "op"),
+		reg.Counter("noms_chunkstore_hits_total", "Number of Get and Has calls to a ChunkStore that found the chunk.", "store", "op"),
+		reg.Counter("noms_chunkstore_bytes_total", "Number of bytes of chunk data read from (op=get) or written to (op=put) a ChunkStore.", "store", "op"),
+		reg.Histogram("noms_chunkstore_call_duration_seconds", "How long calls to a ChunkStore take, by method.", metrics.DurationBuckets, "store", "op"),
+	}
+}
+
+// record records a call of op that started at start.
+func (s *InstrumentedStore) record(op string, start time.Time) {
+	s.calls.Inc(s.name, op)
+	s.times.ObserveSince(start, s.name, op)
+}
+
+func (s *InstrumentedStore) Get(h hash.Hash) Chunk {
+	defer s.record("get", time.Now())
+	c := s.cs.Get(h)
+	if !c.IsEmpty() {
+		s.hits.Inc(s.name, "get")
+		s.bytes.Add(float64(len(c.Data())), s.name, "get")
+	}
+	return c
+}
+
+func (s *InstrumentedStore) Has(h hash.Hash) bool {
+	defer s.record("has", time.Now())
+	has := s.cs.Has(h)
+	if has {
+		s.hits.Inc(s.name, "has")
+	}
+	return has
+}
+
+func (s *InstrumentedStore) Version() string {
+	return s.cs.Version()
+}
+
+func (s *InstrumentedStore) Put(c Chunk) {
+	defer s.record("put", time.Now())
+	s.cs.Put(c)
+	s.bytes.Add(float64(len(c.Data())), s.name, "put")
+}
+
+func (s *InstrumentedStore) PutMany(chunks []Chunk) BackpressureError {
+	defer s.record("put_many", time.Now())
+	bpe := s.cs.PutMany(chunks)
+	refused := hash.HashSet{}
+	for _, h := range bpe {
+		refused.Insert(h)
+	}
+	n := 0
+	for _, c := range chunks {
+		if !refused.Has(c.Hash()) {
+			n += len(c.Data())
+		}
+	}
+	s.bytes.Add(float64(n), s.name, "put")
+	return bpe
+}
+
+func (s *InstrumentedStore) Root() hash.Hash {
+	defer s.record("root", time.Now())
+	return s.cs.Root()
+}
+
+func (s *InstrumentedStore) UpdateRoot(current, last hash.Hash) bool {
+	defer s.record("update_root", time.Now())
+	return s.cs.UpdateRoot(current, last)
+}
+
+func (s *InstrumentedStore) Close() error {
+	return s.cs.Close()
+}
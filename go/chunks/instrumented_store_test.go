@@ -0,0 +1,60 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/attic-labs/noms/go/util/metrics"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestInstrumentedStoreTestSuite(t *testing.T) {
+	suite.Run(t, &InstrumentedStoreTestSuite{})
+}
+
+type InstrumentedStoreTestSuite struct {
+	ChunkStoreTestSuite
+	reg *metrics.Registry
+}
+
+func (suite *InstrumentedStoreTestSuite) SetupTest() {
+	suite.reg = metrics.NewRegistry()
+	suite.Store = NewInstrumentedStore(NewMemoryStore(), suite.reg, "test")
+}
+
+func (suite *InstrumentedStoreTestSuite) TearDownTest() {
+	suite.Store.Close()
+}
+
+func (suite *InstrumentedStoreTestSuite) TestMetrics() {
+	c1, c2 := NewChunk([]byte("abc")), NewChunk([]byte("defg"))
+	suite.Store.Put(c1)
+	suite.Store.PutMany([]Chunk{c2})
+	suite.Store.Get(c1.Hash())
+	suite.Store.Get(NewChunk([]byte("missing")).Hash())
+	suite.Store.Has(c2.Hash())
+	suite.Store.UpdateRoot(c1.Hash(), suite.Store.Root())
+
+	buf := &bytes.Buffer{}
+	suite.NoError(suite.reg.WriteText(buf))
+	text := buf.String()
+	for _, line := range []string{
+		`noms_chunkstore_calls_total{store="test",op="get"} 2`,
+		`noms_chunkstore_calls_total{store="test",op="has"} 1`,
+		`noms_chunkstore_calls_total{store="test",op="put"} 1`,
+		`noms_chunkstore_calls_total{store="test",op="put_many"} 1`,
+		`noms_chunkstore_calls_total{store="test",op="root"} 1`,
+		`noms_chunkstore_calls_total{store="test",op="update_root"} 1`,
+		`noms_chunkstore_hits_total{store="test",op="get"} 1`,
+		`noms_chunkstore_hits_total{store="test",op="has"} 1`,
+		`noms_chunkstore_bytes_total{store="test",op="get"} 3`,
+		`noms_chunkstore_bytes_total{store="test",op="put"} 7`,
+		`noms_chunkstore_call_duration_seconds_count{store="test",op="get"} 2`,
+	} {
+		suite.Contains(text, line+"\n")
+	}
+}
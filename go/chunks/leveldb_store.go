@@ -8,15 +8,16 @@ import (
 	"fmt"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
-	"github.com/golang/snappy"
 	"github.com/syndtr/goleveldb/leveldb"
 	"github.com/syndtr/goleveldb/leveldb/errors"
 	"github.com/syndtr/goleveldb/leveldb/filter"
 	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
 	flag "github.com/tsuru/gnuflag"
 )
 
@@ -24,15 +25,21 @@ const (
 	rootKeyConst     = "/root"
 	versionKeyConst  = "/vers"
 	chunkPrefixConst = "/chunk/"
+
+	sweepBatchSize      = 1 << 10
+	recompressBatchSize = 1 << 10
+	// gcGracePeriod is how long a chunk Put into a LevelDBStore is spared by garbage collection, so that writers have time to commit what they've written.
+	gcGracePeriod = time.Hour
 )
 
 type LevelDBStoreFlags struct {
 	maxFileHandles int
 	dumpStats      bool
+	codec          ChunkCodec
 }
 
 var (
-	ldbFlags        = LevelDBStoreFlags{24, false}
+	ldbFlags        = LevelDBStoreFlags{24, false, SnappyCodec}
 	flagsRegistered = false
 )
 
@@ -41,15 +48,36 @@ func RegisterLevelDBFlags(flags *flag.FlagSet) {
 		flagsRegistered = true
 		flags.IntVar(&ldbFlags.maxFileHandles, "ldb-max-file-handles", 24, "max number of open file handles")
 		flags.BoolVar(&ldbFlags.dumpStats, "ldb-dump-stats", false, "print get/has/put counts on close")
+		flags.Var(&ChunkCodecFlag{&ldbFlags.codec}, "ldb-compression", fmt.Sprintf("codec to compress chunks written to LevelDB with, one of %v", chunkCodecNames()))
 	}
 }
 
 func NewLevelDBStoreUseFlags(dir, ns string) *LevelDBStore {
-	return newLevelDBStore(newBackingStore(dir, ldbFlags.maxFileHandles, ldbFlags.dumpStats), []byte(ns), true)
+	return newLevelDBStore(newBackingStore(dir, ldbFlags.maxFileHandles, ldbFlags.dumpStats, ldbFlags.codec), []byte(ns), true)
 }
 
 func NewLevelDBStore(dir, ns string, maxFileHandles int, dumpStats bool) *LevelDBStore {
-	return newLevelDBStore(newBackingStore(dir, maxFileHandles, dumpStats), []byte(ns), true)
+	return newLevelDBStore(newBackingStore(dir, maxFileHandles, dumpStats, SnappyCodec), []byte(ns), true)
+}
+
+// ChunkCodecFlag is a flag.Value that sets a ChunkCodec by name.
+type ChunkCodecFlag struct {
+	Codec *ChunkCodec
+}
+
+func (f *ChunkCodecFlag) String() string {
+	if f.Codec == nil || *f.Codec == nil {
+		return ""
+	}
+	return (*f.Codec).Name()
+}
+
+func (f *ChunkCodecFlag) Set(name string) error {
+	c, err := ChunkCodecByName(name)
+	if err == nil {
+		*f.Codec = c
+	}
+	return err
 }
 
 func newLevelDBStore(store *internalLevelDBStore, ns []byte, closeBackingStore bool) *LevelDBStore {
@@ -64,6 +92,7 @@ func newLevelDBStore(store *internalLevelDBStore, ns []byte, closeBackingStore b
 		versionKey:           copyNsAndAppend(versionKeyConst),
 		chunkPrefix:          copyNsAndAppend(chunkPrefixConst),
 		closeBackingStore:    closeBackingStore,
+		gcGrace:              gcGracePeriod,
 	}
 }
 
@@ -74,6 +103,20 @@ type LevelDBStore struct {
 	chunkPrefix       []byte
 	closeBackingStore bool
 	versionSetOnce    sync.Once
+	gcMu              sync.Mutex
+	gcWritten         hash.HashSet // non-nil while a garbage collection is in progress
+	gcGrace           time.Duration
+	// The chunks Put since recentSince, and in the gcGrace before it, which Sweep() spares.
+	recentWrites, olderWrites hash.HashSet
+	recentSince               time.Time
+}
+
+// SetGCGracePeriod sets how long chunks Put into l are spared by garbage collection, which is an hour unless set otherwise. Writers usually Put the chunks of their values before the commit that makes them reachable, so those written within the grace period are kept even though they aren't reachable yet. Only the chunks Put through l itself are known to it.
+func (l *LevelDBStore) SetGCGracePeriod(grace time.Duration) {
+	l.gcMu.Lock()
+	defer l.gcMu.Unlock()
+	l.gcGrace = grace
+	l.recentWrites, l.olderWrites = nil, nil
 }
 
 func (l *LevelDBStore) Root() hash.Hash {
@@ -105,6 +148,9 @@ func (l *LevelDBStore) Version() string {
 func (l *LevelDBStore) Put(c Chunk) {
 	d.Chk.True(l.internalLevelDBStore != nil, "Cannot use LevelDBStore after Close().")
 	l.versionSetOnce.Do(l.setVersIfUnset)
+	l.gcMu.Lock()
+	defer l.gcMu.Unlock()
+	l.recordWrite(c.Hash())
 	l.putByKey(l.toChunkKey(c.Hash()), c)
 }
 
@@ -113,15 +159,143 @@ func (l *LevelDBStore) PutMany(chunks []Chunk) (e BackpressureError) {
 	l.versionSetOnce.Do(l.setVersIfUnset)
 	numBytes := 0
 	b := new(leveldb.Batch)
+	l.gcMu.Lock()
+	defer l.gcMu.Unlock()
 	for _, c := range chunks {
-		data := snappy.Encode(nil, c.Data())
+		data := encodeChunkValue(l.codec, c.Data())
 		numBytes += len(data)
 		b.Put(l.toChunkKey(c.Hash()), data)
+		l.recordWrite(c.Hash())
 	}
 	l.putBatch(b, numBytes)
 	return
 }
 
+// BeginGC starts recording the hashes of chunks Put into l, so that a subsequent Sweep() leaves them in place.
+func (l *LevelDBStore) BeginGC() {
+	d.Chk.True(l.internalLevelDBStore != nil, "Cannot use LevelDBStore after Close().")
+	l.gcMu.Lock()
+	defer l.gcMu.Unlock()
+	d.PanicIfTrue(l.gcWritten != nil, "Garbage collection already in progress")
+	l.gcWritten = hash.HashSet{}
+}
+
+// AbortGC stops recording the hashes of chunks Put into l, without deleting anything.
+func (l *LevelDBStore) AbortGC() {
+	l.gcMu.Lock()
+	defer l.gcMu.Unlock()
+	l.gcWritten = nil
+}
+
+// Sweep deletes all chunks in l that are not in keep, were not Put since BeginGC() and were not Put within the grace period (see SetGCGracePeriod), then compacts the underlying LevelDB so that the space is returned to the filesystem. Deletes are applied in batches, each of which is checked against the chunks written so far while holding off concurrent Puts.
+func (l *LevelDBStore) Sweep(keep hash.HashSet) (count, bytes uint64) {
+	d.Chk.True(l.internalLevelDBStore != nil, "Cannot use LevelDBStore after Close().")
+	l.gcMu.Lock()
+	d.PanicIfTrue(l.gcWritten == nil, "Sweep() called without BeginGC()")
+	l.rotateRecentWrites(time.Now())
+	l.gcMu.Unlock()
+
+	defer func() {
+		l.gcMu.Lock()
+		l.gcWritten = nil
+		l.gcMu.Unlock()
+	}()
+
+	type candidate struct {
+		h    hash.Hash
+		size int
+	}
+	candidates := []candidate{}
+	deleteCandidates := func() {
+		b := new(leveldb.Batch)
+		l.gcMu.Lock()
+		defer l.gcMu.Unlock()
+		for _, c := range candidates {
+			if l.gcWritten.Has(c.h) || l.recentWrites.Has(c.h) || l.olderWrites.Has(c.h) {
+				continue
+			}
+			b.Delete(l.toChunkKey(c.h))
+			count++
+			bytes += uint64(c.size)
+		}
+		d.Chk.NoError(l.db.Write(b, nil))
+		candidates = candidates[:0]
+	}
+
+	chunkRange := util.BytesPrefix(l.chunkPrefix)
+	iter := l.db.NewIterator(chunkRange, nil)
+	for iter.Next() {
+		h := hash.FromSlice(iter.Key()[len(l.chunkPrefix):])
+		if keep.Has(h) {
+			continue
+		}
+		candidates = append(candidates, candidate{h, len(iter.Value())})
+		if len(candidates) == sweepBatchSize {
+			deleteCandidates()
+		}
+	}
+	iter.Release()
+	d.Chk.NoError(iter.Error())
+	deleteCandidates()
+
+	d.Chk.NoError(l.db.CompactRange(*chunkRange))
+	return
+}
+
+// Recompress re-encodes every chunk in l that isn't already encoded with codec, then compacts the underlying LevelDB so that any space saved is returned to the filesystem. Chunks Put into l afterwards are still encoded with the codec l was opened with.
+func (l *LevelDBStore) Recompress(codec ChunkCodec) (stats RecompressStats) {
+	d.Chk.True(l.internalLevelDBStore != nil, "Cannot use LevelDBStore after Close().")
+	b := new(leveldb.Batch)
+	chunkRange := util.BytesPrefix(l.chunkPrefix)
+	iter := l.db.NewIterator(chunkRange, nil)
+	for iter.Next() {
+		value := iter.Value()
+		stats.Chunks++
+		stats.BytesBefore += uint64(len(value))
+		if hasChunkValueHeader(value) && value[1] == codec.ID() {
+			stats.BytesAfter += uint64(len(value))
+			continue
+		}
+		data, _, err := decodeChunkValue(value)
+		d.Chk.NoError(err)
+		encoded := encodeChunkValue(codec, data)
+		stats.Recompressed++
+		stats.BytesAfter += uint64(len(encoded))
+		b.Put(append([]byte{}, iter.Key()...), encoded)
+		if b.Len() == recompressBatchSize {
+			d.Chk.NoError(l.db.Write(b, nil))
+			b.Reset()
+		}
+	}
+	iter.Release()
+	d.Chk.NoError(iter.Error())
+	d.Chk.NoError(l.db.Write(b, nil))
+
+	d.Chk.NoError(l.db.CompactRange(*chunkRange))
+	return
+}
+
+// recordWrite notes that h was written, so that garbage collection spares it. Callers must hold gcMu.
+func (l *LevelDBStore) recordWrite(h hash.Hash) {
+	if l.gcWritten != nil {
+		l.gcWritten.Insert(h)
+	}
+	if l.gcGrace > 0 {
+		l.rotateRecentWrites(time.Now())
+		l.recentWrites.Insert(h)
+	}
+}
+
+// rotateRecentWrites forgets the chunks written before the grace period, keeping those of the last one or two periods. Callers must hold gcMu.
+func (l *LevelDBStore) rotateRecentWrites(now time.Time) {
+	switch age := now.Sub(l.recentSince); {
+	case age >= 2*l.gcGrace:
+		l.recentWrites, l.olderWrites, l.recentSince = hash.HashSet{}, nil, now
+	case age >= l.gcGrace:
+		l.recentWrites, l.olderWrites, l.recentSince = hash.HashSet{}, l.recentWrites, now
+	}
+}
+
 func (l *LevelDBStore) Close() error {
 	if l.closeBackingStore {
 		l.internalLevelDBStore.Close()
@@ -150,9 +324,10 @@ type internalLevelDBStore struct {
 	mu                                     sync.Mutex
 	getCount, hasCount, putCount, putBytes int64
 	dumpStats                              bool
+	codec                                  ChunkCodec
 }
 
-func newBackingStore(dir string, maxFileHandles int, dumpStats bool) *internalLevelDBStore {
+func newBackingStore(dir string, maxFileHandles int, dumpStats bool, codec ChunkCodec) *internalLevelDBStore {
 	d.PanicIfTrue(dir == "", "dir cannot be empty")
 	d.PanicIfError(os.MkdirAll(dir, 0700))
 	db, err := leveldb.OpenFile(dir, &opt.Options{
@@ -165,6 +340,7 @@ func newBackingStore(dir string, maxFileHandles int, dumpStats bool) *internalLe
 	return &internalLevelDBStore{
 		db:        &rateLimitedLevelDB{db, make(chan struct{}, maxFileHandles)},
 		dumpStats: dumpStats,
+		codec:     codec,
 	}
 }
 
@@ -192,13 +368,13 @@ func (l *internalLevelDBStore) updateRootByKey(key []byte, current, last hash.Ha
 }
 
 func (l *internalLevelDBStore) getByKey(key []byte, ref hash.Hash) Chunk {
-	compressed, err := l.db.Get(key, nil)
+	value, err := l.db.Get(key, nil)
 	l.getCount++
 	if err == errors.ErrNotFound {
 		return EmptyChunk
 	}
 	d.Chk.NoError(err)
-	data, err := snappy.Decode(nil, compressed)
+	data, _, err := decodeChunkValue(value)
 	d.Chk.NoError(err)
 	return NewChunkWithHash(ref, data)
 }
@@ -225,7 +401,7 @@ func (l *internalLevelDBStore) setVersByKey(key []byte) {
 }
 
 func (l *internalLevelDBStore) putByKey(key []byte, c Chunk) {
-	data := snappy.Encode(nil, c.Data())
+	data := encodeChunkValue(l.codec, c.Data())
 	err := l.db.Put(key, data, nil)
 	d.Chk.NoError(err)
 	l.putCount++
@@ -252,11 +428,11 @@ func (l *internalLevelDBStore) Close() error {
 }
 
 func NewLevelDBStoreFactory(dir string, maxHandles int, dumpStats bool) Factory {
-	return &LevelDBStoreFactory{dir, maxHandles, dumpStats, newBackingStore(dir, maxHandles, dumpStats)}
+	return &LevelDBStoreFactory{dir, maxHandles, dumpStats, newBackingStore(dir, maxHandles, dumpStats, SnappyCodec)}
 }
 
 func NewLevelDBStoreFactoryUseFlags(dir string) Factory {
-	return NewLevelDBStoreFactory(dir, ldbFlags.maxFileHandles, ldbFlags.dumpStats)
+	return &LevelDBStoreFactory{dir, ldbFlags.maxFileHandles, ldbFlags.dumpStats, newBackingStore(dir, ldbFlags.maxFileHandles, ldbFlags.dumpStats, ldbFlags.codec)}
 }
 
 type LevelDBStoreFactory struct {
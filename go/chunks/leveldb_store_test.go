@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/attic-labs/testify/suite"
+	"github.com/golang/snappy"
 )
 
 func TestLevelDBStoreTestSuite(t *testing.T) {
@@ -56,3 +57,46 @@ func (suite *LevelDBStoreTestSuite) TestReservedKeys() {
 	suite.True(bytes.HasSuffix(ldb.versionKey, []byte(versionKeyConst)))
 	suite.True(bytes.HasSuffix(ldb.chunkPrefix, []byte(chunkPrefixConst)))
 }
+
+func (suite *LevelDBStoreTestSuite) TestLegacyValues() {
+	store := suite.Store.(*LevelDBStore)
+	legacy, empty, current := NewChunk([]byte("legacy")), NewChunk([]byte{}), NewChunk([]byte("current"))
+	// Values written before chunk codecs existed are snappy-encoded, without a header.
+	suite.NoError(store.db.Put(store.toChunkKey(legacy.Hash()), snappy.Encode(nil, legacy.Data()), nil))
+	suite.NoError(store.db.Put(store.toChunkKey(empty.Hash()), snappy.Encode(nil, empty.Data()), nil))
+	store.Put(current)
+
+	for _, c := range []Chunk{legacy, empty, current} {
+		suite.Equal(string(c.Data()), string(store.Get(c.Hash()).Data()))
+	}
+}
+
+func (suite *LevelDBStoreTestSuite) TestRecompress() {
+	store := suite.Store.(*LevelDBStore)
+	legacy := NewChunk(bytes.Repeat([]byte("legacy"), 100))
+	suite.NoError(store.db.Put(store.toChunkKey(legacy.Hash()), snappy.Encode(nil, legacy.Data()), nil))
+	chunks := []Chunk{legacy}
+	for i := 0; i < 10; i++ {
+		c := NewChunk(bytes.Repeat([]byte{byte(i)}, 1000))
+		store.Put(c)
+		chunks = append(chunks, c)
+	}
+
+	stats := store.Recompress(NoneCodec)
+	suite.Equal(uint64(11), stats.Chunks)
+	suite.Equal(uint64(11), stats.Recompressed)
+	suite.True(stats.BytesAfter > stats.BytesBefore)
+	for _, c := range chunks {
+		suite.Equal(string(c.Data()), string(store.Get(c.Hash()).Data()))
+	}
+
+	stats = store.Recompress(SnappyCodec)
+	suite.Equal(uint64(11), stats.Recompressed)
+	suite.True(stats.BytesAfter < stats.BytesBefore)
+	stats = store.Recompress(SnappyCodec)
+	suite.Equal(uint64(0), stats.Recompressed)
+	suite.Equal(stats.BytesBefore, stats.BytesAfter)
+	for _, c := range chunks {
+		suite.Equal(string(c.Data()), string(store.Get(c.Hash()).Data()))
+	}
+}
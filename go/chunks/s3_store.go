@@ -0,0 +1,648 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/client/metadata"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/private/signer/v4"
+	"github.com/golang/snappy"
+)
+
+const (
+	s3ServiceName = "s3"
+
+	// Get requests are batched into groups of up to this many chunks.
+	s3MaxGetCount = 256
+
+	// Chunks of the same table that are at most this many bytes apart are fetched with a single ranged GET.
+	s3MaxReadGap = 1 << 12 // 4KiB
+
+	// At most this many ranged GETs of a batch are in flight at once.
+	s3ReadConcurrency = 8
+)
+
+// S3Store is a ChunkStore that keeps chunks in an S3 bucket. Chunks are packed into immutable table objects, in the same format as the table files of TableStore, and are fetched with ranged GETs. The root, the version and the names of the tables making up the store are kept in a manifest object, in the same format as the manifest of TableStore. The manifest is only ever replaced with a conditional PUT against the ETag it was read with, so several S3Stores, possibly in different processes, can safely share a bucket and prefix.
+//
+// Unlike TableStore, S3Store never merges tables, since deleting tables that another S3Store may still be reading from isn't safe without coordination.
+type S3Store struct {
+	s3       *s3Client
+	prefix   string
+	mu       sync.Mutex
+	memtable map[hash.Hash]Chunk
+	memSize  int
+	tables   []*table // newest first
+	root     hash.Hash
+	version  string
+	putCount int64
+	closed   bool
+
+	readQueue    chan ReadRequest
+	finishedChan chan struct{}
+	requestWg    *sync.WaitGroup
+	workerWg     *sync.WaitGroup
+}
+
+// NewS3Store returns a new S3Store keeping its objects in bucket, with keys starting with prefix. Uses the endpoint, region and credentials of the AWS config parameter.
+func NewS3Store(bucket, prefix string, config *aws.Config) *S3Store {
+	d.PanicIfTrue(bucket == "", "bucket cannot be empty")
+	s := &S3Store{
+		s3:           newS3Client(bucket, config),
+		prefix:       strings.Trim(prefix, "/"),
+		memtable:     map[hash.Hash]Chunk{},
+		readQueue:    make(chan ReadRequest, readBufferSize),
+		finishedChan: make(chan struct{}),
+		requestWg:    &sync.WaitGroup{},
+		workerWg:     &sync.WaitGroup{},
+	}
+
+	m, _, err := s.readManifest()
+	d.Chk.NoError(err, "reading manifest of S3Store in %s", s.location())
+	s.root, s.version = m.root, m.version
+	d.Chk.NoError(s.addManifestTables(m), "opening S3Store in %s", s.location())
+	s.batchGetRequests()
+	return s
+}
+
+func (s *S3Store) Root() hash.Hash {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkOpen()
+	return s.root
+}
+
+// UpdateRoot writes out any buffered chunks, then replaces the manifest with one recording current as the root, if the root is still last.
+func (s *S3Store) UpdateRoot(current, last hash.Hash) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkOpen()
+
+	ok, err := s.updateManifest(func(m tableManifest) (hash.Hash, bool) {
+		return current, m.root == last
+	})
+	d.Chk.NoError(err)
+	return ok
+}
+
+func (s *S3Store) Get(h hash.Hash) Chunk {
+	if c, ok := s.getBuffered(h); ok {
+		return c
+	}
+
+	ch := make(chan Chunk)
+	s.requestWg.Add(1)
+	s.readQueue <- NewGetRequest(h, ch)
+	return <-ch
+}
+
+func (s *S3Store) getBuffered(h hash.Hash) (Chunk, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkOpen()
+	c, ok := s.memtable[h]
+	return c, ok
+}
+
+// Has only needs the indices of the tables, which are held in memory, so it never goes to S3.
+func (s *S3Store) Has(h hash.Hash) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkOpen()
+	return s.hasLocked(h)
+}
+
+func (s *S3Store) hasLocked(h hash.Hash) bool {
+	if _, ok := s.memtable[h]; ok {
+		return true
+	}
+	for _, t := range s.tables {
+		if t.has(h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *S3Store) Version() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkOpen()
+	return s.version
+}
+
+func (s *S3Store) Put(c Chunk) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkOpen()
+	s.putLocked(c)
+	if s.memSize >= tableMemtableSize {
+		d.Chk.NoError(s.flushAndRecord())
+	}
+}
+
+func (s *S3Store) PutMany(chunks []Chunk) (e BackpressureError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.checkOpen()
+	for _, c := range chunks {
+		s.putLocked(c)
+	}
+	if s.memSize >= tableMemtableSize {
+		d.Chk.NoError(s.flushAndRecord())
+	}
+	return
+}
+
+func (s *S3Store) putLocked(c Chunk) {
+	s.putCount++
+	if s.hasLocked(c.Hash()) {
+		return
+	}
+	s.memtable[c.Hash()] = c
+	s.memSize += len(c.Data())
+}
+
+// Close writes out any buffered chunks. They are recorded in the manifest, but the root is left as it is.
+func (s *S3Store) Close() error {
+	s.mu.Lock()
+	closed := s.closed
+	s.closed = true
+	s.mu.Unlock()
+	if closed {
+		return nil
+	}
+
+	s.requestWg.Wait()
+	close(s.finishedChan)
+	s.workerWg.Wait()
+	close(s.readQueue)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.flushAndRecord()
+	s.tables = nil
+	return err
+}
+
+func (s *S3Store) checkOpen() {
+	d.Chk.False(s.closed, "Cannot use S3Store after Close().")
+}
+
+// flushAndRecord writes out the memtable and records the new table in the manifest, along with any tables added to it by other S3Stores.
+func (s *S3Store) flushAndRecord() error {
+	if len(s.memtable) == 0 {
+		return nil
+	}
+	_, err := s.updateManifest(func(m tableManifest) (hash.Hash, bool) {
+		return m.root, true
+	})
+	return err
+}
+
+// updateManifest reads the manifest and passes it to newRoot. If newRoot accepts it, the memtable is written out and the manifest is replaced with one listing the tables of s and the root returned by newRoot. If another S3Store replaced the manifest in the meantime, this starts over with the manifest it wrote.
+func (s *S3Store) updateManifest(newRoot func(m tableManifest) (hash.Hash, bool)) (bool, error) {
+	for {
+		m, etag, err := s.readManifest()
+		if err != nil {
+			return false, err
+		}
+		if err = s.addManifestTables(m); err != nil {
+			return false, err
+		}
+		s.root = m.root
+		root, ok := newRoot(m)
+		if !ok {
+			return false, nil
+		}
+		if err = s.flush(); err != nil {
+			return false, err
+		}
+
+		if s.version == "" {
+			s.version = constants.NomsVersion
+		}
+		nm := tableManifest{version: s.version, root: root}
+		for _, t := range s.tables {
+			nm.tables = append(nm.tables, t.name)
+		}
+		err = s.s3.putIfMatch(s.key(tableManifestName), nm.bytes(), etag)
+		if err == nil {
+			s.root = root
+			return true, nil
+		} else if !isS3ConditionFailure(err) {
+			return false, err
+		}
+	}
+}
+
+// flush writes the memtable out as a new table object. The manifest isn't updated.
+func (s *S3Store) flush() error {
+	if len(s.memtable) == 0 {
+		return nil
+	}
+	chunks := make(chunkSlice, 0, len(s.memtable))
+	for _, c := range s.memtable {
+		chunks = append(chunks, c)
+	}
+	chunks.sort()
+
+	buf := &bytes.Buffer{}
+	tw := newTableWriter(buf)
+	if err := tw.addChunks(chunks); err != nil {
+		return err
+	}
+	name, err := tw.finish()
+	if err != nil {
+		return err
+	}
+	if err = s.s3.put(s.key(name), buf.Bytes()); err != nil {
+		return err
+	}
+
+	// The index and filter are read from the table just written, rather than fetched back from S3.
+	data := buf.Bytes()
+	t, err := readTable(nopCloserReaderAt{bytes.NewReader(data)}, int64(len(data)), name)
+	if err != nil {
+		return err
+	}
+	t.r = s3Object{s.s3, s.key(name)}
+	s.tables = append([]*table{t}, s.tables...)
+	s.memtable = map[hash.Hash]Chunk{}
+	s.memSize = 0
+	return nil
+}
+
+// addManifestTables opens any tables listed in m that s doesn't have open yet.
+func (s *S3Store) addManifestTables(m tableManifest) error {
+	open := map[string]bool{}
+	for _, t := range s.tables {
+		open[t.name] = true
+	}
+	added := []*table{}
+	for _, name := range m.tables {
+		if open[name] {
+			continue
+		}
+		o := s3Object{s.s3, s.key(name)}
+		size, err := s.s3.size(o.key)
+		if err != nil {
+			return err
+		}
+		t, err := readTable(o, size, name)
+		if err != nil {
+			return err
+		}
+		added = append(added, t)
+	}
+	s.tables = append(added, s.tables...)
+	return nil
+}
+
+// readManifest returns the manifest and its ETag. If there's no manifest yet, the ETag is empty.
+func (s *S3Store) readManifest() (tableManifest, string, error) {
+	data, etag, err := s.s3.get(s.key(tableManifestName), 0, 0)
+	if isS3NotFound(err) {
+		return tableManifest{version: constants.NomsVersion}, "", nil
+	} else if err != nil {
+		return tableManifest{}, "", err
+	}
+	m, err := parseTableManifest(data, s.location())
+	return m, etag, err
+}
+
+func (s *S3Store) key(name string) string {
+	if s.prefix == "" {
+		return name
+	}
+	return s.prefix + "/" + name
+}
+
+func (s *S3Store) location() string {
+	return "s3://" + path.Join(s.s3.bucket, s.prefix)
+}
+
+func (s *S3Store) batchGetRequests() {
+	s.workerWg.Add(1)
+	go func() {
+		defer s.workerWg.Done()
+
+		for done := false; !done; {
+			select {
+			case req := <-s.readQueue:
+				s.sendGetRequests(req)
+			case <-s.finishedChan:
+				done = true
+			}
+		}
+	}()
+}
+
+// s3Read is a ranged GET of a table, covering the data of one or more of its chunks.
+type s3Read struct {
+	t       *table
+	offset  uint64
+	length  uint64
+	entries []tableEntry
+}
+
+func (s *S3Store) sendGetRequests(req ReadRequest) {
+	batch := ReadBatch{}
+	addReq := func(req ReadRequest) {
+		batch[req.Hash()] = append(batch[req.Hash()], req.Outstanding())
+		s.requestWg.Done()
+	}
+	addReq(req)
+
+	for drained := false; !drained && len(batch) < s3MaxGetCount; {
+		select {
+		case req := <-s.readQueue:
+			addReq(req)
+		default:
+			drained = true
+		}
+	}
+
+	reads := s.planReads(batch)
+	found := make(chan Chunk, len(batch))
+	sem := make(chan struct{}, s3ReadConcurrency)
+	wg := &sync.WaitGroup{}
+	for _, r := range reads {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(r s3Read) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			buf := make([]byte, r.length)
+			_, err := r.t.r.ReadAt(buf, int64(r.offset))
+			d.Chk.NoError(err)
+			for _, e := range r.entries {
+				start := e.offset - r.offset
+				data, err := snappy.Decode(nil, buf[start:start+uint64(e.length)])
+				d.Chk.NoError(err)
+				found <- NewChunkWithHash(hash.New(e.digest), data)
+			}
+		}(r)
+	}
+	wg.Wait()
+	close(found)
+
+	for c := range found {
+		for _, reqChan := range batch[c.Hash()] {
+			reqChan.Satisfy(c)
+		}
+		delete(batch, c.Hash())
+	}
+	batch.Close()
+}
+
+// planReads finds the tables that the chunks in batch are in, and coalesces chunks that are close together in a table into a single read.
+func (s *S3Store) planReads(batch ReadBatch) []s3Read {
+	s.mu.Lock()
+	byTable := map[*table]tableEntriesByOffset{}
+	for h := range batch {
+		for _, t := range s.tables {
+			if e, ok := t.find(h); ok {
+				byTable[t] = append(byTable[t], e)
+				break
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	reads := []s3Read{}
+	for t, entries := range byTable {
+		sort.Sort(entries)
+		var r *s3Read
+		for _, e := range entries {
+			if r != nil && e.offset <= r.offset+r.length+s3MaxReadGap {
+				r.length = e.offset + uint64(e.length) - r.offset
+				r.entries = append(r.entries, e)
+				continue
+			}
+			if r != nil {
+				reads = append(reads, *r)
+			}
+			r = &s3Read{t, e.offset, uint64(e.length), []tableEntry{e}}
+		}
+		reads = append(reads, *r)
+	}
+	return reads
+}
+
+type tableEntriesByOffset []tableEntry
+
+func (es tableEntriesByOffset) Len() int           { return len(es) }
+func (es tableEntriesByOffset) Swap(i, j int)      { es[i], es[j] = es[j], es[i] }
+func (es tableEntriesByOffset) Less(i, j int) bool { return es[i].offset < es[j].offset }
+
+// s3Object reads a table from S3 with ranged GETs.
+type s3Object struct {
+	s3  *s3Client
+	key string
+}
+
+func (o s3Object) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	data, _, err := o.s3.get(o.key, off, len(p))
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data)
+	if n < len(p) {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, nil
+}
+
+func (o s3Object) Close() error {
+	return nil
+}
+
+type nopCloserReaderAt struct {
+	io.ReaderAt
+}
+
+func (nopCloserReaderAt) Close() error {
+	return nil
+}
+
+// s3Client makes the handful of S3 requests that S3Store needs. The vendored aws-sdk-go doesn't include the S3 service, so the requests are built here, and signed, sent and retried by the SDK.
+type s3Client struct {
+	*client.Client
+	bucket    string
+	pathStyle bool
+}
+
+func newS3Client(bucket string, config *aws.Config) *s3Client {
+	c := session.New(config).ClientConfig(s3ServiceName)
+	s3 := &s3Client{
+		Client: client.New(
+			*c.Config,
+			metadata.ClientInfo{
+				ServiceName:   s3ServiceName,
+				SigningRegion: c.SigningRegion,
+				Endpoint:      c.Endpoint,
+				APIVersion:    "2006-03-01",
+			},
+			c.Handlers,
+		),
+		bucket:    bucket,
+		pathStyle: aws.BoolValue(c.Config.S3ForcePathStyle),
+	}
+	s3.Handlers.Sign.PushBack(v4.Sign)
+	s3.Handlers.UnmarshalError.PushBack(unmarshalS3Error)
+	return s3
+}
+
+// send makes a request for the object at key. The caller must close the body of the response.
+func (c *s3Client) send(method, key string, header http.Header, body []byte) (*http.Response, error) {
+	op := &request.Operation{Name: method + "Object", HTTPMethod: method, HTTPPath: "/" + key}
+	if c.pathStyle {
+		op.HTTPPath = "/" + c.bucket + op.HTTPPath
+	}
+	req := c.NewRequest(op, nil, nil)
+	if !c.pathStyle {
+		req.HTTPRequest.URL.Host = c.bucket + "." + req.HTTPRequest.URL.Host
+	}
+	for k, vs := range header {
+		req.HTTPRequest.Header[k] = vs
+	}
+	if body != nil {
+		req.SetBufferBody(body)
+	}
+	if err := req.Send(); err != nil {
+		return nil, err
+	}
+	return req.HTTPResponse, nil
+}
+
+// get returns the object at key, or length bytes of it starting at off if length isn't 0, along with its ETag.
+func (c *s3Client) get(key string, off int64, length int) ([]byte, string, error) {
+	header := http.Header{}
+	if length > 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", off, off+int64(length)-1))
+	}
+	resp, err := c.send("GET", key, header, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	return data, resp.Header.Get("ETag"), err
+}
+
+// size returns the length of the object at key.
+func (c *s3Client) size(key string) (int64, error) {
+	resp, err := c.send("HEAD", key, nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("No Content-Length for s3://%s/%s", c.bucket, key)
+	}
+	return resp.ContentLength, nil
+}
+
+func (c *s3Client) put(key string, data []byte) error {
+	return c.putWithHeader(key, data, http.Header{})
+}
+
+// putIfMatch replaces the object at key only if its ETag is still etag. If etag is empty, the object must not exist yet.
+func (c *s3Client) putIfMatch(key string, data []byte, etag string) error {
+	header := http.Header{}
+	if etag == "" {
+		header.Set("If-None-Match", "*")
+	} else {
+		header.Set("If-Match", etag)
+	}
+	return c.putWithHeader(key, data, header)
+}
+
+func (c *s3Client) putWithHeader(key string, data []byte, header http.Header) error {
+	header.Set("Content-Type", "application/octet-stream")
+	resp, err := c.send("PUT", key, header, data)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// unmarshalS3Error turns an error response into an awserr.RequestFailure, with the code from the XML body if there is one.
+func unmarshalS3Error(r *request.Request) {
+	defer r.HTTPResponse.Body.Close()
+	status := r.HTTPResponse.StatusCode
+	body := struct {
+		Code    string
+		Message string
+	}{}
+	if err := xml.NewDecoder(r.HTTPResponse.Body).Decode(&body); err != nil || body.Code == "" {
+		body.Code = strings.Replace(http.StatusText(status), " ", "", -1)
+		body.Message = http.StatusText(status)
+	}
+	io.Copy(ioutil.Discard, r.HTTPResponse.Body)
+	r.Error = awserr.NewRequestFailure(awserr.New(body.Code, body.Message, nil), status, r.RequestID)
+}
+
+func s3StatusCode(err error) int {
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode()
+	}
+	return 0
+}
+
+func isS3NotFound(err error) bool {
+	return s3StatusCode(err) == http.StatusNotFound
+}
+
+// isS3ConditionFailure returns whether err is due to a conditional PUT losing out to another write of the same object.
+func isS3ConditionFailure(err error) bool {
+	status := s3StatusCode(err)
+	return status == http.StatusPreconditionFailed || status == http.StatusConflict
+}
+
+// NewS3StoreFactory returns a Factory that creates S3Stores in bucket, with prefixes below prefix named after their namespaces.
+func NewS3StoreFactory(bucket, prefix string, config *aws.Config) Factory {
+	return &S3StoreFactory{bucket, prefix, config, false}
+}
+
+type S3StoreFactory struct {
+	bucket    string
+	prefix    string
+	config    *aws.Config
+	shuttered bool
+}
+
+func (f *S3StoreFactory) CreateStore(ns string) ChunkStore {
+	d.Chk.False(f.shuttered, "Cannot use S3StoreFactory after Shutter().")
+	return NewS3Store(f.bucket, path.Join(f.prefix, ns), f.config)
+}
+
+func (f *S3StoreFactory) Shutter() {
+	f.shuttered = true
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"crypto/md5"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/attic-labs/testify/assert"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+)
+
+// fakeS3 is an in-process stand-in for S3 that serves the requests made by S3Store, for use with httptest.NewServer(). Object keys include the bucket, since requests to it must use path-style URLs.
+type fakeS3 struct {
+	assert   *assert.Assertions
+	mu       sync.Mutex
+	objects  map[string][]byte
+	numGets  int
+	numPuts  int
+	numFails int
+}
+
+func createFakeS3(a *assert.Assertions) *fakeS3 {
+	return &fakeS3{assert: a, objects: map[string][]byte{}}
+}
+
+// fakeS3Config returns the config an S3Store needs to talk to a fakeS3 served at url.
+func fakeS3Config(url string) *aws.Config {
+	return aws.NewConfig().
+		WithEndpoint(url).
+		WithRegion("us-west-2").
+		WithS3ForcePathStyle(true).
+		WithCredentials(credentials.NewStaticCredentials("key", "secret", ""))
+}
+
+func (m *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.assert.NotEmpty(r.Header.Get("Authorization"), "Requests should be signed")
+
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	data, exists := m.objects[key]
+	switch r.Method {
+	case "GET", "HEAD":
+		if !exists {
+			m.writeError(w, r, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		status := http.StatusOK
+		w.Header().Set("ETag", fakeS3ETag(data))
+		if rng := r.Header.Get("Range"); rng != "" {
+			var start, end int
+			_, err := fmt.Sscanf(rng, "bytes=%d-%d", &start, &end)
+			m.assert.NoError(err, "Unsupported range %s", rng)
+			if start >= len(data) || end < start {
+				m.writeError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+				return
+			}
+			if end >= len(data) {
+				end = len(data) - 1
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			data = data[start : end+1]
+			status = http.StatusPartialContent
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(status)
+		if r.Method == "GET" {
+			m.numGets++
+			w.Write(data)
+		}
+
+	case "PUT":
+		body, err := ioutil.ReadAll(r.Body)
+		m.assert.NoError(err)
+		if etag := r.Header.Get("If-Match"); etag != "" && (!exists || etag != fakeS3ETag(data)) {
+			m.writeError(w, r, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		if r.Header.Get("If-None-Match") == "*" && exists {
+			m.writeError(w, r, http.StatusPreconditionFailed, "PreconditionFailed")
+			return
+		}
+		m.numPuts++
+		m.objects[key] = body
+		w.Header().Set("ETag", fakeS3ETag(body))
+		w.WriteHeader(http.StatusOK)
+
+	default:
+		m.writeError(w, r, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (m *fakeS3) writeError(w http.ResponseWriter, r *http.Request, status int, code string) {
+	m.numFails++
+	w.WriteHeader(status)
+	if r.Method != "HEAD" {
+		fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, http.StatusText(status))
+	}
+}
+
+func (m *fakeS3) get(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.objects[key]
+	return data, ok
+}
+
+func fakeS3ETag(data []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(data))
+}
@@ -0,0 +1,139 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestS3StoreTestSuite(t *testing.T) {
+	suite.Run(t, &S3StoreTestSuite{})
+}
+
+type S3StoreTestSuite struct {
+	ChunkStoreTestSuite
+	s3      *fakeS3
+	server  *httptest.Server
+	factory Factory
+}
+
+func (suite *S3StoreTestSuite) SetupTest() {
+	suite.s3 = createFakeS3(suite.Assert())
+	suite.server = httptest.NewServer(suite.s3)
+	suite.factory = NewS3StoreFactory("bucket", "prefix", fakeS3Config(suite.server.URL))
+	store := suite.factory.CreateStore("name").(*S3Store)
+	suite.putCountFn = func() int {
+		return int(store.putCount)
+	}
+
+	suite.Store = store
+}
+
+func (suite *S3StoreTestSuite) TearDownTest() {
+	suite.Store.Close()
+	suite.factory.Shutter()
+	suite.server.Close()
+}
+
+func (suite *S3StoreTestSuite) TestObjects() {
+	c := NewChunk([]byte("abc"))
+	suite.Store.Put(c)
+	suite.True(suite.Store.UpdateRoot(c.Hash(), hash.Hash{}))
+
+	data, ok := suite.s3.get("bucket/prefix/name/" + tableManifestName)
+	suite.True(ok)
+	m, err := parseTableManifest(data, "test")
+	suite.NoError(err)
+	suite.Equal(c.Hash(), m.root)
+	suite.Len(m.tables, 1)
+	_, ok = suite.s3.get("bucket/prefix/name/" + m.tables[0])
+	suite.True(ok)
+}
+
+func (suite *S3StoreTestSuite) TestReopen() {
+	c1, c2 := NewChunk([]byte("abc")), NewChunk([]byte("def"))
+	suite.Store.Put(c1)
+	suite.True(suite.Store.UpdateRoot(c1.Hash(), suite.Store.Root()))
+	suite.Store.Put(c2)
+	suite.Store.Close()
+
+	store := suite.factory.CreateStore("name")
+	defer store.Close()
+	suite.Equal(c1.Hash(), store.Root())
+	assertInputInStore("abc", c1.Hash(), store, suite.Assert())
+	// Chunks that are Put are written out on Close(), even without a root update.
+	assertInputInStore("def", c2.Hash(), store, suite.Assert())
+	suite.False(store.Has(hash.Parse("11111111111111111111111111111111")))
+}
+
+func (suite *S3StoreTestSuite) TestNamespaces() {
+	other := suite.factory.CreateStore("other")
+	defer other.Close()
+
+	c := NewChunk([]byte("abc"))
+	suite.Store.Put(c)
+	suite.True(suite.Store.UpdateRoot(c.Hash(), hash.Hash{}))
+	suite.False(other.Has(c.Hash()))
+	suite.True(other.Root().IsEmpty())
+}
+
+func (suite *S3StoreTestSuite) TestUpdateRootSeesOtherStores() {
+	other := suite.factory.CreateStore("name")
+	defer other.Close()
+
+	c1, c2 := NewChunk([]byte("abc")), NewChunk([]byte("def"))
+	other.Put(c1)
+	suite.True(other.UpdateRoot(c1.Hash(), hash.Hash{}))
+
+	// suite.Store still thinks the root is empty, so its update must fail...
+	suite.Store.Put(c2)
+	suite.False(suite.Store.UpdateRoot(c2.Hash(), hash.Hash{}))
+	// ...after which it knows about the new root and the chunks behind it.
+	suite.Equal(c1.Hash(), suite.Store.Root())
+	suite.True(suite.Store.Has(c1.Hash()))
+	suite.True(suite.Store.UpdateRoot(c2.Hash(), c1.Hash()))
+
+	suite.False(other.UpdateRoot(c1.Hash(), c1.Hash()))
+	assertInputInStore("def", c2.Hash(), other, suite.Assert())
+}
+
+func (suite *S3StoreTestSuite) TestBatchedReads() {
+	store := suite.Store.(*S3Store)
+	batch := ReadBatch{}
+	for i := 0; i < 100; i++ {
+		c := NewChunk([]byte(fmt.Sprintf("chunk %d", i)))
+		store.Put(c)
+		batch[c.Hash()] = nil
+	}
+	batch[hash.FromData([]byte("absent"))] = nil
+	suite.True(store.UpdateRoot(hash.Hash{}, hash.Hash{}))
+
+	// The chunks are all next to each other in the same table, so they're read with a single GET.
+	reads := store.planReads(batch)
+	suite.Len(reads, 1)
+	suite.Len(reads[0].entries, 100)
+
+	gets := suite.s3.numGets
+	chans := []chan Chunk{}
+	for h := range batch {
+		ch := make(chan Chunk, 1)
+		chans = append(chans, ch)
+		store.requestWg.Add(1)
+		store.readQueue <- NewGetRequest(h, ch)
+	}
+	found := 0
+	for _, ch := range chans {
+		if c := <-ch; !c.IsEmpty() {
+			found++
+		}
+	}
+	suite.Equal(100, found)
+	suite.True(suite.s3.numGets-gets < 100, "%d GETs for 100 chunks", suite.s3.numGets-gets)
+}
@@ -0,0 +1,360 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/golang/snappy"
+)
+
+// A table file holds an immutable set of chunks, sorted by hash. Its layout is:
+//
+//	chunk data     the snappy-compressed data of each chunk, in hash order
+//	index          for each chunk, its digest (20 bytes), offset (uint64) and length (uint32)
+//	bloom filter   tableBloomBitsPerKey bits per chunk
+//	footer         chunk count, index offset and filter offset (each a uint64), then tableMagic
+//
+// All integers are big-endian. A table is named after the hash of the digests of its chunks.
+const (
+	tableFileExt         = ".tbl"
+	tableMagic           = "NOMSTBL1"
+	tableFooterSize      = 3*8 + len(tableMagic)
+	tableIndexEntrySize  = hash.ByteLen + 8 + 4
+	tableBloomBitsPerKey = 10
+	tableBloomHashes     = 7
+)
+
+type tableEntry struct {
+	digest hash.Digest
+	offset uint64
+	length uint32
+}
+
+// table is an open table. Its index and bloom filter are held in memory, and chunk data is read as needed.
+type table struct {
+	name    string
+	r       tableReader
+	entries []tableEntry
+	filter  bloomFilter
+}
+
+// tableReader is what the data of a table is read from, such as a file.
+type tableReader interface {
+	io.ReaderAt
+	io.Closer
+}
+
+func openTable(dir, name string) (*table, error) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	t, err := readTable(f, fi.Size(), name)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return t, nil
+}
+
+// readTable reads the index and bloom filter of the table of the given size in r.
+func readTable(r tableReader, size int64, name string) (*table, error) {
+	if size < int64(tableFooterSize) {
+		return nil, fmt.Errorf("Table %s is too short", name)
+	}
+
+	footer := make([]byte, tableFooterSize)
+	if _, err := r.ReadAt(footer, size-int64(tableFooterSize)); err != nil {
+		return nil, err
+	}
+	if string(footer[24:]) != tableMagic {
+		return nil, fmt.Errorf("Table %s is not a table file", name)
+	}
+	count := binary.BigEndian.Uint64(footer[0:8])
+	indexOffset := binary.BigEndian.Uint64(footer[8:16])
+	filterOffset := binary.BigEndian.Uint64(footer[16:24])
+	if indexOffset+count*uint64(tableIndexEntrySize) != filterOffset || filterOffset > uint64(size-int64(tableFooterSize)) {
+		return nil, fmt.Errorf("Table %s is corrupt", name)
+	}
+
+	index := make([]byte, filterOffset-indexOffset)
+	if _, err := r.ReadAt(index, int64(indexOffset)); err != nil {
+		return nil, err
+	}
+	entries := make([]tableEntry, count)
+	for i := range entries {
+		b := index[i*tableIndexEntrySize:]
+		copy(entries[i].digest[:], b[:hash.ByteLen])
+		entries[i].offset = binary.BigEndian.Uint64(b[hash.ByteLen:])
+		entries[i].length = binary.BigEndian.Uint32(b[hash.ByteLen+8:])
+	}
+
+	filter := make(bloomFilter, uint64(size)-uint64(tableFooterSize)-filterOffset)
+	if _, err := r.ReadAt(filter, int64(filterOffset)); err != nil {
+		return nil, err
+	}
+	return &table{name, r, entries, filter}, nil
+}
+
+// find returns the index entry of h, if it's in t.
+func (t *table) find(h hash.Hash) (tableEntry, bool) {
+	if !t.filter.mayContain(h) {
+		return tableEntry{}, false
+	}
+	digest := h.Digest()
+	i := sort.Search(len(t.entries), func(i int) bool {
+		return bytes.Compare(t.entries[i].digest[:], digest[:]) >= 0
+	})
+	if i < len(t.entries) && t.entries[i].digest == digest {
+		return t.entries[i], true
+	}
+	return tableEntry{}, false
+}
+
+func (t *table) has(h hash.Hash) bool {
+	_, ok := t.find(h)
+	return ok
+}
+
+func (t *table) get(h hash.Hash) (Chunk, bool) {
+	e, ok := t.find(h)
+	if !ok {
+		return EmptyChunk, false
+	}
+	compressed, err := t.read(e)
+	d.Chk.NoError(err)
+	data, err := snappy.Decode(nil, compressed)
+	d.Chk.NoError(err)
+	return NewChunkWithHash(h, data), true
+}
+
+// dataSize returns the number of bytes of compressed chunk data in t.
+func (t *table) dataSize() (size uint64) {
+	for _, e := range t.entries {
+		size += uint64(e.length)
+	}
+	return
+}
+
+// read returns the compressed data of the chunk at e.
+func (t *table) read(e tableEntry) ([]byte, error) {
+	buf := make([]byte, e.length)
+	_, err := t.r.ReadAt(buf, int64(e.offset))
+	return buf, err
+}
+
+func (t *table) close() error {
+	return t.r.Close()
+}
+
+// tableWriter writes a table. Chunks can be added in any order, but only once each. The index is sorted by finish().
+type tableWriter struct {
+	w       *bufio.Writer
+	offset  uint64
+	entries []tableEntry
+}
+
+func newTableWriter(w io.Writer) *tableWriter {
+	return &tableWriter{w: bufio.NewWriterSize(w, 1<<16)}
+}
+
+// add appends the snappy-compressed data of the chunk with hash h.
+func (tw *tableWriter) add(h hash.Hash, compressed []byte) error {
+	if _, err := tw.w.Write(compressed); err != nil {
+		return err
+	}
+	tw.entries = append(tw.entries, tableEntry{h.Digest(), tw.offset, uint32(len(compressed))})
+	tw.offset += uint64(len(compressed))
+	return nil
+}
+
+// addChunks compresses and appends chunks.
+func (tw *tableWriter) addChunks(chunks []Chunk) error {
+	for _, c := range chunks {
+		if err := tw.add(c.Hash(), snappy.Encode(nil, c.Data())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// finish writes the index, filter and footer, and flushes the table. It returns the name of the table.
+func (tw *tableWriter) finish() (string, error) {
+	sort.Sort(tableEntries(tw.entries))
+	for i := 1; i < len(tw.entries); i++ {
+		d.Chk.True(tw.entries[i-1].digest != tw.entries[i].digest, "Chunks must only be added to a table once")
+	}
+
+	indexOffset := tw.offset
+	digests := make([]byte, 0, len(tw.entries)*hash.ByteLen)
+	filter := newBloomFilter(len(tw.entries))
+	entry := make([]byte, tableIndexEntrySize)
+	for _, e := range tw.entries {
+		copy(entry, e.digest[:])
+		binary.BigEndian.PutUint64(entry[hash.ByteLen:], e.offset)
+		binary.BigEndian.PutUint32(entry[hash.ByteLen+8:], e.length)
+		if _, err := tw.w.Write(entry); err != nil {
+			return "", err
+		}
+		digests = append(digests, e.digest[:]...)
+		filter.add(hash.New(e.digest))
+	}
+	filterOffset := indexOffset + uint64(len(tw.entries)*tableIndexEntrySize)
+	if _, err := tw.w.Write(filter); err != nil {
+		return "", err
+	}
+
+	footer := make([]byte, tableFooterSize)
+	binary.BigEndian.PutUint64(footer[0:], uint64(len(tw.entries)))
+	binary.BigEndian.PutUint64(footer[8:], indexOffset)
+	binary.BigEndian.PutUint64(footer[16:], filterOffset)
+	copy(footer[24:], tableMagic)
+	if _, err := tw.w.Write(footer); err != nil {
+		return "", err
+	}
+	if err := tw.w.Flush(); err != nil {
+		return "", err
+	}
+	return hash.FromData(digests).String() + tableFileExt, nil
+}
+
+// writeTableFile writes a table to a temporary file in dir with write, then syncs the file and moves it into place. It returns the name of the table.
+func writeTableFile(dir string, write func(tw *tableWriter) error) (name string, err error) {
+	f, err := ioutil.TempFile(dir, "table-")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	tw := newTableWriter(f)
+	if err = write(tw); err != nil {
+		return
+	}
+	if name, err = tw.finish(); err != nil {
+		return
+	}
+	if err = f.Sync(); err != nil {
+		return
+	}
+	if err = f.Close(); err != nil {
+		return
+	}
+	err = os.Rename(f.Name(), filepath.Join(dir, name))
+	return
+}
+
+// writeTable writes chunks to a new table file in dir.
+func writeTable(dir string, chunks []Chunk) (string, error) {
+	return writeTableFile(dir, func(tw *tableWriter) error {
+		return tw.addChunks(chunks)
+	})
+}
+
+// mergeTables writes the chunks of all of tables to a single new table file in dir. Chunks found in more than one table are written once.
+func mergeTables(dir string, tables []*table) (string, error) {
+	sources := tableSources{}
+	for _, t := range tables {
+		for _, e := range t.entries {
+			sources = append(sources, tableSource{t, e})
+		}
+	}
+	sort.Sort(sources)
+
+	return writeTableFile(dir, func(tw *tableWriter) error {
+		for i, s := range sources {
+			if i > 0 && sources[i-1].e.digest == s.e.digest {
+				continue
+			}
+			compressed, err := s.t.read(s.e)
+			if err != nil {
+				return err
+			}
+			if err = tw.add(hash.New(s.e.digest), compressed); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type tableEntries []tableEntry
+
+func (te tableEntries) Len() int      { return len(te) }
+func (te tableEntries) Swap(i, j int) { te[i], te[j] = te[j], te[i] }
+func (te tableEntries) Less(i, j int) bool {
+	return bytes.Compare(te[i].digest[:], te[j].digest[:]) < 0
+}
+
+type tableSource struct {
+	t *table
+	e tableEntry
+}
+
+type tableSources []tableSource
+
+func (ts tableSources) Len() int      { return len(ts) }
+func (ts tableSources) Swap(i, j int) { ts[i], ts[j] = ts[j], ts[i] }
+func (ts tableSources) Less(i, j int) bool {
+	return bytes.Compare(ts[i].e.digest[:], ts[j].e.digest[:]) < 0
+}
+
+// bloomFilter is a bloom filter over chunk hashes. Hashes are already uniformly distributed, so the bit positions are derived from the digest by double hashing.
+type bloomFilter []byte
+
+func newBloomFilter(n int) bloomFilter {
+	bytes := (n*tableBloomBitsPerKey + 7) / 8
+	if bytes < 8 {
+		bytes = 8
+	}
+	return make(bloomFilter, bytes)
+}
+
+func (bf bloomFilter) positions(h hash.Hash, cb func(bit uint64)) {
+	digest := h.Digest()
+	h1 := binary.BigEndian.Uint64(digest[0:8])
+	h2 := binary.BigEndian.Uint64(digest[8:16])
+	bits := uint64(len(bf)) * 8
+	for i := uint64(0); i < tableBloomHashes; i++ {
+		cb((h1 + i*h2) % bits)
+	}
+}
+
+func (bf bloomFilter) add(h hash.Hash) {
+	bf.positions(h, func(bit uint64) {
+		bf[bit/8] |= 1 << (bit % 8)
+	})
+}
+
+func (bf bloomFilter) mayContain(h hash.Hash) bool {
+	if len(bf) == 0 {
+		return false
+	}
+	contains := true
+	bf.positions(h, func(bit uint64) {
+		contains = contains && bf[bit/8]&(1<<(bit%8)) != 0
+	})
+	return contains
+}
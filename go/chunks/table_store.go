@@ -0,0 +1,503 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+const (
+	tableManifestName = "manifest"
+
+	// Chunks are buffered in memory until this many bytes have been Put, or until UpdateRoot() or Close() is called, and then written out as a table file.
+	tableMemtableSize = 1 << 24 // 16MiB
+
+	// When a store has more than this many table files, the newest of them are merged into one. See compactionCount().
+	tableMaxTables = 16
+)
+
+// TableStore is a ChunkStore that keeps chunks in immutable table files in a directory. See table.go for their layout. Chunks that are Put are buffered in memory, and written out as a new table file when enough of them have been buffered, or when the root is updated. The root, the version and the names of the table files making up the store are kept in a small manifest file, which is replaced atomically by UpdateRoot().
+//
+// Before a root update is accepted, the manifest is read back from disk so that the compare-and-swap takes updates made through other TableStores in the same directory into account: tables they've added are opened, and tables they've merged away are dropped. A TableStore only merges tables, deleting their files, if the manifest hasn't changed since it last read it, so that it doesn't delete tables that another TableStore has just listed. Tables that another TableStore is already reading from stay readable after they're deleted, until it next reads the manifest.
+type TableStore struct {
+	dir      string
+	mu       sync.Mutex
+	memtable map[hash.Hash]Chunk
+	memSize  int
+	tables   []*table        // newest first
+	manifest []string        // The tables listed in the manifest when ts last read or wrote it.
+	readers  map[*table]int  // The number of Get() and Has() calls reading from each table, which is only closed once none are.
+	retired  map[*table]bool // Tables that were merged into another while being read from, to be closed once they aren't.
+	root     hash.Hash
+	version  string
+	putCount int64
+	closed   bool
+}
+
+// NewTableStore opens the TableStore in dir, creating it if it doesn't exist. If ns is not empty, the store is kept in a subdirectory of dir named after it.
+func NewTableStore(dir, ns string) *TableStore {
+	d.PanicIfTrue(dir == "", "dir cannot be empty")
+	if ns != "" {
+		dir = filepath.Join(dir, ns)
+	}
+	d.PanicIfError(os.MkdirAll(dir, 0700))
+
+	ts := &TableStore{dir: dir, memtable: map[hash.Hash]Chunk{}, readers: map[*table]int{}, retired: map[*table]bool{}}
+	m, err := readTableManifest(dir)
+	d.Chk.NoError(err, "reading manifest of TableStore in %s", dir)
+	ts.root, ts.version = m.root, m.version
+	ts.tables, err = openTables(dir, m.tables)
+	d.Chk.NoError(err, "opening TableStore in %s", dir)
+	ts.manifest = m.tables
+	return ts
+}
+
+func (ts *TableStore) Root() hash.Hash {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.checkOpen()
+	return ts.root
+}
+
+// UpdateRoot writes out any buffered chunks, then replaces the manifest with one recording current as the root, if the root is still last.
+func (ts *TableStore) UpdateRoot(current, last hash.Hash) bool {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.checkOpen()
+
+	m, err := readTableManifest(ts.dir)
+	d.Chk.NoError(err)
+	d.Chk.NoError(ts.syncManifestTables(m))
+	ts.root = m.root
+	if last != m.root {
+		return false
+	}
+
+	d.Chk.NoError(ts.flush())
+	ts.root = current
+	d.Chk.NoError(ts.writeManifest())
+	return true
+}
+
+// Get and Has only hold ts.mu while looking in the memtable and picking the tables to read from, not while reading them.
+func (ts *TableStore) Get(h hash.Hash) Chunk {
+	c, ok, tables := ts.startRead(h)
+	if ok {
+		return c
+	}
+	defer ts.endRead(tables)
+	for _, t := range tables {
+		if c, ok := t.get(h); ok {
+			return c
+		}
+	}
+	return EmptyChunk
+}
+
+func (ts *TableStore) Has(h hash.Hash) bool {
+	_, ok, tables := ts.startRead(h)
+	if ok {
+		return true
+	}
+	defer ts.endRead(tables)
+	for _, t := range tables {
+		if t.has(h) {
+			return true
+		}
+	}
+	return false
+}
+
+// startRead returns the chunk h if it's in the memtable. Otherwise, it returns the tables to look for it in, which stay open, even if they're merged meanwhile, until they're passed to endRead().
+func (ts *TableStore) startRead(h hash.Hash) (Chunk, bool, []*table) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.checkOpen()
+	if c, ok := ts.memtable[h]; ok {
+		return c, true, nil
+	}
+	tables := append([]*table{}, ts.tables...)
+	for _, t := range tables {
+		ts.readers[t]++
+	}
+	return EmptyChunk, false, tables
+}
+
+// endRead closes those of tables that were merged while being read from, if nothing else is reading from them.
+func (ts *TableStore) endRead(tables []*table) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	for _, t := range tables {
+		if ts.readers[t]--; ts.readers[t] > 0 {
+			continue
+		}
+		delete(ts.readers, t)
+		if ts.retired[t] {
+			delete(ts.retired, t)
+			t.close()
+		}
+	}
+}
+
+func (ts *TableStore) hasLocked(h hash.Hash) bool {
+	if _, ok := ts.memtable[h]; ok {
+		return true
+	}
+	for _, t := range ts.tables {
+		if t.has(h) {
+			return true
+		}
+	}
+	return false
+}
+
+func (ts *TableStore) Version() string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.checkOpen()
+	return ts.version
+}
+
+func (ts *TableStore) Put(c Chunk) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.checkOpen()
+	ts.putLocked(c)
+	if ts.memSize >= tableMemtableSize {
+		d.Chk.NoError(ts.flushAndRecord())
+	}
+}
+
+func (ts *TableStore) PutMany(chunks []Chunk) (e BackpressureError) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	ts.checkOpen()
+	for _, c := range chunks {
+		ts.putLocked(c)
+	}
+	if ts.memSize >= tableMemtableSize {
+		d.Chk.NoError(ts.flushAndRecord())
+	}
+	return
+}
+
+func (ts *TableStore) putLocked(c Chunk) {
+	ts.putCount++
+	if ts.hasLocked(c.Hash()) {
+		return
+	}
+	ts.memtable[c.Hash()] = c
+	ts.memSize += len(c.Data())
+}
+
+// Close writes out any buffered chunks. They are recorded in the manifest, but the root is left as it is.
+func (ts *TableStore) Close() error {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	if ts.closed {
+		return nil
+	}
+	err := ts.flushAndRecord()
+	for _, t := range ts.tables {
+		t.close()
+	}
+	ts.tables = nil
+	ts.closed = true
+	return err
+}
+
+func (ts *TableStore) checkOpen() {
+	d.Chk.False(ts.closed, "Cannot use TableStore after Close().")
+}
+
+// flushAndRecord writes out the memtable and records the new table in the manifest, along with any tables added to it by other TableStores.
+func (ts *TableStore) flushAndRecord() error {
+	if len(ts.memtable) == 0 {
+		return nil
+	}
+	m, err := readTableManifest(ts.dir)
+	if err != nil {
+		return err
+	}
+	if err = ts.syncManifestTables(m); err != nil {
+		return err
+	}
+	ts.root = m.root
+	if err = ts.flush(); err != nil {
+		return err
+	}
+	return ts.writeManifest()
+}
+
+// flush writes the memtable out as a new table file, and merges the table files if there are too many of them. The manifest isn't updated.
+func (ts *TableStore) flush() error {
+	if len(ts.memtable) > 0 {
+		chunks := make(chunkSlice, 0, len(ts.memtable))
+		for _, c := range ts.memtable {
+			chunks = append(chunks, c)
+		}
+		chunks.sort()
+		name, err := writeTable(ts.dir, chunks)
+		if err != nil {
+			return err
+		}
+		t, err := openTable(ts.dir, name)
+		if err != nil {
+			return err
+		}
+		ts.tables = append([]*table{t}, ts.tables...)
+		ts.memtable = map[hash.Hash]Chunk{}
+		ts.memSize = 0
+	}
+
+	if len(ts.tables) <= tableMaxTables {
+		return nil
+	}
+	// If another TableStore has changed the manifest since it was read, the tables to merge may be ones it has just listed, so merging is left until next time.
+	m, err := readTableManifest(ts.dir)
+	if err != nil {
+		return err
+	}
+	if !equalTableNames(m.tables, ts.manifest) {
+		return nil
+	}
+	n := compactionCount(ts.tables)
+	name, err := mergeTables(ts.dir, ts.tables[:n])
+	if err != nil {
+		return err
+	}
+	t, err := openTable(ts.dir, name)
+	if err != nil {
+		return err
+	}
+	old := ts.tables[:n]
+	ts.tables = append([]*table{t}, ts.tables[n:]...)
+	if err = ts.writeManifest(); err != nil {
+		return err
+	}
+	for _, o := range old {
+		ts.retire(o)
+		if o.name != name {
+			os.Remove(filepath.Join(ts.dir, o.name))
+		}
+	}
+	return nil
+}
+
+// compactionCount returns how many of the newest of tables to merge. Starting with the newest two, the next older table is added as long as it's at most twice the size of those already picked, so that tables are merged with others of similar size. Since a chunk is only rewritten when its table is merged with ones at least half as big in all, each chunk is rewritten a logarithmic number of times as the store grows, rather than every time there are too many tables.
+func compactionCount(tables []*table) int {
+	n := 2
+	size := tables[0].dataSize() + tables[1].dataSize()
+	for n < len(tables) && tables[n].dataSize() <= 2*size {
+		size += tables[n].dataSize()
+		n++
+	}
+	return n
+}
+
+// syncManifestTables makes ts.tables match m, the manifest as just read from disk. Tables listed in m that ts doesn't have open yet are opened, and those that were listed when ts last read or wrote the manifest but no longer are, because another TableStore merged them, are dropped. Tables that ts has written but not recorded in the manifest yet are kept.
+func (ts *TableStore) syncManifestTables(m tableManifest) error {
+	open := map[string]*table{}
+	for _, t := range ts.tables {
+		open[t.name] = t
+	}
+	listed, added := []*table{}, []*table{}
+	for _, name := range m.tables {
+		t, ok := open[name]
+		if !ok {
+			var err error
+			if t, err = openTable(ts.dir, name); err != nil {
+				for _, t := range added {
+					t.close()
+				}
+				return err
+			}
+			added = append(added, t)
+		}
+		listed = append(listed, t)
+		delete(open, name)
+	}
+
+	wasListed := map[string]bool{}
+	for _, name := range ts.manifest {
+		wasListed[name] = true
+	}
+	unrecorded := []*table{}
+	for _, t := range ts.tables {
+		if _, ok := open[t.name]; !ok {
+			continue
+		}
+		if wasListed[t.name] {
+			ts.retire(t)
+		} else {
+			unrecorded = append(unrecorded, t)
+		}
+	}
+	ts.tables = append(unrecorded, listed...)
+	ts.manifest = m.tables
+	return nil
+}
+
+// retire closes t, which is no longer one of ts.tables, or if it's being read from, leaves it to endRead() to close. Readers keep the file open, so it can be removed from under them.
+func (ts *TableStore) retire(t *table) {
+	if ts.readers[t] > 0 {
+		ts.retired[t] = true
+	} else {
+		t.close()
+	}
+}
+
+func equalTableNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func (ts *TableStore) writeManifest() error {
+	if ts.version == "" {
+		ts.version = constants.NomsVersion
+	}
+	m := tableManifest{version: ts.version, root: ts.root}
+	for _, t := range ts.tables {
+		m.tables = append(m.tables, t.name)
+	}
+	if err := m.write(ts.dir); err != nil {
+		return err
+	}
+	ts.manifest = m.tables
+	return nil
+}
+
+// openTables opens the tables with the given names in dir.
+func openTables(dir string, names []string) ([]*table, error) {
+	tables := []*table{}
+	for _, name := range names {
+		t, err := openTable(dir, name)
+		if err != nil {
+			for _, t := range tables {
+				t.close()
+			}
+			return nil, err
+		}
+		tables = append(tables, t)
+	}
+	return tables, nil
+}
+
+// tableManifest is the contents of the manifest file of a TableStore: the version on the first line, the root on the second, then the names of the table files, newest first, one per line.
+type tableManifest struct {
+	version string
+	root    hash.Hash
+	tables  []string
+}
+
+func readTableManifest(dir string) (tableManifest, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, tableManifestName))
+	if os.IsNotExist(err) {
+		return tableManifest{version: constants.NomsVersion}, nil
+	} else if err != nil {
+		return tableManifest{}, err
+	}
+	return parseTableManifest(data, dir)
+}
+
+// parseTableManifest parses the manifest of the store at where.
+func parseTableManifest(data []byte, where string) (tableManifest, error) {
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) < 2 {
+		return tableManifest{}, fmt.Errorf("Invalid manifest in %s", where)
+	}
+	m := tableManifest{version: lines[0], tables: lines[2:]}
+	if lines[1] != "" {
+		var ok bool
+		if m.root, ok = hash.MaybeParse(lines[1]); !ok {
+			return tableManifest{}, fmt.Errorf("Invalid root in manifest in %s: %s", where, lines[1])
+		}
+	}
+	return m, nil
+}
+
+func (m tableManifest) bytes() []byte {
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, m.version)
+	if !m.root.IsEmpty() {
+		fmt.Fprint(buf, m.root.String())
+	}
+	fmt.Fprintln(buf)
+	for _, t := range m.tables {
+		fmt.Fprintln(buf, t)
+	}
+	return buf.Bytes()
+}
+
+// write replaces the manifest in dir with m. The new manifest is synced to a temporary file, which is then renamed over the old one.
+func (m tableManifest) write(dir string) error {
+	f, err := ioutil.TempFile(dir, tableManifestName+"-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(m.bytes())
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(dir, tableManifestName))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+type chunkSlice []Chunk
+
+func (cs chunkSlice) Len() int      { return len(cs) }
+func (cs chunkSlice) Swap(i, j int) { cs[i], cs[j] = cs[j], cs[i] }
+func (cs chunkSlice) Less(i, j int) bool {
+	return cs[i].Hash().Less(cs[j].Hash())
+}
+
+func (cs chunkSlice) sort() {
+	sort.Sort(cs)
+}
+
+// NewTableStoreFactory returns a Factory that creates TableStores in subdirectories of dir named after their namespaces.
+func NewTableStoreFactory(dir string) Factory {
+	return &TableStoreFactory{dir, false}
+}
+
+type TableStoreFactory struct {
+	dir       string
+	shuttered bool
+}
+
+func (f *TableStoreFactory) CreateStore(ns string) ChunkStore {
+	d.Chk.False(f.shuttered, "Cannot use TableStoreFactory after Shutter().")
+	return NewTableStore(f.dir, ns)
+}
+
+func (f *TableStoreFactory) Shutter() {
+	f.shuttered = true
+}
@@ -0,0 +1,238 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package chunks
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/testify/assert"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestTableStoreTestSuite(t *testing.T) {
+	suite.Run(t, &TableStoreTestSuite{})
+}
+
+type TableStoreTestSuite struct {
+	ChunkStoreTestSuite
+	factory Factory
+	dir     string
+}
+
+func (suite *TableStoreTestSuite) SetupTest() {
+	var err error
+	suite.dir, err = ioutil.TempDir(os.TempDir(), "")
+	suite.NoError(err)
+	suite.factory = NewTableStoreFactory(suite.dir)
+	store := suite.factory.CreateStore("name").(*TableStore)
+	suite.putCountFn = func() int {
+		return int(store.putCount)
+	}
+
+	suite.Store = store
+}
+
+func (suite *TableStoreTestSuite) TearDownTest() {
+	suite.Store.Close()
+	suite.factory.Shutter()
+	os.RemoveAll(suite.dir)
+}
+
+func (suite *TableStoreTestSuite) TestReopen() {
+	c1, c2 := NewChunk([]byte("abc")), NewChunk([]byte("def"))
+	suite.Store.Put(c1)
+	suite.True(suite.Store.UpdateRoot(c1.Hash(), suite.Store.Root()))
+	suite.Store.Put(c2)
+	suite.Store.Close()
+
+	store := suite.factory.CreateStore("name")
+	defer store.Close()
+	suite.Equal(c1.Hash(), store.Root())
+	assertInputInStore("abc", c1.Hash(), store, suite.Assert())
+	// Chunks that are Put are written out on Close(), even without a root update.
+	assertInputInStore("def", c2.Hash(), store, suite.Assert())
+	suite.False(store.Has(hash.Parse("11111111111111111111111111111111")))
+}
+
+func (suite *TableStoreTestSuite) TestNamespaces() {
+	other := suite.factory.CreateStore("other")
+	defer other.Close()
+
+	c := NewChunk([]byte("abc"))
+	suite.Store.Put(c)
+	suite.True(suite.Store.UpdateRoot(c.Hash(), hash.Hash{}))
+	suite.False(other.Has(c.Hash()))
+	suite.True(other.Root().IsEmpty())
+}
+
+func (suite *TableStoreTestSuite) TestUpdateRootSeesOtherStores() {
+	other := NewTableStore(suite.dir, "name")
+	defer other.Close()
+
+	c1, c2 := NewChunk([]byte("abc")), NewChunk([]byte("def"))
+	other.Put(c1)
+	suite.True(other.UpdateRoot(c1.Hash(), hash.Hash{}))
+
+	// suite.Store still thinks the root is empty, so its update must fail...
+	suite.Store.Put(c2)
+	suite.False(suite.Store.UpdateRoot(c2.Hash(), hash.Hash{}))
+	// ...after which it knows about the new root and the chunks behind it.
+	suite.Equal(c1.Hash(), suite.Store.Root())
+	suite.True(suite.Store.Has(c1.Hash()))
+	suite.True(suite.Store.UpdateRoot(c2.Hash(), c1.Hash()))
+
+	suite.False(other.UpdateRoot(c1.Hash(), c1.Hash()))
+	assertInputInStore("def", c2.Hash(), other, suite.Assert())
+}
+
+func (suite *TableStoreTestSuite) TestMergeTables() {
+	store := suite.Store.(*TableStore)
+	root := hash.Hash{}
+	hashes := hash.HashSlice{}
+	for i := 0; i <= tableMaxTables; i++ {
+		c := NewChunk([]byte(fmt.Sprintf("chunk %d", i)))
+		hashes = append(hashes, c.Hash())
+		store.Put(c)
+		suite.True(store.UpdateRoot(c.Hash(), root))
+		root = c.Hash()
+	}
+	suite.Len(store.tables, 1)
+
+	files, err := filepath.Glob(filepath.Join(suite.dir, "name", "*"+tableFileExt))
+	suite.NoError(err)
+	suite.Len(files, 1)
+	for i, h := range hashes {
+		assertInputInStore(fmt.Sprintf("chunk %d", i), h, store, suite.Assert())
+	}
+}
+
+func (suite *TableStoreTestSuite) TestMergeTablesOfSimilarSize() {
+	store := suite.Store.(*TableStore)
+	root := hash.Hash{}
+	commit := func(chunks ...Chunk) {
+		store.PutMany(chunks)
+		suite.True(store.UpdateRoot(chunks[0].Hash(), root))
+		root = chunks[0].Hash()
+	}
+	big := []Chunk{}
+	for i := 0; i < 1000; i++ {
+		big = append(big, NewChunk([]byte(fmt.Sprintf("big chunk %d", i))))
+	}
+	commit(big...)
+	bigTable := store.tables[0]
+
+	// Only the small tables are merged, rather than the big one being rewritten along with them.
+	for i := 0; i < tableMaxTables; i++ {
+		commit(NewChunk([]byte(fmt.Sprintf("small chunk %d", i))))
+	}
+	suite.Len(store.tables, 2)
+	suite.True(bigTable == store.tables[1])
+	assertInputInStore("big chunk 0", big[0].Hash(), store, suite.Assert())
+	assertInputInStore("small chunk 0", NewChunk([]byte("small chunk 0")).Hash(), store, suite.Assert())
+}
+
+func (suite *TableStoreTestSuite) TestMergeByOtherStore() {
+	store := suite.Store.(*TableStore)
+	c := NewChunk([]byte("chunk 0"))
+	store.Put(c)
+	suite.True(store.UpdateRoot(c.Hash(), hash.Hash{}))
+
+	// other merges away the table that store has open...
+	other := NewTableStore(suite.dir, "name")
+	defer other.Close()
+	root := c.Hash()
+	for i := 1; i <= tableMaxTables; i++ {
+		c := NewChunk([]byte(fmt.Sprintf("chunk %d", i)))
+		other.Put(c)
+		suite.True(other.UpdateRoot(c.Hash(), root))
+		root = c.Hash()
+	}
+	suite.Len(other.tables, 1)
+
+	// ...which store drops when it next reads the manifest, rather than listing it again.
+	last := NewChunk([]byte("last chunk"))
+	store.Put(last)
+	suite.True(store.UpdateRoot(last.Hash(), root))
+	suite.Len(store.tables, 2)
+	suite.Equal(other.tables[0].name, store.tables[1].name)
+	m, err := readTableManifest(filepath.Join(suite.dir, "name"))
+	suite.NoError(err)
+	for _, name := range m.tables {
+		_, err := os.Stat(filepath.Join(suite.dir, "name", name))
+		suite.NoError(err)
+	}
+	assertInputInStore("chunk 0", c.Hash(), store, suite.Assert())
+	suite.False(other.UpdateRoot(root, root))
+	assertInputInStore("last chunk", last.Hash(), other, suite.Assert())
+}
+
+func (suite *TableStoreTestSuite) TestReadDuringMerge() {
+	store := suite.Store.(*TableStore)
+	root := hash.Hash{}
+	c := NewChunk([]byte("chunk 0"))
+	for i := 0; i < tableMaxTables; i++ {
+		c := NewChunk([]byte(fmt.Sprintf("chunk %d", i)))
+		store.Put(c)
+		suite.True(store.UpdateRoot(c.Hash(), root))
+		root = c.Hash()
+	}
+
+	// A read that has picked its tables can finish from them after they're merged away.
+	_, _, tables := store.startRead(c.Hash())
+	last := NewChunk([]byte("last chunk"))
+	store.Put(last)
+	suite.True(store.UpdateRoot(last.Hash(), root))
+	suite.Len(store.tables, 1)
+	found, ok := tables[len(tables)-1].get(c.Hash())
+	suite.True(ok)
+	suite.Equal(c.Data(), found.Data())
+	suite.Len(store.retired, len(tables))
+	store.endRead(tables)
+	suite.Empty(store.retired)
+	suite.Empty(store.readers)
+}
+
+func TestTableFile(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	chunks := chunkSlice{}
+	for i := 0; i < 1000; i++ {
+		chunks = append(chunks, NewChunk([]byte(fmt.Sprintf("chunk %d", i))))
+	}
+	chunks.sort()
+	name, err := writeTable(dir, chunks)
+	assert.NoError(err)
+
+	tbl, err := openTable(dir, name)
+	assert.NoError(err)
+	defer tbl.close()
+	for _, c := range chunks {
+		read, ok := tbl.get(c.Hash())
+		assert.True(ok)
+		assert.Equal(c.Data(), read.Data())
+	}
+
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		h := hash.FromData([]byte(fmt.Sprintf("absent %d", i)))
+		assert.False(tbl.has(h))
+		if tbl.filter.mayContain(h) {
+			falsePositives++
+		}
+	}
+	assert.True(falsePositives < 50, "%d false positives", falsePositives)
+
+	assert.NoError(ioutil.WriteFile(filepath.Join(dir, "bogus"+tableFileExt), []byte("not a table"), 0600))
+	_, err = openTable(dir, "bogus"+tableFileExt)
+	assert.Error(err)
+}
@@ -6,7 +6,10 @@ package constants
 
 const (
 	RootPath       = "/root/"
+	WatchRootPath  = "/watch/"
 	GetRefsPath    = "/getRefs/"
 	HasRefsPath    = "/hasRefs/"
 	WriteValuePath = "/writeValue/"
+	ValuePath      = "/value/"
+	MetricsPath    = "/metrics"
 )
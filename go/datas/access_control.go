@@ -0,0 +1,235 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// AccessControl decides which datasets the clients of a server may read and write, according to the bearer tokens they send, either in an Authorization header or as an access_token query parameter. It's read from a JSON config such as:
+//
+//	{
+//	  "tokens": {
+//	    "s3cr3t": {"read": ["*"], "write": ["photos", "photos/*"]}
+//	  },
+//	  "anonymous": {"read": ["public/*"]}
+//	}
+//
+// Each entry in "read" and "write" is either a dataset ID, or a prefix of one followed by *. Permission to write a dataset includes permission to read it. The record of the commits that a database is missing (see Database.MissingParents) is shared by all datasets, so only tokens that can write "*", or are granted "$missing" explicitly, can change it. "anonymous" applies to requests without a token; requests with a token that isn't listed are refused.
+//
+// Writes are checked dataset by dataset, by comparing the root a client proposes with the one it replaces. Reads can't be, since chunks are fetched by hash without saying which dataset they belong to, so a client that can read any dataset can read every chunk in the database.
+type AccessControl struct {
+	Tokens    map[string]Grant `json:"tokens"`
+	Anonymous Grant            `json:"anonymous"`
+}
+
+// Grant lists the datasets that a token can read and write.
+type Grant struct {
+	Read  []string `json:"read"`
+	Write []string `json:"write"`
+}
+
+// ReadAccessControl reads an AccessControl config from r.
+func ReadAccessControl(r io.Reader) (*AccessControl, error) {
+	ac := &AccessControl{}
+	if err := json.NewDecoder(r).Decode(ac); err != nil {
+		return nil, fmt.Errorf("Invalid access control config: %s", err)
+	}
+	return ac, nil
+}
+
+// LoadAccessControl reads an AccessControl config from the file at path.
+func LoadAccessControl(path string) (*AccessControl, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadAccessControl(f)
+}
+
+// CanRead returns whether token can read the dataset datasetID.
+func (ac *AccessControl) CanRead(token, datasetID string) bool {
+	g, ok := ac.grant(token)
+	return ok && (matchesAny(g.Read, datasetID) || matchesAny(g.Write, datasetID))
+}
+
+// CanWrite returns whether token can write the dataset datasetID.
+func (ac *AccessControl) CanWrite(token, datasetID string) bool {
+	g, ok := ac.grant(token)
+	return ok && matchesAny(g.Write, datasetID)
+}
+
+func (ac *AccessControl) grant(token string) (g Grant, ok bool) {
+	if token == "" {
+		return ac.Anonymous, true
+	}
+	g, ok = ac.Tokens[token]
+	return
+}
+
+func matchesAny(patterns []string, datasetID string) bool {
+	for _, p := range patterns {
+		if p == datasetID || strings.HasSuffix(p, "*") && strings.HasPrefix(datasetID, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadHandler wraps hndlr, which serves chunks or the root, so that it's only called for clients that can read at least one dataset. If ac is nil, every client can.
+func (ac *AccessControl) ReadHandler(hndlr Handler) Handler {
+	if ac == nil {
+		return hndlr
+	}
+	return func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+		token := requestToken(req)
+		if g, ok := ac.grant(token); !ok || len(g.Read) == 0 && len(g.Write) == 0 {
+			ac.refuse(w, token)
+			return
+		}
+		hndlr(w, req, ps, cs)
+	}
+}
+
+// ValueReadHandler wraps hndlr, which serves values at the AbsolutePath in its "path" URL param (see spec.HandleValue), so that it's only called if the client can read the dataset that the path starts at. Paths that start at a hash are like chunks: clients that can read any dataset can read them. If ac is nil, every client can read everything.
+func (ac *AccessControl) ValueReadHandler(hndlr Handler) Handler {
+	if ac == nil {
+		return hndlr
+	}
+	readHandler := ac.ReadHandler(hndlr)
+	return func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+		id := datasetPrefixRe.FindString(strings.TrimPrefix(ps.ByName("path"), "/"))
+		if id == "" {
+			readHandler(w, req, ps, cs)
+			return
+		}
+		if token := requestToken(req); !ac.CanRead(token, id) {
+			ac.refuse(w, token)
+			return
+		}
+		hndlr(w, req, ps, cs)
+	}
+}
+
+// WriteHandler wraps hndlr, which writes chunks, so that it's only called for clients that can write at least one dataset. Chunks that aren't reachable from a dataset are harmless, so which datasets are written is only checked when the root is updated. If ac is nil, every client can write.
+func (ac *AccessControl) WriteHandler(hndlr Handler) Handler {
+	if ac == nil {
+		return hndlr
+	}
+	return func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+		token := requestToken(req)
+		if g, ok := ac.grant(token); !ok || len(g.Write) == 0 {
+			ac.refuse(w, token)
+			return
+		}
+		hndlr(w, req, ps, cs)
+	}
+}
+
+// RootPostHandler wraps hndlr, which is HandleRootPost or something like it, so that it's only called if the client can write every dataset whose head the new root changes, adds or removes, along with the record of missing parents if it changes that. If ac is nil, every client can write every dataset.
+func (ac *AccessControl) RootPostHandler(hndlr Handler) Handler {
+	if ac == nil {
+		return hndlr
+	}
+	return func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+		token := requestToken(req)
+		if g, ok := ac.grant(token); !ok || len(g.Write) == 0 {
+			ac.refuse(w, token)
+			return
+		}
+
+		var changed []string
+		err := d.Try(func() {
+			params := req.URL.Query()
+			changed = changedDatasets(cs, hash.Parse(params.Get("last")), hash.Parse(params.Get("current")))
+		})
+		if err != nil {
+			w.Header().Set(NomsVersionHeader, constants.NomsVersion)
+			http.Error(w, fmt.Sprintf("Error: %v", err), http.StatusBadRequest)
+			return
+		}
+		for _, id := range changed {
+			// missingParentsKey isn't a valid dataset ID, so only a grant of "*", or of missingParentsKey itself, matches it.
+			if !ac.CanWrite(token, id) {
+				w.Header().Set(NomsVersionHeader, constants.NomsVersion)
+				if id == missingParentsKey {
+					http.Error(w, "Not allowed to write the record of missing parents", http.StatusForbidden)
+				} else {
+					http.Error(w, fmt.Sprintf("Not allowed to write dataset %s", id), http.StatusForbidden)
+				}
+				return
+			}
+		}
+		hndlr(w, req, ps, cs)
+	}
+}
+
+// changedDatasets returns the IDs of the datasets whose heads differ between the roots last and current in cs.
+func changedDatasets(cs chunks.ChunkStore, last, current hash.Hash) (changed []string) {
+	readRoot := func(h hash.Hash) types.Map {
+		if h.IsEmpty() {
+			return types.NewMap()
+		}
+		c := cs.Get(h)
+		d.PanicIfTrue(c.IsEmpty(), "Root %s is not present", h)
+		m, ok := types.DecodeValue(c, nil).(types.Map)
+		d.PanicIfTrue(!ok, "Root of a Database must be a Map")
+		return m
+	}
+	lastRoot, currentRoot := readRoot(last), readRoot(current)
+
+	currentRoot.IterAll(func(k, v types.Value) {
+		if old, ok := lastRoot.MaybeGet(k); !ok || !old.Equals(v) {
+			changed = append(changed, string(k.(types.String)))
+		}
+	})
+	lastRoot.IterAll(func(k, v types.Value) {
+		if !currentRoot.Has(k) {
+			changed = append(changed, string(k.(types.String)))
+		}
+	})
+	return
+}
+
+// datasetPrefixRe matches the dataset ID at the start of an AbsolutePath. It's dataset.DatasetRe, which can't be imported here.
+var datasetPrefixRe = regexp.MustCompile(`^[a-zA-Z0-9\-_/]+`)
+
+// requestToken returns the bearer token sent with req, or "" if there isn't one.
+func requestToken(req *http.Request) string {
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		if token := strings.TrimSpace(strings.TrimPrefix(auth, "Bearer ")); token != "" {
+			return token
+		}
+	}
+	return req.URL.Query().Get("access_token")
+}
+
+// refuse responds to a request that token doesn't allow. If the token is missing or unknown, the client is asked to authenticate; otherwise it's simply forbidden.
+func (ac *AccessControl) refuse(w http.ResponseWriter, token string) {
+	w.Header().Set(NomsVersionHeader, constants.NomsVersion)
+	if _, ok := ac.Tokens[token]; ok {
+		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		return
+	}
+	if token == "" {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="Restricted"`)
+	} else {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="Restricted", error="invalid_token"`)
+	}
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
@@ -0,0 +1,186 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+const testAccessConfig = `{
+	"tokens": {
+		"writer": {"read": ["*"], "write": ["photos", "photos/*"]},
+		"admin": {"write": ["*"]},
+		"reader": {"read": ["public/*"]},
+		"nobody": {}
+	},
+	"anonymous": {"read": ["public/*"]}
+}`
+
+func newTestAccessControl(assert *assert.Assertions) *AccessControl {
+	ac, err := ReadAccessControl(strings.NewReader(testAccessConfig))
+	assert.NoError(err)
+	return ac
+}
+
+func TestAccessControlPermissions(t *testing.T) {
+	assert := assert.New(t)
+	ac := newTestAccessControl(assert)
+
+	assert.True(ac.CanRead("writer", "anything"))
+	assert.True(ac.CanWrite("writer", "photos"))
+	assert.True(ac.CanWrite("writer", "photos/2016"))
+	assert.False(ac.CanWrite("writer", "photoshop"))
+
+	assert.True(ac.CanRead("reader", "public/films"))
+	assert.False(ac.CanRead("reader", "photos"))
+	assert.False(ac.CanWrite("reader", "public/films"))
+
+	assert.True(ac.CanRead("", "public/films"))
+	assert.False(ac.CanWrite("", "public/films"))
+	assert.False(ac.CanRead("unknown", "public/films"))
+
+	_, err := ReadAccessControl(strings.NewReader("{"))
+	assert.Error(err)
+}
+
+func TestAccessControlReadHandler(t *testing.T) {
+	assert := assert.New(t)
+	ac := newTestAccessControl(assert)
+	cs := chunks.NewTestStore()
+	handler := ac.ReadHandler(HandleRootGet)
+
+	get := func(auth string) int {
+		w := httptest.NewRecorder()
+		handler(w, newRequest("GET", auth, "", nil, nil), params{}, cs)
+		return w.Code
+	}
+	assert.Equal(http.StatusOK, get(""))
+	assert.Equal(http.StatusOK, get("Bearer reader"))
+	assert.Equal(http.StatusUnauthorized, get("Bearer unknown"))
+	assert.Equal(http.StatusForbidden, get("Bearer nobody"))
+
+	// Without an AccessControl, anything goes.
+	w := httptest.NewRecorder()
+	(*AccessControl)(nil).ReadHandler(HandleRootGet)(w, newRequest("GET", "Bearer unknown", "", nil, nil), params{}, cs)
+	assert.Equal(http.StatusOK, w.Code)
+}
+
+func TestAccessControlValueReadHandler(t *testing.T) {
+	assert := assert.New(t)
+	ac := newTestAccessControl(assert)
+	handler := ac.ValueReadHandler(func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {})
+
+	get := func(auth, path string) int {
+		w := httptest.NewRecorder()
+		handler(w, newRequest("GET", auth, "", nil, nil), params{"path": path}, nil)
+		return w.Code
+	}
+	assert.Equal(http.StatusOK, get("", "/public/films.value[0]"))
+	assert.Equal(http.StatusUnauthorized, get("", "/photos.value"))
+	assert.Equal(http.StatusForbidden, get("Bearer reader", "/photos"))
+	assert.Equal(http.StatusOK, get("Bearer writer", "/photos/2016.value"))
+
+	// Values addressed by hash can be read by anyone who can read something.
+	h := "/#" + hash.FromData([]byte("x")).String()
+	assert.Equal(http.StatusOK, get("Bearer reader", h))
+	assert.Equal(http.StatusForbidden, get("Bearer nobody", h))
+}
+
+func TestAccessControlRootPostHandler(t *testing.T) {
+	assert := assert.New(t)
+	ac := newTestAccessControl(assert)
+	cs := chunks.NewTestStore()
+	vs := types.NewValueStore(types.NewBatchStoreAdaptor(cs))
+	handler := ac.RootPostHandler(HandleRootPost)
+
+	commit := NewCommit(types.String("head"), types.NewSet(), types.EmptyStruct)
+	commitRef := vs.WriteValue(commit)
+	writeRoot := func(m types.Map) hash.Hash {
+		c := types.EncodeValue(m, nil)
+		cs.Put(c)
+		return c.Hash()
+	}
+	last := writeRoot(types.NewMap(types.String("photos"), commitRef, types.String("public/films"), commitRef))
+	assert.True(cs.UpdateRoot(last, hash.Hash{}))
+
+	post := func(auth string, current hash.Hash) int {
+		q := url.Values{}
+		q.Add("last", cs.Root().String())
+		q.Add("current", current.String())
+		w := httptest.NewRecorder()
+		handler(w, newRequest("POST", auth, "?"+q.Encode(), nil, nil), params{}, cs)
+		return w.Code
+	}
+
+	// Adding, changing or removing a dataset is a write to it.
+	other := vs.WriteValue(NewCommit(types.String("other"), types.NewSet(), types.EmptyStruct))
+	addsPublic := writeRoot(types.NewMap(types.String("photos"), commitRef, types.String("public/films"), commitRef, types.String("public/other"), other))
+	changesPublic := writeRoot(types.NewMap(types.String("photos"), commitRef, types.String("public/films"), other))
+	removesPublic := writeRoot(types.NewMap(types.String("photos"), commitRef))
+	for _, current := range []hash.Hash{addsPublic, changesPublic, removesPublic} {
+		assert.Equal(http.StatusForbidden, post("Bearer writer", current))
+		assert.Equal(cs.Root(), last)
+	}
+	assert.Equal(http.StatusUnauthorized, post("", changesPublic))
+	assert.Equal(http.StatusForbidden, post("Bearer reader", changesPublic))
+
+	// Datasets that aren't touched don't need to be writable.
+	changesPhotos := writeRoot(types.NewMap(types.String("photos"), other, types.String("public/films"), commitRef))
+	assert.Equal(http.StatusOK, post("Bearer writer", changesPhotos))
+	assert.Equal(changesPhotos, cs.Root())
+
+	// The record of missing parents is shared by all datasets, so it can only be changed by those who can write them all.
+	record := vs.WriteValue(newMissingParentsRecord(hash.HashSet{hash.FromData([]byte("parent")): struct{}{}}))
+	addsMissing := writeRoot(types.NewMap(types.String("photos"), other, types.String("public/films"), commitRef, types.String(missingParentsKey), record))
+	assert.Equal(http.StatusForbidden, post("Bearer writer", addsMissing))
+	assert.Equal(changesPhotos, cs.Root())
+	assert.Equal(http.StatusOK, post("Bearer admin", addsMissing))
+	assert.Equal(addsMissing, cs.Root())
+
+	// Nor can it be replaced by something that isn't a record of missing parents.
+	bogus := vs.WriteValue(NewCommit(types.NewSet(types.Number(42)), types.NewSet(), types.EmptyStruct))
+	bogusMissing := writeRoot(types.NewMap(types.String("photos"), other, types.String("public/films"), commitRef, types.String(missingParentsKey), bogus))
+	assert.Equal(http.StatusBadRequest, post("Bearer admin", bogusMissing))
+	assert.Equal(addsMissing, cs.Root())
+}
+
+func TestAccessControlRequestToken(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("abc", requestToken(newRequest("GET", "Bearer abc", "", nil, nil)))
+	assert.Equal("abc", requestToken(newRequest("GET", "", "?access_token=abc", nil, nil)))
+	assert.Equal("abc", requestToken(newRequest("GET", "Bearer ", "?access_token=abc", nil, nil)))
+	assert.Equal("", requestToken(newRequest("GET", "Bearer ", "", nil, nil)))
+}
+
+func TestAccessControlServer(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewTestStore()
+	server := NewRemoteDatabaseServer(cs, 0)
+	server.AccessControl = newTestAccessControl(assert)
+	ts := httptest.NewServer(server.newHTTPServer().Handler)
+	defer ts.Close()
+
+	var writer Database = NewRemoteDatabase(ts.URL, "Bearer writer")
+	writer, err := writer.Commit("photos", NewCommit(types.String("photo"), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	err = d.Try(func() {
+		writer.Commit("public/films", NewCommit(types.String("film"), types.NewSet(), types.EmptyStruct))
+	})
+	assert.Contains(err.Error(), "Not allowed to write dataset public/films")
+
+	reader := NewRemoteDatabase(ts.URL, "Bearer reader")
+	assert.Equal(uint64(1), reader.Datasets().Len())
+	assert.Error(d.Try(func() { NewRemoteDatabase(ts.URL, "Bearer unknown") }))
+}
@@ -0,0 +1,66 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"sync"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/walk"
+)
+
+// WriteBundle writes a bundle to path holding a database in which datasetID has head as its head, along with every chunk reachable from head in db. head must be a Commit. It doesn't need to have been written to db, but the chunks it refers to must be in db. Returns the number of chunks written. A bundle can be read with chunks.NewBundleStore.
+func WriteBundle(db Database, datasetID string, head types.Struct, path string, concurrency int) (count int, err error) {
+	d.PanicIfTrue(!IsCommitType(head.Type()), "Can't bundle a non-Commit struct as dataset %s", datasetID)
+
+	bw, err := chunks.NewBundleWriter(path)
+	if err != nil {
+		return 0, err
+	}
+	err = d.Try(func() {
+		bs := db.validatingBatchStore()
+		mu := sync.Mutex{}
+		added := hash.HashSet{}
+		dbMissing, missing := missingParentsOf(db), hash.HashSet{}
+
+		// head and the root map are added directly, since head may not have been written to db.
+		d.PanicIfError(bw.Add(types.EncodeValue(head, nil)))
+		for _, r := range head.Chunks() {
+			walk.SomeChunksP(r, bs, func(r types.Ref) bool {
+				mu.Lock()
+				defer mu.Unlock()
+				if dbMissing.Has(r.TargetHash()) {
+					missing.Insert(r.TargetHash())
+					return true
+				}
+				if added.Has(r.TargetHash()) {
+					return true
+				}
+				added.Insert(r.TargetHash())
+				return false
+			}, func(r types.Ref, c chunks.Chunk) {
+				d.PanicIfError(bw.Add(c))
+			}, concurrency)
+		}
+
+		// If db is missing some of the history of head, so is the bundle.
+		datasets := types.NewMap(types.String(datasetID), types.NewRef(head))
+		if len(missing) > 0 {
+			record := newMissingParentsRecord(missing)
+			d.PanicIfError(bw.Add(types.EncodeValue(record, nil)))
+			datasets = datasets.Set(types.String(missingParentsKey), types.NewRef(record))
+		}
+		d.PanicIfError(bw.Add(types.EncodeValue(datasets, nil)))
+		d.PanicIfError(bw.Finish(datasets.Hash()))
+	})
+	if err != nil {
+		bw.Abort()
+		return 0, d.Unwrap(err)
+	}
+	return bw.Count(), nil
+}
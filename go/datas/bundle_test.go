@@ -0,0 +1,54 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestWriteBundle(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.nbundle")
+
+	db := NewDatabase(chunks.NewMemoryStore())
+	defer db.Close()
+	l := types.NewList()
+	for i := 0; i < 10000; i++ {
+		l = l.Append(types.Number(i))
+	}
+	db, err = db.Commit("ds", NewCommit(db.WriteValue(l), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	first := db.HeadRef("ds")
+	db, err = db.Commit("ds", NewCommit(types.String("second"), types.NewSet(first), types.EmptyStruct))
+	assert.NoError(err)
+	db, err = db.Commit("other", NewCommit(types.String("other"), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+
+	count, err := WriteBundle(db, "bundled", db.Head("ds"), path, 4)
+	assert.NoError(err)
+	assert.True(count > 3)
+
+	bundle := NewDatabase(chunks.NewBundleStore(path))
+	defer bundle.Close()
+	assert.Equal(1, int(bundle.Datasets().Len()))
+	assert.True(db.Head("ds").Equals(bundle.Head("bundled")))
+	// The history of the head, and the values it refers to, are bundled too.
+	firstCommit := bundle.ReadValue(first.TargetHash()).(types.Struct)
+	assert.True(l.Equals(firstCommit.Get(ValueField).(types.Ref).TargetValue(bundle)))
+
+	assert.Panics(func() {
+		WriteBundle(db, "ds", types.NewStruct("NotACommit", nil), path, 4)
+	})
+}
@@ -0,0 +1,102 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"time"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// The fields of the standard commit meta struct. See CommitMeta.
+const (
+	MetaStructName   = "Meta"
+	MetaAuthorField  = "author"
+	MetaDateField    = "date"
+	MetaMessageField = "message"
+)
+
+// MetaDateFormat is the ISO 8601 format dates are stored in. Dates are always stored in UTC.
+const MetaDateFormat = time.RFC3339
+
+// legacyMetaDateFormat is the format older importers stored dates in.
+const legacyMetaDateFormat = "2006-01-02T15:04:05-0700"
+
+// CommitMeta is the standard metadata of a commit. It is stored in the meta field of the commit as:
+//
+//	struct Meta {
+//	  author: String,
+//	  date: String,
+//	  message: String,
+//	  ...
+//	}
+//
+// where date is formatted according to MetaDateFormat, and any other fields come from Extra. Empty or zero fields are left out.
+type CommitMeta struct {
+	Author  string
+	Date    time.Time
+	Message string
+	// Extra holds any other metadata, such as where the committed value was imported from. The standard fields take precedence over entries of the same name.
+	Extra types.StructData
+}
+
+// Struct returns the meta struct for m.
+func (m CommitMeta) Struct() types.Struct {
+	data := types.StructData{}
+	for k, v := range m.Extra {
+		data[k] = v
+	}
+	if m.Author != "" {
+		data[MetaAuthorField] = types.String(m.Author)
+	}
+	if !m.Date.IsZero() {
+		data[MetaDateField] = types.String(m.Date.UTC().Format(MetaDateFormat))
+	}
+	if m.Message != "" {
+		data[MetaMessageField] = types.String(m.Message)
+	}
+	return types.NewStruct(MetaStructName, data)
+}
+
+// CommitMetaFromStruct reads the standard metadata from the meta struct of a commit. Standard fields that are missing or are not Strings are left empty, as are dates that can't be parsed. All other fields are returned in Extra.
+func CommitMetaFromStruct(meta types.Struct) CommitMeta {
+	m := CommitMeta{Extra: types.StructData{}}
+	meta.Type().Desc.(types.StructDesc).IterFields(func(name string, t *types.Type) {
+		v := meta.Get(name)
+		s, isString := v.(types.String)
+		switch {
+		case name == MetaAuthorField && isString:
+			m.Author = string(s)
+		case name == MetaDateField && isString:
+			m.Date, _ = ParseMetaDate(string(s))
+		case name == MetaMessageField && isString:
+			m.Message = string(s)
+		default:
+			m.Extra[name] = v
+		}
+	})
+	return m
+}
+
+// GetCommitMeta returns the standard metadata of commit.
+func GetCommitMeta(commit types.Struct) CommitMeta {
+	if meta, ok := commit.MaybeGet(MetaField); ok {
+		if meta, ok := meta.(types.Struct); ok {
+			return CommitMetaFromStruct(meta)
+		}
+	}
+	return CommitMeta{Extra: types.StructData{}}
+}
+
+// ParseMetaDate parses a date in MetaDateFormat. For compatibility with older commits it also accepts dates with numeric zone offsets without a colon, such as 2016-09-01T12:00:00-0700.
+func ParseMetaDate(str string) (time.Time, error) {
+	t, err := time.Parse(MetaDateFormat, str)
+	if err != nil {
+		if t2, err2 := time.Parse(legacyMetaDateFormat, str); err2 == nil {
+			return t2, nil
+		}
+	}
+	return t, err
+}
@@ -0,0 +1,78 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestCommitMetaStruct(t *testing.T) {
+	assert := assert.New(t)
+
+	date := time.Date(2016, 9, 1, 12, 30, 0, 0, time.FixedZone("PDT", -7*60*60))
+	m := CommitMeta{
+		Author:  "alice@example.com",
+		Date:    date,
+		Message: "Initial import",
+		Extra:   types.StructData{"file": types.String("data.csv")},
+	}
+	st := m.Struct()
+	assert.True(types.NewStruct(MetaStructName, types.StructData{
+		MetaAuthorField:  types.String("alice@example.com"),
+		MetaDateField:    types.String("2016-09-01T19:30:00Z"),
+		MetaMessageField: types.String("Initial import"),
+		"file":           types.String("data.csv"),
+	}).Equals(st))
+
+	m2 := CommitMetaFromStruct(st)
+	assert.Equal(m.Author, m2.Author)
+	assert.True(date.Equal(m2.Date))
+	assert.Equal(m.Message, m2.Message)
+	assert.Equal(m.Extra, m2.Extra)
+
+	// Empty fields are left out.
+	assert.True(types.NewStruct(MetaStructName, types.StructData{
+		MetaMessageField: types.String("msg"),
+	}).Equals(CommitMeta{Message: "msg"}.Struct()))
+}
+
+func TestGetCommitMeta(t *testing.T) {
+	assert := assert.New(t)
+
+	legacy := types.NewStruct("Meta", types.StructData{
+		"date":    types.String("2016-09-01T12:30:00-0700"),
+		"comment": types.String("old style"),
+	})
+	m := GetCommitMeta(NewCommit(types.Number(1), types.NewSet(), legacy))
+	assert.True(time.Date(2016, 9, 1, 19, 30, 0, 0, time.UTC).Equal(m.Date))
+	assert.Equal("", m.Message)
+	assert.Equal(types.StructData{"comment": types.String("old style")}, m.Extra)
+
+	m = GetCommitMeta(NewCommit(types.Number(1), types.NewSet(), types.EmptyStruct))
+	assert.Equal(CommitMeta{Extra: types.StructData{}}, m)
+
+	m = CommitMetaFromStruct(types.NewStruct("Meta", types.StructData{"author": types.Number(42)}))
+	assert.Equal("", m.Author)
+	assert.Equal(types.StructData{"author": types.Number(42)}, m.Extra)
+}
+
+func TestParseMetaDate(t *testing.T) {
+	assert := assert.New(t)
+
+	d, err := ParseMetaDate("2016-09-01T12:30:00Z")
+	assert.NoError(err)
+	assert.True(time.Date(2016, 9, 1, 12, 30, 0, 0, time.UTC).Equal(d))
+
+	d, err = ParseMetaDate("2016-09-01T12:30:00+02:00")
+	assert.NoError(err)
+	assert.True(time.Date(2016, 9, 1, 10, 30, 0, 0, time.UTC).Equal(d))
+
+	_, err = ParseMetaDate("yesterday")
+	assert.Error(err)
+}
@@ -34,17 +34,24 @@ type Database interface {
 	// Datasets returns the root of the database which is a MapOfStringToRefOfCommit where string is a datasetID.
 	Datasets() types.Map
 
+	// MissingParents returns the hashes of the commits that this Database deliberately doesn't have, because only the most recent part of the history of their children was pulled into it, e.g. by PullShallow. Reading them returns nil. Unlike Datasets(), it reflects the current state of the Database, since pulls update it. If the record of them is malformed, it returns ErrMalformedMissingParents.
+	MissingParents() (hash.HashSet, error)
+
 	// Commit updates the Commit that datasetID in this database points at. All Values that have been written to this Database are guaranteed to be persistent after Commit(). If the update cannot be performed, e.g., because of a conflict, error will be non-nil. The newest snapshot of the database is always returned.
 	Commit(datasetID string, commit types.Struct) (Database, error)
 
-	// Delete removes the Dataset named datasetID from the map at the root of the Database. The Dataset data is not necessarily cleaned up at this time, but may be garbage collected in the future. If the update cannot be performed, e.g., because of a conflict, error will non-nil. The newest snapshot of the database is always returned.
+	// Delete removes the Dataset named datasetID from the map at the root of the Database. The Dataset data is not necessarily cleaned up at this time, but can be reclaimed later by CollectGarbage(). If the update cannot be performed, e.g., because of a conflict, error will non-nil. The newest snapshot of the database is always returned.
 	Delete(datasetID string) (Database, error)
 
 	// SetHead sets the Commit that datasetID in this database points at. All Values that have been written to this Database are guaranteed to be persistent after SetHead(). If the update cannot be performed, e.g., because of a conflict, error will be non-nil. The newest snapshot of the database is always returned.
 	SetHead(datasetID string, commit types.Struct) (Database, error)
 
+	// Watch returns a channel on which the ref of the head Commit of datasetID is sent, if it has one, and then each new head as datasetID is committed to, by this process or any other. The channel is closed when the Database is closed.
+	Watch(datasetID string) <-chan types.Ref
+
 	has(hash hash.Hash) bool
 	validatingBatchStore() types.BatchStore
+	updateMissingParents(add, remove hash.HashSet)
 }
 
 func NewDatabase(cs chunks.ChunkStore) Database {
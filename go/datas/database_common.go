@@ -6,6 +6,7 @@ package datas
 
 import (
 	"errors"
+	"sync"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
@@ -18,16 +19,40 @@ type databaseCommon struct {
 	vs       *types.ValueStore
 	rt       chunks.RootTracker
 	rootRef  hash.Hash
+	root     *types.Map
 	datasets *types.Map
+	closed   *closeSignal
 }
 
+// closeSignal is shared by all the snapshots of a Database, so that Watch() can tell when any of them is closed.
+type closeSignal struct {
+	once sync.Once
+	ch   chan struct{}
+}
+
+func (c *closeSignal) close() {
+	c.once.Do(func() { close(c.ch) })
+}
+
+// missingParentsKey is the key in the root map under which a Database records the commits that it deliberately doesn't have, because only part of the history of their children was pulled (see PullShallow). It isn't a valid dataset ID, so it can't clash with a dataset, and it's left out of Datasets(). Its value is a Commit whose value is a Set of the hashes of those commits, as Strings, since a Set of Refs would refer to chunks that aren't there.
+const missingParentsKey = "$missing"
+
 var (
 	ErrOptimisticLockFailed = errors.New("Optimistic lock failed on database Root update")
 	ErrMergeNeeded          = errors.New("Dataset head is not ancestor of commit")
+	// ErrMalformedMissingParents is returned if the entry under missingParentsKey isn't a record of missing parents, e.g. because it was written by something other than noms.
+	ErrMalformedMissingParents = errors.New("Record of missing parents is malformed")
 )
 
 func newDatabaseCommon(cch *cachingChunkHaver, vs *types.ValueStore, rt chunks.RootTracker) databaseCommon {
-	return databaseCommon{cch: cch, vs: vs, rt: rt, rootRef: rt.Root()}
+	return databaseCommon{cch: cch, vs: vs, rt: rt, rootRef: rt.Root(), closed: &closeSignal{ch: make(chan struct{})}}
+}
+
+// snapshot returns a databaseCommon that shares ds's stores, as of the current root.
+func (ds *databaseCommon) snapshot() databaseCommon {
+	s := newDatabaseCommon(ds.cch, ds.vs, ds.rt)
+	s.closed = ds.closed
+	return s
 }
 
 func (ds *databaseCommon) MaybeHead(datasetID string) (types.Struct, bool) {
@@ -58,15 +83,120 @@ func (ds *databaseCommon) HeadRef(datasetID string) types.Ref {
 
 func (ds *databaseCommon) Datasets() types.Map {
 	if ds.datasets == nil {
+		datasets := ds.rootMap()
+		if datasets.Has(types.String(missingParentsKey)) {
+			datasets = datasets.Remove(types.String(missingParentsKey))
+		}
+		ds.datasets = &datasets
+	}
+
+	return *ds.datasets
+}
+
+// rootMap returns the map at the root of the Database, which is Datasets() along with any bookkeeping entries, such as the one under missingParentsKey.
+func (ds *databaseCommon) rootMap() types.Map {
+	if ds.root == nil {
 		if ds.rootRef.IsEmpty() {
 			emptyMap := types.NewMap()
-			ds.datasets = &emptyMap
+			ds.root = &emptyMap
 		} else {
-			ds.datasets = ds.datasetsFromRef(ds.rootRef)
+			ds.root = ds.datasetsFromRef(ds.rootRef)
 		}
 	}
 
-	return *ds.datasets
+	return *ds.root
+}
+
+func (ds *databaseCommon) MissingParents() (hash.HashSet, error) {
+	// Pulls update the record without making a new Database, so it's read from the current root.
+	_, currentRoot := ds.getRootAndDatasets()
+	return missingParentsIn(currentRoot, ds)
+}
+
+// missingParentsIn returns the hashes of the commits recorded as missing in root, the map at the root of a Database. If the entry under missingParentsKey isn't a record such as newMissingParentsRecord returns, it returns ErrMalformedMissingParents.
+func missingParentsIn(root types.Map, vr types.ValueReader) (hash.HashSet, error) {
+	missing := hash.HashSet{}
+	v, ok := root.MaybeGet(types.String(missingParentsKey))
+	if !ok {
+		return missing, nil
+	}
+	r, ok := v.(types.Ref)
+	if !ok {
+		return nil, ErrMalformedMissingParents
+	}
+	record, ok := r.TargetValue(vr).(types.Struct)
+	if !ok || !IsCommitType(record.Type()) {
+		return nil, ErrMalformedMissingParents
+	}
+	hashes, ok := record.Get(ValueField).(types.Set)
+	if !ok {
+		return nil, ErrMalformedMissingParents
+	}
+	hashes.Iter(func(v types.Value) (stop bool) {
+		var h hash.Hash
+		if s, isString := v.(types.String); isString {
+			h, ok = hash.MaybeParse(string(s))
+		} else {
+			ok = false
+		}
+		if ok {
+			missing.Insert(h)
+		}
+		return !ok
+	})
+	if !ok {
+		return nil, ErrMalformedMissingParents
+	}
+	return missing, nil
+}
+
+// newMissingParentsRecord returns the Commit to store under missingParentsKey to record the commits in missing.
+func newMissingParentsRecord(missing hash.HashSet) types.Struct {
+	hashes := make([]types.Value, 0, len(missing))
+	for h := range missing {
+		hashes = append(hashes, types.String(h.String()))
+	}
+	return NewCommit(types.NewSet(hashes...), types.NewSet(), types.EmptyStruct)
+}
+
+// updateMissingParents adds the commits in add to those recorded as missing, and removes the ones in remove, retrying if the root moves underneath it.
+func (ds *databaseCommon) updateMissingParents(add, remove hash.HashSet) {
+	for {
+		// The root map is read afresh, since pulling may have replaced the ValueStore that read it before, and writing it requires the new one to know about everything it refers to.
+		currentRootRef, currentRoot := ds.rt.Root(), types.NewMap()
+		if !currentRootRef.IsEmpty() {
+			currentRoot = *ds.datasetsFromRef(currentRootRef)
+		}
+		missing, err := missingParentsIn(currentRoot, ds)
+		d.PanicIfError(err)
+		changed := false
+		for h := range add {
+			if !missing.Has(h) {
+				missing.Insert(h)
+				changed = true
+			}
+		}
+		for h := range remove {
+			if missing.Has(h) {
+				missing.Remove(h)
+				changed = true
+			}
+		}
+		if !changed {
+			return
+		}
+
+		if len(missing) == 0 {
+			currentRoot = currentRoot.Remove(types.String(missingParentsKey))
+		} else {
+			record := newMissingParentsRecord(missing)
+			currentRoot = currentRoot.Set(types.String(missingParentsKey), ds.WriteValue(record))
+		}
+		if err := ds.tryUpdateRoot(currentRoot, currentRootRef); err != ErrOptimisticLockFailed {
+			d.PanicIfError(err)
+			return
+		}
+	}
 }
 
 func (ds *databaseCommon) datasetsFromRef(datasetsRef hash.Hash) *types.Map {
@@ -87,6 +217,7 @@ func (ds *databaseCommon) WriteValue(v types.Value) types.Ref {
 }
 
 func (ds *databaseCommon) Close() error {
+	ds.closed.close()
 	return ds.vs.Close()
 }
 
@@ -94,7 +225,11 @@ func (ds *databaseCommon) doSetHead(datasetID string, commit types.Struct) error
 	d.PanicIfTrue(!IsCommitType(commit.Type()), "Can't commit a non-Commit struct to dataset %s", datasetID)
 
 	currentRootRef, currentDatasets := ds.getRootAndDatasets()
-	commitRef := ds.WriteValue(commit) // will be orphaned if the tryUpdateRoot() below fails
+	missing, err := missingParentsIn(currentDatasets, ds)
+	if err != nil {
+		return err
+	}
+	commitRef := ds.writeCommit(commit, missing) // will be orphaned if the tryUpdateRoot() below fails
 
 	currentDatasets = currentDatasets.Set(types.String(datasetID), commitRef)
 	return ds.tryUpdateRoot(currentDatasets, currentRootRef)
@@ -105,7 +240,11 @@ func (ds *databaseCommon) doCommit(datasetID string, commit types.Struct) error
 	d.PanicIfTrue(!IsCommitType(commit.Type()), "Can't commit a non-Commit struct to dataset %s", datasetID)
 
 	currentRootRef, currentDatasets := ds.getRootAndDatasets()
-	commitRef := ds.WriteValue(commit) // will be orphaned if the tryUpdateRoot() below fails
+	missing, err := missingParentsIn(currentDatasets, ds)
+	if err != nil {
+		return err
+	}
+	commitRef := ds.writeCommit(commit, missing) // will be orphaned if the tryUpdateRoot() below fails
 
 	// First commit in store is always fast-foward.
 	if !currentRootRef.IsEmpty() {
@@ -118,7 +257,7 @@ func (ds *databaseCommon) doCommit(datasetID string, commit types.Struct) error
 			if commitRef.Equals(currentHeadRef) {
 				return nil
 			}
-			if !descendsFrom(commit, currentHeadRef, ds) {
+			if !descendsFrom(commit, currentHeadRef, ds, missing) {
 				return ErrMergeNeeded
 			}
 		}
@@ -127,6 +266,20 @@ func (ds *databaseCommon) doCommit(datasetID string, commit types.Struct) error
 	return ds.tryUpdateRoot(currentDatasets, currentRootRef)
 }
 
+// writeCommit writes commit, unless some of its parents are missing. Then it can't be validated, so it must already be in the Database, e.g. because it was pulled by PullShallow.
+func (ds *databaseCommon) writeCommit(commit types.Struct, missing hash.HashSet) types.Ref {
+	hasMissingParent := false
+	commit.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+		hasMissingParent = hasMissingParent || missing.Has(v.(types.Ref).TargetHash())
+	})
+	if !hasMissingParent {
+		return ds.WriteValue(commit)
+	}
+	// Reading the commit lets it be referred to by the root map.
+	d.PanicIfTrue(ds.ReadValue(commit.Hash()) == nil, "Can't write commit %s, since some of its parents are missing", commit.Hash())
+	return types.NewRef(commit)
+}
+
 // doDelete manages concurrent access the single logical piece of mutable state: the current Root. doDelete is optimistic in that it is attempting to update head making the assumption that currentRootRef is the hash of the current head. The call to UpdateRoot below will return an 'ErrOptimisticLockFailed' error if that assumption fails (e.g. because of a race with another writer) and the entire algorithm must be tried again.
 func (ds *databaseCommon) doDelete(datasetID string) error {
 	currentRootRef, currentDatasets := ds.getRootAndDatasets()
@@ -136,7 +289,7 @@ func (ds *databaseCommon) doDelete(datasetID string) error {
 
 func (ds *databaseCommon) getRootAndDatasets() (currentRootRef hash.Hash, currentDatasets types.Map) {
 	currentRootRef = ds.rt.Root()
-	currentDatasets = ds.Datasets()
+	currentDatasets = ds.rootMap()
 
 	if currentRootRef != currentDatasets.Hash() && !currentRootRef.IsEmpty() {
 		// The root has been advanced.
@@ -155,22 +308,26 @@ func (ds *databaseCommon) tryUpdateRoot(currentDatasets types.Map, currentRootRe
 	return
 }
 
-func descendsFrom(commit types.Struct, currentHeadRef types.Ref, vr types.ValueReader) bool {
+// descendsFrom reports whether currentHeadRef is an ancestor of commit. The commits in missing aren't in vr, so their ancestry is unknown, and it's assumed not to include currentHeadRef.
+func descendsFrom(commit types.Struct, currentHeadRef types.Ref, vr types.ValueReader, missing hash.HashSet) bool {
 	// BFS because the common case is that the ancestor is only a step or two away
 	ancestors := commit.Get(ParentsField).(types.Set)
 	for !ancestors.Has(currentHeadRef) {
 		if ancestors.Empty() {
 			return false
 		}
-		ancestors = getAncestors(ancestors, vr)
+		ancestors = getAncestors(ancestors, vr, missing)
 	}
 	return true
 }
 
-func getAncestors(commits types.Set, vr types.ValueReader) types.Set {
+func getAncestors(commits types.Set, vr types.ValueReader, missing hash.HashSet) types.Set {
 	ancestors := types.NewSet()
 	commits.IterAll(func(v types.Value) {
 		r := v.(types.Ref)
+		if missing.Has(r.TargetHash()) {
+			return
+		}
 		c := r.TargetValue(vr).(types.Struct)
 		next := []types.Value{}
 		c.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
@@ -0,0 +1,158 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/attic-labs/noms/go/d"
+)
+
+// pipeBaseURL is the base URL of requests sent over a pipe. Only its path matters to the server.
+const pipeBaseURL = "http://stdio/"
+
+// NewCommandDatabase returns a Database served by cmd over its stdin and stdout, such as `noms serve --stdio` run on another host by ssh. cmd is started here, and its stderr goes to ours unless it's already been set. Closing the Database closes cmd's stdin, which makes the server exit, and waits for cmd to finish.
+func NewCommandDatabase(cmd *exec.Cmd) (*RemoteDatabaseClient, error) {
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, err
+	}
+	doer := &pipeDoer{cmd: cmd, w: in, r: bufio.NewReader(out)}
+	bs := newHTTPBatchStoreWithClient(pipeBaseURL, "", doer)
+
+	// The root is requested straight away, which fails if cmd isn't serving a database.
+	var db *RemoteDatabaseClient
+	if err = d.Try(func() { db = newRemoteDatabaseClient(bs) }); err != nil {
+		bs.Close()
+		return nil, d.Unwrap(err)
+	}
+	return db, nil
+}
+
+// pipeDoer sends requests to a server over a single connection, made of the stdin and stdout of the command running it. Requests are sent one at a time, and each response is read in full before the next request is sent.
+type pipeDoer struct {
+	cmd    *exec.Cmd
+	mu     sync.Mutex
+	w      io.WriteCloser
+	r      *bufio.Reader
+	closed bool
+}
+
+func (p *pipeDoer) Do(req *http.Request) (*http.Response, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, fmt.Errorf("Connection to %s is closed", p.command())
+	}
+	if err := req.Write(p.w); err != nil {
+		return nil, fmt.Errorf("Writing request to %s: %s", p.command(), err)
+	}
+	res, err := http.ReadResponse(p.r, req)
+	if err != nil {
+		return nil, fmt.Errorf("Reading response from %s: %s", p.command(), err)
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Reading response from %s: %s", p.command(), err)
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return res, nil
+}
+
+func (p *pipeDoer) command() string {
+	return strings.Join(p.cmd.Args, " ")
+}
+
+// Close closes the connection and waits for the command to exit.
+func (p *pipeDoer) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	p.w.Close()
+	return p.cmd.Wait()
+}
+
+// newPipeListener returns a net.Listener that accepts a single connection, made of in and out, then waits for that connection to be closed. The server's end of the connection is one end of a net.Pipe, whose other end is copied to and from in and out, so that it supports the deadlines that http.Server relies on.
+func newPipeListener(in io.Reader, out io.Writer) (*pipeConn, net.Listener) {
+	server, client := net.Pipe()
+	conn := &pipeConn{Conn: server, closed: make(chan struct{}), copied: make(chan struct{})}
+	go func() {
+		io.Copy(client, in)
+		// The client only closes its end once it has read all the responses it's waiting for, so there's nothing more to copy to out.
+		client.Close()
+	}()
+	go func() {
+		io.Copy(out, client)
+		close(conn.copied)
+	}()
+	return conn, &pipeListener{conn: conn}
+}
+
+type pipeConn struct {
+	net.Conn
+	closeOnce sync.Once
+	closed    chan struct{}
+	copied    chan struct{}
+}
+
+func (c *pipeConn) Close() error {
+	err := c.Conn.Close()
+	c.closeOnce.Do(func() {
+		close(c.closed)
+	})
+	return err
+}
+
+// wait waits for the connection to be closed, and for everything written to it to be copied out.
+func (c *pipeConn) wait() {
+	<-c.closed
+	<-c.copied
+}
+
+type pipeListener struct {
+	conn     *pipeConn
+	accepted bool
+}
+
+func (l *pipeListener) Accept() (net.Conn, error) {
+	if !l.accepted {
+		l.accepted = true
+		return l.conn, nil
+	}
+	<-l.conn.closed
+	return nil, io.EOF
+}
+
+func (l *pipeListener) Close() error {
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
@@ -0,0 +1,76 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+const stdioServerEnv = "NOMS_TEST_STDIO_SERVER"
+
+// TestStdioServerProcess isn't a real test. It's run as a subprocess by the tests below to serve the ldb database named by stdioServerEnv over its stdin and stdout.
+func TestStdioServerProcess(t *testing.T) {
+	dir := os.Getenv(stdioServerEnv)
+	if dir == "" {
+		return
+	}
+	NewRemoteDatabaseServer(chunks.NewLevelDBStore(dir, "", 1, false), 0).RunStdio(os.Stdin, os.Stdout)
+	os.Exit(0)
+}
+
+func stdioServerCommand(dir string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestStdioServerProcess$")
+	cmd.Env = append(os.Environ(), stdioServerEnv+"="+dir)
+	return cmd
+}
+
+func TestCommandDatabase(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	db := NewDatabase(chunks.NewLevelDBStore(dir, "", 1, false))
+	db, err = db.Commit("ds", NewCommit(types.String("local"), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	db.Close()
+
+	remote, err := NewCommandDatabase(stdioServerCommand(dir))
+	assert.NoError(err)
+	assert.True(types.String("local").Equals(remote.Head("ds").Get(ValueField)))
+
+	values := types.ValueSlice{}
+	for i := 0; i < 5000; i++ {
+		values = append(values, types.String(strings.Repeat("x", i%100)))
+	}
+	l := types.NewList(values...)
+	parents := types.NewSet(remote.HeadRef("ds"))
+	_, err = remote.Commit("ds", NewCommit(remote.WriteValue(l), parents, types.EmptyStruct))
+	assert.NoError(err)
+	assert.NoError(remote.Close())
+
+	db = NewDatabase(chunks.NewLevelDBStore(dir, "", 1, false))
+	defer db.Close()
+	head := db.Head("ds")
+	assert.True(l.Equals(head.Get(ValueField).(types.Ref).TargetValue(db)))
+}
+
+func TestCommandDatabaseFailure(t *testing.T) {
+	assert := assert.New(t)
+	_, err := NewCommandDatabase(exec.Command("/nonexistent/command"))
+	assert.Error(err)
+
+	// A command that exits straight away can't serve anything.
+	_, err = NewCommandDatabase(exec.Command("true"))
+	assert.Error(err)
+}
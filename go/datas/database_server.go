@@ -5,14 +5,20 @@
 package datas
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net"
 	"net/http"
+	"os"
 	"strconv"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/util/metrics"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -29,13 +35,25 @@ type remoteDatabaseServer struct {
 	closing bool
 	// Called just before the server is started.
 	Ready func()
+	// If set, limits which datasets clients can read and write. Must be set before the server is started.
+	AccessControl *AccessControl
+	// If set, the server only accepts TLS connections, configured by TLSConfig. See NewServerTLSConfig.
+	TLSConfig *tls.Config
+	// If set, the server listens on a unix domain socket at this path, rather than on a port.
+	Socket string
+	// If set, serves GET requests to the value/ endpoint, e.g. with spec.HandleValue. Must be set before the server is started.
+	ValueHandler Handler
+	// If set, the server records metrics of the requests it handles in Metrics, and serves them at /metrics. Wrapping its ChunkStore in a chunks.InstrumentedStore that records into the same Registry adds metrics of that too. Must be set before the server is started.
+	Metrics *metrics.Registry
+	// If set, handles POSTs to the writeValue/ and root/ endpoints instead of the server's ChunkStore, e.g. the WriteHandler of a ReplicaStore. Must be set before the server is started.
+	WriteHandler Handler
 }
 
 func NewRemoteDatabaseServer(cs chunks.ChunkStore, port int) *remoteDatabaseServer {
 	dataVersion := cs.Version()
 	d.PanicIfTrue(constants.NomsVersion != dataVersion, "SDK version %s is incompatible with data of version %s", constants.NomsVersion, dataVersion)
 	return &remoteDatabaseServer{
-		cs, port, nil, make(chan *connectionState, 16), false, func() {},
+		cs, port, nil, make(chan *connectionState, 16), false, func() {}, nil, nil, "", nil, nil, nil,
 	}
 }
 
@@ -46,34 +64,29 @@ func (s *remoteDatabaseServer) Port() int {
 
 // Run blocks while the remoteDatabaseServer is listening. Running on a separate go routine is supported.
 func (s *remoteDatabaseServer) Run() {
-
-	l, err := net.Listen("tcp", fmt.Sprintf(":%d", s.port))
-	d.Chk.NoError(err)
+	var l net.Listener
+	if s.Socket != "" {
+		var err error
+		l, err = listenUnix(s.Socket)
+		d.PanicIfError(err)
+		fmt.Printf("Listening on socket %s...\n", s.Socket)
+	} else {
+		var err error
+		l, err = net.Listen("tcp", fmt.Sprintf(":%d", s.port))
+		d.Chk.NoError(err)
+		_, port, err := net.SplitHostPort(l.Addr().String())
+		d.Chk.NoError(err)
+		s.port, err = strconv.Atoi(port)
+		d.Chk.NoError(err)
+		fmt.Printf("Listening on port %d...\n", s.port)
+	}
+	if s.TLSConfig != nil {
+		l = tls.NewListener(l, s.TLSConfig)
+	}
 	s.l = &l
-	_, port, err := net.SplitHostPort(l.Addr().String())
-	d.Chk.NoError(err)
-	s.port, err = strconv.Atoi(port)
-	d.Chk.NoError(err)
-	fmt.Printf("Listening on port %d...\n", s.port)
-
-	router := httprouter.New()
-
-	router.POST(constants.GetRefsPath, s.corsHandle(s.makeHandle(HandleGetRefs)))
-	router.OPTIONS(constants.GetRefsPath, s.corsHandle(noopHandle))
-	router.POST(constants.HasRefsPath, s.corsHandle(s.makeHandle(HandleHasRefs)))
-	router.OPTIONS(constants.HasRefsPath, s.corsHandle(noopHandle))
-	router.GET(constants.RootPath, s.corsHandle(s.makeHandle(HandleRootGet)))
-	router.POST(constants.RootPath, s.corsHandle(s.makeHandle(HandleRootPost)))
-	router.OPTIONS(constants.RootPath, s.corsHandle(noopHandle))
-	router.POST(constants.WriteValuePath, s.corsHandle(s.makeHandle(HandleWriteValue)))
-	router.OPTIONS(constants.WriteValuePath, s.corsHandle(noopHandle))
 
-	srv := &http.Server{
-		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
-			router.ServeHTTP(w, req)
-		}),
-		ConnState: s.connState,
-	}
+	srv := s.newHTTPServer()
+	srv.ConnState = s.connState
 
 	go func() {
 		m := map[net.Conn]http.ConnState{}
@@ -94,7 +107,50 @@ func (s *remoteDatabaseServer) Run() {
 	srv.Serve(l)
 }
 
-func (s *remoteDatabaseServer) makeHandle(hndlr Handler) httprouter.Handle {
+// RunStdio serves the database over a single connection made of in and out, such as a process's stdin and stdout, instead of listening on a port. Requests are read from in and responses written to out, in the same protocol as over HTTP. RunStdio blocks until in is closed, then closes the database.
+func (s *remoteDatabaseServer) RunStdio(in io.Reader, out io.Writer) {
+	conn, l := newPipeListener(in, out)
+	go s.Ready()
+	s.newHTTPServer().Serve(l)
+	conn.wait()
+	s.cs.Close()
+}
+
+func (s *remoteDatabaseServer) newHTTPServer() *http.Server {
+	router := httprouter.New()
+
+	ac := s.AccessControl
+	router.POST(constants.GetRefsPath, s.corsHandle(s.makeHandle("get_refs", ac.ReadHandler(HandleGetRefs))))
+	router.OPTIONS(constants.GetRefsPath, s.corsHandle(noopHandle))
+	router.POST(constants.HasRefsPath, s.corsHandle(s.makeHandle("has_refs", ac.ReadHandler(HandleHasRefs))))
+	router.OPTIONS(constants.HasRefsPath, s.corsHandle(noopHandle))
+	router.GET(constants.RootPath, s.corsHandle(s.makeHandle("root_get", ac.ReadHandler(HandleRootGet))))
+	writeValue, rootPost := HandleWriteValue, HandleRootPost
+	if s.WriteHandler != nil {
+		writeValue, rootPost = s.WriteHandler, s.WriteHandler
+	}
+	router.POST(constants.RootPath, s.corsHandle(s.makeHandle("root_post", ac.RootPostHandler(rootPost))))
+	router.OPTIONS(constants.RootPath, s.corsHandle(noopHandle))
+	router.GET(constants.WatchRootPath, s.corsHandle(s.makeHandle("watch", ac.ReadHandler(HandleRootWatch))))
+	router.POST(constants.WriteValuePath, s.corsHandle(s.makeHandle("write_value", ac.WriteHandler(writeValue))))
+	router.OPTIONS(constants.WriteValuePath, s.corsHandle(noopHandle))
+	if s.ValueHandler != nil {
+		router.GET(constants.ValuePath+"*path", s.corsHandle(s.makeHandle("value", ac.ValueReadHandler(s.ValueHandler))))
+	}
+	if s.Metrics != nil {
+		router.Handler("GET", constants.MetricsPath, s.Metrics)
+	}
+
+	return &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			router.ServeHTTP(w, req)
+		}),
+	}
+}
+
+// makeHandle returns a Handle that calls hndlr with the server's ChunkStore. If the server records metrics, the requests are recorded as those of the handler called name.
+func (s *remoteDatabaseServer) makeHandle(name string, hndlr Handler) httprouter.Handle {
+	hndlr = InstrumentHandler(s.Metrics, name, hndlr)
 	return func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
 		hndlr(w, req, ps, s.cs)
 	}
@@ -110,7 +166,7 @@ func (s *remoteDatabaseServer) corsHandle(f httprouter.Handle) httprouter.Handle
 		// Can't use * when clients are using cookies.
 		w.Header().Add("Access-Control-Allow-Origin", r.Header.Get("Origin"))
 		w.Header().Add("Access-Control-Allow-Methods", "GET, POST")
-		w.Header().Add("Access-Control-Allow-Headers", NomsVersionHeader)
+		w.Header().Add("Access-Control-Allow-Headers", NomsVersionHeader+", Authorization")
 		w.Header().Add("Access-Control-Expose-Headers", NomsVersionHeader)
 		w.Header().Add(NomsVersionHeader, constants.NomsVersion)
 		f(w, r, ps)
@@ -132,3 +188,68 @@ func (s *remoteDatabaseServer) Stop() {
 	(s.cs).Close()
 	close(s.csChan)
 }
+
+// listenUnix listens on a unix domain socket at path. A socket left behind by a server that didn't shut down cleanly is removed, but not one that another server is still listening on.
+func listenUnix(path string) (net.Listener, error) {
+	l, err := net.Listen("unix", path)
+	if err == nil {
+		return l, nil
+	}
+	if fi, serr := os.Stat(path); serr != nil || fi.Mode()&os.ModeSocket == 0 {
+		return nil, err
+	}
+	if conn, derr := net.Dial("unix", path); derr == nil {
+		conn.Close()
+		return nil, fmt.Errorf("Another server is already listening on %s", path)
+	}
+	if err = os.Remove(path); err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+// NewServerTLSConfig returns a TLSConfig for a server with the certificate and private key in the PEM files certFile and keyFile. If clientCAFile isn't empty, clients must present a certificate signed by one of the CAs in it.
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}}
+	if clientCAFile != "" {
+		if config.ClientCAs, err = loadCertPool(clientCAFile); err != nil {
+			return nil, err
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
+
+// NewClientTLSConfig returns a TLSConfig for a client that trusts the CAs in the PEM file caFile, rather than the system's, and presents the certificate and private key in certFile and keyFile to servers that ask for one. Any of the files can be left empty.
+func NewClientTLSConfig(caFile, certFile, keyFile string) (config *tls.Config, err error) {
+	config = &tls.Config{}
+	if caFile != "" {
+		if config.RootCAs, err = loadCertPool(caFile); err != nil {
+			return nil, err
+		}
+	}
+	if certFile != "" || keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return
+}
+
+func loadCertPool(file string) (*x509.CertPool, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("No certificates found in %s", file)
+	}
+	return pool, nil
+}
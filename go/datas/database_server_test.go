@@ -0,0 +1,198 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/util/metrics"
+	"github.com/attic-labs/testify/assert"
+)
+
+// startTestServer runs server until the test is done with it, and returns once it's listening.
+func startTestServer(server *remoteDatabaseServer) {
+	ready := make(chan struct{})
+	server.Ready = func() { close(ready) }
+	go server.Run()
+	<-ready
+}
+
+func TestServeSocket(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "noms.sock")
+
+	server := NewRemoteDatabaseServer(chunks.NewMemoryStore(), 0)
+	server.Socket = socket
+	startTestServer(server)
+
+	// Several clients can share the database.
+	var db Database = NewSocketDatabase(socket, "")
+	db, err = db.Commit("ds", NewCommit(types.String("shared"), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	other := NewSocketDatabase(socket, "")
+	assert.True(types.String("shared").Equals(other.Head("ds").Get(ValueField)))
+	db.Close()
+	other.Close()
+
+	// A second server can't take over the socket while the first is listening on it.
+	assert.Error(d.Try(func() {
+		s2 := NewRemoteDatabaseServer(chunks.NewMemoryStore(), 0)
+		s2.Socket = socket
+		s2.Run()
+	}))
+
+	server.Stop()
+	_, err = os.Stat(socket)
+	assert.True(os.IsNotExist(err))
+}
+
+func TestServeSocketRemovesStaleSocket(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "noms.sock")
+
+	// Leave a socket behind, as a server that crashed would.
+	l, err := net.Listen("unix", socket)
+	assert.NoError(err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+
+	server := NewRemoteDatabaseServer(chunks.NewMemoryStore(), 0)
+	server.Socket = socket
+	startTestServer(server)
+	defer server.Stop()
+	db := NewSocketDatabase(socket, "")
+	assert.Zero(db.Datasets().Len())
+	db.Close()
+}
+
+// writeTestCert writes a self-signed certificate for localhost, and its key, as PEM files in dir, and returns their paths. The certificate can be used by servers and clients alike, and as its own CA.
+func writeTestCert(assert *assert.Assertions, dir, name string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(err)
+
+	certFile, keyFile = filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	assert.NoError(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return
+}
+
+func TestServeTLS(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	serverCert, serverKey := writeTestCert(assert, dir, "server")
+	clientCert, clientKey := writeTestCert(assert, dir, "client")
+
+	server := NewRemoteDatabaseServer(chunks.NewMemoryStore(), 0)
+	server.TLSConfig, err = NewServerTLSConfig(serverCert, serverKey, clientCert)
+	assert.NoError(err)
+	startTestServer(server)
+	defer server.Stop()
+	url := fmt.Sprintf("https://localhost:%d", server.Port())
+
+	// The server's certificate isn't trusted by default, and it wants one from the client.
+	assert.Error(d.Try(func() { NewRemoteDatabase(url, "") }))
+	config, err := NewClientTLSConfig(serverCert, "", "")
+	assert.NoError(err)
+	assert.Error(d.Try(func() { NewRemoteDatabaseWithTLS(url, "", config) }))
+
+	config, err = NewClientTLSConfig(serverCert, clientCert, clientKey)
+	assert.NoError(err)
+	var db Database = NewRemoteDatabaseWithTLS(url, "", config)
+	db, err = db.Commit("ds", NewCommit(types.String("secure"), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	assert.True(types.String("secure").Equals(db.Head("ds").Get(ValueField)))
+	db.Close()
+
+	_, err = NewServerTLSConfig(serverCert, filepath.Join(dir, "missing.key"), "")
+	assert.Error(err)
+}
+
+func TestServeMetrics(t *testing.T) {
+	assert := assert.New(t)
+	reg := metrics.NewRegistry()
+	server := NewRemoteDatabaseServer(chunks.NewInstrumentedStore(chunks.NewMemoryStore(), reg, "database"), 0)
+	server.Metrics = reg
+	ts := httptest.NewServer(server.newHTTPServer().Handler)
+	defer ts.Close()
+
+	var db Database = NewRemoteDatabase(ts.URL, "")
+	db, err := db.Commit("ds", NewCommit(types.String("measured"), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	db.Close()
+	res, err := http.Post(ts.URL+constants.GetRefsPath, "application/x-www-form-urlencoded", strings.NewReader("ref=bogus"))
+	assert.NoError(err)
+	res.Body.Close()
+	// The root has changed since the empty one, so the watch is answered at once.
+	req, err := http.NewRequest("GET", ts.URL+constants.WatchRootPath+"?last="+hash.Hash{}.String(), nil)
+	assert.NoError(err)
+	req.Header.Set(NomsVersionHeader, constants.NomsVersion)
+	res, err = http.DefaultClient.Do(req)
+	assert.NoError(err)
+	res.Body.Close()
+
+	res, err = http.Get(ts.URL + constants.MetricsPath)
+	assert.NoError(err)
+	defer res.Body.Close()
+	data, err := ioutil.ReadAll(res.Body)
+	assert.NoError(err)
+	text := string(data)
+	for _, line := range []string{
+		`noms_http_requests_total{handler="root_post",code="200"} 1`,
+		`noms_http_requests_total{handler="get_refs",code="400"} 1`,
+		`noms_http_errors_total{handler="get_refs"} 1`,
+		`noms_http_request_duration_seconds_count{handler="write_value"} 1`,
+		`noms_http_batch_size_count{handler="write_value"} 1`,
+		`noms_http_long_poll_duration_seconds_count{handler="watch"} 1`,
+		`noms_chunkstore_calls_total{store="database",op="update_root"} 1`,
+	} {
+		assert.Contains(text, line+"\n")
+	}
+	assert.NotContains(text, `noms_http_request_duration_seconds_count{handler="watch"}`)
+}
@@ -41,6 +41,17 @@ func (suite *LocalDatabaseSuite) SetupTest() {
 	suite.ds = suite.makeDs(suite.cs)
 }
 
+func (suite *LocalDatabaseSuite) TestMalformedMissingParents() {
+	lds := suite.ds.(*LocalDatabase)
+	root := types.NewMap(types.String(missingParentsKey), lds.WriteValue(types.String("not a record")))
+	suite.NoError(lds.tryUpdateRoot(root, lds.rt.Root()))
+
+	_, err := lds.MissingParents()
+	suite.Equal(ErrMalformedMissingParents, err)
+	_, err = lds.Commit("ds", NewCommit(types.Number(1), types.NewSet(), types.EmptyStruct))
+	suite.Equal(ErrMalformedMissingParents, err)
+}
+
 type RemoteDatabaseSuite struct {
 	DatabaseSuite
 }
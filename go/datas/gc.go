@@ -0,0 +1,94 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/noms/go/walk"
+)
+
+var ErrGCNotSupported = errors.New("ChunkStore does not support garbage collection")
+
+// GCStats describes the chunks deleted by CollectGarbage.
+type GCStats struct {
+	ChunksDeleted  uint64
+	BytesReclaimed uint64
+}
+
+// CollectGarbage deletes every chunk in cs that is not reachable from its root, i.e. that is not part of the commit graph of some dataset. Reachable chunks are marked by walking from the root with the given concurrency. If the root moves while marking, the walk is repeated from the new root until it settles. Any chunk written after marking began, or shortly before it, is kept regardless (for a LevelDBStore, those written within its grace period; see SetGCGracePeriod), so it's safe to run while other writers use cs, as long as they commit what they write within that time. If marking fails, e.g. because a reachable chunk is missing, nothing is deleted and the error is returned. Returns ErrGCNotSupported if cs does not implement chunks.GarbageCollector.
+func CollectGarbage(cs chunks.ChunkStore, concurrency int) (GCStats, error) {
+	gc, ok := cs.(chunks.GarbageCollector)
+	if !ok {
+		return GCStats{}, ErrGCNotSupported
+	}
+
+	gc.BeginGC()
+	swept := false
+	defer func() {
+		// If marking fails, end the collection, or no other could ever begin.
+		if !swept {
+			gc.AbortGC()
+		}
+	}()
+	bs := types.NewBatchStoreAdaptor(cs) // Not closed, since that would close cs.
+	vs := types.NewValueStore(bs)
+	keep := hash.HashSet{}
+	mu := sync.Mutex{}
+	mark := func(root hash.Hash) {
+		if root.IsEmpty() {
+			return
+		}
+		d.PanicIfTrue(!cs.Has(root), "The root %s is missing", root)
+		rootValue := types.DecodeValue(cs.Get(root), vs)
+		// Commits that the database is missing on purpose aren't there to be walked.
+		missing, err := missingParentsIn(rootValue.(types.Map), vs)
+		d.PanicIfError(err)
+		// The walk panics on its own goroutines if it comes across a missing chunk, so look for them first.
+		var absent hash.Hash
+		walk.SomeChunksP(types.NewRef(rootValue), bs, func(r types.Ref) bool {
+			h := r.TargetHash()
+			mu.Lock()
+			seen := keep.Has(h) || missing.Has(h) || !absent.IsEmpty()
+			mu.Unlock()
+			if seen {
+				return true
+			}
+			present := cs.Has(h)
+			mu.Lock()
+			defer mu.Unlock()
+			if !present {
+				absent = h
+				return true
+			}
+			keep.Insert(h)
+			return false
+		}, nil, concurrency)
+		d.PanicIfTrue(!absent.IsEmpty(), "Chunk %s is reachable from the root, but missing", absent)
+	}
+
+	err := d.Try(func() {
+		for root := cs.Root(); ; {
+			mark(root)
+			next := cs.Root()
+			if next == root {
+				break
+			}
+			root = next
+		}
+	})
+	if err != nil {
+		return GCStats{}, d.Unwrap(err)
+	}
+
+	count, bytes := gc.Sweep(keep)
+	swept = true
+	return GCStats{count, bytes}, nil
+}
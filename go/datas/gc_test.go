@@ -0,0 +1,169 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cs := chunks.NewLevelDBStore(dir, "", 24, false)
+	defer cs.Close()
+	cs.SetGCGracePeriod(0)
+	db := NewDatabase(cs)
+
+	keptValue := types.NewList(types.String("kept"))
+	keptCommit := NewCommit(keptValue, types.NewSet(), types.EmptyStruct)
+	db, err = db.Commit("kept", keptCommit)
+	assert.NoError(err)
+
+	deletedCommit := NewCommit(types.NewList(types.String("deleted")), types.NewSet(), types.EmptyStruct)
+	db, err = db.Commit("deleted", deletedCommit)
+	assert.NoError(err)
+	db, err = db.Delete("deleted")
+	assert.NoError(err)
+
+	assert.True(cs.Has(deletedCommit.Hash()))
+	stats, err := CollectGarbage(cs, 4)
+	assert.NoError(err)
+	assert.True(stats.ChunksDeleted > 0)
+	assert.True(stats.BytesReclaimed > 0)
+
+	assert.False(cs.Has(deletedCommit.Hash()))
+	assert.True(cs.Has(keptCommit.Hash()))
+	assert.True(keptValue.Equals(NewDatabase(cs).Head("kept").Get(ValueField)))
+
+	// Collecting again finds nothing more to delete.
+	stats, err = CollectGarbage(cs, 4)
+	assert.NoError(err)
+	assert.Equal(GCStats{}, stats)
+}
+
+func TestCollectGarbageShallow(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	source := NewDatabase(chunks.NewMemoryStore())
+	defer source.Close()
+	source, err = source.Commit("ds", NewCommit(types.String("first"), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	source, err = source.Commit("ds", NewCommit(types.String("second"), types.NewSet(source.HeadRef("ds")), types.EmptyStruct))
+	assert.NoError(err)
+
+	cs := chunks.NewLevelDBStore(dir, "", 24, false)
+	defer cs.Close()
+	db := NewDatabase(cs)
+	PullShallow(source, db, source.HeadRef("ds"), types.Ref{}, 1, 2, nil)
+	db, err = db.Commit("ds", source.Head("ds"))
+	assert.NoError(err)
+	assert.Len(missingParentsOf(db), 1)
+
+	// The missing parent isn't walked, and the record of it is kept.
+	_, err = CollectGarbage(cs, 4)
+	assert.NoError(err)
+	db = NewDatabase(cs)
+	assert.True(types.String("second").Equals(db.Head("ds").Get(ValueField)))
+	assert.Len(missingParentsOf(db), 1)
+}
+
+func TestCollectGarbageKeepsConcurrentWrites(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cs := chunks.NewLevelDBStore(dir, "", 24, false)
+	defer cs.Close()
+	cs.SetGCGracePeriod(0)
+
+	cs.BeginGC()
+	c := chunks.NewChunk([]byte("written during gc"))
+	cs.Put(c)
+	count, _ := cs.Sweep(nil)
+	assert.Equal(uint64(0), count)
+	assert.True(cs.Has(c.Hash()))
+
+	cs.BeginGC()
+	count, _ = cs.Sweep(nil)
+	assert.Equal(uint64(1), count)
+	assert.False(cs.Has(c.Hash()))
+}
+
+func TestCollectGarbageAbortsOnFailure(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cs := chunks.NewLevelDBStore(dir, "", 24, false)
+	defer cs.Close()
+	cs.SetGCGracePeriod(0)
+	c := chunks.NewChunk([]byte("unreachable"))
+	cs.Put(c)
+
+	// A root that isn't there can't be marked from.
+	missing := chunks.NewChunk([]byte("missing")).Hash()
+	assert.True(cs.UpdateRoot(missing, cs.Root()))
+	_, err = CollectGarbage(cs, 1)
+	assert.Error(err)
+	assert.True(cs.Has(c.Hash()))
+
+	// The failed collection doesn't stop the next.
+	assert.True(cs.UpdateRoot(hash.Hash{}, missing))
+	stats, err := CollectGarbage(cs, 1)
+	assert.NoError(err)
+	assert.Equal(uint64(1), stats.ChunksDeleted)
+	assert.False(cs.Has(c.Hash()))
+}
+
+func TestCollectGarbageSparesRecentWrites(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir(os.TempDir(), "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	cs := chunks.NewLevelDBStore(dir, "", 24, false)
+	defer cs.Close()
+
+	// A writer's values aren't reachable until it commits them, which may be after a collection began.
+	db := NewDatabase(cs)
+	bs := db.validatingBatchStore()
+	r := db.WriteValue(types.NewList(types.String("written before the commit")))
+	bs.Flush()
+	stats, err := CollectGarbage(cs, 1)
+	assert.NoError(err)
+	assert.Equal(uint64(0), stats.ChunksDeleted)
+	assert.True(cs.Has(r.TargetHash()))
+	db, err = db.Commit("ds", NewCommit(r, types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+
+	// Once the grace period has passed, unreachable chunks are swept.
+	db, err = db.Delete("ds")
+	assert.NoError(err)
+	cs.SetGCGracePeriod(0)
+	stats, err = CollectGarbage(cs, 1)
+	assert.NoError(err)
+	assert.True(stats.ChunksDeleted > 0)
+	assert.False(cs.Has(r.TargetHash()))
+}
+
+func TestCollectGarbageUnsupported(t *testing.T) {
+	_, err := CollectGarbage(chunks.NewMemoryStore(), 1)
+	assert.Equal(t, ErrGCNotSupported, err)
+}
@@ -0,0 +1,99 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/util/metrics"
+)
+
+// batchSizeKey is the key of the *int in the context of a request, if any, in which handlers record how many chunks or hashes the request carries.
+type batchSizeKey struct{}
+
+// longPollKey is the key of the *bool in the context of a request, if any, in which handlers record that they held the request until there was something to respond with.
+type longPollKey struct{}
+
+// longPollBuckets are the upper bounds, in seconds, of the buckets of the histogram of how long long-polled requests are held, which is up to watchTimeout.
+var longPollBuckets = []float64{.1, .5, 1, 2.5, 5, 10, 15, 20, 25, 30, 35}
+
+// InstrumentHandler wraps hndlr so that its requests are recorded in reg under the given handler name: how many there are, by status code, how many fail, how long they take and, for the handlers that take batches of chunks or hashes, how big the batches are. Long-polled requests, such as those to the watch endpoint, are held until the root changes, so how long they take is recorded apart from the other requests, in noms_http_long_poll_duration_seconds. If reg is nil, hndlr is returned as is.
+func InstrumentHandler(reg *metrics.Registry, name string, hndlr Handler) Handler {
+	if reg == nil {
+		return hndlr
+	}
+	requests := reg.Counter("noms_http_requests_total", "Number of HTTP requests, by handler and status code.", "handler", "code")
+	errors := reg.Counter("noms_http_errors_total", "Number of HTTP requests that failed, i.e. whose status code is 400 or more.", "handler")
+	times := reg.Histogram("noms_http_request_duration_seconds", "How long HTTP requests take to handle.", metrics.DurationBuckets, "handler")
+	longPollTimes := reg.Histogram("noms_http_long_poll_duration_seconds", "How long long-polled HTTP requests are held before they're responded to.", longPollBuckets, "handler")
+	batchSizes := reg.Histogram("noms_http_batch_size", "Number of chunks or hashes in HTTP requests that carry them.", metrics.SizeBuckets, "handler")
+
+	return func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		batchSize, longPoll := -1, false
+		defer func() {
+			// Handlers that panic outright, rather than through d.Try, fail the request.
+			r := recover()
+			if r != nil {
+				sw.code = http.StatusInternalServerError
+			}
+			requests.Inc(name, fmt.Sprint(sw.code))
+			if sw.code >= 400 {
+				errors.Inc(name)
+			}
+			if longPoll {
+				longPollTimes.ObserveSince(start, name)
+			} else {
+				times.ObserveSince(start, name)
+			}
+			if batchSize >= 0 {
+				batchSizes.Observe(float64(batchSize), name)
+			}
+			if r != nil {
+				panic(r)
+			}
+		}()
+		ctx := context.WithValue(context.WithValue(req.Context(), batchSizeKey{}, &batchSize), longPollKey{}, &longPoll)
+		hndlr(sw, req.WithContext(ctx), ps, cs)
+	}
+}
+
+// recordBatchSize records that req carries n chunks or hashes, if its handler is instrumented.
+func recordBatchSize(req *http.Request, n int) {
+	if p, ok := req.Context().Value(batchSizeKey{}).(*int); ok {
+		*p = n
+	}
+}
+
+// recordLongPoll records that req is held until there's something to respond with, if its handler is instrumented.
+func recordLongPoll(req *http.Request) {
+	if p, ok := req.Context().Value(longPollKey{}).(*bool); ok {
+		*p = true
+	}
+}
+
+// statusWriter is an http.ResponseWriter that remembers the status code of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	code        int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.code, sw.wroteHeader = code, true
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(data []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(data)
+}
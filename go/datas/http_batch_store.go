@@ -50,12 +50,17 @@ type httpBatchStore struct {
 }
 
 func newHTTPBatchStore(baseURL, auth string) *httpBatchStore {
+	return newHTTPBatchStoreWithClient(baseURL, auth, makeHTTPClient(httpChunkSinkConcurrency))
+}
+
+// newHTTPBatchStoreWithClient returns an httpBatchStore that sends its requests with httpClient. If httpClient is an io.Closer, it's closed along with the store.
+func newHTTPBatchStoreWithClient(baseURL, auth string, httpClient httpDoer) *httpBatchStore {
 	u, err := url.Parse(baseURL)
 	d.PanicIfError(err)
 	d.PanicIfTrue(u.Scheme != "http" && u.Scheme != "https", "Unrecognized scheme: %s", u.Scheme)
 	buffSink := &httpBatchStore{
 		host:          u,
-		httpClient:    makeHTTPClient(httpChunkSinkConcurrency),
+		httpClient:    httpClient,
 		auth:          auth,
 		getQueue:      make(chan chunks.ReadRequest, readBufferSize),
 		hasQueue:      make(chan chunks.ReadRequest, readBufferSize),
@@ -85,12 +90,13 @@ type writeRequest struct {
 
 // Use a custom http client rather than http.DefaultClient. We limit ourselves to a maximum of |requestLimit| concurrent http requests, the custom httpClient ups the maxIdleConnsPerHost value so that one connection stays open for each concurrent request.
 func makeHTTPClient(requestLimit int) *http.Client {
-	t := http.Transport(*http.DefaultTransport.(*http.Transport))
+	// Clone rather than copy the default transport, whose idle connections it would otherwise share without sharing its locks.
+	t := http.DefaultTransport.(*http.Transport).Clone()
 	t.MaxIdleConnsPerHost = requestLimit
 	// This sets, essentially, an idle-timeout. The timer starts counting AFTER the client has finished sending the entire request to the server. As soon as the client receives the server's response headers, the timeout is canceled.
 	t.ResponseHeaderTimeout = time.Duration(2) * time.Minute
 
-	return &http.Client{Transport: &t}
+	return &http.Client{Transport: t}
 }
 
 func (bhcs *httpBatchStore) IsValidating() bool {
@@ -114,6 +120,9 @@ func (bhcs *httpBatchStore) Close() (e error) {
 	close(bhcs.hasQueue)
 	close(bhcs.writeQueue)
 	close(bhcs.rateLimit)
+	if c, ok := bhcs.httpClient.(io.Closer); ok {
+		e = c.Close()
+	}
 	return
 }
 
@@ -428,7 +437,9 @@ func (bhcs *httpBatchStore) Root() hash.Hash {
 	expectVersion(res)
 	defer closeResponse(res.Body)
 
-	d.Chk.True(http.StatusOK == res.StatusCode, "Unexpected response: %s", http.StatusText(res.StatusCode))
+	if res.StatusCode != http.StatusOK {
+		d.PanicIfError(fmt.Errorf("Unexpected response: %s", formatErrorResponse(res)))
+	}
 	data, err := ioutil.ReadAll(res.Body)
 	d.Chk.NoError(err)
 	return hash.Parse(string(data))
@@ -443,10 +454,45 @@ func (bhcs *httpBatchStore) UpdateRoot(current, last hash.Hash) bool {
 	expectVersion(res)
 	defer closeResponse(res.Body)
 
-	d.Chk.True(res.StatusCode == http.StatusOK || res.StatusCode == http.StatusConflict, "Unexpected response: %s", http.StatusText(res.StatusCode))
+	// The server may refuse the update, e.g. because the client isn't allowed to write some of the datasets it changes.
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusConflict {
+		d.PanicIfError(fmt.Errorf("Unexpected response: %s", formatErrorResponse(res)))
+	}
 	return res.StatusCode == http.StatusOK
 }
 
+// watchRoot waits for the server's Root to be something other than last, and returns it. The server gives up waiting after a while, in which case last is returned. If cancel is closed first, it returns false.
+func (bhcs *httpBatchStore) watchRoot(last hash.Hash, cancel <-chan struct{}) (hash.Hash, bool) {
+	// GET http://<host>/watch?last=<ref>. Response will be ref of root, once it differs from last or the server times out.
+	u := *bhcs.host
+	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.WatchRootPath)
+	params := u.Query()
+	params.Add("last", last.String())
+	u.RawQuery = params.Encode()
+
+	req := newRequest("GET", bhcs.auth, u.String(), nil, nil)
+	req.Cancel = cancel
+	res, err := bhcs.httpClient.Do(req)
+	select {
+	case <-cancel:
+		if res != nil {
+			closeResponse(res.Body)
+		}
+		return last, false
+	default:
+	}
+	d.PanicIfError(err)
+	expectVersion(res)
+	defer closeResponse(res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		d.PanicIfError(fmt.Errorf("Unexpected response: %s", formatErrorResponse(res)))
+	}
+	data, err := ioutil.ReadAll(res.Body)
+	d.PanicIfError(err)
+	return hash.Parse(string(data)), true
+}
+
 func (bhcs *httpBatchStore) requestRoot(method string, current, last hash.Hash) *http.Response {
 	u := *bhcs.host
 	u.Path = httprouter.CleanPath(bhcs.host.Path + constants.RootPath)
@@ -24,19 +24,29 @@ func newLocalDatabase(cs chunks.ChunkStore) *LocalDatabase {
 	}
 }
 
+// rootUpdated wakes up Watch() on every Database backed by lds.cs, unless err says the root wasn't updated.
+func (lds *LocalDatabase) rootUpdated(err error) {
+	if err == nil {
+		rootUpdated(lds.cs)
+	}
+}
+
 func (lds *LocalDatabase) Commit(datasetID string, commit types.Struct) (Database, error) {
 	err := lds.doCommit(datasetID, commit)
-	return &LocalDatabase{newDatabaseCommon(lds.cch, lds.vs, lds.rt), lds.cs}, err
+	lds.rootUpdated(err)
+	return &LocalDatabase{lds.snapshot(), lds.cs}, err
 }
 
 func (lds *LocalDatabase) Delete(datasetID string) (Database, error) {
 	err := lds.doDelete(datasetID)
-	return &LocalDatabase{newDatabaseCommon(lds.cch, lds.vs, lds.rt), lds.cs}, err
+	lds.rootUpdated(err)
+	return &LocalDatabase{lds.snapshot(), lds.cs}, err
 }
 
 func (lds *LocalDatabase) SetHead(datasetID string, commit types.Struct) (Database, error) {
 	err := lds.doSetHead(datasetID, commit)
-	return &LocalDatabase{newDatabaseCommon(lds.cch, lds.vs, lds.rt), lds.cs}, err
+	lds.rootUpdated(err)
+	return &LocalDatabase{lds.snapshot(), lds.cs}, err
 }
 
 func (lds *LocalDatabase) validatingBatchStore() (bs types.BatchStore) {
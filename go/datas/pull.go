@@ -7,7 +7,9 @@ package datas
 import (
 	"sort"
 	"sync"
+	"time"
 
+	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
@@ -17,18 +19,193 @@ type PullProgress struct {
 	DoneCount, KnownCount, DoneBytes uint64
 }
 
-// Pull objects that descends from sourceRef from srcDB to sinkDB. sinkHeadRef should point to a Commit (in sinkDB) that's an ancestor of sourceRef. This allows the algorithm to figure out which portions of data are already present in sinkDB and skip copying them.
+// Pull objects that descends from sourceRef from srcDB to sinkDB. sinkHeadRef should point to a Commit (in sinkDB) that's an ancestor of sourceRef. This allows the algorithm to figure out which portions of data are already present in sinkDB and skip copying them. If srcDB is missing some of the history of sourceRef, or sinkDB is missing some of its own (see Database.MissingParents), Pull is the same as PullShallow with no limit on depth, so that sinkDB ends up with as much of the history of sourceRef as srcDB has.
 func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency int, progressCh chan PullProgress) {
-	srcQ, sinkQ := &types.RefByHeight{sourceRef}, &types.RefByHeight{sinkHeadRef}
+	doPull(srcDB, sinkDB, sourceRef, sinkHeadRef, concurrency, progressCh, nil)
+}
+
+// PullShallow is like Pull, but only pulls the commit at sourceRef and its ancestors up to depth commits away, along with everything their values refer to. The parents of the oldest commits pulled are left out, and recorded as missing in sinkDB (see Database.MissingParents), as are any of the ancestors that srcDB is itself missing. A depth of 0 means no limit, so that all of the history in srcDB is pulled. Pulling commits that sinkDB is missing, e.g. by pulling the same sourceRef again with a greater depth, deepens its history. sinkDB must be a LocalDatabase, since commits with missing parents can't be written through a validating BatchStore.
+func PullShallow(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, depth int, concurrency int, progressCh chan PullProgress) {
+	doPullShallow(srcDB, sinkDB, sourceRef, sinkHeadRef, depth, concurrency, progressCh, nil)
+}
+
+// ResumablePull is Pull, or PullShallow if depth is greater than 0, saving its progress to cp as it goes, unless cp is nil. If cp holds the progress of an earlier pull of sourceRef on top of sinkHeadRef that was interrupted, it carries on from there.
+func ResumablePull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, depth int, concurrency int, progressCh chan PullProgress, cp *PullCheckpoint) {
+	if depth > 0 {
+		doPullShallow(srcDB, sinkDB, sourceRef, sinkHeadRef, depth, concurrency, progressCh, cp)
+	} else {
+		doPull(srcDB, sinkDB, sourceRef, sinkHeadRef, concurrency, progressCh, cp)
+	}
+}
 
-	// If the sourceRef points to an object already in sinkDB, there's nothing to do.
-	if sinkDB.has(sourceRef.TargetHash()) {
+func doPull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency int, progressCh chan PullProgress, cp *PullCheckpoint) {
+	srcMissing, sinkMissing := missingParentsOf(srcDB), missingParentsOf(sinkDB)
+	if _, ok := sinkDB.(*LocalDatabase); ok && len(sinkMissing) > 0 || len(srcMissing) > 0 {
+		doPullShallow(srcDB, sinkDB, sourceRef, sinkHeadRef, 0, concurrency, progressCh, cp)
 		return
 	}
+	if found := pull(srcDB, sinkDB, types.RefSlice{sourceRef}, sinkHeadRef, sinkMissing, concurrency, progressCh, cp); len(found) > 0 {
+		sinkDB.updateMissingParents(nil, found)
+	}
+}
+
+// missingParentsOf returns db.MissingParents(), panicking if the record of them is malformed.
+func missingParentsOf(db Database) hash.HashSet {
+	missing, err := db.MissingParents()
+	d.PanicIfError(err)
+	return missing
+}
+
+func doPullShallow(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, depth int, concurrency int, progressCh chan PullProgress, cp *PullCheckpoint) {
+	lds, ok := sinkDB.(*LocalDatabase)
+	d.PanicIfTrue(!ok, "Can't pull a partial history into a remote database")
+	srcMissing, sinkMissing := missingParentsOf(srcDB), missingParentsOf(sinkDB)
 
-	// We generally expect that sourceRef descends from sinkHeadRef, so that walking down from sinkHeadRef yields useful hints. If it's not even in the srcDB, then just clear out sinkQ right now and don't bother.
-	if !srcDB.has(sinkHeadRef.TargetHash()) {
-		sinkQ.PopBack()
+	// Walk the history of sourceRef breadth first, down to depth, to find the commits sinkDB doesn't have and the parents that will be missing once they've been pulled. If sinkDB isn't missing anything, it has the whole history of any commit it has, so there's no need to go further. Otherwise, its commits are walked through, so that the ones it's missing can be filled in.
+	commits := []types.Struct{}
+	values := types.RefSlice{}
+	pulled, missing, seen := hash.HashSet{}, hash.HashSet{}, hash.HashSet{}
+	level := types.RefSlice{sourceRef}
+	for n := 1; len(level) > 0; n++ {
+		next := types.RefSlice{}
+		for _, r := range level {
+			h := r.TargetHash()
+			if seen.Has(h) {
+				continue
+			}
+			seen.Insert(h)
+
+			present := sinkDB.has(h)
+			if !present && (srcMissing.Has(h) || depth > 0 && n > depth) {
+				missing.Insert(h)
+				continue
+			}
+			if depth > 0 && n > depth || present && len(sinkMissing) == 0 {
+				continue
+			}
+
+			var commit types.Struct
+			if present {
+				commit = sinkDB.ReadValue(h).(types.Struct)
+			} else {
+				commit = srcDB.ReadValue(h).(types.Struct)
+				commits = append(commits, commit)
+				pulled.Insert(h)
+			}
+			parents := hash.HashSet{}
+			commit.Get(ParentsField).(types.Set).IterAll(func(v types.Value) {
+				next = append(next, v.(types.Ref))
+				parents.Insert(v.(types.Ref).TargetHash())
+			})
+			if !present {
+				for _, cr := range commit.Chunks() {
+					if !parents.Has(cr.TargetHash()) {
+						values = append(values, cr)
+					}
+				}
+			}
+		}
+		level = next
+	}
+
+	// Pull everything the commits refer to, other than their parents, and only then write the commits themselves, without validation, since some of their parents are missing.
+	pull(srcDB, sinkDB, values, sinkHeadRef, sinkMissing, concurrency, progressCh, cp)
+	sinkDB.validatingBatchStore().Flush()
+	for _, commit := range commits {
+		lds.cs.Put(types.EncodeValue(commit, nil))
+		setCache(lds.cch, commit.Hash(), true)
+	}
+
+	found := hash.HashSet{}
+	for h := range sinkMissing {
+		if pulled.Has(h) {
+			found.Insert(h)
+		}
+	}
+	sinkDB.updateMissingParents(missing, found)
+}
+
+// pull does the work of Pull, copying everything reachable from sourceRefs in srcDB that isn't in sinkDB. sinkMissing holds the commits that sinkDB is missing, which won't be looked for under sinkHeadRef. Returns those of them that were pulled.
+func pull(srcDB, sinkDB Database, sourceRefs types.RefSlice, sinkHeadRef types.Ref, sinkMissing hash.HashSet, concurrency int, progressCh chan PullProgress, cp *PullCheckpoint) (found hash.HashSet) {
+	found = hash.HashSet{}
+	srcQ, sinkQ := &types.RefByHeight{}, &types.RefByHeight{sinkHeadRef}
+	// hc and reachableChunks aren't goroutine-safe, so only write them here.
+	hc := hintCache{}
+	reachableChunks := hash.HashSet{}
+	var doneCount, knownCount, doneBytes uint64
+
+	// A resumable pull holds back what it pulls until it can be written to sinkDB, so that sinkDB can be flushed whenever the pull saves its progress. Only what's still held back then needs saving.
+	var pending *pendingChunks
+	var st pullState
+	resumed := false
+	if cp != nil {
+		if st, resumed = cp.load(sourceRefs, sinkHeadRef); resumed {
+			srcQ = decodeRefs(st.SrcQ)
+			pending, resumed = restorePendingChunks(st.Pending, srcQ, sinkDB)
+		}
+		if !resumed {
+			srcQ, pending = &types.RefByHeight{}, newPendingChunks(sinkDB.validatingBatchStore())
+		}
+	}
+	if resumed {
+		sinkQ = decodeRefs(st.SinkQ)
+		for h := range sinkMissing {
+			if pending.chunks[h] != nil || sinkDB.has(h) {
+				found.Insert(h)
+			}
+		}
+		doneCount, knownCount, doneBytes = st.DoneCount, st.KnownCount, st.DoneBytes
+		cp.resumed = PullProgress{doneCount, knownCount, doneBytes}
+	} else {
+		// If a sourceRef points to an object already in sinkDB, there's nothing to do for it.
+		for _, r := range sourceRefs {
+			if !sinkDB.has(r.TargetHash()) {
+				srcQ.PushBack(r)
+			}
+		}
+		if srcQ.Empty() {
+			return
+		}
+		sort.Sort(srcQ)
+		srcQ.Unique()
+
+		// We generally expect that sourceRef descends from sinkHeadRef, so that walking down from sinkHeadRef yields useful hints. If it's not even in the srcDB, then just clear out sinkQ right now and don't bother.
+		if !srcDB.has(sinkHeadRef.TargetHash()) {
+			sinkQ.PopBack()
+		}
+	}
+
+	if cp != nil {
+		// The first save is due an interval after the pull starts, so that short pulls don't save anything.
+		cp.saved = time.Now()
+	}
+
+	addHints := func() {
+		hints := types.Hints{}
+		for hash := range reachableChunks {
+			if hint, present := hc[hash]; present {
+				hints[hint] = struct{}{}
+			}
+		}
+		sinkDB.validatingBatchStore().AddHints(hints)
+	}
+	saveState := func() {
+		addHints()
+		sinkDB.validatingBatchStore().Flush()
+		sources, sinkHead := pullKey(sourceRefs, sinkHeadRef)
+		d.PanicIfError(cp.save(pullState{
+			Sources:    sources,
+			SinkHead:   sinkHead,
+			SrcQ:       encodeRefs(srcQ),
+			SinkQ:      encodeRefs(sinkQ),
+			Pending:    pending.encode(),
+			DoneCount:  doneCount,
+			KnownCount: knownCount,
+			DoneBytes:  doneBytes,
+		}))
+		if cp.interrupt != nil {
+			cp.interrupt()
+		}
 	}
 
 	// Since we expect sinkHeadRef to descend from sourceRef, we assume srcDB has a superset of the data in sinkDB. There are some cases where, logically, the code wants to read data it knows to be in sinkDB. In this case, it doesn't actually matter which Database the data comes from, so as an optimization we use whichever is a LocalDatabase -- if either is.
@@ -64,7 +241,7 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 			for {
 				select {
 				case srcRef := <-srcChan:
-					srcResChan <- traverseSource(srcRef, srcDB, sinkDB)
+					srcResChan <- traverseSource(srcRef, srcDB, sinkDB, pending == nil)
 				case sinkRef := <-sinkChan:
 					sinkResChan <- traverseSink(sinkRef, mostLocalDB)
 				case comRef := <-comChan:
@@ -80,21 +257,29 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 		traverseWorker()
 	}
 
-	var doneCount, knownCount, doneBytes uint64
 	updateProgress := func(moreDone, moreKnown, moreBytes uint64) {
-		if progressCh == nil {
-			return
-		}
 		doneCount, knownCount, doneBytes = doneCount+moreDone, knownCount+moreKnown, doneBytes+moreBytes
-		progressCh <- PullProgress{doneCount, knownCount + uint64(srcQ.Len()), doneBytes}
+		if progressCh != nil {
+			progressCh <- PullProgress{doneCount, knownCount + uint64(srcQ.Len()), doneBytes}
+		}
 	}
 
-	// hc and reachableChunks aren't goroutine-safe, so only write them here.
-	hc := hintCache{}
-	reachableChunks := hash.HashSet{}
 	for !srcQ.Empty() {
 		srcRefs, sinkRefs, comRefs := planWork(srcQ, sinkQ)
+		if cp != nil && len(srcRefs) > cp.batchSize {
+			// The rest are of the same height as these, and aren't common to sinkDB, so they can wait for the next round.
+			for _, r := range srcRefs[cp.batchSize:] {
+				srcQ.PushBack(r)
+			}
+			srcRefs = srcRefs[:cp.batchSize]
+		}
 		srcWork, sinkWork, comWork := len(srcRefs), len(sinkRefs), len(comRefs)
+		if pending != nil {
+			// Common refs are already in sinkDB.
+			for _, r := range comRefs {
+				pending.complete(r.TargetHash())
+			}
+		}
 		if srcWork+comWork > 0 {
 			updateProgress(0, uint64(srcWork+comWork), 0)
 		}
@@ -114,11 +299,25 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 				}
 				if !res.readHash.IsEmpty() {
 					reachableChunks.Remove(res.readHash)
+					if sinkMissing.Has(res.readHash) {
+						found.Insert(res.readHash)
+					}
+				}
+				if pending != nil {
+					if res.readHash.IsEmpty() {
+						pending.complete(res.ref.TargetHash())
+					} else {
+						pending.add(res.c, res.ref.Height(), res.reachables)
+					}
 				}
 				srcWork--
 				updateProgress(1, 0, uint64(res.readBytes))
 			case res := <-sinkResChan:
 				for _, reachable := range res.reachables {
+					// Commits that sinkDB is missing can't be walked on its side, but they're still pulled if they're reachable from srcQ.
+					if sinkMissing.Has(reachable.TargetHash()) {
+						continue
+					}
 					sinkQ.PushBack(reachable)
 					hc[reachable.TargetHash()] = res.readHash
 				}
@@ -126,7 +325,9 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 			case res := <-comResChan:
 				isHeadOfSink := res.readHash == sinkHeadRef.TargetHash()
 				for _, reachable := range res.reachables {
-					sinkQ.PushBack(reachable)
+					if !sinkMissing.Has(reachable.TargetHash()) {
+						sinkQ.PushBack(reachable)
+					}
 					if !isHeadOfSink {
 						srcQ.PushBack(reachable)
 					}
@@ -140,21 +341,24 @@ func Pull(srcDB, sinkDB Database, sourceRef, sinkHeadRef types.Ref, concurrency
 		sort.Sort(srcQ)
 		sinkQ.Unique()
 		srcQ.Unique()
-	}
-
-	hints := types.Hints{}
-	for hash := range reachableChunks {
-		if hint, present := hc[hash]; present {
-			hints[hint] = struct{}{}
+		if cp != nil && !srcQ.Empty() && cp.due() {
+			saveState()
 		}
 	}
-	sinkDB.validatingBatchStore().AddHints(hints)
+	if pending != nil {
+		d.Chk.True(len(pending.chunks) == 0, "Pulled chunks were never written to the sink")
+	}
+	addHints()
+	return
 }
 
 type traverseResult struct {
 	readHash   hash.Hash
 	reachables types.RefSlice
 	readBytes  int
+	// ref and c are set for the results of traverseSource: c is the chunk that was pulled, if it wasn't put to the sink.
+	ref types.Ref
+	c   chunks.Chunk
 }
 
 // planWork deals with three possible situations:
@@ -223,22 +427,27 @@ func sendWork(ch chan<- types.Ref, refs types.RefSlice) {
 
 type hintCache map[hash.Hash]hash.Hash
 
-func traverseSource(srcRef types.Ref, srcDB, sinkDB Database) traverseResult {
+// traverseSource pulls the chunk of srcRef, unless sinkDB already has it. If put is set, it's scheduled to be put to sinkDB; otherwise it's left to the caller to put.
+func traverseSource(srcRef types.Ref, srcDB, sinkDB Database, put bool) traverseResult {
 	h := srcRef.TargetHash()
 	if !sinkDB.has(h) {
 		srcBS := srcDB.validatingBatchStore()
 		c := srcBS.Get(h)
 		v := types.DecodeValue(c, srcDB)
 		d.Chk.True(v != nil, "Expected decoded chunk to be non-nil.")
-		sinkDB.validatingBatchStore().SchedulePut(c, srcRef.Height(), types.Hints{})
-		return traverseResult{h, v.Chunks(), len(c.Data())}
+		res := traverseResult{h, v.Chunks(), len(c.Data()), srcRef, c}
+		if put {
+			sinkDB.validatingBatchStore().SchedulePut(c, srcRef.Height(), types.Hints{})
+			res.c = chunks.EmptyChunk
+		}
+		return res
 	}
-	return traverseResult{}
+	return traverseResult{ref: srcRef}
 }
 
 func traverseSink(sinkRef types.Ref, db Database) traverseResult {
 	if sinkRef.Height() > 1 {
-		return traverseResult{sinkRef.TargetHash(), sinkRef.TargetValue(db).Chunks(), 0}
+		return traverseResult{readHash: sinkRef.TargetHash(), reachables: sinkRef.TargetValue(db).Chunks()}
 	}
 	return traverseResult{}
 }
@@ -261,7 +470,7 @@ func traverseCommon(comRef, sinkHead types.Ref, db Database) traverseResult {
 			}
 			i++
 		}
-		return traverseResult{comRef.TargetHash(), chunks, 0}
+		return traverseResult{readHash: comRef.TargetHash(), reachables: chunks}
 	}
 	return traverseResult{}
 }
@@ -0,0 +1,218 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"encoding/gob"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+const (
+	pullCheckpointInterval  = 10 * time.Second
+	pullCheckpointBatchSize = 1 << 14
+	pullCheckpointState     = "state"
+)
+
+// PullCheckpoint saves the progress of a pull to a directory on local disk, so that if the pull is interrupted, a later one of the same commit into the same sink can pick up where it stopped, rather than starting over. Every so often, the pull flushes what it has written to the sink, and saves the rest of its state -- its queues, and the chunks it has pulled that can't be written to the sink yet because some of what they refer to hasn't been pulled -- alongside. The directory isn't created until the first save, and should be removed once the pulled data has been committed to the sink.
+type PullCheckpoint struct {
+	dir      string
+	interval time.Duration
+	saved    time.Time
+	resumed  PullProgress
+	// batchSize is the most refs a pull takes off its queue at a time, so that it can save its progress part way through a level of a big tree, such as its leaves.
+	batchSize int
+	// interrupt, if set, is called each time the state is saved. Tests use it to stop a pull part way through.
+	interrupt func()
+}
+
+// pullState is what's saved of the state of a pull, in gob encoding. Refs are saved encoded as noms values, since they carry their type and height. The hints found by the pull aren't saved, so a resumed pull does without those found before it was interrupted, which only makes validating what it writes slower.
+type pullState struct {
+	Sources    hash.Digest
+	SinkHead   hash.Digest
+	SrcQ       [][]byte
+	SinkQ      [][]byte
+	Pending    [][]byte
+	DoneCount  uint64
+	KnownCount uint64
+	DoneBytes  uint64
+}
+
+// NewPullCheckpoint returns the checkpoint in dir, which needn't exist yet.
+func NewPullCheckpoint(dir string) *PullCheckpoint {
+	return &PullCheckpoint{dir: dir, interval: pullCheckpointInterval, batchSize: pullCheckpointBatchSize}
+}
+
+// Resumed returns the work that a pull was spared by resuming from cp, or the zero PullProgress if it started afresh.
+func (cp *PullCheckpoint) Resumed() PullProgress {
+	return cp.resumed
+}
+
+// Remove deletes the directory of cp, if it was created.
+func (cp *PullCheckpoint) Remove() error {
+	return os.RemoveAll(cp.dir)
+}
+
+// pullKey identifies a pull by what it pulls and where to.
+func pullKey(sourceRefs types.RefSlice, sinkHeadRef types.Ref) (sources, sinkHead hash.Digest) {
+	data := []byte{}
+	for _, r := range sourceRefs {
+		data = append(data, r.TargetHash().DigestSlice()...)
+	}
+	return hash.FromData(data).Digest(), sinkHeadRef.TargetHash().Digest()
+}
+
+// load returns the saved state of the pull of sourceRefs on top of sinkHeadRef, if there is one.
+func (cp *PullCheckpoint) load(sourceRefs types.RefSlice, sinkHeadRef types.Ref) (st pullState, ok bool) {
+	f, err := os.Open(filepath.Join(cp.dir, pullCheckpointState))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	// A state that can't be decoded is as good as none.
+	if gob.NewDecoder(f).Decode(&st) != nil {
+		return
+	}
+	sources, sinkHead := pullKey(sourceRefs, sinkHeadRef)
+	if st.Sources != sources || st.SinkHead != sinkHead {
+		return
+	}
+	return st, true
+}
+
+// save writes st to a temporary file and renames it into place, so that the saved state is never partly written.
+func (cp *PullCheckpoint) save(st pullState) error {
+	if err := os.MkdirAll(cp.dir, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(cp.dir, pullCheckpointState)
+	f, err := os.Create(path + ".tmp")
+	if err != nil {
+		return err
+	}
+	if err = gob.NewEncoder(f).Encode(st); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	cp.saved = time.Now()
+	return os.Rename(f.Name(), path)
+}
+
+func (cp *PullCheckpoint) due() bool {
+	return time.Since(cp.saved) >= cp.interval
+}
+
+func encodeRefs(q *types.RefByHeight) [][]byte {
+	out := make([][]byte, len(*q))
+	for i, r := range *q {
+		out[i] = types.EncodeValue(r, nil).Data()
+	}
+	return out
+}
+
+func decodeRefs(data [][]byte) *types.RefByHeight {
+	q := make(types.RefByHeight, len(data))
+	for i, b := range data {
+		q[i] = types.DecodeValue(chunks.NewChunk(b), nil).(types.Ref)
+	}
+	return &q
+}
+
+// pendingChunks holds back the chunks that a resumable pull has pulled until everything they refer to has been written to the sink, so that the sink can be flushed part way through the pull: being validating, it refuses chunks that refer to ones it doesn't have. Chunks are written in the order they become complete. Since a pull works down from the tallest refs, every ref to a chunk has been seen by the time the chunk itself is pulled.
+type pendingChunks struct {
+	sink   types.BatchStore
+	chunks map[hash.Hash]*pendingChunk
+	// waiters holds, for each chunk that isn't complete yet, the pending chunks that refer to it.
+	waiters map[hash.Hash][]hash.Hash
+}
+
+type pendingChunk struct {
+	c       chunks.Chunk
+	height  uint64
+	waiting int
+}
+
+func newPendingChunks(sink types.BatchStore) *pendingChunks {
+	return &pendingChunks{sink, map[hash.Hash]*pendingChunk{}, map[hash.Hash][]hash.Hash{}}
+}
+
+// add holds back c, the chunk of a ref of the given height, until the chunks of reachables are complete.
+func (pc *pendingChunks) add(c chunks.Chunk, height uint64, reachables types.RefSlice) {
+	h := c.Hash()
+	pc.chunks[h] = &pendingChunk{c, height, len(reachables)}
+	for _, r := range reachables {
+		pc.waiters[r.TargetHash()] = append(pc.waiters[r.TargetHash()], h)
+	}
+	if len(reachables) == 0 {
+		pc.complete(h)
+	}
+}
+
+// complete records that the chunk of h is in the sink, or has been pulled and can be written to it, and writes any pending chunks that are now complete as a result.
+func (pc *pendingChunks) complete(h hash.Hash) {
+	for done := []hash.Hash{h}; len(done) > 0; {
+		h, done = done[len(done)-1], done[:len(done)-1]
+		if p, ok := pc.chunks[h]; ok {
+			pc.sink.SchedulePut(p.c, p.height, types.Hints{})
+			delete(pc.chunks, h)
+		}
+		for _, w := range pc.waiters[h] {
+			p := pc.chunks[w]
+			if p.waiting--; p.waiting == 0 {
+				done = append(done, w)
+			}
+		}
+		delete(pc.waiters, h)
+	}
+}
+
+func (pc *pendingChunks) encode() [][]byte {
+	out := make([][]byte, 0, len(pc.chunks))
+	for _, p := range pc.chunks {
+		out = append(out, p.c.Data())
+	}
+	return out
+}
+
+// restorePendingChunks returns the pending chunks of a saved pull, given its queue of refs still to be pulled. All of the chunks that they refer to and that aren't pending or queued must be in sinkDB, since they were written to it before the state was saved; if any aren't, e.g. because they were garbage collected since, it returns false.
+func restorePendingChunks(data [][]byte, srcQ *types.RefByHeight, sinkDB Database) (*pendingChunks, bool) {
+	pc := newPendingChunks(sinkDB.validatingBatchStore())
+	incomplete := hash.HashSet{}
+	for _, r := range *srcQ {
+		incomplete.Insert(r.TargetHash())
+	}
+	values := make([]types.Value, len(data))
+	for i, b := range data {
+		values[i] = types.DecodeValue(chunks.NewChunk(b), nil)
+		incomplete.Insert(values[i].Hash())
+	}
+	for i, v := range values {
+		reachables := types.RefSlice{}
+		for _, r := range v.Chunks() {
+			if incomplete.Has(r.TargetHash()) {
+				reachables = append(reachables, r)
+			} else if !sinkDB.has(r.TargetHash()) {
+				return nil, false
+			}
+		}
+		h := v.Hash()
+		pc.chunks[h] = &pendingChunk{chunks.NewChunkWithHash(h, data[i]), types.NewRef(v).Height(), len(reachables)}
+		for _, r := range reachables {
+			pc.waiters[r.TargetHash()] = append(pc.waiters[r.TargetHash()], h)
+		}
+	}
+	return pc, true
+}
@@ -0,0 +1,119 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestResumablePull(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	sourceCS := chunks.NewTestStore()
+	source := NewDatabase(sourceCS)
+	l := buildListOfHeight(6, source)
+	source, err = source.Commit(dsID, NewCommit(l, types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	sourceRef := source.HeadRef(dsID)
+	reads := sourceCS.Reads
+	Pull(source, NewDatabase(chunks.NewTestStore()), sourceRef, types.Ref{}, 2, nil)
+	fullReads := sourceCS.Reads - reads
+
+	// Stop the first pull once it has written some of what it pulled to the sink, as if it had been killed.
+	sinkCS := chunks.NewTestStore()
+	cp := NewPullCheckpoint(dir)
+	cp.interval, cp.batchSize = 0, 2
+	cp.interrupt = func() {
+		if sinkCS.Writes > 0 {
+			panic(errors.New("interrupted"))
+		}
+	}
+	assert.Panics(func() {
+		ResumablePull(source, NewDatabase(sinkCS), sourceRef, types.Ref{}, 0, 2, nil, cp)
+	})
+	assert.Zero(cp.Resumed().DoneCount)
+
+	// What was pulled before the last save has been written to the sink, other than the chunks that refer to some that hadn't been pulled yet, which are all that's saved.
+	st, ok := cp.load(types.RefSlice{sourceRef}, types.Ref{})
+	assert.True(ok)
+	assert.NotEmpty(st.Pending)
+	assert.True(sinkCS.Writes > 0)
+	assert.Equal(int(st.DoneCount), len(st.Pending)+sinkCS.Writes)
+
+	cp = NewPullCheckpoint(dir)
+	sink := NewDatabase(sinkCS)
+	reads = sourceCS.Reads
+	ResumablePull(source, sink, sourceRef, types.Ref{}, 0, 2, nil, cp)
+	assert.True(cp.Resumed().DoneCount > 0)
+	assert.True(cp.Resumed().DoneBytes > 0)
+
+	// The chunks pulled before the interruption weren't read from source again.
+	assert.Equal(fullReads-int(cp.Resumed().DoneCount), sourceCS.Reads-reads)
+	sink, err = sink.Commit(dsID, sourceRef.TargetValue(sink).(types.Struct))
+	assert.NoError(err)
+	assert.True(l.Equals(sink.Head(dsID).Get(ValueField)))
+	sink.Close()
+
+	assert.NoError(cp.Remove())
+	_, err = os.Stat(dir)
+	assert.True(os.IsNotExist(err))
+}
+
+func TestResumablePullIgnoresOtherPulls(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	source := NewDatabase(chunks.NewTestStore())
+	source, err = source.Commit(dsID, NewCommit(buildListOfHeight(3, source), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	first := source.HeadRef(dsID)
+	source, err = source.Commit(dsID, NewCommit(buildListOfHeight(4, source), types.NewSet(first), types.EmptyStruct))
+	assert.NoError(err)
+
+	cp := NewPullCheckpoint(dir)
+	cp.interval = 0
+	ResumablePull(source, NewDatabase(chunks.NewTestStore()), first, types.Ref{}, 0, 2, nil, cp)
+
+	// A checkpoint left by a pull of a different commit isn't resumed from.
+	sink := NewDatabase(chunks.NewTestStore())
+	ResumablePull(source, sink, source.HeadRef(dsID), types.Ref{}, 0, 2, nil, cp)
+	assert.Zero(cp.Resumed().DoneCount)
+	sink, err = sink.Commit(dsID, source.HeadRef(dsID).TargetValue(sink).(types.Struct))
+	assert.NoError(err)
+	sink.Close()
+}
+
+func TestResumablePullUpToDate(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	source := NewDatabase(chunks.NewTestStore())
+	source, err = source.Commit(dsID, NewCommit(types.Number(42), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	sink := NewDatabase(chunks.NewTestStore())
+	Pull(source, sink, source.HeadRef(dsID), types.Ref{}, 2, nil)
+
+	// There's nothing to pull, so nothing is saved.
+	cp := NewPullCheckpoint(filepath.Join(dir, "cp"))
+	cp.interval = 0
+	ResumablePull(source, sink, source.HeadRef(dsID), types.Ref{}, 0, 2, nil, cp)
+	_, err = os.Stat(filepath.Join(dir, "cp"))
+	assert.True(os.IsNotExist(err))
+}
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/attic-labs/testify/assert"
 	"github.com/attic-labs/testify/suite"
@@ -307,6 +308,72 @@ func (suite *PullSuite) TestPullUpdates() {
 	suite.True(srcL.Equals(v.Get(ValueField)))
 }
 
+// Source: C4 -> C3 -> C2 -> C1
+//
+// Sink: Nada, then C4 -> C3 with C2 missing, then C4 -> C3 -> C2 with C1 missing, then everything.
+func (suite *PullSuite) TestPullShallow() {
+	sourceRefs := []types.Ref{}
+	parents := types.NewSet()
+	for i := 1; i <= 4; i++ {
+		sourceRefs = append(sourceRefs, suite.commitToSource(buildListOfHeight(i, suite.source), parents))
+		parents = types.NewSet(sourceRefs[i-1])
+	}
+	sourceRef := sourceRefs[3]
+	if !suite.sinkIsLocal() {
+		suite.Panics(func() { PullShallow(suite.source, suite.sink, sourceRef, types.Ref{}, 2, 2, nil) })
+		return
+	}
+	hasCommit := func(i int) bool {
+		return suite.sinkCS.Has(sourceRefs[i-1].TargetHash())
+	}
+
+	PullShallow(suite.source, suite.sink, sourceRef, types.Ref{}, 2, 2, nil)
+	suite.True(hasCommit(4))
+	suite.True(hasCommit(3))
+	suite.False(hasCommit(2))
+	suite.Equal(hash.HashSet{sourceRefs[1].TargetHash(): struct{}{}}, missingParentsOf(suite.sink))
+
+	var err error
+	suite.sink, err = suite.sink.Commit(dsID, sourceRef.TargetValue(suite.sink).(types.Struct))
+	suite.NoError(err)
+	suite.Equal(1, int(suite.sink.Datasets().Len()))
+	v := suite.sink.Head(dsID).Get(ValueField)
+	suite.True(buildListOfHeight(4, suite.source).Equals(v))
+
+	// A commit on top of a shallow head doesn't need its missing history.
+	suite.sink, err = suite.sink.Commit(dsID, NewCommit(types.Number(42), types.NewSet(sourceRef), types.EmptyStruct))
+	suite.NoError(err)
+
+	PullShallow(suite.source, suite.sink, sourceRef, suite.sink.HeadRef(dsID), 3, 2, nil)
+	suite.True(hasCommit(2))
+	suite.False(hasCommit(1))
+	suite.Equal(hash.HashSet{sourceRefs[0].TargetHash(): struct{}{}}, missingParentsOf(suite.sink))
+
+	Pull(suite.source, suite.sink, sourceRef, suite.sink.HeadRef(dsID), 2, nil)
+	suite.True(hasCommit(1))
+	suite.Empty(missingParentsOf(suite.sink))
+	suite.Equal(1, int(suite.sink.Datasets().Len()))
+}
+
+func (suite *PullSuite) TestPullFromShallow() {
+	if !suite.sinkIsLocal() {
+		return
+	}
+	r1 := suite.commitToSource(buildListOfHeight(1, suite.source), types.NewSet())
+	r2 := suite.commitToSource(buildListOfHeight(2, suite.source), types.NewSet(r1))
+	r3 := suite.commitToSource(buildListOfHeight(3, suite.source), types.NewSet(r2))
+	PullShallow(suite.source, suite.sink, r3, types.Ref{}, 2, 2, nil)
+
+	otherCS := chunks.NewTestStore()
+	other := NewDatabase(otherCS)
+	defer other.Close()
+	Pull(suite.sink, other, r3, types.Ref{}, 2, nil)
+	suite.True(otherCS.Has(r3.TargetHash()))
+	suite.True(otherCS.Has(r2.TargetHash()))
+	suite.False(otherCS.Has(r1.TargetHash()))
+	suite.Equal(missingParentsOf(suite.sink), missingParentsOf(other))
+}
+
 func (suite *PullSuite) commitToSource(v types.Value, p types.Set) types.Ref {
 	var err error
 	suite.source, err = suite.source.Commit(dsID, NewCommit(v, p, types.EmptyStruct))
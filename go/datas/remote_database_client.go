@@ -5,18 +5,45 @@
 package datas
 
 import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/types"
 	"github.com/julienschmidt/httprouter"
 )
 
+// socketBaseURL is the base URL of requests sent over a unix domain socket. Only its path matters to the server.
+const socketBaseURL = "http://unix/"
+
 // Database provides versioned storage for noms values. Each Database instance represents one moment in history. Heads() returns the Commit from each active fork at that moment. The Commit() method returns a new Database, representing a new moment in history.
 type RemoteDatabaseClient struct {
 	databaseCommon
 }
 
 func NewRemoteDatabase(baseURL, auth string) *RemoteDatabaseClient {
-	httpBS := newHTTPBatchStore(baseURL, auth)
+	return newRemoteDatabaseClient(newHTTPBatchStore(baseURL, auth))
+}
+
+// NewRemoteDatabaseWithTLS is like NewRemoteDatabase, but its https connections are configured by tlsConfig, e.g. to trust a private CA or to present a client certificate. See NewClientTLSConfig.
+func NewRemoteDatabaseWithTLS(baseURL, auth string, tlsConfig *tls.Config) *RemoteDatabaseClient {
+	client := makeHTTPClient(httpChunkSinkConcurrency)
+	client.Transport.(*http.Transport).TLSClientConfig = tlsConfig
+	return newRemoteDatabaseClient(newHTTPBatchStoreWithClient(baseURL, auth, client))
+}
+
+// NewSocketDatabase returns a Database served over the unix domain socket at socketPath, e.g. by `noms serve --socket`.
+func NewSocketDatabase(socketPath, auth string) *RemoteDatabaseClient {
+	client := makeHTTPClient(httpChunkSinkConcurrency)
+	client.Transport.(*http.Transport).DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+	}
+	return newRemoteDatabaseClient(newHTTPBatchStoreWithClient(socketBaseURL, auth, client))
+}
+
+func newRemoteDatabaseClient(httpBS *httpBatchStore) *RemoteDatabaseClient {
 	return &RemoteDatabaseClient{newDatabaseCommon(newCachingChunkHaver(httpBS), types.NewValueStore(httpBS), httpBS)}
 }
 
@@ -28,17 +55,17 @@ func (rds *RemoteDatabaseClient) validatingBatchStore() (bs types.BatchStore) {
 
 func (rds *RemoteDatabaseClient) Commit(datasetID string, commit types.Struct) (Database, error) {
 	err := rds.doCommit(datasetID, commit)
-	return &RemoteDatabaseClient{newDatabaseCommon(rds.cch, rds.vs, rds.rt)}, err
+	return &RemoteDatabaseClient{rds.snapshot()}, err
 }
 
 func (rds *RemoteDatabaseClient) Delete(datasetID string) (Database, error) {
 	err := rds.doDelete(datasetID)
-	return &RemoteDatabaseClient{newDatabaseCommon(rds.cch, rds.vs, rds.rt)}, err
+	return &RemoteDatabaseClient{rds.snapshot()}, err
 }
 
 func (rds *RemoteDatabaseClient) SetHead(datasetID string, commit types.Struct) (Database, error) {
 	err := rds.doSetHead(datasetID, commit)
-	return &RemoteDatabaseClient{newDatabaseCommon(rds.cch, rds.vs, rds.rt)}, err
+	return &RemoteDatabaseClient{rds.snapshot()}, err
 }
 
 func (f RemoteStoreFactory) CreateStore(ns string) Database {
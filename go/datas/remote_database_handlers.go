@@ -27,6 +27,12 @@ type URLParams interface {
 
 type Handler func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore)
 
+// batchReader is implemented by ChunkStores that read many chunks much faster together than one at a time, like a ReplicaStore, which reads the chunks its cache doesn't have from upstream.
+type batchReader interface {
+	prefetch(hashes hash.HashSlice)
+	hasMany(hashes hash.HashSlice) map[hash.Hash]bool
+}
+
 // NomsVersionHeader is the name of the header that Noms clients and servers must set in every request/response.
 const NomsVersionHeader = "x-noms-vers"
 
@@ -50,6 +56,9 @@ var (
 	// HandleWriteValue is meant to handle HTTP POST requests to the root/ server endpoint. This is used to update the Root to point to a new Chunk.
 	// TODO: Nice comment about what headers it expects/honors, payload format, and error responses.
 	HandleRootPost = versionCheck(handleRootPost)
+
+	// HandleRootWatch is meant to handle HTTP GET requests to the watch/ server endpoint. Given the hash of the Root the client last saw in the "last" query param, the server waits for the Root to change and returns its new hash as a string. If it doesn't change within a while, the server returns "last" unchanged and the client should ask again.
+	HandleRootWatch = versionCheck(handleRootWatch)
 )
 
 func versionCheck(hndlr Handler) Handler {
@@ -88,7 +97,9 @@ func handleWriteValue(w http.ResponseWriter, req *http.Request, ps URLParams, cs
 	chunkChan := make(chan *chunks.Chunk, 16)
 	go chunks.DeserializeToChan(reader, chunkChan)
 	var bpe chunks.BackpressureError
+	count := 0
 	for c := range chunkChan {
+		count++
 		if bpe == nil {
 			bpe = vbs.Enqueue(*c)
 		} else {
@@ -97,6 +108,7 @@ func handleWriteValue(w http.ResponseWriter, req *http.Request, ps URLParams, cs
 		// If a previous Enqueue() errored, we still need to drain chunkChan
 		// TODO: what about having DeserializeToChan take a 'done' channel to stop it?
 	}
+	recordBatchSize(req, count)
 	if bpe == nil {
 		bpe = vbs.Flush()
 	}
@@ -167,6 +179,9 @@ func handleGetRefs(w http.ResponseWriter, req *http.Request, ps URLParams, cs ch
 	d.PanicIfTrue(req.Method != "POST", "Expected post method.")
 
 	hashes := extractHashes(req)
+	if br, ok := cs.(batchReader); ok {
+		br.prefetch(hashes)
+	}
 
 	w.Header().Add("Content-Type", "application/octet-stream")
 	writer := respWriter(req, w)
@@ -190,6 +205,7 @@ func extractHashes(req *http.Request) hash.HashSlice {
 	for idx, refStr := range hashStrs {
 		hashes[idx] = hash.Parse(refStr)
 	}
+	recordBatchSize(req, len(hashes))
 	return hashes
 }
 
@@ -206,12 +222,18 @@ func handleHasRefs(w http.ResponseWriter, req *http.Request, ps URLParams, cs ch
 
 	hashes := extractHashes(req)
 
+	has := cs.Has
+	if br, ok := cs.(batchReader); ok {
+		found := br.hasMany(hashes)
+		has = func(h hash.Hash) bool { return found[h] }
+	}
+
 	w.Header().Add("Content-Type", "text/plain")
 	writer := respWriter(req, w)
 	defer writer.Close()
 
 	for _, h := range hashes {
-		fmt.Fprintf(writer, "%s %t\n", h, cs.Has(h))
+		fmt.Fprintf(writer, "%s %t\n", h, has(h))
 	}
 }
 
@@ -245,11 +267,32 @@ func handleRootPost(w http.ResponseWriter, req *http.Request, ps URLParams, cs c
 	if !m.Empty() && !isMapOfStringToRefOfCommit(m) {
 		panic(d.Wrap(fmt.Errorf("Root of a Database must be a Map<String, Ref<Commit>>, not %s", m.Type().Describe())))
 	}
+	// The record of missing parents must be one that Databases can read. vs isn't closed, since that would close cs.
+	vs := types.NewValueStore(types.NewBatchStoreAdaptor(cs))
+	_, err := missingParentsIn(m, vs)
+	d.PanicIfError(err)
 
 	if !cs.UpdateRoot(current, last) {
 		w.WriteHeader(http.StatusConflict)
 		return
 	}
+	rootUpdated(cs)
+}
+
+func handleRootWatch(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+	d.PanicIfTrue(req.Method != "GET", "Expected get method.")
+
+	tokens := req.URL.Query()["last"]
+	d.PanicIfTrue(len(tokens) != 1, `Expected "last" query param value`)
+	last := hash.Parse(tokens[0])
+
+	recordLongPoll(req)
+	root, ok := waitForRoot(cs, last, watchTimeout, req.Context().Done())
+	if !ok {
+		return // The client has gone away.
+	}
+	w.Header().Add("content-type", "text/plain")
+	fmt.Fprintf(w, "%v", root.String())
 }
 
 func isMapOfStringToRefOfCommit(m types.Map) bool {
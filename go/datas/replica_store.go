@@ -0,0 +1,210 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+)
+
+// ErrReplicaWrite is returned when something tries to write to a ReplicaStore directly, rather than through the upstream database.
+var ErrReplicaWrite = errors.New("A replica can't be written to directly, only through its upstream database")
+
+// ReplicaStore is a ChunkStore that serves a replica of the database at an upstream URL, e.g. another noms serve, so that clients far from it can read it quickly without all of them hitting it. Chunks are read from a local cache, and those it doesn't have are fetched from upstream, in batches, and added to it. Since chunks never change, nothing in the cache ever goes stale, but the Root does, so it's only cached for a short while. A ReplicaStore can't be written to: a server of one handles writes with WriteHandler, which forwards them upstream.
+type ReplicaStore struct {
+	cache    chunks.ChunkStore
+	upstream *httpBatchStore
+	proxy    *httputil.ReverseProxy
+	rootTTL  time.Duration
+	mu       *sync.Mutex
+	root     hash.Hash
+	rootTime time.Time
+	// If set, WriteHandler refuses writes rather than forwarding them upstream.
+	ReadOnly bool
+}
+
+// NewReplicaStore returns a ReplicaStore of the database at upstreamURL, which it reaches with the Authorization header auth and, if it isn't nil, tlsConfig. Chunks read from upstream are cached in cache, and the upstream Root is only read again once it's older than rootTTL.
+func NewReplicaStore(upstreamURL, auth string, tlsConfig *tls.Config, cache chunks.ChunkStore, rootTTL time.Duration) *ReplicaStore {
+	client := makeHTTPClient(httpChunkSinkConcurrency)
+	client.Transport.(*http.Transport).TLSClientConfig = tlsConfig
+	upstream := newHTTPBatchStoreWithClient(upstreamURL, auth, client)
+
+	// Writes are made with the credentials of the client that makes them, not those the replica reads with.
+	target := *upstream.host
+	target.RawQuery = ""
+	proxy := httputil.NewSingleHostReverseProxy(&target)
+	proxy.Transport = client.Transport
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Host = target.Host
+	}
+
+	rs := &ReplicaStore{cache: cache, upstream: upstream, proxy: proxy, rootTTL: rootTTL, mu: &sync.Mutex{}}
+	proxy.ModifyResponse = rs.forwarded
+	return rs
+}
+
+func (rs *ReplicaStore) Get(h hash.Hash) chunks.Chunk {
+	if c := rs.cache.Get(h); !c.IsEmpty() {
+		return c
+	}
+	return rs.fetch(hash.HashSlice{h})[h]
+}
+
+func (rs *ReplicaStore) Has(h hash.Hash) bool {
+	return rs.cache.Has(h) || rs.hasMany(hash.HashSlice{h})[h]
+}
+
+// prefetch makes sure that those of hashes that upstream has are in the cache, by fetching the ones that aren't in batches, rather than one at a time as Get would.
+func (rs *ReplicaStore) prefetch(hashes hash.HashSlice) {
+	missing := hash.HashSlice{}
+	for _, h := range hashes {
+		if !rs.cache.Has(h) {
+			missing = append(missing, h)
+		}
+	}
+	rs.fetch(missing)
+}
+
+// fetch reads the chunks of hashes from upstream, adds them to the cache and returns them. Chunks upstream doesn't have are left out.
+func (rs *ReplicaStore) fetch(hashes hash.HashSlice) map[hash.Hash]chunks.Chunk {
+	found := map[hash.Hash]chunks.Chunk{}
+	eachBatch(hashes, func(batch hash.HashSet) {
+		chans := map[hash.Hash]chan chunks.Chunk{}
+		rb := chunks.ReadBatch{}
+		for h := range batch {
+			chans[h] = make(chan chunks.Chunk, 1)
+			rb[h] = []chunks.OutstandingRequest{chunks.OutstandingGet(chans[h])}
+		}
+		rs.upstream.getRefs(batch, rb)
+		rb.Close()
+
+		cs := []chunks.Chunk{}
+		for h, ch := range chans {
+			if c := <-ch; !c.IsEmpty() {
+				found[h] = c
+				cs = append(cs, c)
+			}
+		}
+		d.PanicIfTrue(rs.cache.PutMany(cs) != nil, "The cache refused chunks")
+	})
+	return found
+}
+
+// hasMany returns which of hashes are in the cache or upstream, asking upstream about the ones that aren't in the cache in batches, rather than one at a time as Has would.
+func (rs *ReplicaStore) hasMany(hashes hash.HashSlice) map[hash.Hash]bool {
+	has := map[hash.Hash]bool{}
+	missing := hash.HashSlice{}
+	for _, h := range hashes {
+		if has[h] = rs.cache.Has(h); !has[h] {
+			missing = append(missing, h)
+		}
+	}
+	eachBatch(missing, func(batch hash.HashSet) {
+		chans := map[hash.Hash]chan bool{}
+		rb := chunks.ReadBatch{}
+		for h := range batch {
+			chans[h] = make(chan bool, 1)
+			rb[h] = []chunks.OutstandingRequest{chunks.OutstandingHas(chans[h])}
+		}
+		rs.upstream.hasRefs(batch, rb)
+		rb.Close()
+
+		for h, ch := range chans {
+			has[h] = <-ch
+		}
+	})
+	return has
+}
+
+// eachBatch calls f with the distinct hashes of hashes, at most readBufferSize at a time.
+func eachBatch(hashes hash.HashSlice, f func(batch hash.HashSet)) {
+	batch := hash.HashSet{}
+	for _, h := range hashes {
+		batch.Insert(h)
+		if len(batch) == readBufferSize {
+			f(batch)
+			batch = hash.HashSet{}
+		}
+	}
+	if len(batch) > 0 {
+		f(batch)
+	}
+}
+
+func (rs *ReplicaStore) Version() string {
+	return rs.cache.Version()
+}
+
+// Root returns the upstream Root, as of at most rootTTL ago.
+func (rs *ReplicaStore) Root() hash.Hash {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	if rs.rootTime.IsZero() || time.Since(rs.rootTime) >= rs.rootTTL {
+		rs.root, rs.rootTime = rs.upstream.Root(), time.Now()
+	}
+	return rs.root
+}
+
+// forgetRoot makes the next call to Root read it from upstream, e.g. because a write that was forwarded there may have changed it.
+func (rs *ReplicaStore) forgetRoot() {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	rs.rootTime = time.Time{}
+}
+
+func (rs *ReplicaStore) Put(c chunks.Chunk) {
+	d.PanicIfError(ErrReplicaWrite)
+}
+
+func (rs *ReplicaStore) PutMany(chnx []chunks.Chunk) chunks.BackpressureError {
+	// Databases flush their pending writes when they're closed, even if there are none.
+	if len(chnx) > 0 {
+		d.PanicIfError(ErrReplicaWrite)
+	}
+	return nil
+}
+
+func (rs *ReplicaStore) UpdateRoot(current, last hash.Hash) bool {
+	d.PanicIfError(ErrReplicaWrite)
+	return false
+}
+
+func (rs *ReplicaStore) Close() error {
+	rs.upstream.Close()
+	return rs.cache.Close()
+}
+
+// WriteHandler returns a Handler for the writeValue/ and root/ POST endpoints of a server of rs, which forwards requests to upstream, with the client's credentials, and upstream's responses back. If rs is ReadOnly, it refuses them instead.
+func (rs *ReplicaStore) WriteHandler() Handler {
+	return func(w http.ResponseWriter, req *http.Request, ps URLParams, cs chunks.ChunkStore) {
+		if rs.ReadOnly {
+			http.Error(w, "Error: This server is a read-only replica", http.StatusForbidden)
+			return
+		}
+		rs.proxy.ServeHTTP(w, req)
+	}
+}
+
+// forwarded is called with upstream's responses to forwarded writes. The server of rs sets the CORS and version headers itself, so upstream's are dropped rather than repeated.
+func (rs *ReplicaStore) forwarded(res *http.Response) error {
+	for k := range res.Header {
+		if strings.HasPrefix(k, "Access-Control-") || k == http.CanonicalHeaderKey(NomsVersionHeader) {
+			res.Header.Del(k)
+		}
+	}
+	rs.forgetRoot()
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestReplicaStoreSuite(t *testing.T) {
+	suite.Run(t, &ReplicaStoreSuite{})
+}
+
+type ReplicaStoreSuite struct {
+	suite.Suite
+	upstreamCS *chunks.MemoryStore
+	upstream   *httptest.Server
+	cache      *chunks.MemoryStore
+	replica    *ReplicaStore
+	server     *httptest.Server
+	mu         *sync.Mutex
+	requests   map[string]int
+}
+
+func (suite *ReplicaStoreSuite) SetupTest() {
+	suite.mu, suite.requests = &sync.Mutex{}, map[string]int{}
+	suite.upstreamCS = chunks.NewMemoryStore()
+	handler := NewRemoteDatabaseServer(suite.upstreamCS, 0).newHTTPServer().Handler
+	suite.upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		suite.mu.Lock()
+		suite.requests[req.Method+" "+req.URL.Path]++
+		suite.mu.Unlock()
+		handler.ServeHTTP(w, req)
+	}))
+
+	suite.cache = chunks.NewMemoryStore()
+	suite.replica = NewReplicaStore(suite.upstream.URL, "", nil, suite.cache, time.Hour)
+	server := NewRemoteDatabaseServer(suite.replica, 0)
+	server.WriteHandler = suite.replica.WriteHandler()
+	suite.server = httptest.NewServer(server.newHTTPServer().Handler)
+}
+
+func (suite *ReplicaStoreSuite) TearDownTest() {
+	suite.server.Close()
+	suite.replica.Close()
+	suite.upstream.Close()
+}
+
+func (suite *ReplicaStoreSuite) requestCount(method, path string) int {
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	return suite.requests[method+" "+path]
+}
+
+func (suite *ReplicaStoreSuite) commitUpstream(v types.Value) {
+	var db Database = NewRemoteDatabase(suite.upstream.URL, "")
+	defer db.Close()
+	parents := types.NewSet()
+	if head, ok := db.MaybeHeadRef("ds"); ok {
+		parents = types.NewSet(head)
+	}
+	_, err := db.Commit("ds", NewCommit(v, parents, types.EmptyStruct))
+	suite.NoError(err)
+}
+
+func (suite *ReplicaStoreSuite) TestReadsAreCached() {
+	suite.commitUpstream(types.String("hello"))
+
+	db := NewRemoteDatabase(suite.server.URL, "")
+	defer db.Close()
+	head := db.Head("ds")
+	suite.True(types.String("hello").Equals(head.Get(ValueField)))
+	suite.True(suite.cache.Has(head.Hash()))
+
+	// Once they're cached, chunks aren't read from upstream again.
+	gets := suite.requestCount("POST", constants.GetRefsPath)
+	other := NewRemoteDatabase(suite.server.URL, "")
+	defer other.Close()
+	suite.True(types.String("hello").Equals(other.Head("ds").Get(ValueField)))
+	suite.Equal(gets, suite.requestCount("POST", constants.GetRefsPath))
+}
+
+func (suite *ReplicaStoreSuite) TestMissesAreFetchedInBatches() {
+	hashes := hash.HashSlice{}
+	for i := 0; i < 10; i++ {
+		c := types.EncodeValue(types.Number(i), nil)
+		suite.upstreamCS.Put(c)
+		hashes = append(hashes, c.Hash())
+	}
+	missing := types.EncodeValue(types.String("missing"), nil).Hash()
+	suite.cache.Put(suite.upstreamCS.Get(hashes[0]))
+
+	suite.replica.prefetch(append(hashes, missing))
+	suite.Equal(1, suite.requestCount("POST", constants.GetRefsPath))
+	for _, h := range hashes {
+		suite.True(suite.cache.Has(h))
+	}
+	suite.False(suite.cache.Has(missing))
+
+	has := suite.replica.hasMany(hash.HashSlice{hashes[1], missing})
+	suite.Equal(map[hash.Hash]bool{hashes[1]: true, missing: false}, has)
+	suite.Equal(1, suite.requestCount("POST", constants.HasRefsPath))
+}
+
+func (suite *ReplicaStoreSuite) TestRootIsCached() {
+	suite.commitUpstream(types.Number(1))
+	root := suite.replica.Root()
+	suite.Equal(suite.upstreamCS.Root(), root)
+
+	suite.commitUpstream(types.Number(2))
+	suite.Equal(root, suite.replica.Root())
+	suite.replica.forgetRoot()
+	suite.Equal(suite.upstreamCS.Root(), suite.replica.Root())
+
+	suite.replica.rootTTL = 0
+	suite.commitUpstream(types.Number(3))
+	suite.Equal(suite.upstreamCS.Root(), suite.replica.Root())
+}
+
+func (suite *ReplicaStoreSuite) TestWritesAreForwarded() {
+	suite.commitUpstream(types.Number(1))
+	suite.replica.Root()
+	writes, rootPosts := suite.requestCount("POST", constants.WriteValuePath), suite.requestCount("POST", constants.RootPath)
+
+	var db Database = NewRemoteDatabase(suite.server.URL, "")
+	defer db.Close()
+	db, err := db.Commit("ds", NewCommit(types.Number(2), types.NewSet(db.HeadRef("ds")), types.EmptyStruct))
+	suite.NoError(err)
+	suite.Equal(writes+1, suite.requestCount("POST", constants.WriteValuePath))
+	suite.Equal(rootPosts+1, suite.requestCount("POST", constants.RootPath))
+
+	// The replica sees its own writes straight away, despite caching the root.
+	suite.Equal(suite.upstreamCS.Root(), suite.replica.Root())
+	upstream := NewRemoteDatabase(suite.upstream.URL, "")
+	defer upstream.Close()
+	suite.True(types.Number(2).Equals(upstream.Head("ds").Get(ValueField)))
+}
+
+func (suite *ReplicaStoreSuite) TestReadOnly() {
+	suite.commitUpstream(types.Number(1))
+	suite.replica.ReadOnly = true
+	root := suite.upstreamCS.Root()
+	writes, rootPosts := suite.requestCount("POST", constants.WriteValuePath), suite.requestCount("POST", constants.RootPath)
+
+	for _, path := range []string{constants.WriteValuePath, constants.RootPath + "?last=" + root.String() + "&current=" + root.String()} {
+		req, err := http.NewRequest("POST", suite.server.URL+path, nil)
+		suite.NoError(err)
+		req.Header.Set(NomsVersionHeader, constants.NomsVersion)
+		res, err := http.DefaultClient.Do(req)
+		suite.NoError(err)
+		res.Body.Close()
+		suite.Equal(http.StatusForbidden, res.StatusCode)
+	}
+	suite.Equal(writes, suite.requestCount("POST", constants.WriteValuePath))
+	suite.Equal(rootPosts, suite.requestCount("POST", constants.RootPath))
+	suite.Panics(func() { suite.replica.Put(chunks.NewChunk([]byte("abc"))) })
+}
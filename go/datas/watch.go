@@ -0,0 +1,156 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+const (
+	// watchPollInterval is how often the root of a ChunkStore is checked while waiting for it to change. Changes made in this process are noticed straight away, but those made by other processes sharing the store are only noticed by polling.
+	watchPollInterval = time.Second
+
+	// watchTimeout is how long the server holds a request to the watch endpoint before responding that the root hasn't changed, so that the request doesn't time out.
+	watchTimeout = 30 * time.Second
+)
+
+// rootWatchers holds, for each ChunkStore, channels to close when its root is updated in this process.
+var rootWatchers = struct {
+	mu sync.Mutex
+	m  map[chunks.ChunkStore]map[chan struct{}]struct{}
+}{m: map[chunks.ChunkStore]map[chan struct{}]struct{}{}}
+
+// rootUpdated wakes up everything waiting for the root of cs to change.
+func rootUpdated(cs chunks.ChunkStore) {
+	rootWatchers.mu.Lock()
+	defer rootWatchers.mu.Unlock()
+	for ch := range rootWatchers.m[cs] {
+		close(ch)
+	}
+	delete(rootWatchers.m, cs)
+}
+
+// watchRoot returns a channel that's closed the next time rootUpdated(cs) is called, and a func to call if it's no longer needed.
+func watchRoot(cs chunks.ChunkStore) (<-chan struct{}, func()) {
+	rootWatchers.mu.Lock()
+	defer rootWatchers.mu.Unlock()
+	ch := make(chan struct{})
+	if rootWatchers.m[cs] == nil {
+		rootWatchers.m[cs] = map[chan struct{}]struct{}{}
+	}
+	rootWatchers.m[cs][ch] = struct{}{}
+	return ch, func() {
+		rootWatchers.mu.Lock()
+		defer rootWatchers.mu.Unlock()
+		delete(rootWatchers.m[cs], ch)
+	}
+}
+
+// waitForRoot waits for the root of cs to be something other than last, and returns it. If that doesn't happen within timeout, it returns last; a timeout of 0 means waiting for as long as it takes. If done is closed first, it returns false.
+func waitForRoot(cs chunks.ChunkStore, last hash.Hash, timeout time.Duration, done <-chan struct{}) (hash.Hash, bool) {
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		deadline = time.After(timeout)
+	}
+	for {
+		// Start watching before reading the root, so that an update in between isn't missed.
+		updated, stop := watchRoot(cs)
+		root := cs.Root()
+		if root != last {
+			stop()
+			return root, true
+		}
+		select {
+		case <-updated:
+		case <-time.After(watchPollInterval):
+			stop()
+		case <-deadline:
+			stop()
+			return last, true
+		case <-done:
+			stop()
+			return last, false
+		}
+	}
+}
+
+// watch implements Watch(). nextRoot(last) should wait for the root of the Database to be something other than last and return it, or return false if the Database has been closed. The roots it returns are read from vr.
+func (ds *databaseCommon) watch(datasetID string, vr types.ValueReader, nextRoot func(last hash.Hash) (hash.Hash, bool)) <-chan types.Ref {
+	ch := make(chan types.Ref)
+	go func() {
+		defer close(ch)
+		var last hash.Hash
+		var head types.Ref
+		// If the Database can no longer be reached, there's nothing more to send.
+		d.Try(func() {
+			for {
+				root, ok := nextRoot(last)
+				if !ok {
+					return
+				}
+				last = root
+				r, ok := vr.ReadValue(root).(types.Map).MaybeGet(types.String(datasetID))
+				if !ok {
+					head = types.Ref{}
+					continue
+				}
+				if r.(types.Ref).TargetHash() == head.TargetHash() {
+					continue
+				}
+				head = r.(types.Ref)
+				select {
+				case ch <- head:
+				case <-ds.closed.ch:
+					return
+				}
+			}
+		})
+	}()
+	return ch
+}
+
+// Watch sends the head of datasetID on the returned channel, and then each new head as the dataset is committed to, whether through this Database or by another process. If the dataset doesn't exist yet, its first head is the first thing sent; if it's deleted, nothing is sent until it's committed to again. The channel is closed when the Database is closed.
+func (lds *LocalDatabase) Watch(datasetID string) <-chan types.Ref {
+	// The watch reads roots through a ValueStore of its own, since validatingBatchStore() may replace lds.vs while it runs.
+	vs := types.NewValueStore(types.NewBatchStoreAdaptor(lds.cs))
+	return lds.watch(datasetID, vs, func(last hash.Hash) (hash.Hash, bool) {
+		return waitForRoot(lds.cs, last, 0, lds.closed.ch)
+	})
+}
+
+// Watch sends the head of datasetID on the returned channel, and then each new head as the dataset is committed to, whether through this Database or by any other client of the server. If the dataset doesn't exist yet, its first head is the first thing sent; if it's deleted, nothing is sent until it's committed to again. The channel is closed when the Database is closed, or if the server can no longer be reached.
+func (rds *RemoteDatabaseClient) Watch(datasetID string) <-chan types.Ref {
+	bs := rds.rt.(*httpBatchStore)
+	return rds.watch(datasetID, rds.vs, func(last hash.Hash) (hash.Hash, bool) {
+		for {
+			// A connection over a pipe can only carry one request at a time, so rather than tie it up with requests to the watch endpoint, the root is polled.
+			if _, ok := bs.httpClient.(*pipeDoer); ok {
+				select {
+				case <-time.After(watchPollInterval):
+				case <-rds.closed.ch:
+					return last, false
+				}
+				if root := bs.Root(); root != last {
+					return root, true
+				}
+				continue
+			}
+
+			root, ok := bs.watchRoot(last, rds.closed.ch)
+			if !ok {
+				return last, false
+			}
+			if root != last {
+				return root, true
+			}
+		}
+	})
+}
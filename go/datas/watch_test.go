@@ -0,0 +1,104 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package datas
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+// nextHead returns the next head sent by Watch(), or false if there isn't one within a few seconds.
+func nextHead(heads <-chan types.Ref) (types.Ref, bool) {
+	select {
+	case r, ok := <-heads:
+		return r, ok
+	case <-time.After(5 * time.Second):
+		return types.Ref{}, false
+	}
+}
+
+// assertWatch commits to "ds" through db and other, and checks that each new head is sent on heads.
+func assertWatch(assert *assert.Assertions, heads <-chan types.Ref, db, other Database) {
+	commit := func(db Database, v string, parents ...types.Value) (Database, types.Ref) {
+		db, err := db.Commit("ds", NewCommit(types.String(v), types.NewSet(parents...), types.EmptyStruct))
+		assert.NoError(err)
+		return db, db.HeadRef("ds")
+	}
+
+	db, first := commit(db, "first")
+	r, ok := nextHead(heads)
+	assert.True(ok)
+	assert.True(first.Equals(r))
+
+	// Changes to other datasets aren't sent.
+	db, err := db.Commit("other", NewCommit(types.String("other"), types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+
+	other, second := commit(other, "second", first)
+	r, ok = nextHead(heads)
+	assert.True(ok)
+	assert.True(second.Equals(r))
+
+	_, err = other.Delete("ds")
+	assert.NoError(err)
+	_, third := commit(db, "third")
+	r, ok = nextHead(heads)
+	assert.True(ok)
+	assert.True(third.Equals(r))
+}
+
+func TestLocalDatabaseWatch(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+	db := NewDatabase(cs)
+	heads := db.Watch("ds")
+	assertWatch(assert, heads, db, NewDatabase(cs))
+
+	// Watching a dataset that already exists starts with its head.
+	r, ok := nextHead(db.Watch("other"))
+	assert.True(ok)
+	assert.True(r.Equals(NewDatabase(cs).HeadRef("other")))
+
+	db.Close()
+	_, ok = nextHead(heads)
+	assert.False(ok)
+}
+
+func TestRemoteDatabaseWatch(t *testing.T) {
+	assert := assert.New(t)
+	server := NewRemoteDatabaseServer(chunks.NewMemoryStore(), 0)
+	ts := httptest.NewServer(server.newHTTPServer().Handler)
+	defer ts.Close()
+
+	db := NewRemoteDatabase(ts.URL, "")
+	heads := db.Watch("ds")
+	other := NewRemoteDatabase(ts.URL, "")
+	assertWatch(assert, heads, db, other)
+	other.Close()
+
+	db.Close()
+	_, ok := nextHead(heads)
+	assert.False(ok)
+}
+
+func TestWaitForRoot(t *testing.T) {
+	assert := assert.New(t)
+	cs := chunks.NewMemoryStore()
+
+	// With nothing committed, it times out and returns the same root.
+	root, ok := waitForRoot(cs, cs.Root(), 10*time.Millisecond, nil)
+	assert.True(ok)
+	assert.Equal(cs.Root(), root)
+
+	done := make(chan struct{})
+	close(done)
+	_, ok = waitForRoot(cs, cs.Root(), 0, done)
+	assert.False(ok)
+}
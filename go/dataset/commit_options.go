@@ -4,7 +4,12 @@
 
 package dataset
 
-import "github.com/attic-labs/noms/go/types"
+import (
+	"time"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+)
 
 // CommitOptions is used to pass options into Commit.
 type CommitOptions struct {
@@ -12,3 +17,12 @@ type CommitOptions struct {
 	Parents types.Set
 	Meta    types.Struct
 }
+
+// WithMeta returns a copy of opts whose Meta is the standard commit metadata m. If m.Date is not set, the current time is used.
+func (opts CommitOptions) WithMeta(m datas.CommitMeta) CommitOptions {
+	if m.Date.IsZero() {
+		m.Date = time.Now()
+	}
+	opts.Meta = m.Struct()
+	return opts
+}
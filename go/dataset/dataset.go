@@ -111,6 +111,24 @@ func (ds *Dataset) Pull(sourceDB datas.Database, sourceRef types.Ref, concurrenc
 	datas.Pull(sourceDB, ds.Database(), sourceRef, sinkHeadRef, concurrency, progressCh)
 }
 
+// PullShallow is like Pull, but only pulls the most recent depth commits of the history of sourceRef. See datas.PullShallow.
+func (ds *Dataset) PullShallow(sourceDB datas.Database, sourceRef types.Ref, depth, concurrency int, progressCh chan datas.PullProgress) {
+	sinkHeadRef := types.Ref{}
+	if currentHeadRef, ok := ds.MaybeHeadRef(); ok {
+		sinkHeadRef = currentHeadRef
+	}
+	datas.PullShallow(sourceDB, ds.Database(), sourceRef, sinkHeadRef, depth, concurrency, progressCh)
+}
+
+// ResumablePull is like Pull, or PullShallow if depth is greater than 0, but saves its progress to cp, so that it can carry on from where an earlier, interrupted pull of the same sourceRef into ds stopped. See datas.ResumablePull.
+func (ds *Dataset) ResumablePull(sourceDB datas.Database, sourceRef types.Ref, depth, concurrency int, progressCh chan datas.PullProgress, cp *datas.PullCheckpoint) {
+	sinkHeadRef := types.Ref{}
+	if currentHeadRef, ok := ds.MaybeHeadRef(); ok {
+		sinkHeadRef = currentHeadRef
+	}
+	datas.ResumablePull(sourceDB, ds.Database(), sourceRef, sinkHeadRef, depth, concurrency, progressCh, cp)
+}
+
 // FastForward takes a types.Ref to a Commit object and makes it the new Head of ds iff it is a descendant of the current Head. Intended to be used e.g. after a call to Pull(). If the update cannot be performed, e.g., because another process moved the current Head out from under you, err will be non-nil. The newest snapshot of the Dataset is always returned, so the caller an easily retry using the latest.
 func (ds *Dataset) FastForward(newHeadRef types.Ref) (sink Dataset, err error) {
 	sink = *ds
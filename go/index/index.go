@@ -0,0 +1,276 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package index maintains secondary indexes over datasets whose head value is a Map or Set of structs.
+//
+// An index maps the values found at a path within each row to the set of rows having that value, as a Map<FieldValue, Set<Ref<Row>>>. For a Map the rows are its values, for a Set its elements. Rows without a value at the path are not indexed, and equal rows share a single Ref, which stays in the index as long as any of them does: the number of keys of a Map that hold the same row is kept alongside the entries, for the rows held by more than one.
+//
+// Indexes are stored in their own dataset next to the source dataset (see DatasetID), as a struct that also records the source commit the index reflects. Updating an index only applies the changes made to the source since that commit.
+package index
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/types"
+)
+
+const (
+	// StructName is the name of the struct an index is stored as.
+	StructName = "Index"
+	// SourceField holds a Ref to the source commit the index reflects.
+	SourceField = "source"
+	// ByField holds the path the rows are indexed by, as a String.
+	ByField = "by"
+	// EntriesField holds the Map<FieldValue, Set<Ref<Row>>>.
+	EntriesField = "entries"
+	// DuplicatesField holds the Map<Ref<Row>, Number> of how many keys hold each row that's held by more than one.
+	DuplicatesField = "duplicates"
+)
+
+// Index is a secondary index of the rows of a source dataset.
+type Index struct {
+	// By is the path within each row whose value the row is indexed by.
+	By types.Path
+	// Source is the source commit the index reflects.
+	Source types.Ref
+	// Entries maps each value found at By to the set of refs to the rows having that value.
+	Entries types.Map
+	// Duplicates maps the ref to each indexed row of a Map source that more than one key holds to the number of keys holding it, so that the row is only removed from Entries once the last of them is.
+	Duplicates types.Map
+}
+
+// Lookup returns the set of refs to the rows whose value at ix.By is v.
+func (ix Index) Lookup(v types.Value) types.Set {
+	if s, ok := ix.Entries.MaybeGet(v); ok {
+		return s.(types.Set)
+	}
+	return types.NewSet()
+}
+
+// Stale returns true if the head of the source dataset has moved on from the commit ix reflects.
+func (ix Index) Stale(db datas.Database, sourceID string) bool {
+	headRef, ok := db.MaybeHeadRef(sourceID)
+	return !ok || headRef.TargetHash() != ix.Source.TargetHash()
+}
+
+func (ix Index) toStruct() types.Struct {
+	return types.NewStruct(StructName, types.StructData{
+		SourceField:     ix.Source,
+		ByField:         types.String(ix.By.String()),
+		EntriesField:    ix.Entries,
+		DuplicatesField: ix.Duplicates,
+	})
+}
+
+func fromStruct(s types.Struct) (Index, error) {
+	by, err := types.ParsePath(string(s.Get(ByField).(types.String)))
+	if err != nil {
+		return Index{}, err
+	}
+	duplicates := types.NewMap()
+	if d, ok := s.MaybeGet(DuplicatesField); ok {
+		duplicates = d.(types.Map)
+	}
+	return Index{by, s.Get(SourceField).(types.Ref), s.Get(EntriesField).(types.Map), duplicates}, nil
+}
+
+// DatasetID returns the ID of the dataset the index of sourceID by the path by is stored in. For example, the index of "people" by ".address.city" is stored in "people/index/address/city". Only paths made up of struct fields can be used to name an index.
+func DatasetID(sourceID string, by types.Path) (string, error) {
+	if len(by) == 0 {
+		return "", errors.New("Index path must not be empty")
+	}
+	parts := make([]string, len(by))
+	for i, part := range by {
+		fp, ok := part.(types.FieldPath)
+		if !ok {
+			return "", fmt.Errorf("Index path may only contain struct fields: %s", by)
+		}
+		parts[i] = fp.Name
+	}
+	return sourceID + "/index/" + strings.Join(parts, "/"), nil
+}
+
+// Read returns the index of sourceID by the path by, if one exists.
+func Read(db datas.Database, sourceID string, by types.Path) (Index, bool, error) {
+	id, err := DatasetID(sourceID, by)
+	if err != nil {
+		return Index{}, false, err
+	}
+	v, ok := db.MaybeHead(id)
+	if !ok {
+		return Index{}, false, nil
+	}
+	s, ok := v.Get(datas.ValueField).(types.Struct)
+	if !ok || s.Type().Desc.(types.StructDesc).Name != StructName {
+		return Index{}, false, fmt.Errorf("Dataset %s does not hold an index", id)
+	}
+	ix, err := fromStruct(s)
+	return ix, err == nil, err
+}
+
+// Update brings the index of sourceID by the path by up to date with the head of sourceID, creating the index if it does not exist yet, and commits it to its dataset. If the index already reflects the source head, nothing is committed. Update returns the up to date index and the dataset it is stored in.
+func Update(db datas.Database, sourceID string, by types.Path) (Index, dataset.Dataset, error) {
+	id, err := DatasetID(sourceID, by)
+	if err != nil {
+		return Index{}, dataset.Dataset{}, err
+	}
+	ds := dataset.NewDataset(db, id)
+
+	headRef, ok := db.MaybeHeadRef(sourceID)
+	if !ok {
+		return Index{}, ds, fmt.Errorf("Dataset %s has no head", sourceID)
+	}
+	head := db.Head(sourceID).Get(datas.ValueField)
+
+	ix, ok, err := Read(db, sourceID, by)
+	if err != nil {
+		return Index{}, ds, err
+	}
+
+	var last types.Value
+	if ok {
+		if ix.Source.TargetHash() == headRef.TargetHash() {
+			return ix, ds, nil
+		}
+		last = ix.Source.TargetValue(db).(types.Struct).Get(datas.ValueField)
+	} else {
+		ix = Index{By: by, Entries: types.NewMap(), Duplicates: types.NewMap()}
+	}
+
+	ix, err = UpdateEntries(db, ix, last, head)
+	if err != nil {
+		return Index{}, ds, err
+	}
+	ix.Source = headRef
+	ds, err = ds.CommitValue(ix.toStruct())
+	return ix, ds, err
+}
+
+// UpdateEntries applies the changes that turn the rows of last into the rows of current to the entries of ix, and returns ix with the new entries. last may be nil, in which case all rows of current are added. Rows are written to vrw so that the refs to them can be resolved.
+func UpdateEntries(vrw types.ValueReadWriter, ix Index, last, current types.Value) (Index, error) {
+	u := &updater{vrw, ix.Entries, ix.Duplicates, ix.By}
+	switch current := current.(type) {
+	case types.Map:
+		lastMap, ok := last.(types.Map)
+		if !ok {
+			if last != nil {
+				return Index{}, fmt.Errorf("Cannot update index from %s to Map", last.Type().Describe())
+			}
+			lastMap = types.NewMap()
+		}
+		u.applyMapDiff(current, lastMap)
+	case types.Set:
+		lastSet, ok := last.(types.Set)
+		if !ok {
+			if last != nil {
+				return Index{}, fmt.Errorf("Cannot update index from %s to Set", last.Type().Describe())
+			}
+			lastSet = types.NewSet()
+		}
+		u.applySetDiff(current, lastSet)
+	default:
+		return Index{}, fmt.Errorf("Can only index a Map or Set, not %s", current.Type().Describe())
+	}
+	ix.Entries, ix.Duplicates = u.entries, u.duplicates
+	return ix, nil
+}
+
+type updater struct {
+	vrw        types.ValueReadWriter
+	entries    types.Map
+	duplicates types.Map
+	by         types.Path
+}
+
+func (u *updater) applyMapDiff(current, last types.Map) {
+	u.applyDiff(func(changes chan<- types.ValueChanged, closeChan <-chan struct{}) {
+		current.Diff(last, changes, closeChan)
+	}, func(change types.ValueChanged) {
+		if change.ChangeType != types.DiffChangeAdded {
+			u.remove(last.Get(change.V))
+		}
+		if change.ChangeType != types.DiffChangeRemoved {
+			u.add(current.Get(change.V))
+		}
+	})
+}
+
+func (u *updater) applySetDiff(current, last types.Set) {
+	u.applyDiff(func(changes chan<- types.ValueChanged, closeChan <-chan struct{}) {
+		current.Diff(last, changes, closeChan)
+	}, func(change types.ValueChanged) {
+		if change.ChangeType == types.DiffChangeRemoved {
+			u.remove(change.V)
+		} else {
+			u.add(change.V)
+		}
+	})
+}
+
+func (u *updater) applyDiff(diff func(changes chan<- types.ValueChanged, closeChan <-chan struct{}), apply func(change types.ValueChanged)) {
+	changes := make(chan types.ValueChanged)
+	closeChan := make(chan struct{})
+	go func() {
+		diff(changes, closeChan)
+		close(changes)
+	}()
+	for change := range changes {
+		apply(change)
+	}
+}
+
+func (u *updater) add(row types.Value) {
+	key := u.by.Resolve(row)
+	if key == nil {
+		return
+	}
+	r := u.vrw.WriteValue(row)
+	rows := u.rowsFor(key)
+	if rows.Has(r) {
+		// Another key of a Map already holds an equal row.
+		u.duplicates = u.duplicates.Set(r, types.Number(u.holders(r)+1))
+		return
+	}
+	u.entries = u.entries.Set(key, rows.Insert(r))
+}
+
+func (u *updater) remove(row types.Value) {
+	key := u.by.Resolve(row)
+	if key == nil {
+		return
+	}
+	r := types.NewRef(row)
+	if n := u.holders(r); n > 2 {
+		u.duplicates = u.duplicates.Set(r, types.Number(n-1))
+		return
+	} else if n == 2 {
+		u.duplicates = u.duplicates.Remove(r)
+		return
+	}
+	rows := u.rowsFor(key).Remove(r)
+	if rows.Empty() {
+		u.entries = u.entries.Remove(key)
+	} else {
+		u.entries = u.entries.Set(key, rows)
+	}
+}
+
+// holders returns how many keys of a Map hold the indexed row r.
+func (u *updater) holders(r types.Ref) int {
+	if n, ok := u.duplicates.MaybeGet(r); ok {
+		return int(n.(types.Number))
+	}
+	return 1
+}
+
+func (u *updater) rowsFor(key types.Value) types.Set {
+	if rows, ok := u.entries.MaybeGet(key); ok {
+		return rows.(types.Set)
+	}
+	return types.NewSet()
+}
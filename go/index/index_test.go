@@ -0,0 +1,194 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package index
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/dataset"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func person(name, city string) types.Struct {
+	return types.NewStruct("Person", types.StructData{
+		"name":    types.String(name),
+		"address": types.NewStruct("Address", types.StructData{"city": types.String(city)}),
+	})
+}
+
+func mustPath(s string) types.Path {
+	p, err := types.ParsePath(s)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func rowNames(assert *assert.Assertions, db datas.Database, rows types.Set) []string {
+	names := []string{}
+	rows.IterAll(func(v types.Value) {
+		row := v.(types.Ref).TargetValue(db)
+		assert.NotNil(row)
+		names = append(names, string(row.(types.Struct).Get("name").(types.String)))
+	})
+	return names
+}
+
+func TestDatasetID(t *testing.T) {
+	assert := assert.New(t)
+
+	id, err := DatasetID("people", mustPath(".address.city"))
+	assert.NoError(err)
+	assert.Equal("people/index/address/city", id)
+
+	_, err = DatasetID("people", mustPath(".names[0]"))
+	assert.Error(err)
+	_, err = DatasetID("people", types.Path{})
+	assert.Error(err)
+}
+
+func TestUpdateMap(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	by := mustPath(".address.city")
+
+	source := dataset.NewDataset(db, "people")
+	people := types.NewMap(
+		types.Number(1), person("alice", "Berlin"),
+		types.Number(2), person("bob", "Paris"),
+		types.Number(3), person("carol", "Berlin"),
+		types.Number(4), types.NewStruct("Person", types.StructData{"name": types.String("dave")}),
+	)
+	source, err := source.CommitValue(people)
+	assert.NoError(err)
+
+	ix, ds, err := Update(source.Database(), "people", by)
+	assert.NoError(err)
+	assert.Equal("people/index/address/city", ds.ID())
+	assert.True(ix.Source.Equals(source.HeadRef()))
+	assert.False(ix.Stale(ds.Database(), "people"))
+	assert.Equal(uint64(2), ix.Entries.Len())
+	assert.Equal([]string{"alice", "carol"}, rowNames(assert, ds.Database(), ix.Lookup(types.String("Berlin"))))
+	assert.Equal([]string{"bob"}, rowNames(assert, ds.Database(), ix.Lookup(types.String("Paris"))))
+	assert.True(ix.Lookup(types.String("Rome")).Empty())
+
+	// Move bob to Berlin, remove alice and add erin.
+	source = dataset.NewDataset(ds.Database(), "people")
+	people = people.Set(types.Number(2), person("bob", "Berlin")).Remove(types.Number(1)).Set(types.Number(5), person("erin", "Rome"))
+	source, err = source.CommitValue(people)
+	assert.NoError(err)
+
+	stored, ok, err := Read(source.Database(), "people", by)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.True(stored.Stale(source.Database(), "people"))
+
+	ix, ds, err = Update(source.Database(), "people", by)
+	assert.NoError(err)
+	assert.True(ix.Source.Equals(source.HeadRef()))
+	assert.Equal(uint64(2), ix.Entries.Len())
+	assert.Equal([]string{"bob", "carol"}, rowNames(assert, ds.Database(), ix.Lookup(types.String("Berlin"))))
+	assert.Equal([]string{"erin"}, rowNames(assert, ds.Database(), ix.Lookup(types.String("Rome"))))
+	assert.True(ix.Lookup(types.String("Paris")).Empty())
+
+	// The incrementally updated index matches one built from scratch.
+	fresh, err := UpdateEntries(ds.Database(), Index{By: by, Entries: types.NewMap(), Duplicates: types.NewMap()}, nil, people)
+	assert.NoError(err)
+	assert.True(fresh.Entries.Equals(ix.Entries))
+
+	// Updating an up to date index does not commit.
+	headRef := ds.HeadRef()
+	_, ds, err = Update(ds.Database(), "people", by)
+	assert.NoError(err)
+	assert.True(headRef.Equals(ds.HeadRef()))
+}
+
+func TestUpdateSet(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	by := mustPath(".name")
+
+	source := dataset.NewDataset(db, "people")
+	source, err := source.CommitValue(types.NewSet(person("alice", "Berlin"), person("bob", "Paris")))
+	assert.NoError(err)
+	_, ds, err := Update(source.Database(), "people", by)
+	assert.NoError(err)
+
+	source = dataset.NewDataset(ds.Database(), "people")
+	source, err = source.CommitValue(types.NewSet(person("alice", "Rome"), person("bob", "Paris")))
+	assert.NoError(err)
+	ix, ds, err := Update(source.Database(), "people", by)
+	assert.NoError(err)
+
+	rows := ix.Lookup(types.String("alice"))
+	assert.Equal(uint64(1), rows.Len())
+	city := rows.First().(types.Ref).TargetValue(ds.Database()).(types.Struct).Get("address").(types.Struct).Get("city")
+	assert.True(types.String("Rome").Equals(city))
+}
+
+func TestUpdateErrors(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	by := mustPath(".name")
+
+	_, _, err := Update(db, "people", by)
+	assert.Error(err)
+
+	source := dataset.NewDataset(db, "people")
+	source, err = source.CommitValue(types.NewList(person("alice", "Berlin")))
+	assert.NoError(err)
+	_, _, err = Update(source.Database(), "people", by)
+	assert.Error(err)
+}
+
+func TestUpdateMapDuplicateRows(t *testing.T) {
+	assert := assert.New(t)
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	by := mustPath(".address.city")
+
+	source := dataset.NewDataset(db, "people")
+	source, err := source.CommitValue(types.NewMap(
+		types.Number(1), person("alice", "Berlin"),
+		types.Number(2), person("alice", "Berlin"),
+		types.Number(3), person("alice", "Berlin"),
+	))
+	assert.NoError(err)
+	ix, _, err := Update(source.Database(), "people", by)
+	assert.NoError(err)
+	assert.Equal([]string{"alice"}, rowNames(assert, source.Database(), ix.Lookup(types.String("Berlin"))))
+	alice := ix.Lookup(types.String("Berlin")).First()
+	assert.True(types.Number(3).Equals(ix.Duplicates.Get(alice)))
+
+	// Removing some of the equal rows leaves the others indexed.
+	source, err = source.CommitValue(source.HeadValue().(types.Map).Remove(types.Number(1)))
+	assert.NoError(err)
+	ix, _, err = Update(source.Database(), "people", by)
+	assert.NoError(err)
+	assert.Equal([]string{"alice"}, rowNames(assert, source.Database(), ix.Lookup(types.String("Berlin"))))
+	assert.True(types.Number(2).Equals(ix.Duplicates.Get(alice)))
+
+	source, err = source.CommitValue(source.HeadValue().(types.Map).Set(types.Number(3), person("alice", "Paris")))
+	assert.NoError(err)
+	ix, _, err = Update(source.Database(), "people", by)
+	assert.NoError(err)
+	assert.Equal([]string{"alice"}, rowNames(assert, source.Database(), ix.Lookup(types.String("Berlin"))))
+	assert.Equal([]string{"alice"}, rowNames(assert, source.Database(), ix.Lookup(types.String("Paris"))))
+	assert.True(ix.Duplicates.Empty())
+
+	source, err = source.CommitValue(source.HeadValue().(types.Map).Remove(types.Number(3)))
+	assert.NoError(err)
+	ix, _, err = Update(source.Database(), "people", by)
+	assert.NoError(err)
+
+	source, err = source.CommitValue(source.HeadValue().(types.Map).Remove(types.Number(2)))
+	assert.NoError(err)
+	ix, _, err = Update(source.Database(), "people", by)
+	assert.NoError(err)
+	assert.True(ix.Lookup(types.String("Berlin")).Empty())
+	assert.True(ix.Entries.Empty())
+}
@@ -0,0 +1,233 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"fmt"
+	"io/ioutil"
+	"reflect"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Unmarshal converts a Noms value into a Go value. It decodes v and stores the result in the value pointed to by out.
+//
+// Unmarshal uses the inverse of the encodings that Marshal uses with the following additional rules:
+//
+// To unmarshal a Noms struct into a Go struct, Unmarshal matches incoming struct fields to the Go struct fields using the same names as Marshal. Every Go field must be present in the Noms struct; extra Noms fields are ignored.
+//
+// To unmarshal a Noms list into a slice, Unmarshal resets the slice length to zero and then appends each element to the slice. Arrays must have the same length as the list.
+//
+// To unmarshal a Noms map or set into a Go map, Unmarshal replaces the map with a new one.
+//
+// Fields and values of a types.Value type are set to the Noms value, as long as it is assignable.
+//
+// Pointers are allocated as needed.
+//
+// Unmarshal returns an UnmarshalTypeMismatchError if a Noms value is not appropriate for the Go type it is decoded into, and an InvalidUnmarshalError if out is not a non-nil pointer.
+func Unmarshal(v types.Value, out interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case *UnmarshalTypeMismatchError, *UnsupportedTypeError, *InvalidTagError:
+				err = r.(error)
+			default:
+				panic(r)
+			}
+		}
+	}()
+
+	rv := reflect.ValueOf(out)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(out)}
+	}
+	decodeValue(v, rv.Elem())
+	return
+}
+
+// InvalidUnmarshalError describes an invalid argument passed to Unmarshal. The argument to Unmarshal must be a non-nil pointer.
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "Cannot unmarshal into Go nil value"
+	}
+	if e.Type.Kind() != reflect.Ptr {
+		return "Cannot unmarshal into Go non pointer of type " + e.Type.String()
+	}
+	return "Cannot unmarshal into Go nil pointer of type " + e.Type.String()
+}
+
+// UnmarshalTypeMismatchError describes a Noms value that is not appropriate for the Go type it is being decoded into.
+type UnmarshalTypeMismatchError struct {
+	Value types.Value
+	Type  reflect.Type
+}
+
+func (e *UnmarshalTypeMismatchError) Error() string {
+	return fmt.Sprintf("Cannot unmarshal %s into Go value of type %s", types.EncodedValue(e.Value.Type()), e.Type)
+}
+
+func mismatch(v types.Value, t reflect.Type) {
+	panic(&UnmarshalTypeMismatchError{v, t})
+}
+
+func decodeValue(v types.Value, rv reflect.Value) {
+	t := rv.Type()
+	if t.Implements(nomsValueInterface) {
+		nv := reflect.ValueOf(v)
+		if !nv.Type().AssignableTo(t) {
+			mismatch(v, t)
+		}
+		rv.Set(nv)
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		b, ok := v.(types.Bool)
+		if !ok {
+			mismatch(v, t)
+		}
+		rv.SetBool(bool(b))
+	case reflect.Float32, reflect.Float64:
+		rv.SetFloat(float64(numberValue(v, t)))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		rv.SetInt(int64(numberValue(v, t)))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		rv.SetUint(uint64(numberValue(v, t)))
+	case reflect.String:
+		s, ok := v.(types.String)
+		if !ok {
+			mismatch(v, t)
+		}
+		rv.SetString(string(s))
+	case reflect.Struct:
+		decodeStruct(v, rv)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			decodeBlob(v, rv)
+		} else {
+			decodeList(v, rv)
+		}
+	case reflect.Map:
+		if isSetType(t) {
+			decodeSet(v, rv)
+		} else {
+			decodeMap(v, rv)
+		}
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(t.Elem()))
+		}
+		decodeValue(v, rv.Elem())
+	default:
+		panic(&UnsupportedTypeError{Type: t})
+	}
+}
+
+func numberValue(v types.Value, t reflect.Type) types.Number {
+	n, ok := v.(types.Number)
+	if !ok {
+		mismatch(v, t)
+	}
+	return n
+}
+
+func decodeStruct(v types.Value, rv reflect.Value) {
+	s, ok := v.(types.Struct)
+	if !ok {
+		mismatch(v, rv.Type())
+	}
+	for _, f := range structFields(rv.Type()) {
+		fv, ok := s.MaybeGet(f.name)
+		if !ok {
+			panic(&UnmarshalTypeMismatchError{v, rv.Type()})
+		}
+		decodeValue(fv, rv.FieldByIndex(f.index))
+	}
+}
+
+func decodeBlob(v types.Value, rv reflect.Value) {
+	b, ok := v.(types.Blob)
+	if !ok {
+		mismatch(v, rv.Type())
+	}
+	data, err := ioutil.ReadAll(b.Reader())
+	d.PanicIfError(err)
+
+	if rv.Kind() == reflect.Slice {
+		rv.SetBytes(data)
+		return
+	}
+	if len(data) != rv.Len() {
+		mismatch(v, rv.Type())
+	}
+	reflect.Copy(rv, reflect.ValueOf(data))
+}
+
+func decodeList(v types.Value, rv reflect.Value) {
+	l, ok := v.(types.List)
+	if !ok {
+		mismatch(v, rv.Type())
+	}
+
+	if rv.Kind() == reflect.Array {
+		if l.Len() != uint64(rv.Len()) {
+			mismatch(v, rv.Type())
+		}
+		l.IterAll(func(v types.Value, i uint64) {
+			decodeValue(v, rv.Index(int(i)))
+		})
+		return
+	}
+
+	slice := reflect.MakeSlice(rv.Type(), int(l.Len()), int(l.Len()))
+	l.IterAll(func(v types.Value, i uint64) {
+		decodeValue(v, slice.Index(int(i)))
+	})
+	rv.Set(slice)
+}
+
+func decodeSet(v types.Value, rv reflect.Value) {
+	s, ok := v.(types.Set)
+	if !ok {
+		mismatch(v, rv.Type())
+	}
+
+	t := rv.Type()
+	m := reflect.MakeMap(t)
+	present := reflect.New(t.Elem()).Elem()
+	if t.Elem().Kind() == reflect.Bool {
+		present.SetBool(true)
+	}
+	s.IterAll(func(v types.Value) {
+		k := reflect.New(t.Key()).Elem()
+		decodeValue(v, k)
+		m.SetMapIndex(k, present)
+	})
+	rv.Set(m)
+}
+
+func decodeMap(v types.Value, rv reflect.Value) {
+	nm, ok := v.(types.Map)
+	if !ok {
+		mismatch(v, rv.Type())
+	}
+
+	t := rv.Type()
+	m := reflect.MakeMap(t)
+	nm.IterAll(func(k, v types.Value) {
+		gk := reflect.New(t.Key()).Elem()
+		decodeValue(k, gk)
+		gv := reflect.New(t.Elem()).Elem()
+		decodeValue(v, gv)
+		m.SetMapIndex(gk, gv)
+	})
+	rv.Set(m)
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+	assert := assert.New(t)
+
+	var b bool
+	assert.NoError(Unmarshal(types.Bool(true), &b))
+	assert.True(b)
+
+	var i int8
+	assert.NoError(Unmarshal(types.Number(-3), &i))
+	assert.Equal(int8(-3), i)
+
+	var s string
+	assert.NoError(Unmarshal(types.String("hi"), &s))
+	assert.Equal("hi", s)
+
+	var l []int
+	assert.NoError(Unmarshal(types.NewList(types.Number(1), types.Number(2)), &l))
+	assert.Equal([]int{1, 2}, l)
+
+	var a [2]string
+	assert.NoError(Unmarshal(types.NewList(types.String("a"), types.String("b")), &a))
+	assert.Equal([2]string{"a", "b"}, a)
+
+	var bs []byte
+	assert.NoError(Unmarshal(types.NewBlob(bytes.NewReader([]byte{1, 2, 3})), &bs))
+	assert.Equal([]byte{1, 2, 3}, bs)
+
+	var m map[string]float64
+	assert.NoError(Unmarshal(types.NewMap(types.String("a"), types.Number(1)), &m))
+	assert.Equal(map[string]float64{"a": 1}, m)
+
+	var set map[string]struct{}
+	assert.NoError(Unmarshal(types.NewSet(types.String("a")), &set))
+	assert.Equal(map[string]struct{}{"a": {}}, set)
+
+	var boolSet map[float64]bool
+	assert.NoError(Unmarshal(types.NewSet(types.Number(1)), &boolSet))
+	assert.Equal(map[float64]bool{1: true}, boolSet)
+
+	var v types.Value
+	assert.NoError(Unmarshal(types.Number(1), &v))
+	assert.True(types.Number(1).Equals(v))
+
+	var p *string
+	assert.NoError(Unmarshal(types.String("ptr"), &p))
+	assert.Equal("ptr", *p)
+}
+
+func TestDecodeStructRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	in := TestStruct{
+		Str:    "foo",
+		Num:    42,
+		Nested: TestNested{true},
+		List:   []TestNested{{false}, {true}},
+		Value:  types.NewList(types.String("bar")),
+	}
+	v, err := Marshal(in)
+	assert.NoError(err)
+
+	var out TestStruct
+	assert.NoError(Unmarshal(v, &out))
+	assert.Equal(in.Str, out.Str)
+	assert.Equal(in.Num, out.Num)
+	assert.Equal(in.Nested, out.Nested)
+	assert.Equal(in.List, out.List)
+	assert.True(in.Value.Equals(out.Value))
+}
+
+func TestDecodeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	var s string
+	assert.IsType(&InvalidUnmarshalError{}, Unmarshal(types.String("hi"), s))
+	assert.IsType(&InvalidUnmarshalError{}, Unmarshal(types.String("hi"), nil))
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(types.Number(1), &s))
+
+	var a [1]int
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(types.NewList(types.Number(1), types.Number(2)), &a))
+
+	var n TestNested
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(types.NewStruct("TestNested", types.StructData{}), &n))
+
+	var str types.String
+	assert.IsType(&UnmarshalTypeMismatchError{}, Unmarshal(types.Number(1), &str))
+}
@@ -0,0 +1,244 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package marshal implements encoding and decoding of Noms values. The mapping between Go values and Noms values is described in the documentation for the Marshal and Unmarshal functions.
+package marshal
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Marshal converts a Go value to a Noms value.
+//
+// Marshal traverses the value v recursively. Marshal uses the following type-dependent encodings:
+//
+// Boolean values are encoded as Noms types.Bool.
+//
+// Floating point and integer values are encoded as Noms types.Number. At the moment this might lead to some loss in precision because types.Number currently takes a float64.
+//
+// String values are encoded as Noms types.String.
+//
+// []byte values are encoded as Noms types.Blob.
+//
+// Other slices and arrays are encoded as Noms types.List.
+//
+// Maps whose value type is struct{} or bool are encoded as Noms types.Set of their keys. For map[T]bool, only the keys that map to true are included.
+//
+// Other maps are encoded as Noms types.Map.
+//
+// Struct values are encoded as Noms structs (types.Struct). Each exported Go struct field becomes a member of the Noms struct unless the field is omitted using a `noms:"-"` tag. The name of the Noms struct is the name of the Go type, and the field names default to the Go field name with its first rune lowercased. A different field name can be given with a `noms:"name"` tag.
+//
+// Values that already implement types.Value are used as is.
+//
+// Pointers are encoded as the value they point to.
+//
+// Marshal returns an UnsupportedTypeError for values it can not encode, such as channels, functions, interfaces other than types.Value and nil pointers.
+func Marshal(v interface{}) (nomsValue types.Value, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case *UnsupportedTypeError, *InvalidTagError:
+				err = r.(error)
+			default:
+				panic(r)
+			}
+		}
+	}()
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() {
+		return nil, &UnsupportedTypeError{Message: "Cannot marshal nil"}
+	}
+	nomsValue = encodeValue(rv)
+	return
+}
+
+// UnsupportedTypeError is returned by Marshal and MarshalType when the Go value or type cannot be represented in Noms.
+type UnsupportedTypeError struct {
+	Type    reflect.Type
+	Message string
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = "Unsupported type"
+	}
+	if e.Type == nil {
+		return msg
+	}
+	return fmt.Sprintf("%s: %s", msg, e.Type)
+}
+
+// InvalidTagError is returned by Marshal, MarshalType and Unmarshal when a `noms` struct tag does not specify a valid Noms field name.
+type InvalidTagError struct {
+	message string
+}
+
+func (e *InvalidTagError) Error() string {
+	return e.message
+}
+
+var nomsValueInterface = reflect.TypeOf((*types.Value)(nil)).Elem()
+
+func encodeValue(v reflect.Value) types.Value {
+	t := v.Type()
+	if t.Implements(nomsValueInterface) {
+		if v.Kind() == reflect.Interface && v.IsNil() {
+			panic(&UnsupportedTypeError{Type: t, Message: "Cannot marshal nil types.Value"})
+		}
+		return v.Interface().(types.Value)
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return types.Bool(v.Bool())
+	case reflect.Float32, reflect.Float64:
+		return types.Number(v.Float())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return types.Number(float64(v.Int()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return types.Number(float64(v.Uint()))
+	case reflect.String:
+		return types.String(v.String())
+	case reflect.Struct:
+		return encodeStruct(v)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return encodeBlob(v)
+		}
+		return encodeList(v)
+	case reflect.Map:
+		if isSetType(t) {
+			return encodeSet(v)
+		}
+		return encodeMap(v)
+	case reflect.Ptr:
+		if v.IsNil() {
+			panic(&UnsupportedTypeError{Type: t, Message: "Cannot marshal nil pointer"})
+		}
+		return encodeValue(v.Elem())
+	default:
+		panic(&UnsupportedTypeError{Type: t})
+	}
+}
+
+func encodeStruct(v reflect.Value) types.Value {
+	data := types.StructData{}
+	for _, f := range structFields(v.Type()) {
+		data[f.name] = encodeValue(v.FieldByIndex(f.index))
+	}
+	return types.NewStruct(structName(v.Type()), data)
+}
+
+func encodeBlob(v reflect.Value) types.Value {
+	if v.Kind() == reflect.Slice {
+		return types.NewBlob(bytes.NewReader(v.Bytes()))
+	}
+	b := make([]byte, v.Len())
+	reflect.Copy(reflect.ValueOf(b), v)
+	return types.NewBlob(bytes.NewReader(b))
+}
+
+func encodeList(v reflect.Value) types.Value {
+	values := make([]types.Value, v.Len())
+	for i := range values {
+		values[i] = encodeValue(v.Index(i))
+	}
+	return types.NewList(values...)
+}
+
+func encodeSet(v reflect.Value) types.Value {
+	isBool := v.Type().Elem().Kind() == reflect.Bool
+	values := make([]types.Value, 0, v.Len())
+	for _, k := range v.MapKeys() {
+		if isBool && !v.MapIndex(k).Bool() {
+			continue
+		}
+		values = append(values, encodeValue(k))
+	}
+	return types.NewSet(values...)
+}
+
+func encodeMap(v reflect.Value) types.Value {
+	kvs := make([]types.Value, 0, 2*v.Len())
+	for _, k := range v.MapKeys() {
+		kvs = append(kvs, encodeValue(k), encodeValue(v.MapIndex(k)))
+	}
+	return types.NewMap(kvs...)
+}
+
+// isSetType returns true for map[T]struct{} and map[T]bool, which are represented as Noms sets.
+func isSetType(t reflect.Type) bool {
+	e := t.Elem()
+	return e.Kind() == reflect.Bool || (e.Kind() == reflect.Struct && e.NumField() == 0)
+}
+
+type field struct {
+	name  string
+	index []int
+}
+
+type fieldSlice []field
+
+func (fs fieldSlice) Len() int           { return len(fs) }
+func (fs fieldSlice) Swap(i, j int)      { fs[i], fs[j] = fs[j], fs[i] }
+func (fs fieldSlice) Less(i, j int) bool { return fs[i].name < fs[j].name }
+
+// structFields returns the Noms fields of the Go struct type t, ordered by name.
+func structFields(t reflect.Type) fieldSlice {
+	fields := fieldSlice{}
+	seen := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" { // unexported
+			continue
+		}
+		name := f.Tag.Get("noms")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = lowerFirst(f.Name)
+		}
+		if !validFieldName(name) {
+			panic(&InvalidTagError{fmt.Sprintf("Invalid struct field name: %s", name)})
+		}
+		if seen[name] {
+			panic(&InvalidTagError{fmt.Sprintf("Duplicate struct field name: %s", name)})
+		}
+		seen[name] = true
+		fields = append(fields, field{name, f.Index})
+	}
+	sort.Sort(fields)
+	return fields
+}
+
+func structName(t reflect.Type) string {
+	return t.Name()
+}
+
+func lowerFirst(s string) string {
+	r, n := utf8.DecodeRuneInString(s)
+	return string(unicode.ToLower(r)) + s[n:]
+}
+
+func validFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		isLetter := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+		if !isLetter && (i == 0 || (r != '_' && (r < '0' || r > '9'))) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestEncode(tt *testing.T) {
+	assert := assert.New(tt)
+	t := func(exp types.Value, v interface{}) {
+		actual, err := Marshal(v)
+		assert.NoError(err)
+		assert.True(exp.Equals(actual), "expected %s, got %s", types.EncodedValue(exp), types.EncodedValue(actual))
+	}
+
+	t(types.Bool(true), true)
+	t(types.Number(42), 42)
+	t(types.Number(1.5), float32(1.5))
+	t(types.Number(255), uint8(255))
+	t(types.String("hi"), "hi")
+	t(types.String("hi"), types.String("hi"))
+	t(types.NewList(types.Number(1), types.Number(2)), []int{1, 2})
+	t(types.NewList(types.String("a")), [1]string{"a"})
+	t(types.NewBlob(bytes.NewReader([]byte{1, 2, 3})), []byte{1, 2, 3})
+	t(types.NewMap(types.String("a"), types.Number(1)), map[string]int{"a": 1})
+	t(types.NewSet(types.String("a"), types.String("b")), map[string]struct{}{"a": {}, "b": {}})
+	t(types.NewSet(types.String("a")), map[string]bool{"a": true, "b": false})
+
+	s := 42
+	t(types.Number(42), &s)
+}
+
+type TestStruct struct {
+	Str     string
+	Num     float64 `noms:"number"`
+	Skipped bool    `noms:"-"`
+	Nested  TestNested
+	List    []TestNested
+	Value   types.Value
+	private int
+}
+
+type TestNested struct {
+	B bool
+}
+
+func TestEncodeStruct(t *testing.T) {
+	assert := assert.New(t)
+	v, err := Marshal(TestStruct{
+		Str:     "foo",
+		Num:     42,
+		Skipped: true,
+		Nested:  TestNested{true},
+		List:    []TestNested{{false}},
+		Value:   types.String("bar"),
+		private: 1,
+	})
+	assert.NoError(err)
+
+	expected := types.NewStruct("TestStruct", types.StructData{
+		"str":    types.String("foo"),
+		"number": types.Number(42),
+		"nested": types.NewStruct("TestNested", types.StructData{"b": types.Bool(true)}),
+		"list":   types.NewList(types.NewStruct("TestNested", types.StructData{"b": types.Bool(false)})),
+		"value":  types.String("bar"),
+	})
+	assert.True(expected.Equals(v), "got %s", types.EncodedValue(v))
+}
+
+func TestEncodeErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := Marshal(nil)
+	assert.IsType(&UnsupportedTypeError{}, err)
+
+	_, err = Marshal(make(chan int))
+	assert.IsType(&UnsupportedTypeError{}, err)
+
+	var p *int
+	_, err = Marshal(p)
+	assert.IsType(&UnsupportedTypeError{}, err)
+
+	_, err = Marshal(struct {
+		A int `noms:"1a"`
+	}{})
+	assert.IsType(&InvalidTagError{}, err)
+
+	_, err = Marshal(struct {
+		A int
+		B int `noms:"a"`
+	}{})
+	assert.IsType(&InvalidTagError{}, err)
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"reflect"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+// MarshalType computes the Noms type of the values Marshal produces for the Go type of v. This is useful for checking whether a Noms value can be decoded into a Go value:
+//
+//	t, err := marshal.MarshalType(person)
+//	if err == nil && types.IsSubtype(t, v.Type()) {
+//	  err = marshal.Unmarshal(v, &person)
+//	}
+//
+// Fields of type types.Value map to Value, and fields of the concrete Noms collection types map to the corresponding collection of Value. Recursive Go struct types map to Noms cycle types.
+//
+// MarshalType returns an UnsupportedTypeError for types Marshal cannot encode.
+func MarshalType(v interface{}) (nomsType *types.Type, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch r := r.(type) {
+			case *UnsupportedTypeError, *InvalidTagError:
+				err = r.(error)
+			default:
+				panic(r)
+			}
+		}
+	}()
+	t := reflect.TypeOf(v)
+	if t == nil {
+		return nil, &UnsupportedTypeError{Message: "Cannot marshal type of nil"}
+	}
+	nomsType = encodeType(t, nil)
+	return
+}
+
+var nomsTypes = map[reflect.Type]*types.Type{
+	reflect.TypeOf(types.Bool(false)): types.BoolType,
+	reflect.TypeOf(types.Number(0)):   types.NumberType,
+	reflect.TypeOf(types.String("")):  types.StringType,
+	reflect.TypeOf(types.Blob{}):      types.BlobType,
+	reflect.TypeOf(types.List{}):      types.MakeListType(types.ValueType),
+	reflect.TypeOf(types.Set{}):       types.MakeSetType(types.ValueType),
+	reflect.TypeOf(types.Map{}):       types.MakeMapType(types.ValueType, types.ValueType),
+	reflect.TypeOf(types.Ref{}):       types.MakeRefType(types.ValueType),
+	reflect.TypeOf(&types.Type{}):     types.TypeType,
+}
+
+// encodeType returns the Noms type for t. parentStructTypes holds the Go struct types enclosing t, innermost last, and is used to detect cycles.
+func encodeType(t reflect.Type, parentStructTypes []reflect.Type) *types.Type {
+	if nt, ok := nomsTypes[t]; ok {
+		return nt
+	}
+	if t.Implements(nomsValueInterface) {
+		return types.ValueType
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return types.BoolType
+	case reflect.Float32, reflect.Float64, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return types.NumberType
+	case reflect.String:
+		return types.StringType
+	case reflect.Struct:
+		return encodeStructType(t, parentStructTypes)
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return types.BlobType
+		}
+		return types.MakeListType(encodeType(t.Elem(), parentStructTypes))
+	case reflect.Map:
+		if isSetType(t) {
+			return types.MakeSetType(encodeType(t.Key(), parentStructTypes))
+		}
+		return types.MakeMapType(encodeType(t.Key(), parentStructTypes), encodeType(t.Elem(), parentStructTypes))
+	case reflect.Ptr:
+		return encodeType(t.Elem(), parentStructTypes)
+	default:
+		panic(&UnsupportedTypeError{Type: t})
+	}
+}
+
+func encodeStructType(t reflect.Type, parentStructTypes []reflect.Type) *types.Type {
+	for i := len(parentStructTypes) - 1; i >= 0; i-- {
+		if parentStructTypes[i] == t {
+			return types.MakeCycleType(uint32(len(parentStructTypes) - 1 - i))
+		}
+	}
+
+	parentStructTypes = append(parentStructTypes, t)
+	fields := structFields(t)
+	fieldNames := make([]string, len(fields))
+	fieldTypes := make([]*types.Type, len(fields))
+	for i, f := range fields {
+		fieldNames[i] = f.name
+		fieldTypes[i] = encodeType(t.FieldByIndex(f.index).Type, parentStructTypes)
+	}
+	return types.MakeStructType(structName(t), fieldNames, fieldTypes)
+}
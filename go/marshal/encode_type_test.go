@@ -0,0 +1,80 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package marshal
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestMarshalType(tt *testing.T) {
+	assert := assert.New(tt)
+	t := func(exp *types.Type, v interface{}) {
+		actual, err := MarshalType(v)
+		assert.NoError(err)
+		assert.True(exp.Equals(actual), "expected %s, got %s", exp.Describe(), actual.Describe())
+	}
+
+	t(types.BoolType, true)
+	t(types.NumberType, 42)
+	t(types.StringType, "hi")
+	t(types.BlobType, []byte{})
+	t(types.MakeListType(types.NumberType), []uint{})
+	t(types.MakeSetType(types.StringType), map[string]bool{})
+	t(types.MakeSetType(types.StringType), map[string]struct{}{})
+	t(types.MakeMapType(types.StringType, types.NumberType), map[string]int{})
+	t(types.ValueType, (*types.Value)(nil))
+	t(types.StringType, types.String(""))
+	t(types.MakeListType(types.ValueType), types.NewList())
+
+	t(types.MakeStructType("TestNested", []string{"b"}, []*types.Type{types.BoolType}), TestNested{})
+	t(types.MakeStructType("TestStruct",
+		[]string{"list", "nested", "number", "str", "value"},
+		[]*types.Type{
+			types.MakeListType(types.MakeStructType("TestNested", []string{"b"}, []*types.Type{types.BoolType})),
+			types.MakeStructType("TestNested", []string{"b"}, []*types.Type{types.BoolType}),
+			types.NumberType,
+			types.StringType,
+			types.ValueType,
+		}), &TestStruct{})
+}
+
+type Node struct {
+	Value    string
+	Children []Node
+}
+
+func TestMarshalTypeCycle(t *testing.T) {
+	assert := assert.New(t)
+	typ, err := MarshalType(Node{})
+	assert.NoError(err)
+	expected := types.MakeStructType("Node", []string{"children", "value"}, []*types.Type{
+		types.MakeListType(types.MakeCycleType(0)),
+		types.StringType,
+	})
+	assert.True(expected.Equals(typ))
+
+	v, err := Marshal(Node{"root", []Node{{"leaf", nil}}})
+	assert.NoError(err)
+	assert.True(types.IsSubtype(typ, v.Type()))
+}
+
+func TestMarshalTypeIsSubtype(t *testing.T) {
+	assert := assert.New(t)
+	typ, err := MarshalType(TestNested{})
+	assert.NoError(err)
+
+	v, err := Marshal(TestNested{true})
+	assert.NoError(err)
+	assert.True(types.IsSubtype(typ, v.Type()))
+	assert.False(types.IsSubtype(typ, types.NewStruct("TestNested", types.StructData{"b": types.Number(1)}).Type()))
+}
+
+func TestMarshalTypeErrors(t *testing.T) {
+	_, err := MarshalType(make(chan int))
+	assert.IsType(t, &UnsupportedTypeError{}, err)
+}
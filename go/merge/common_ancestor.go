@@ -0,0 +1,70 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import (
+	"sort"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// FindCommonAncestor returns the most recent common ancestor of the Commits referenced by c1 and c2, if there is one. The search walks both commit graphs in parallel, always expanding the tallest outstanding Refs first, so the first Ref seen on both sides is the tallest shared ancestor.
+func FindCommonAncestor(c1, c2 types.Ref, vr types.ValueReader) (a types.Ref, ok bool) {
+	c1Q, c2Q := &types.RefByHeight{c1}, &types.RefByHeight{c2}
+	for !c1Q.Empty() && !c2Q.Empty() {
+		c1Ht, c2Ht := c1Q.PeekEnd().Height(), c2Q.PeekEnd().Height()
+		if c1Ht == c2Ht {
+			c1Refs, c2Refs := popRefsOfHeight(c1Q, c1Ht), popRefsOfHeight(c2Q, c2Ht)
+			if common, found := findCommonRef(c1Refs, c2Refs); found {
+				return common, true
+			}
+			parentsToQueue(c1Refs, c1Q, vr)
+			parentsToQueue(c2Refs, c2Q, vr)
+		} else if c1Ht > c2Ht {
+			parentsToQueue(popRefsOfHeight(c1Q, c1Ht), c1Q, vr)
+		} else {
+			parentsToQueue(popRefsOfHeight(c2Q, c2Ht), c2Q, vr)
+		}
+	}
+	return
+}
+
+// popRefsOfHeight removes and returns all the Refs at the end of q that have the given height.
+func popRefsOfHeight(q *types.RefByHeight, height uint64) types.RefSlice {
+	refs := types.RefSlice{}
+	for !q.Empty() && q.PeekEnd().Height() == height {
+		refs = append(refs, q.PopBack())
+	}
+	return refs
+}
+
+func findCommonRef(a, b types.RefSlice) (types.Ref, bool) {
+	toCheck := hash.HashSet{}
+	for _, r := range a {
+		toCheck.Insert(r.TargetHash())
+	}
+	sort.Sort(b)
+	for _, r := range b {
+		if toCheck.Has(r.TargetHash()) {
+			return r, true
+		}
+	}
+	return types.Ref{}, false
+}
+
+func parentsToQueue(refs types.RefSlice, q *types.RefByHeight, vr types.ValueReader) {
+	for _, r := range refs {
+		c, ok := r.TargetValue(vr).(types.Struct)
+		d.PanicIfTrue(!ok || !datas.IsCommitType(c.Type()), "Not a commit: %s", r.TargetHash())
+		c.Get(datas.ParentsField).(types.Set).IterAll(func(v types.Value) {
+			q.PushBack(v.(types.Ref))
+		})
+	}
+	q.Unique()
+	sort.Sort(q)
+}
@@ -0,0 +1,53 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestFindCommonAncestor(t *testing.T) {
+	assert := assert.New(t)
+	vs := types.NewTestValueStore()
+
+	commit := func(v float64, parents ...types.Ref) types.Ref {
+		ps := types.NewSet()
+		for _, p := range parents {
+			ps = ps.Insert(p)
+		}
+		return vs.WriteValue(datas.NewCommit(types.Number(v), ps, types.EmptyStruct))
+	}
+
+	// a1 <- a2 <- a3 <- a4
+	//        \         /
+	//         b3 <--- b4 <- b5
+	a1 := commit(1)
+	a2 := commit(2, a1)
+	a3 := commit(3, a2)
+	b3 := commit(30, a2)
+	a4 := commit(4, a3, b3)
+	b4 := commit(40, b3)
+	b5 := commit(50, b4)
+
+	assertAncestor := func(expected, c1, c2 types.Ref) {
+		a, ok := FindCommonAncestor(c1, c2, vs)
+		if assert.True(ok) {
+			assert.True(expected.Equals(a), "expected %s, got %s", expected.TargetHash(), a.TargetHash())
+		}
+	}
+
+	assertAncestor(a2, a3, b3)
+	assertAncestor(a2, a3, b5)
+	assertAncestor(b3, a4, b5)
+	assertAncestor(a3, a4, a3)
+	assertAncestor(a1, a1, b5)
+
+	_, ok := FindCommonAncestor(a4, commit(100), vs)
+	assert.False(ok)
+}
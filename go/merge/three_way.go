@@ -0,0 +1,374 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package merge implements three-way merging of Noms values.
+package merge
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// Conflict describes a location at which the two sides of a merge made incompatible changes. Left, Right and Parent are the values found at Path in each version, or nil if there is no value there.
+type Conflict struct {
+	Path   types.Path
+	Left   types.Value
+	Right  types.Value
+	Parent types.Value
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s: left %s, right %s, parent %s", pathString(c.Path), encodedOrAbsent(c.Left), encodedOrAbsent(c.Right), encodedOrAbsent(c.Parent))
+}
+
+// ConflictError is returned by ThreeWay when some changes could not be automatically resolved.
+type ConflictError struct {
+	Conflicts []Conflict
+}
+
+func (e *ConflictError) Error() string {
+	strs := make([]string, len(e.Conflicts))
+	for i, c := range e.Conflicts {
+		strs[i] = c.String()
+	}
+	return fmt.Sprintf("Merge conflict at %d path(s):\n%s", len(e.Conflicts), strings.Join(strs, "\n"))
+}
+
+// ThreeWay merges left and right, which are both assumed to descend from parent. Changes that touch disjoint parts of Map, Set, List and Struct values are combined structurally, recursing into nested collections. Any other divergence is a conflict; if there are conflicts, ThreeWay returns a *ConflictError listing all of them. parent may be nil if left and right share no history.
+func ThreeWay(left, right, parent types.Value) (types.Value, error) {
+	m := &merger{}
+	merged := m.threeWay(left, right, parent, types.Path{})
+	if len(m.conflicts) > 0 {
+		return nil, &ConflictError{m.conflicts}
+	}
+	return merged, nil
+}
+
+type merger struct {
+	conflicts []Conflict
+}
+
+func (m *merger) conflict(p types.Path, left, right, parent types.Value) {
+	m.conflicts = append(m.conflicts, Conflict{p, left, right, parent})
+}
+
+// threeWay returns the merge of a and b, or nil if the merged value should be absent. On conflict, it records the conflict and returns a.
+func (m *merger) threeWay(a, b, parent types.Value, p types.Path) types.Value {
+	if valuesEqual(a, b) || valuesEqual(b, parent) {
+		return a
+	}
+	if valuesEqual(a, parent) {
+		return b
+	}
+	if a == nil || b == nil {
+		// One side removed the value, the other changed it.
+		m.conflict(p, a, b, parent)
+		return a
+	}
+
+	switch a := a.(type) {
+	case types.Map:
+		if b, ok := b.(types.Map); ok {
+			pm, ok := parent.(types.Map)
+			if !ok {
+				pm = types.NewMap()
+			}
+			return m.mergeMaps(a, b, pm, p)
+		}
+	case types.Set:
+		if b, ok := b.(types.Set); ok {
+			ps, ok := parent.(types.Set)
+			if !ok {
+				ps = types.NewSet()
+			}
+			return mergeSets(a, b, ps)
+		}
+	case types.List:
+		if b, ok := b.(types.List); ok {
+			pl, ok := parent.(types.List)
+			if !ok {
+				pl = types.NewList()
+			}
+			return m.mergeLists(a, b, pl, p)
+		}
+	case types.Struct:
+		if b, ok := b.(types.Struct); ok {
+			if ps, ok := parent.(types.Struct); ok && structName(a) == structName(b) && structName(a) == structName(ps) {
+				return m.mergeStructs(a, b, ps, p)
+			}
+		}
+	}
+
+	m.conflict(p, a, b, parent)
+	return a
+}
+
+func (m *merger) mergeMaps(a, b, parent types.Map, p types.Path) types.Map {
+	aChanges := collectChanges(func(changes chan<- types.ValueChanged, closeChan <-chan struct{}) {
+		a.Diff(parent, changes, closeChan)
+	})
+	bChanges := collectChanges(func(changes chan<- types.ValueChanged, closeChan <-chan struct{}) {
+		b.Diff(parent, changes, closeChan)
+	})
+
+	bOnly := changesByHash(bChanges)
+	merged := a
+	for _, change := range aChanges {
+		k := change.V
+		if _, ok := bOnly[k.Hash()]; !ok {
+			continue
+		}
+		delete(bOnly, k.Hash())
+
+		av, _ := a.MaybeGet(k)
+		bv, _ := b.MaybeGet(k)
+		pv, _ := parent.MaybeGet(k)
+		if v := m.threeWay(av, bv, pv, appendPath(p, keyPathPart(k))); v != nil {
+			merged = merged.Set(k, v)
+		} else {
+			merged = merged.Remove(k)
+		}
+	}
+
+	for _, change := range bChanges {
+		if _, ok := bOnly[change.V.Hash()]; !ok {
+			continue
+		}
+		if change.ChangeType == types.DiffChangeRemoved {
+			merged = merged.Remove(change.V)
+		} else {
+			merged = merged.Set(change.V, b.Get(change.V))
+		}
+	}
+	return merged
+}
+
+// mergeSets never conflicts, since the only possible changes to an element are to add or remove it, and any change made on both sides must have been the same one.
+func mergeSets(a, b, parent types.Set) types.Set {
+	bChanges := collectChanges(func(changes chan<- types.ValueChanged, closeChan <-chan struct{}) {
+		b.Diff(parent, changes, closeChan)
+	})
+
+	merged := a
+	for _, change := range bChanges {
+		if change.ChangeType == types.DiffChangeRemoved {
+			merged = merged.Remove(change.V)
+		} else {
+			merged = merged.Insert(change.V)
+		}
+	}
+	return merged
+}
+
+func (m *merger) mergeStructs(a, b, parent types.Struct, p types.Path) types.Struct {
+	aChanges := collectChanges(func(changes chan<- types.ValueChanged, closeChan <-chan struct{}) {
+		a.Diff(parent, changes, closeChan)
+	})
+	bChanges := collectChanges(func(changes chan<- types.ValueChanged, closeChan <-chan struct{}) {
+		b.Diff(parent, changes, closeChan)
+	})
+
+	data := types.StructData{}
+	a.Type().Desc.(types.StructDesc).IterFields(func(name string, t *types.Type) {
+		data[name] = a.Get(name)
+	})
+
+	bOnly := changesByHash(bChanges)
+	for _, change := range aChanges {
+		if _, ok := bOnly[change.V.Hash()]; !ok {
+			continue
+		}
+		delete(bOnly, change.V.Hash())
+
+		name := string(change.V.(types.String))
+		av, _ := a.MaybeGet(name)
+		bv, _ := b.MaybeGet(name)
+		pv, _ := parent.MaybeGet(name)
+		if v := m.threeWay(av, bv, pv, appendPath(p, types.NewFieldPath(name))); v != nil {
+			data[name] = v
+		} else {
+			delete(data, name)
+		}
+	}
+
+	for _, change := range bChanges {
+		if _, ok := bOnly[change.V.Hash()]; !ok {
+			continue
+		}
+		name := string(change.V.(types.String))
+		if change.ChangeType == types.DiffChangeRemoved {
+			delete(data, name)
+		} else {
+			data[name] = b.Get(name)
+		}
+	}
+	return types.NewStruct(structName(a), data)
+}
+
+type pendingSplice struct {
+	at      uint64
+	removed uint64
+	values  []types.Value
+}
+
+// mergeLists combines the splices that turn parent into a and b. Splices that touch overlapping ranges of parent, or insert at the same index, conflict unless they are identical. On conflict, it records the conflicts and returns a.
+func (m *merger) mergeLists(a, b, parent types.List, p types.Path) types.List {
+	aSplices, bSplices := collectSplices(a, parent), collectSplices(b, parent)
+
+	pending := []pendingSplice{}
+	conflicted := map[uint64]bool{} // Indices of parent that conflicts have been recorded at.
+	offset := int64(0)              // Net number of elements inserted by the a-splices before the current b-splice.
+	i := 0                          // The first a-splice that isn't before the current b-splice.
+	for _, bs := range bSplices {
+		for i < len(aSplices) && aSplices[i].SpAt < bs.SpAt && !splicesOverlap(aSplices[i], bs) {
+			offset += int64(aSplices[i].SpAdded) - int64(aSplices[i].SpRemoved)
+			i++
+		}
+
+		// a-splices are ordered and don't overlap each other, so the ones that overlap bs follow on from i. An a-splice may overlap several b-splices, so i stays put.
+		overlapped := false
+		for k := i; k < len(aSplices) && splicesOverlap(aSplices[k], bs); k++ {
+			overlapped = true
+			if as := aSplices[k]; !splicesEqual(as, bs, a, b) {
+				if at := minUint64(as.SpAt, bs.SpAt); !conflicted[at] {
+					conflicted[at] = true
+					idx := types.NewIndexPath(types.Number(at))
+					m.conflict(appendPath(p, idx), idx.Resolve(a), idx.Resolve(b), idx.Resolve(parent))
+				}
+			}
+		}
+		// Identical splices are already in a, and once there's a conflict, a is all that's returned.
+		if overlapped || len(conflicted) > 0 {
+			continue
+		}
+
+		values := make([]types.Value, bs.SpAdded)
+		for k := range values {
+			values[k] = b.Get(bs.SpFrom + uint64(k))
+		}
+		pending = append(pending, pendingSplice{uint64(int64(bs.SpAt) + offset), bs.SpRemoved, values})
+	}
+	if len(conflicted) > 0 {
+		return a
+	}
+
+	// Apply from the end so that earlier indices stay valid.
+	merged := a
+	for i := len(pending) - 1; i >= 0; i-- {
+		ps := pending[i]
+		merged = merged.Splice(ps.at, ps.removed, ps.values...)
+	}
+	return merged
+}
+
+// splicesOverlap reports whether x and y touch the same part of their parent list: both insert at the same index, or one starts, whether it removes anything or only inserts, inside the range that the other removes.
+func splicesOverlap(x, y types.Splice) bool {
+	if x.SpAt == y.SpAt {
+		return true
+	}
+	if y.SpAt < x.SpAt {
+		x, y = y, x
+	}
+	return y.SpAt < x.SpAt+x.SpRemoved
+}
+
+func splicesEqual(x, y types.Splice, xList, yList types.List) bool {
+	if x.SpAt != y.SpAt || x.SpRemoved != y.SpRemoved || x.SpAdded != y.SpAdded {
+		return false
+	}
+	for k := uint64(0); k < x.SpAdded; k++ {
+		if !xList.Get(x.SpFrom + k).Equals(yList.Get(y.SpFrom + k)) {
+			return false
+		}
+	}
+	return true
+}
+
+func collectChanges(diff func(changes chan<- types.ValueChanged, closeChan <-chan struct{})) []types.ValueChanged {
+	changes := make(chan types.ValueChanged)
+	closeChan := make(chan struct{})
+	go func() {
+		diff(changes, closeChan)
+		close(changes)
+	}()
+
+	result := []types.ValueChanged{}
+	for c := range changes {
+		result = append(result, c)
+	}
+	return result
+}
+
+func collectSplices(l, last types.List) []types.Splice {
+	splices := make(chan types.Splice)
+	closeChan := make(chan struct{})
+	go func() {
+		l.Diff(last, splices, closeChan)
+		close(splices)
+	}()
+
+	result := []types.Splice{}
+	for sp := range splices {
+		result = append(result, sp)
+	}
+	return result
+}
+
+func changesByHash(changes []types.ValueChanged) map[hash.Hash]types.ValueChanged {
+	m := make(map[hash.Hash]types.ValueChanged, len(changes))
+	for _, c := range changes {
+		m[c.V.Hash()] = c
+	}
+	return m
+}
+
+func keyPathPart(k types.Value) types.PathPart {
+	switch k.Type().Kind() {
+	case types.StringKind, types.BoolKind, types.NumberKind:
+		return types.NewIndexPath(k)
+	default:
+		return types.NewHashIndexPath(k.Hash())
+	}
+}
+
+func appendPath(p types.Path, part types.PathPart) types.Path {
+	result := make(types.Path, len(p), len(p)+1)
+	copy(result, p)
+	return append(result, part)
+}
+
+func valuesEqual(a, b types.Value) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return a.Equals(b)
+}
+
+func structName(s types.Struct) string {
+	return s.Type().Desc.(types.StructDesc).Name
+}
+
+func pathString(p types.Path) string {
+	if len(p) == 0 {
+		return "(root)"
+	}
+	return p.String()
+}
+
+func encodedOrAbsent(v types.Value) string {
+	if v == nil {
+		return "(absent)"
+	}
+	return types.EncodedValue(v)
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
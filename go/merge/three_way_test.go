@@ -0,0 +1,143 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package merge
+
+import (
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func mustMerge(assert *assert.Assertions, left, right, parent types.Value) types.Value {
+	merged, err := ThreeWay(left, right, parent)
+	assert.NoError(err)
+	return merged
+}
+
+func assertConflictPaths(assert *assert.Assertions, err error, paths ...string) {
+	if assert.IsType(&ConflictError{}, err) {
+		conflicts := err.(*ConflictError).Conflicts
+		actual := make([]string, len(conflicts))
+		for i, c := range conflicts {
+			actual[i] = c.Path.String()
+		}
+		assert.Equal(paths, actual)
+	}
+}
+
+func TestThreeWayTrivial(t *testing.T) {
+	assert := assert.New(t)
+	p, l := types.Number(1), types.Number(2)
+
+	assert.True(l.Equals(mustMerge(assert, l, p, p)))
+	assert.True(l.Equals(mustMerge(assert, p, l, p)))
+	assert.True(l.Equals(mustMerge(assert, l, l, p)))
+
+	_, err := ThreeWay(l, types.Number(3), p)
+	assertConflictPaths(assert, err, "")
+}
+
+func TestThreeWayMap(t *testing.T) {
+	assert := assert.New(t)
+	parent := types.NewMap(types.String("a"), types.Number(1), types.String("b"), types.Number(2), types.String("c"), types.Number(3))
+	left := parent.Set(types.String("a"), types.Number(10)).Remove(types.String("c"))
+	right := parent.Set(types.String("b"), types.Number(20)).Set(types.String("d"), types.Number(4))
+
+	expected := types.NewMap(types.String("a"), types.Number(10), types.String("b"), types.Number(20), types.String("d"), types.Number(4))
+	assert.True(expected.Equals(mustMerge(assert, left, right, parent)))
+	assert.True(expected.Equals(mustMerge(assert, right, left, parent)))
+
+	// Both sides removing the same key is not a conflict.
+	assert.True(parent.Remove(types.String("a")).Equals(mustMerge(assert, parent.Remove(types.String("a")), parent.Remove(types.String("a")), parent)))
+
+	_, err := ThreeWay(left, parent.Set(types.String("a"), types.Number(11)), parent)
+	assertConflictPaths(assert, err, `["a"]`)
+
+	_, err = ThreeWay(left, parent.Set(types.String("c"), types.Number(30)), parent)
+	assertConflictPaths(assert, err, `["c"]`)
+}
+
+func TestThreeWayNested(t *testing.T) {
+	assert := assert.New(t)
+	inner := types.NewMap(types.Number(1), types.String("one"), types.Number(2), types.String("two"))
+	parent := types.NewStruct("S", types.StructData{"m": inner, "n": types.Number(0)})
+	left := parent.Set("m", inner.Set(types.Number(1), types.String("uno")))
+	right := parent.Set("m", inner.Set(types.Number(3), types.String("three")))
+
+	expected := parent.Set("m", inner.Set(types.Number(1), types.String("uno")).Set(types.Number(3), types.String("three")))
+	assert.True(expected.Equals(mustMerge(assert, left, right, parent)))
+
+	_, err := ThreeWay(left, parent.Set("m", inner.Set(types.Number(1), types.String("eins"))), parent)
+	assertConflictPaths(assert, err, ".m[1]")
+}
+
+func TestThreeWayStruct(t *testing.T) {
+	assert := assert.New(t)
+	parent := types.NewStruct("S", types.StructData{"a": types.Number(1), "b": types.Number(2)})
+	left := types.NewStruct("S", types.StructData{"a": types.Number(10), "b": types.Number(2), "c": types.Bool(true)})
+	right := types.NewStruct("S", types.StructData{"a": types.Number(1)})
+
+	expected := types.NewStruct("S", types.StructData{"a": types.Number(10), "c": types.Bool(true)})
+	assert.True(expected.Equals(mustMerge(assert, left, right, parent)))
+
+	_, err := ThreeWay(left, types.NewStruct("T", types.StructData{}), parent)
+	assertConflictPaths(assert, err, "")
+}
+
+func TestThreeWaySet(t *testing.T) {
+	assert := assert.New(t)
+	parent := types.NewSet(types.Number(1), types.Number(2), types.Number(3))
+	left := parent.Insert(types.Number(4)).Remove(types.Number(1))
+	right := parent.Insert(types.Number(5)).Remove(types.Number(1), types.Number(3))
+
+	expected := types.NewSet(types.Number(2), types.Number(4), types.Number(5))
+	assert.True(expected.Equals(mustMerge(assert, left, right, parent)))
+}
+
+func TestThreeWayList(t *testing.T) {
+	assert := assert.New(t)
+	n := func(vs ...float64) types.List {
+		l := types.NewList()
+		for _, v := range vs {
+			l = l.Append(types.Number(v))
+		}
+		return l
+	}
+	parent := n(0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+	left := n(0, 10, 11, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+	right := n(0, 1, 2, 3, 4, 5, 6, 8, 9, 12)
+
+	expected := n(0, 10, 11, 1, 2, 3, 4, 5, 6, 8, 9, 12)
+	assert.True(expected.Equals(mustMerge(assert, left, right, parent)))
+	assert.True(expected.Equals(mustMerge(assert, right, left, parent)))
+
+	_, err := ThreeWay(left, n(0, 13, 1, 2, 3, 4, 5, 6, 7, 8, 9), parent)
+	assertConflictPaths(assert, err, "[1]")
+}
+
+func TestThreeWayListOverlappingSplices(t *testing.T) {
+	assert := assert.New(t)
+	n := func(vs ...float64) types.List {
+		l := types.NewList()
+		for _, v := range vs {
+			l = l.Append(types.Number(v))
+		}
+		return l
+	}
+	parent := n(0, 1, 2, 3, 4, 5, 6, 7, 8, 9)
+
+	// One splice on the left touches several on the right.
+	_, err := ThreeWay(n(), n(0, 1, 20, 3, 4, 5, 6, 70, 8, 9), parent)
+	assertConflictPaths(assert, err, "[0]")
+	_, err = ThreeWay(n(0, 1, 20, 3, 4, 5, 6, 70, 8, 9), n(), parent)
+	assertConflictPaths(assert, err, "[0]")
+
+	// An insertion inside a range that the other side removes.
+	_, err = ThreeWay(n(0, 1, 6, 7, 8, 9), n(0, 1, 2, 3, 42, 4, 5, 6, 7, 8, 9), parent)
+	assert.Error(err)
+	_, err = ThreeWay(n(0, 1, 2, 3, 42, 4, 5, 6, 7, 8, 9), n(0, 1, 6, 7, 8, 9), parent)
+	assert.Error(err)
+}
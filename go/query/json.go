@@ -0,0 +1,71 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package query
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// WriteJSON writes v to w as a single line of JSON. Structs become objects, Lists and Sets become arrays, Maps with String keys become objects and other Maps become arrays of [key, value] pairs. Blobs are base64 encoded, Refs are written as their target hash prefixed with # and Types as their description.
+func WriteJSON(w io.Writer, v types.Value) error {
+	return json.NewEncoder(w).Encode(toJSON(v))
+}
+
+func toJSON(v types.Value) interface{} {
+	switch v := v.(type) {
+	case types.Bool:
+		return bool(v)
+	case types.Number:
+		return float64(v)
+	case types.String:
+		return string(v)
+	case types.Blob:
+		data, err := ioutil.ReadAll(v.Reader())
+		d.PanicIfError(err)
+		return base64.StdEncoding.EncodeToString(data)
+	case types.List:
+		result := make([]interface{}, 0, v.Len())
+		v.IterAll(func(v types.Value, _ uint64) {
+			result = append(result, toJSON(v))
+		})
+		return result
+	case types.Set:
+		result := make([]interface{}, 0, v.Len())
+		v.IterAll(func(v types.Value) {
+			result = append(result, toJSON(v))
+		})
+		return result
+	case types.Map:
+		if v.Empty() || v.Type().Desc.(types.CompoundDesc).ElemTypes[0].Kind() == types.StringKind {
+			result := make(map[string]interface{}, v.Len())
+			v.IterAll(func(k, v types.Value) {
+				result[string(k.(types.String))] = toJSON(v)
+			})
+			return result
+		}
+		result := make([]interface{}, 0, v.Len())
+		v.IterAll(func(k, v types.Value) {
+			result = append(result, []interface{}{toJSON(k), toJSON(v)})
+		})
+		return result
+	case types.Struct:
+		result := map[string]interface{}{}
+		v.Type().Desc.(types.StructDesc).IterFields(func(name string, t *types.Type) {
+			result[name] = toJSON(v.Get(name))
+		})
+		return result
+	case types.Ref:
+		return "#" + v.TargetHash().String()
+	case *types.Type:
+		return v.Describe()
+	}
+	panic("unreachable")
+}
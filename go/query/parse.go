@@ -0,0 +1,360 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/attic-labs/noms/go/types"
+)
+
+type tokenKind int
+
+const (
+	eofToken tokenKind = iota
+	wordToken
+	pathToken
+	stringToken
+	numberToken
+	opToken
+	punctToken
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	if t.kind == eofToken {
+		return "end of query"
+	}
+	return fmt.Sprintf("%q at %d", t.text, t.pos)
+}
+
+// lex splits a query into tokens. Paths run until the next space, operator or punctuation outside of brackets and quotes, so that they can be handed to types.ParsePath as is.
+func lex(str string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(str); {
+		c := str[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '.' && (i+1 == len(str) || !isDigit(str[i+1])):
+			end, err := scanPath(str, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{pathToken, str[i:end], i})
+			i = end
+		case c == '"':
+			end, err := scanString(str, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{stringToken, str[i:end], i})
+			i = end
+		case isDigit(c) || c == '-' || c == '.':
+			end := i + 1
+			for end < len(str) && (isDigit(str[end]) || strings.IndexByte(".eE+-", str[end]) >= 0) {
+				end++
+			}
+			tokens = append(tokens, token{numberToken, str[i:end], i})
+			i = end
+		case strings.IndexByte("=!<>", c) >= 0:
+			end := i + 1
+			if end < len(str) && str[end] == '=' {
+				end++
+			}
+			tokens = append(tokens, token{opToken, str[i:end], i})
+			i = end
+		case c == '(' || c == ')' || c == ',':
+			tokens = append(tokens, token{punctToken, str[i : i+1], i})
+			i++
+		case unicode.IsLetter(rune(c)):
+			end := i + 1
+			for end < len(str) && (unicode.IsLetter(rune(str[end])) || isDigit(str[end]) || str[end] == '_') {
+				end++
+			}
+			tokens = append(tokens, token{wordToken, strings.ToLower(str[i:end]), i})
+			i = end
+		default:
+			return nil, fmt.Errorf("Unexpected character %q at %d", c, i)
+		}
+	}
+	return append(tokens, token{eofToken, "", len(str)}), nil
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func scanPath(str string, start int) (int, error) {
+	depth := 0
+	for i := start; i < len(str); i++ {
+		switch c := str[i]; {
+		case c == '"':
+			end, err := scanString(str, i)
+			if err != nil {
+				return 0, err
+			}
+			i = end - 1
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case depth == 0 && strings.IndexByte(" \t\n\r=!<>(),", c) >= 0:
+			return i, nil
+		}
+	}
+	return len(str), nil
+}
+
+func scanString(str string, start int) (int, error) {
+	for i := start + 1; i < len(str); i++ {
+		switch str[i] {
+		case '\\':
+			i++
+		case '"':
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("Unterminated string at %d", start)
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != eofToken {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) isWord(w string) bool {
+	t := p.peek()
+	return t.kind == wordToken && t.text == w
+}
+
+func (p *parser) expectWord(w string) error {
+	if t := p.next(); t.kind != wordToken || t.text != w {
+		return fmt.Errorf("Expected %s, got %s", w, t)
+	}
+	return nil
+}
+
+func (p *parser) parseQuery() (*Query, error) {
+	q := &Query{}
+	var err error
+	if p.isWord("where") {
+		p.next()
+		if q.where, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case p.isWord("select"):
+		p.next()
+		if q.selects, err = p.parseSelects(); err != nil {
+			return nil, err
+		}
+	case p.isWord("count"):
+		p.next()
+		q.count = true
+	case p.isWord("group"):
+		p.next()
+		if err = p.expectWord("by"); err != nil {
+			return nil, err
+		}
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		q.groupBy = &column{resultName(path), path}
+	}
+
+	if p.isWord("limit") {
+		p.next()
+		t := p.next()
+		n, err := strconv.ParseUint(t.text, 10, 64)
+		if t.kind != numberToken || err != nil {
+			return nil, fmt.Errorf("Expected limit, got %s", t)
+		}
+		q.limit = n
+	}
+
+	if t := p.next(); t.kind != eofToken {
+		return nil, fmt.Errorf("Unexpected %s", t)
+	}
+	return q, nil
+}
+
+func (p *parser) parseSelects() ([]column, error) {
+	columns := []column{}
+	seen := map[string]bool{}
+	for {
+		path, err := p.parsePath()
+		if err != nil {
+			return nil, err
+		}
+		name := resultName(path)
+		if name == "" {
+			return nil, fmt.Errorf("Selected path must end in a field: %s", path)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("Field %s is selected more than once", name)
+		}
+		seen[name] = true
+		columns = append(columns, column{name, path})
+
+		if t := p.peek(); t.kind != punctToken || t.text != "," {
+			return columns, nil
+		}
+		p.next()
+	}
+}
+
+func (p *parser) parsePath() (types.Path, error) {
+	t := p.next()
+	if t.kind != pathToken {
+		return nil, fmt.Errorf("Expected path, got %s", t)
+	}
+	return types.ParsePath(t.text)
+}
+
+func (p *parser) parseOr() (predicate, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orPredicate{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (predicate, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andPredicate{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (predicate, error) {
+	if p.isWord("not") {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notPredicate{operand}, nil
+	}
+	if t := p.peek(); t.kind == punctToken && t.text == "(" {
+		p.next()
+		pred, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.next(); t.kind != punctToken || t.text != ")" {
+			return nil, fmt.Errorf("Expected ), got %s", t)
+		}
+		return pred, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (predicate, error) {
+	path, err := p.parsePath()
+	if err != nil {
+		return nil, err
+	}
+	if p.isWord("exists") {
+		p.next()
+		return existsPredicate{path}, nil
+	}
+
+	t := p.next()
+	if t.kind != opToken {
+		return nil, fmt.Errorf("Expected comparison operator, got %s", t)
+	}
+	op := t.text
+	switch op {
+	case "==":
+		op = "="
+	case "=", "!=", "<", "<=", ">", ">=":
+	default:
+		return nil, fmt.Errorf("Unknown operator %s", t)
+	}
+
+	lit, err := p.parseLiteral()
+	if err != nil {
+		return nil, err
+	}
+	return comparison{path, op, lit}, nil
+}
+
+func (p *parser) parseLiteral() (types.Value, error) {
+	t := p.next()
+	switch t.kind {
+	case stringToken:
+		s, err := strconv.Unquote(t.text)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid string %s", t)
+		}
+		return types.String(s), nil
+	case numberToken:
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid number %s", t)
+		}
+		return types.Number(f), nil
+	case wordToken:
+		switch t.text {
+		case "true":
+			return types.Bool(true), nil
+		case "false":
+			return types.Bool(false), nil
+		}
+	}
+	return nil, fmt.Errorf("Expected string, number or boolean, got %s", t)
+}
+
+// resultName returns the name of the last field in path, or "" if path does not end in a field.
+func resultName(path types.Path) string {
+	if len(path) == 0 {
+		return ""
+	}
+	if fp, ok := path[len(path)-1].(types.FieldPath); ok {
+		return fp.Name
+	}
+	return ""
+}
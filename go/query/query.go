@@ -0,0 +1,260 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package query implements a small query language over the rows of Noms collections. The rows of a List or Set are its elements and the rows of a Map are its values; rows are typically structs.
+//
+// A query is made up of the following optional clauses, in this order:
+//
+//	where <predicate>        keep only the rows matching <predicate>
+//	select <path>, ...       project each row to a struct of the given fields
+//	count                    produce the number of rows instead of the rows
+//	group by <path>          produce the number of rows for each value at <path>
+//	limit <n>                produce at most <n> results
+//
+// Only one of select, count and group by may be given. Paths use the syntax of types.ParsePath and are resolved against each row, e.g. .name or .address.city. A predicate compares the value at a path to a literal with one of =, !=, <, <=, > and >=, or tests whether there is a value at a path with <path> exists. Literals are double-quoted strings, numbers, true and false. Predicates can be combined with and, or and not, and grouped with parentheses:
+//
+//	where .status = "failed" and (.retries > 3 or not .owner exists) select .id, .owner limit 10
+//
+// Rows that have no value at a compared path never match, except when negated.
+package query
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/attic-labs/noms/go/hash"
+	"github.com/attic-labs/noms/go/types"
+)
+
+// GroupStructName is the name of the structs produced by group by queries.
+const GroupStructName = "Group"
+
+// CountField is the name of the field holding the number of rows in the results of a group by query.
+const CountField = "count"
+
+// Query is a parsed query, ready to be run against a collection.
+type Query struct {
+	where   predicate
+	selects []column
+	count   bool
+	groupBy *column
+	limit   uint64
+}
+
+type column struct {
+	name string
+	path types.Path
+}
+
+// Parse parses a query. The empty query produces every row.
+func Parse(str string) (*Query, error) {
+	tokens, err := lex(str)
+	if err != nil {
+		return nil, err
+	}
+	return (&parser{tokens: tokens}).parseQuery()
+}
+
+// Run runs q against the rows of coll, which must be a List, Map or Set, and calls emit with each result as it is produced. Rows are read from coll lazily, so results of queries without count or group by are emitted while the collection is being read, and reading stops once the limit is reached or emit returns true.
+//
+// The results are the matching rows themselves, or structs holding the selected fields of each matching row, named like the row. A count query produces a single Number. A group by query produces a GroupStructName struct for each distinct value at the grouping path, in ascending order, with that value in a field named after the last field of the path (or "value" if there is none) and the number of rows in CountField.
+func (q *Query) Run(coll types.Value, emit func(result types.Value) (stop bool)) error {
+	emitted := uint64(0)
+	limitedEmit := func(result types.Value) bool {
+		if q.limit > 0 && emitted >= q.limit {
+			return true
+		}
+		emitted++
+		return emit(result) || (q.limit > 0 && emitted >= q.limit)
+	}
+
+	switch {
+	case q.count:
+		n := uint64(0)
+		err := q.iterMatches(coll, func(row types.Value) bool {
+			n++
+			return false
+		})
+		if err != nil {
+			return err
+		}
+		limitedEmit(types.Number(n))
+		return nil
+
+	case q.groupBy != nil:
+		g := newGrouper(q.groupBy.name)
+		err := q.iterMatches(coll, func(row types.Value) bool {
+			if v := q.groupBy.path.Resolve(row); v != nil {
+				g.add(v)
+			}
+			return false
+		})
+		if err != nil {
+			return err
+		}
+		g.emit(limitedEmit)
+		return nil
+
+	case q.selects != nil:
+		return q.iterMatches(coll, func(row types.Value) bool {
+			return limitedEmit(q.project(row))
+		})
+
+	default:
+		return q.iterMatches(coll, limitedEmit)
+	}
+}
+
+func (q *Query) iterMatches(coll types.Value, cb func(row types.Value) (stop bool)) error {
+	filtered := func(row types.Value) bool {
+		if q.where != nil && !q.where.matches(row) {
+			return false
+		}
+		return cb(row)
+	}
+
+	switch coll := coll.(type) {
+	case types.List:
+		coll.Iter(func(v types.Value, _ uint64) bool {
+			return filtered(v)
+		})
+	case types.Map:
+		coll.Iter(func(_, v types.Value) bool {
+			return filtered(v)
+		})
+	case types.Set:
+		coll.Iter(filtered)
+	default:
+		return fmt.Errorf("Can only query a List, Map or Set, not %s", coll.Type().Describe())
+	}
+	return nil
+}
+
+func (q *Query) project(row types.Value) types.Value {
+	name := ""
+	if s, ok := row.(types.Struct); ok {
+		name = s.Type().Desc.(types.StructDesc).Name
+	}
+	data := types.StructData{}
+	for _, c := range q.selects {
+		if v := c.path.Resolve(row); v != nil {
+			data[c.name] = v
+		}
+	}
+	return types.NewStruct(name, data)
+}
+
+type group struct {
+	value types.Value
+	count uint64
+}
+
+type grouper struct {
+	name   string
+	groups map[hash.Hash]*group
+}
+
+func newGrouper(name string) *grouper {
+	if name == "" || name == CountField {
+		name = "value"
+	}
+	return &grouper{name, map[hash.Hash]*group{}}
+}
+
+func (g *grouper) add(v types.Value) {
+	h := v.Hash()
+	if gr, ok := g.groups[h]; ok {
+		gr.count++
+	} else {
+		g.groups[h] = &group{v, 1}
+	}
+}
+
+func (g *grouper) emit(emit func(types.Value) bool) {
+	values := make(types.ValueSlice, 0, len(g.groups))
+	for _, gr := range g.groups {
+		values = append(values, gr.value)
+	}
+	sort.Sort(values)
+	for _, v := range values {
+		result := types.NewStruct(GroupStructName, types.StructData{
+			g.name:     v,
+			CountField: types.Number(g.groups[v.Hash()].count),
+		})
+		if emit(result) {
+			return
+		}
+	}
+}
+
+type predicate interface {
+	matches(row types.Value) bool
+}
+
+type andPredicate struct {
+	left, right predicate
+}
+
+func (p andPredicate) matches(row types.Value) bool {
+	return p.left.matches(row) && p.right.matches(row)
+}
+
+type orPredicate struct {
+	left, right predicate
+}
+
+func (p orPredicate) matches(row types.Value) bool {
+	return p.left.matches(row) || p.right.matches(row)
+}
+
+type notPredicate struct {
+	operand predicate
+}
+
+func (p notPredicate) matches(row types.Value) bool {
+	return !p.operand.matches(row)
+}
+
+type existsPredicate struct {
+	path types.Path
+}
+
+func (p existsPredicate) matches(row types.Value) bool {
+	return p.path.Resolve(row) != nil
+}
+
+type comparison struct {
+	path    types.Path
+	op      string
+	literal types.Value
+}
+
+func (c comparison) matches(row types.Value) bool {
+	v := c.path.Resolve(row)
+	if v == nil {
+		return false
+	}
+	switch c.op {
+	case "=":
+		return v.Equals(c.literal)
+	case "!=":
+		return !v.Equals(c.literal)
+	}
+
+	// Ordering comparisons only make sense between values of the same primitive kind.
+	if v.Type().Kind() != c.literal.Type().Kind() {
+		return false
+	}
+	switch c.op {
+	case "<":
+		return v.Less(c.literal)
+	case "<=":
+		return !c.literal.Less(v)
+	case ">":
+		return c.literal.Less(v)
+	case ">=":
+		return !v.Less(c.literal)
+	}
+	panic("unreachable")
+}
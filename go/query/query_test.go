@@ -0,0 +1,173 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package query
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/assert"
+)
+
+func row(id int, status string, retries int) types.Struct {
+	return types.NewStruct("Job", types.StructData{
+		"id":      types.Number(id),
+		"status":  types.String(status),
+		"retries": types.Number(retries),
+	})
+}
+
+func testRows() types.List {
+	return types.NewList(
+		row(1, "ok", 0),
+		row(2, "failed", 4),
+		row(3, "failed", 1),
+		row(4, "ok", 2),
+		types.NewStruct("Job", types.StructData{"id": types.Number(5), "status": types.String("pending")}),
+	)
+}
+
+func runQuery(assert *assert.Assertions, coll types.Value, str string) []types.Value {
+	q, err := Parse(str)
+	assert.NoError(err)
+	results := []types.Value{}
+	assert.NoError(q.Run(coll, func(v types.Value) bool {
+		results = append(results, v)
+		return false
+	}))
+	return results
+}
+
+func ids(results []types.Value) []int {
+	ids := []int{}
+	for _, r := range results {
+		ids = append(ids, int(r.(types.Struct).Get("id").(types.Number)))
+	}
+	return ids
+}
+
+func TestQueryWhere(t *testing.T) {
+	assert := assert.New(t)
+	rows := testRows()
+
+	assert.Equal([]int{1, 2, 3, 4, 5}, ids(runQuery(assert, rows, "")))
+	assert.Equal([]int{2, 3}, ids(runQuery(assert, rows, `where .status = "failed"`)))
+	assert.Equal([]int{2, 3}, ids(runQuery(assert, rows, `WHERE .status == "failed"`)))
+	assert.Equal([]int{1, 4, 5}, ids(runQuery(assert, rows, `where .status != "failed"`)))
+	assert.Equal([]int{2, 4}, ids(runQuery(assert, rows, `where .retries >= 2`)))
+	assert.Equal([]int{1, 3}, ids(runQuery(assert, rows, `where .retries<2`)))
+	assert.Equal([]int{3}, ids(runQuery(assert, rows, `where .status = "failed" and .retries <= 1`)))
+	assert.Equal([]int{2, 3, 5}, ids(runQuery(assert, rows, `where .status = "failed" or .status = "pending"`)))
+	assert.Equal([]int{1, 4, 5}, ids(runQuery(assert, rows, `where not (.status = "failed")`)))
+	assert.Equal([]int{5}, ids(runQuery(assert, rows, `where not .retries exists`)))
+	assert.Equal([]int{4}, ids(runQuery(assert, rows, `where .status = "ok" and (.retries > 1 or .id = 3)`)))
+
+	// Ordering comparisons between different kinds never match.
+	assert.Equal([]int{}, ids(runQuery(assert, rows, `where .status > 1`)))
+}
+
+func TestQueryCollections(t *testing.T) {
+	assert := assert.New(t)
+
+	m := types.NewMap(types.String("a"), row(1, "ok", 0), types.String("b"), row(2, "failed", 0))
+	assert.Equal([]int{2}, ids(runQuery(assert, m, `where .status = "failed"`)))
+
+	s := types.NewSet(row(1, "ok", 0), row(2, "failed", 0))
+	assert.Equal([]int{1}, ids(runQuery(assert, s, `where .status = "ok"`)))
+
+	q, err := Parse("")
+	assert.NoError(err)
+	assert.Error(q.Run(types.Number(1), func(types.Value) bool { return false }))
+}
+
+func TestQuerySelect(t *testing.T) {
+	assert := assert.New(t)
+
+	results := runQuery(assert, testRows(), `where .id >= 4 select .id, .retries`)
+	assert.Equal(2, len(results))
+	assert.True(types.NewStruct("Job", types.StructData{"id": types.Number(4), "retries": types.Number(2)}).Equals(results[0]))
+	assert.True(types.NewStruct("Job", types.StructData{"id": types.Number(5)}).Equals(results[1]))
+}
+
+func TestQueryCountAndGroupBy(t *testing.T) {
+	assert := assert.New(t)
+	rows := testRows()
+
+	assert.Equal([]types.Value{types.Number(2)}, runQuery(assert, rows, `where .status = "failed" count`))
+	assert.Equal([]types.Value{types.Number(5)}, runQuery(assert, rows, `count`))
+
+	results := runQuery(assert, rows, `group by .status`)
+	assert.Equal(3, len(results))
+	expected := []struct {
+		status string
+		count  int
+	}{{"failed", 2}, {"ok", 2}, {"pending", 1}}
+	for i, e := range expected {
+		assert.True(types.NewStruct("Group", types.StructData{"status": types.String(e.status), "count": types.Number(e.count)}).Equals(results[i]))
+	}
+
+	results = runQuery(assert, rows, `where .retries > 0 group by .status limit 1`)
+	assert.Equal(1, len(results))
+	assert.True(types.NewStruct("Group", types.StructData{"status": types.String("failed"), "count": types.Number(2)}).Equals(results[0]))
+}
+
+func TestQueryLimit(t *testing.T) {
+	assert := assert.New(t)
+	rows := testRows()
+
+	assert.Equal([]int{1, 2}, ids(runQuery(assert, rows, `limit 2`)))
+	assert.Equal([]int{2}, ids(runQuery(assert, rows, `where .status = "failed" limit 1`)))
+
+	// Reading stops at the limit.
+	n := 0
+	q, err := Parse("limit 3")
+	assert.NoError(err)
+	assert.NoError(q.Run(rows, func(types.Value) bool {
+		n++
+		return false
+	}))
+	assert.Equal(3, n)
+}
+
+func TestParseErrors(t *testing.T) {
+	assert := assert.New(t)
+	for _, str := range []string{
+		`where`,
+		`where .status`,
+		`where .status = `,
+		`where .status = "failed`,
+		`where .status ~ "x"`,
+		`where status = "x"`,
+		`where (.id = 1`,
+		`select`,
+		`select .id, .id`,
+		`select .ids[0]`,
+		`group .status`,
+		`limit x`,
+		`count limit 1 extra`,
+		`where .id = 1 where .id = 2`,
+	} {
+		_, err := Parse(str)
+		assert.Error(err, str)
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	v := types.NewStruct("", types.StructData{
+		"name":  types.String("x"),
+		"ok":    types.Bool(true),
+		"n":     types.Number(1.5),
+		"list":  types.NewList(types.Number(1), types.Number(2)),
+		"smap":  types.NewMap(types.String("a"), types.Number(1)),
+		"nmap":  types.NewMap(types.Number(1), types.String("a")),
+		"empty": types.NewMap(),
+	})
+	buf := &bytes.Buffer{}
+	assert.NoError(WriteJSON(buf, v))
+	assert.Equal(`{"empty":{},"list":[1,2],"n":1.5,"name":"x","nmap":[[1,"a"]],"ok":true,"smap":{"a":1}}`+"\n", buf.String())
+}
@@ -69,22 +69,55 @@ func NewAbsolutePath(str string) (AbsolutePath, error) {
 	return AbsolutePath{hash: h, dataset: dataset, path: path}, nil
 }
 
+// Dataset returns the name of the dataset p starts at, or "" if it starts at a hash.
+func (p AbsolutePath) Dataset() string {
+	return p.dataset
+}
+
+// Resolve returns the value p addresses in db, or nil if there is none. If p addresses several values, Resolve returns the first of them.
 func (p AbsolutePath) Resolve(db datas.Database) (val types.Value) {
+	p.ResolveEach(db, func(v types.Value) bool {
+		val = v
+		return true
+	})
+	return
+}
+
+// ResolveEach calls cb with each value p addresses in db, in order, until cb returns true. See types.Path.ResolveEach.
+func (p AbsolutePath) ResolveEach(db datas.Database, cb func(v types.Value) (stop bool)) {
+	var val types.Value
 	if len(p.dataset) > 0 {
 		var ok bool
 		if val, ok = db.MaybeHead(p.dataset); !ok {
-			val = nil
+			return
 		}
 	} else if !p.hash.IsEmpty() {
-		val = db.ReadValue(p.hash)
+		if val = db.ReadValue(p.hash); val == nil {
+			return
+		}
 	} else {
 		d.Chk.Fail("Unreachable")
 	}
 
-	if val != nil && p.path != nil {
-		val = p.path.Resolve(val)
-	}
-	return
+	p.path.ResolveEach(val, db, cb)
+}
+
+// ResolveAll returns all the values p addresses in db, in order.
+func (p AbsolutePath) ResolveAll(db datas.Database) []types.Value {
+	values := []types.Value{}
+	p.ResolveEach(db, func(v types.Value) bool {
+		values = append(values, v)
+		return false
+	})
+	return values
+}
+
+// append returns the path to part of the value at p.
+func (p AbsolutePath) append(part types.PathPart) AbsolutePath {
+	path := make(types.Path, len(p.path), len(p.path)+1)
+	copy(path, p.path)
+	p.path = append(path, part)
+	return p
 }
 
 func (p AbsolutePath) String() (str string) {
@@ -28,6 +28,8 @@ func TestAbsolutePathToAndFromString(t *testing.T) {
 	h := types.Number(42).Hash() // arbitrary hash
 	test(fmt.Sprintf("foo.bar[#%s]", h.String()))
 	test(fmt.Sprintf("#%s.bar[42]", h.String()))
+	test("foo.bar[*].baz@type")
+	test("foo@target[1:2]")
 }
 
 func TestAbsolutePaths(t *testing.T) {
@@ -97,3 +99,35 @@ func TestAbsolutePathParseErrors(t *testing.T) {
 	invHash := strings.Repeat("z", hash.StringLen)
 	test("#"+invHash, "Invalid hash: "+invHash)
 }
+
+func TestAbsolutePathResolveAll(t *testing.T) {
+	assert := assert.New(t)
+
+	db := datas.NewDatabase(chunks.NewMemoryStore())
+	list := types.NewList(types.String("a"), types.String("b"), types.String("c"))
+	db, err := db.Commit("ds", datas.NewCommit(list, types.NewSet(), types.EmptyStruct))
+	assert.NoError(err)
+	parent := db.HeadRef("ds")
+	db, err = db.Commit("ds", datas.NewCommit(list, types.NewSet(parent), types.EmptyStruct))
+	assert.NoError(err)
+
+	resolvesToAll := func(exp []types.Value, str string) {
+		p, err := NewAbsolutePath(str)
+		assert.NoError(err)
+		act := p.ResolveAll(db)
+		assert.Equal(len(exp), len(act), str)
+		for i := range exp {
+			assert.True(exp[i].Equals(act[i]), str)
+		}
+	}
+
+	resolvesToAll([]types.Value{types.String("b"), types.String("c")}, "ds.value[1:]")
+	resolvesToAll([]types.Value{types.String("a"), types.String("b"), types.String("c")}, "ds.value[*]")
+	resolvesToAll([]types.Value{parent.TargetValue(db)}, "ds.parents[*]@target")
+	resolvesToAll([]types.Value{types.NewSet()}, "ds.parents[*]@target.parents")
+	resolvesToAll([]types.Value{}, "foo.value[*]")
+
+	p, err := NewAbsolutePath("ds.parents[*]@target.value[2]")
+	assert.NoError(err)
+	assert.True(types.String("c").Equals(p.Resolve(db)))
+}
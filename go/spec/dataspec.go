@@ -5,10 +5,14 @@
 package spec
 
 import (
+	"crypto/tls"
 	"fmt"
 	"net/url"
+	"os"
+	"os/exec"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
@@ -16,12 +20,38 @@ import (
 	"github.com/attic-labs/noms/go/dataset"
 	"github.com/attic-labs/noms/go/hash"
 	"github.com/attic-labs/noms/go/types"
+	"github.com/aws/aws-sdk-go/aws"
 	flag "github.com/tsuru/gnuflag"
 )
 
 var (
 	datasetRe = regexp.MustCompile("^" + dataset.DatasetRe.String() + "$")
-	ldbStores = map[string]*refCountingLdbStore{}
+	// sshHomePathRe matches the paths of ssh specs that are relative to a home directory, capturing the ~ or ~user and the rest of the path.
+	sshHomePathRe = regexp.MustCompile(`^/(~[\w.-]*)(/.*)?$`)
+	ldbStores     = map[string]*refCountingLdbStore{}
+	nbsStores     = map[string]*refCountingTableStore{}
+
+	encryptAll        bool
+	encryptionKeyFile string
+)
+
+const (
+	// encryptedPrefix marks a spec whose database is encrypted, e.g. enc+ldb:/path.
+	encryptedPrefix = "enc+"
+
+	// encryptionKeyEnv is the environment variable encryption keys are read from if no keyfile is given.
+	encryptionKeyEnv = "NOMS_ENCRYPTION_KEY"
+
+	// sshCommandEnv is the environment variable holding the command ssh specs are reached with, and any arguments to it, which defaults to ssh.
+	sshCommandEnv = "NOMS_SSH_COMMAND"
+
+	// sshRemoteNomsEnv is the environment variable holding the path of noms on the remote host, which defaults to noms.
+	sshRemoteNomsEnv = "NOMS_SSH_REMOTE_NOMS"
+
+	// tlsCAEnv, tlsCertEnv and tlsKeyEnv are the environment variables holding the paths of the PEM files that https specs are reached with: the CAs to trust in place of the system's, and the certificate and key to present to servers that ask for one.
+	tlsCAEnv   = "NOMS_TLS_CA"
+	tlsCertEnv = "NOMS_TLS_CERT"
+	tlsKeyEnv  = "NOMS_TLS_KEY"
 )
 
 func GetDatabase(str string) (datas.Database, error) {
@@ -39,15 +69,32 @@ func GetChunkStore(str string) (chunks.ChunkStore, error) {
 	}
 
 	switch sp.Protocol {
-	case "ldb":
-		return getLDBStore(sp.Path), nil
-	case "mem":
-		return chunks.NewMemoryStore(), nil
+	case "ldb", "nbs", "s3", "dynamo", "bundle", "mem":
+		return sp.chunkStore()
 	default:
 		return nil, fmt.Errorf("Unable to create chunkstore for protocol: %s", str)
 	}
 }
 
+// GetReplicaStore returns a ReplicaStore of the database at upstream, which must be an http or https spec, that caches chunks in cache and reads the upstream Root at most once every rootTTL.
+func GetReplicaStore(upstream string, cache chunks.ChunkStore, rootTTL time.Duration) (rs *datas.ReplicaStore, err error) {
+	sp, err := parseDatabaseSpec(upstream)
+	if err != nil {
+		return nil, err
+	}
+	if sp.Protocol != "http" && sp.Protocol != "https" {
+		return nil, fmt.Errorf("Upstream database must be an http or https spec: %s", upstream)
+	}
+	config, err := clientTLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	err = d.Unwrap(d.Try(func() {
+		rs = datas.NewReplicaStore(sp.String(), "Bearer "+sp.accessToken, config, cache, rootTTL)
+	}))
+	return
+}
+
 func GetDataset(str string) (dataset.Dataset, error) {
 	sp, err := parseDatasetSpec(str)
 	if err != nil {
@@ -64,10 +111,20 @@ func GetPath(str string) (datas.Database, types.Value, error) {
 	return sp.Value()
 }
 
+// GetPathValues returns the database str refers to and all the values its path addresses. Unlike GetPath, this supports paths that fan out to many values, such as those with slices or wildcards.
+func GetPathValues(str string) (datas.Database, []types.Value, error) {
+	sp, err := parsePathSpec(str)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sp.Values()
+}
+
 type databaseSpec struct {
 	Protocol    string
 	Path        string
 	accessToken string
+	encrypted   bool
 }
 
 type datasetSpec struct {
@@ -81,6 +138,18 @@ type pathSpec struct {
 }
 
 func parseDatabaseSpec(spec string) (databaseSpec, error) {
+	if strings.HasPrefix(spec, encryptedPrefix) {
+		sp, err := parseDatabaseSpec(strings.TrimPrefix(spec, encryptedPrefix))
+		if err != nil {
+			return databaseSpec{}, err
+		}
+		if sp.Protocol == "http" || sp.Protocol == "https" || sp.Protocol == "ssh" || sp.Protocol == "unix" || sp.Protocol == "bundle" || sp.encrypted {
+			return databaseSpec{}, fmt.Errorf("Only local and AWS databases, other than bundles, can be encrypted: %s", spec)
+		}
+		sp.encrypted = true
+		return sp, nil
+	}
+
 	ldbDatabaseSpec := func(path string) (databaseSpec, error) {
 		if len(path) == 0 {
 			return databaseSpec{}, fmt.Errorf("Empty file system path")
@@ -111,9 +180,43 @@ func parseDatabaseSpec(spec string) (databaseSpec, error) {
 		token := u.Query().Get("access_token")
 		return databaseSpec{Protocol: protocol, Path: path, accessToken: token}, nil
 
+	case "ssh":
+		u, err := url.Parse(spec)
+		if err != nil || len(u.Host) == 0 || len(strings.TrimPrefix(u.Path, "/")) == 0 {
+			return databaseSpec{}, fmt.Errorf("Invalid SSH URL, expected ssh://[user@]host/path: %s", spec)
+		}
+		return databaseSpec{Protocol: protocol, Path: path}, nil
+
+	case "unix":
+		u, err := url.Parse(spec)
+		if err != nil || socketPath(u) == "" {
+			return databaseSpec{}, fmt.Errorf("Invalid unix socket spec, expected unix:/path/to.sock: %s", spec)
+		}
+		return databaseSpec{Protocol: protocol, Path: path, accessToken: u.Query().Get("access_token")}, nil
+
 	case "ldb":
 		return ldbDatabaseSpec(path)
 
+	case "nbs", "bundle":
+		if len(path) == 0 {
+			return databaseSpec{}, fmt.Errorf("Empty file system path")
+		}
+		return databaseSpec{Protocol: protocol, Path: path}, nil
+
+	case "s3":
+		u, err := url.Parse(spec)
+		if err != nil || len(u.Host) == 0 {
+			return databaseSpec{}, fmt.Errorf("Invalid S3 URL, expected s3://bucket/prefix: %s", spec)
+		}
+		return databaseSpec{Protocol: protocol, Path: path}, nil
+
+	case "dynamo":
+		u, err := url.Parse(spec)
+		if err != nil || len(u.Host) == 0 {
+			return databaseSpec{}, fmt.Errorf("Invalid DynamoDB URL, expected dynamo://table/namespace: %s", spec)
+		}
+		return databaseSpec{Protocol: protocol, Path: path}, nil
+
 	case "mem":
 		return databaseSpec{}, fmt.Errorf(`In-memory database must be specified as "mem", not "mem:%s"`, path)
 
@@ -164,27 +267,87 @@ func parsePathSpec(spec string) (pathSpec, error) {
 }
 
 func (s databaseSpec) String() string {
-	return s.Protocol + ":" + s.Path
+	prefix := ""
+	if s.encrypted {
+		prefix = encryptedPrefix
+	}
+	return prefix + s.Protocol + ":" + s.Path
 }
 
 func (spec databaseSpec) Database() (ds datas.Database, err error) {
 	switch spec.Protocol {
 	case "http", "https":
+		config, cerr := clientTLSConfig()
+		if cerr != nil {
+			return nil, cerr
+		}
 		err = d.Unwrap(d.Try(func() {
-			ds = datas.NewRemoteDatabase(spec.String(), "Bearer "+spec.accessToken)
+			if config != nil {
+				ds = datas.NewRemoteDatabaseWithTLS(spec.String(), "Bearer "+spec.accessToken, config)
+			} else {
+				ds = datas.NewRemoteDatabase(spec.String(), "Bearer "+spec.accessToken)
+			}
 		}))
-	case "ldb":
+	case "unix":
 		err = d.Unwrap(d.Try(func() {
-			ds = datas.NewDatabase(getLDBStore(spec.Path))
+			u, _ := url.Parse(spec.String())
+			ds = datas.NewSocketDatabase(socketPath(u), "Bearer "+spec.accessToken)
 		}))
-	case "mem":
-		ds = datas.NewDatabase(chunks.NewMemoryStore())
+	case "ssh":
+		ds, err = datas.NewCommandDatabase(sshCommand(spec.Path))
+	case "ldb", "nbs", "s3", "dynamo", "bundle", "mem":
+		var cs chunks.ChunkStore
+		if cs, err = spec.chunkStore(); err == nil {
+			ds = datas.NewDatabase(cs)
+		}
 	default:
 		err = fmt.Errorf("Invalid path prototocol: %s", spec.Protocol)
 	}
 	return
 }
 
+// chunkStore returns the ChunkStore of a spec with a non-http protocol, wrapped in an EncryptedStore if the spec is encrypted or --encrypt was given. Bundles are never encrypted.
+func (spec databaseSpec) chunkStore() (cs chunks.ChunkStore, err error) {
+	var keys *chunks.EncryptionKeys
+	if spec.encrypted || (encryptAll && spec.Protocol != "bundle") {
+		if keys, err = encryptionKeys(); err != nil {
+			return nil, err
+		}
+	}
+
+	err = d.Unwrap(d.Try(func() {
+		switch spec.Protocol {
+		case "ldb":
+			cs = getLDBStore(spec.Path)
+		case "nbs":
+			cs = getNBSStore(spec.Path)
+		case "s3":
+			cs = getS3Store(spec.Path)
+		case "dynamo":
+			cs = getDynamoStore(spec.Path)
+		case "bundle":
+			cs = chunks.NewBundleStore(spec.Path)
+		case "mem":
+			cs = chunks.NewMemoryStore()
+		}
+	}))
+	if err == nil && keys != nil {
+		cs = chunks.NewEncryptedStore(cs, keys)
+	}
+	return
+}
+
+// encryptionKeys returns the keys to encrypt databases with, read from the --encryption-keyfile if one was given, otherwise from the NOMS_ENCRYPTION_KEY environment variable.
+func encryptionKeys() (*chunks.EncryptionKeys, error) {
+	if encryptionKeyFile != "" {
+		return chunks.ReadEncryptionKeyFile(encryptionKeyFile)
+	}
+	if env := os.Getenv(encryptionKeyEnv); env != "" {
+		return chunks.ParseEncryptionKeys(env)
+	}
+	return nil, fmt.Errorf("Encrypted databases need a key, from --encryption-keyfile or %s", encryptionKeyEnv)
+}
+
 func (spec datasetSpec) Dataset() (dataset.Dataset, error) {
 	store, err := spec.DbSpec.Database()
 	if err != nil {
@@ -223,8 +386,20 @@ func (spec pathSpec) Value() (db datas.Database, val types.Value, err error) {
 	return
 }
 
+func (spec pathSpec) Values() (db datas.Database, vals []types.Value, err error) {
+	db, err = spec.DbSpec.Database()
+	if err != nil {
+		return
+	}
+
+	vals = spec.Path.ResolveAll(db)
+	return
+}
+
 func RegisterDatabaseFlags(flags *flag.FlagSet) {
 	chunks.RegisterLevelDBFlags(flags)
+	flags.BoolVar(&encryptAll, "encrypt", false, "encrypt all local and AWS databases, as if their specs started with "+encryptedPrefix)
+	flags.StringVar(&encryptionKeyFile, "encryption-keyfile", "", "file to read encryption keys from, one base64-encoded AES key per line with the current key first (defaults to $"+encryptionKeyEnv+")")
 }
 
 func CreateDatabaseSpecString(protocol, path string) string {
@@ -251,3 +426,104 @@ func getLDBStore(path string) chunks.ChunkStore {
 	ldbStores[path] = store
 	return store
 }
+
+func getNBSStore(path string) chunks.ChunkStore {
+	if store, ok := nbsStores[path]; ok {
+		store.AddRef()
+		return store
+	}
+
+	store := newRefCountingTableStore(path, func() {
+		delete(nbsStores, path)
+	})
+	nbsStores[path] = store
+	return store
+}
+
+// getS3Store returns an S3Store for the path of an s3 spec, which is of the form //bucket/prefix. Several S3Stores can safely share a prefix, so unlike LevelDBStores they aren't shared between databases.
+func getS3Store(path string) chunks.ChunkStore {
+	u, err := url.Parse("s3:" + path)
+	d.PanicIfError(err)
+	return chunks.NewS3Store(u.Host, u.Path, awsConfig(""))
+}
+
+// getDynamoStore returns a DynamoStore for the path of a dynamo spec, which is of the form //table/namespace, optionally followed by region and endpoint query parameters.
+func getDynamoStore(path string) chunks.ChunkStore {
+	u, err := url.Parse("dynamo:" + path)
+	d.PanicIfError(err)
+	q := u.Query()
+	config := awsConfig(q.Get("region"))
+	if endpoint := q.Get("endpoint"); endpoint != "" {
+		config = config.WithEndpoint(endpoint)
+	}
+	return chunks.NewDynamoStore(u.Host, strings.TrimPrefix(u.Path, "/"), config, false)
+}
+
+// socketPath returns the path of the socket named by a unix spec, which is relative if the spec's URL is opaque, as in unix:noms.sock.
+func socketPath(u *url.URL) string {
+	if u.Opaque != "" {
+		return u.Opaque
+	}
+	return u.Path
+}
+
+// clientTLSConfig returns the TLS config that https specs are reached with, or nil if none of the NOMS_TLS_* environment variables are set.
+func clientTLSConfig() (*tls.Config, error) {
+	ca, cert, key := os.Getenv(tlsCAEnv), os.Getenv(tlsCertEnv), os.Getenv(tlsKeyEnv)
+	if ca == "" && cert == "" && key == "" {
+		return nil, nil
+	}
+	return datas.NewClientTLSConfig(ca, cert, key)
+}
+
+// sshCommand returns the command that serves the database of an ssh spec, whose path is of the form //[user@]host[:port]/path, by running noms serve --stdio on the host. The command is ssh unless NOMS_SSH_COMMAND says otherwise. ssh runs what it's given with the remote shell, so like git, the path and the remote noms are quoted for it.
+func sshCommand(path string) *exec.Cmd {
+	u, err := url.Parse("ssh:" + path)
+	d.PanicIfError(err)
+
+	args := strings.Fields(os.Getenv(sshCommandEnv))
+	if len(args) == 0 {
+		args = []string{"ssh"}
+	}
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	host := u.Hostname()
+	if u.User != nil {
+		host = u.User.Username() + "@" + host
+	}
+	remoteNoms := os.Getenv(sshRemoteNomsEnv)
+	if remoteNoms == "" {
+		remoteNoms = "noms"
+	}
+	args = append(args, host, shellQuote(remoteNoms), "serve", "--stdio", quoteSSHPath(u.Path))
+	return exec.Command(args[0], args[1:]...)
+}
+
+// quoteSSHPath quotes the path of an ssh spec for the remote shell. As with git, ssh://host/~/path is relative to the home directory on the host, and ssh://host/~user/path to that of user, so the ~ or ~user is left for the shell to expand.
+func quoteSSHPath(p string) string {
+	if m := sshHomePathRe.FindStringSubmatch(p); m != nil {
+		if m[2] == "" {
+			return m[1]
+		}
+		return m[1] + "/" + shellQuote(m[2][1:])
+	}
+	return shellQuote(p)
+}
+
+// shellQuote quotes s for a POSIX shell, the way git does: it's wrapped in single quotes, and any single quote in it is ended, escaped and begun again.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// awsConfig returns the config for AWS-backed specs. Credentials are taken from the environment, as are other AWS tools'. The region is taken from the environment too unless one is given, and defaults to us-west-2.
+func awsConfig(region string) *aws.Config {
+	config := aws.NewConfig()
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = "us-west-2"
+	}
+	return config.WithRegion(region)
+}
@@ -5,10 +5,15 @@
 package spec
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"os/exec"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/attic-labs/noms/go/chunks"
@@ -44,6 +49,87 @@ func TestLDBDatabase(t *testing.T) {
 	os.Remove(dir)
 }
 
+func TestNBSDatabase(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(os.TempDir(), "nbs")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	spec := fmt.Sprintf("nbs:%s", dir)
+
+	ds, err := GetDataset(spec + "::testDs")
+	assert.NoError(err)
+	ds, err = ds.CommitValue(types.String("A String"))
+	assert.NoError(err)
+	ds.Database().Close()
+
+	_, val, err := GetPath(spec + "::testDs.value")
+	assert.NoError(err)
+	assert.Equal(types.String("A String"), val)
+}
+
+func TestEncryptedDatabase(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir(os.TempDir(), "enc")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	spec := fmt.Sprintf("enc+nbs:%s", dir)
+
+	os.Setenv(encryptionKeyEnv, "")
+	_, err = GetDatabase(spec)
+	assert.Error(err)
+
+	os.Setenv(encryptionKeyEnv, base64.StdEncoding.EncodeToString([]byte("0123456789abcdef")))
+	defer os.Unsetenv(encryptionKeyEnv)
+	ds, err := GetDataset(spec + "::testDs")
+	assert.NoError(err)
+	ds, err = ds.CommitValue(types.String("A String"))
+	assert.NoError(err)
+	ds.Database().Close()
+
+	_, val, err := GetPath(spec + "::testDs.value")
+	assert.NoError(err)
+	assert.Equal(types.String("A String"), val)
+
+	// Without decryption, the value can't be read.
+	cs, err := GetChunkStore("nbs:" + dir)
+	assert.NoError(err)
+	defer cs.Close()
+	assert.Panics(func() {
+		datas.NewDatabase(cs).ReadValue(cs.Root())
+	})
+}
+
+func TestDynamoDatabase(t *testing.T) {
+	assert := assert.New(t)
+
+	server := httptest.NewServer(chunks.NewFakeDynamoServer(assert))
+	defer server.Close()
+	os.Setenv("AWS_ACCESS_KEY_ID", "key")
+	os.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	defer os.Unsetenv("AWS_ACCESS_KEY_ID")
+	defer os.Unsetenv("AWS_SECRET_ACCESS_KEY")
+	spec := fmt.Sprintf("dynamo://table/ns?endpoint=%s", url.QueryEscape(server.URL))
+
+	ds, err := GetDataset(spec + "::testDs")
+	assert.NoError(err)
+	ds, err = ds.CommitValue(types.String("A String"))
+	assert.NoError(err)
+	ds.Database().Close()
+
+	_, val, err := GetPath(spec + "::testDs.value")
+	assert.NoError(err)
+	assert.Equal(types.String("A String"), val)
+
+	// Namespaces are separate databases in the same table.
+	ds, err = GetDataset(fmt.Sprintf("dynamo://table/other?endpoint=%s::testDs", url.QueryEscape(server.URL)))
+	assert.NoError(err)
+	_, ok := ds.MaybeHead()
+	assert.False(ok)
+	ds.Database().Close()
+}
+
 func TestMemDatabase(t *testing.T) {
 	assert := assert.New(t)
 
@@ -58,6 +144,36 @@ func TestMemDatabase(t *testing.T) {
 	assert.Equal(types.Bool(true), store.ReadValue(r.TargetHash()))
 }
 
+func TestUnixDataset(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+	socket := path.Join(dir, "noms.sock")
+
+	server := datas.NewRemoteDatabaseServer(chunks.NewMemoryStore(), 0)
+	server.Socket = socket
+	ready := make(chan struct{})
+	server.Ready = func() { close(ready) }
+	go server.Run()
+	<-ready
+	defer server.Stop()
+
+	ds, err := GetDataset("unix:" + socket + "::ds")
+	assert.NoError(err)
+	ds, err = ds.CommitValue(types.String("over a socket"))
+	assert.NoError(err)
+	ds.Database().Close()
+
+	db, v, err := GetPath("unix:" + socket + "::ds.value")
+	assert.NoError(err)
+	assert.Equal(types.String("over a socket"), v)
+	db.Close()
+
+	_, err = GetDatabase("unix:" + path.Join(dir, "missing.sock"))
+	assert.Error(err)
+}
+
 func TestMemDataset(t *testing.T) {
 	assert := assert.New(t)
 
@@ -164,7 +280,7 @@ func TestReadHash(t *testing.T) {
 func TestDatabaseSpecs(t *testing.T) {
 	assert := assert.New(t)
 
-	badSpecs := []string{"mem:stuff", "mem:", "http:", "https:", "random:", "random:random", "/file/ba:d"}
+	badSpecs := []string{"mem:stuff", "mem:", "http:", "https:", "nbs:", "s3:", "s3:///prefix", "dynamo:", "dynamo:///ns", "enc+", "enc+http://localhost:8000", "enc+enc+mem", "ssh:", "ssh://host", "ssh://host/", "ssh:///path", "enc+ssh://host/path", "unix:", "enc+unix:/tmp/noms.sock", "bundle:", "enc+bundle:/file", "random:", "random:random", "/file/ba:d"}
 	for _, spec := range badSpecs {
 		_, err := parseDatabaseSpec(spec)
 		assert.Error(err, spec)
@@ -179,10 +295,22 @@ func TestDatabaseSpecs(t *testing.T) {
 		testCase{"http://localhost:8000/fff", "http", "//localhost:8000/fff", ""},
 		testCase{"https://local.attic.io/john/doe", "https", "//local.attic.io/john/doe", ""},
 		testCase{"ldb:/filesys/john/doe", "ldb", "/filesys/john/doe", ""},
+		testCase{"ssh://host/john/doe", "ssh", "//host/john/doe", ""},
+		testCase{"ssh://jane@host:2222/~/doe", "ssh", "//jane@host:2222/~/doe", ""},
+		testCase{"unix:/tmp/noms.sock", "unix", "/tmp/noms.sock", ""},
+		testCase{"unix:noms.sock?access_token=jane", "unix", "noms.sock?access_token=jane", "jane"},
+		testCase{"nbs:/filesys/john/doe", "nbs", "/filesys/john/doe", ""},
+		testCase{"bundle:/filesys/john/doe.nbundle", "bundle", "/filesys/john/doe.nbundle", ""},
+		testCase{"s3://bucket", "s3", "//bucket", ""},
+		testCase{"s3://bucket/john/doe", "s3", "//bucket/john/doe", ""},
+		testCase{"dynamo://table", "dynamo", "//table", ""},
+		testCase{"dynamo://table/john/doe?region=us-east-1", "dynamo", "//table/john/doe?region=us-east-1", ""},
 		testCase{"./john/doe", "ldb", "./john/doe", ""},
 		testCase{"john/doe", "ldb", "john/doe", ""},
 		testCase{"/john/doe", "ldb", "/john/doe", ""},
 		testCase{"mem", "mem", "", ""},
+		testCase{"enc+mem", "mem", "", ""},
+		testCase{"enc+ldb:/filesys/john/doe", "ldb", "/filesys/john/doe", ""},
 		testCase{"http://server.com/john/doe?access_token=jane", "http", "//server.com/john/doe?access_token=jane", "jane"},
 		testCase{"https://server.com/john/doe/?arg=2&qp1=true&access_token=jane", "https", "//server.com/john/doe/?arg=2&qp1=true&access_token=jane", "jane"},
 	}
@@ -190,10 +318,44 @@ func TestDatabaseSpecs(t *testing.T) {
 	for _, tc := range testCases {
 		dbSpec, err := parseDatabaseSpec(tc.spec)
 		assert.NoError(err)
-		assert.Equal(databaseSpec{Protocol: tc.scheme, Path: tc.path, accessToken: tc.accessToken}, dbSpec)
+		encrypted := strings.HasPrefix(tc.spec, encryptedPrefix)
+		assert.Equal(databaseSpec{Protocol: tc.scheme, Path: tc.path, accessToken: tc.accessToken, encrypted: encrypted}, dbSpec)
 	}
 }
 
+func TestSSHCommand(t *testing.T) {
+	assert := assert.New(t)
+	defer os.Unsetenv(sshCommandEnv)
+	defer os.Unsetenv(sshRemoteNomsEnv)
+
+	os.Unsetenv(sshCommandEnv)
+	os.Unsetenv(sshRemoteNomsEnv)
+	assert.Equal([]string{"ssh", "host", "'noms'", "serve", "--stdio", "'/john/doe'"}, sshCommand("//host/john/doe").Args)
+	assert.Equal([]string{"ssh", "-p", "2222", "jane@host", "'noms'", "serve", "--stdio", "~/'doe'"}, sshCommand("//jane@host:2222/~/doe").Args)
+	assert.Equal([]string{"ssh", "host", "'noms'", "serve", "--stdio", "~john/'doe'"}, sshCommand("//host/~john/doe").Args)
+	assert.Equal([]string{"ssh", "host", "'noms'", "serve", "--stdio", "~"}, sshCommand("//host/~").Args)
+
+	os.Setenv(sshCommandEnv, "ssh -i key")
+	os.Setenv(sshRemoteNomsEnv, "/opt/bin/noms")
+	assert.Equal([]string{"ssh", "-i", "key", "host", "'/opt/bin/noms'", "serve", "--stdio", "'/db'"}, sshCommand("//host/db").Args)
+}
+
+func TestSSHCommandQuoting(t *testing.T) {
+	assert := assert.New(t)
+	defer os.Unsetenv(sshCommandEnv)
+	defer os.Unsetenv(sshRemoteNomsEnv)
+
+	os.Unsetenv(sshCommandEnv)
+	os.Setenv(sshRemoteNomsEnv, "/opt/my bin/noms")
+	args := sshCommand("//host/my db/it's;rm -rf ~").Args
+	assert.Equal([]string{"ssh", "host", "'/opt/my bin/noms'", "serve", "--stdio", `'/my db/it'\''s;rm -rf ~'`}, args)
+
+	// The remote shell sees each of them as a single word.
+	out, err := exec.Command("sh", "-c", `printf '%s\n' `+strings.Join(args[2:], " ")).Output()
+	assert.NoError(err)
+	assert.Equal("/opt/my bin/noms\nserve\n--stdio\n/my db/it's;rm -rf ~\n", string(out))
+}
+
 func TestDatasetSpecs(t *testing.T) {
 	assert := assert.New(t)
 	badSpecs := []string{"mem", "mem:", "mem:::ds", "http", "http:", "http://foo", "monkey", "monkey:balls", "mem:/a/bogus/path:dsname", "http://localhost:8000/one"}
@@ -32,3 +32,27 @@ func (r *refCountingLdbStore) Close() (err error) {
 	}
 	return
 }
+
+type refCountingTableStore struct {
+	*chunks.TableStore
+	refCount int
+	closeFn  func()
+}
+
+func newRefCountingTableStore(path string, closeFn func()) *refCountingTableStore {
+	return &refCountingTableStore{chunks.NewTableStore(path, ""), 1, closeFn}
+}
+
+func (r *refCountingTableStore) AddRef() {
+	r.refCount++
+}
+
+func (r *refCountingTableStore) Close() (err error) {
+	d.Chk.True(r.refCount > 0)
+	r.refCount--
+	if r.refCount == 0 {
+		err = r.TableStore.Close()
+		r.closeFn()
+	}
+	return
+}
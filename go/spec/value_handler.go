@@ -0,0 +1,264 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package spec
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/constants"
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+)
+
+const (
+	defaultValueLimit = 100
+	maxValueLimit     = 1000
+	defaultValueDepth = 1
+)
+
+// valueResponse is the JSON body of a response from HandleValue.
+type valueResponse struct {
+	Path  string      `json:"path"`
+	Hash  string      `json:"hash"`
+	Type  string      `json:"type"`
+	Value interface{} `json:"value"`
+	// Length, Offset and Next are only set for collections and blobs. Next is the URL of the following page, if there is one.
+	Length *uint64 `json:"length,omitempty"`
+	Offset uint64  `json:"offset,omitempty"`
+	Next   string  `json:"next,omitempty"`
+}
+
+// HandleValue is meant to handle HTTP GET requests to the value/ server endpoint, for clients such as web dashboards that can't speak the chunk protocol. The rest of the URL path is an AbsolutePath, e.g. /value/photos.value[0] or /value/%23<hash>.value, and the value it addresses is returned as JSON:
+//
+//	{"path": "photos.value", "hash": "...", "type": "List<String>", "value": ["a", "b"], "length": 2}
+//
+// Structs become objects, Lists and Sets arrays, Maps with String keys objects and other Maps arrays of [key, value] pairs, and Blobs base64 encoded strings. Refs become {"@ref": <hash>, "@href": <URL of the target>}.
+//
+// Collections and blobs are returned a page at a time: the "limit" query param sets the number of elements (or bytes) per page, up to 1000, and "offset" the first one. If there are more, "next" holds the URL of the next page. Values nested more than "depth" levels deep, and nested collections with more than "limit" elements, are returned as {"@href": <URL of the value>, "@length": <length if any>} rather than inline.
+//
+// Values addressed by hash never change, so responses to them can be cached indefinitely.
+func HandleValue(w http.ResponseWriter, req *http.Request, ps datas.URLParams, cs chunks.ChunkStore) {
+	d.PanicIfTrue(req.Method != "GET", "Expected get method.")
+
+	str := strings.TrimPrefix(ps.ByName("path"), "/")
+	if str == "" {
+		http.Error(w, "Expected a path, e.g. /value/<dataset>.value", http.StatusBadRequest)
+		return
+	}
+	p, err := NewAbsolutePath(str)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	params := req.URL.Query()
+	limit, err := uintParam(params, "limit", defaultValueLimit)
+	if err == nil && (limit == 0 || limit > maxValueLimit) {
+		err = fmt.Errorf("limit must be between 1 and %d", maxValueLimit)
+	}
+	offset, err2 := uintParam(params, "offset", 0)
+	depth, err3 := uintParam(params, "depth", defaultValueDepth)
+	for _, e := range []error{err, err2, err3} {
+		if e != nil {
+			http.Error(w, e.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	v := p.Resolve(datas.NewDatabase(cs))
+	if v == nil {
+		http.Error(w, fmt.Sprintf("No value at %s", p), http.StatusNotFound)
+		return
+	}
+
+	// The response depends on the query params as well as the value, but they're part of the URL anyway.
+	etag := strconv.Quote(v.Hash().String())
+	if p.Dataset() == "" {
+		w.Header().Set("Cache-Control", "max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	w.Header().Set("ETag", etag)
+	if req.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	e := valueEncoder{limit, int(depth)}
+	res := valueResponse{Path: p.String(), Hash: v.Hash().String(), Type: v.Type().Describe()}
+	value, length := e.encodePage(v, p, offset)
+	res.Value = value
+	if _, ok := valueLength(v); ok {
+		res.Length = &length
+		res.Offset = offset
+	}
+	if offset+limit < length {
+		q := url.Values{}
+		q.Set("offset", fmt.Sprint(offset+limit))
+		q.Set("limit", fmt.Sprint(limit))
+		q.Set("depth", fmt.Sprint(depth))
+		res.Next = valueURL(p) + "?" + q.Encode()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	d.PanicIfError(json.NewEncoder(w).Encode(res))
+}
+
+func uintParam(params url.Values, name string, def uint64) (uint64, error) {
+	str := params.Get(name)
+	if str == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid %s: %s", name, str)
+	}
+	return n, nil
+}
+
+// valueURL returns the URL at which HandleValue serves the value at p.
+func valueURL(p AbsolutePath) string {
+	return constants.ValuePath + url.PathEscape(p.String())
+}
+
+// valueLength returns the number of elements in v, or of bytes if it's a Blob, and whether it has such a thing.
+func valueLength(v types.Value) (uint64, bool) {
+	switch v := v.(type) {
+	case types.Blob:
+		return v.Len(), true
+	case types.List:
+		return v.Len(), true
+	case types.Set:
+		return v.Len(), true
+	case types.Map:
+		return v.Len(), true
+	}
+	return 0, false
+}
+
+// valueEncoder turns Values into things that encoding/json can marshal, as described by HandleValue.
+type valueEncoder struct {
+	limit uint64
+	depth int
+}
+
+// encodePage encodes v, the value at p. If it's a collection or blob, only the page starting at offset is included. The length of v is returned as well.
+func (e valueEncoder) encodePage(v types.Value, p AbsolutePath, offset uint64) (interface{}, uint64) {
+	length, ok := valueLength(v)
+	if !ok {
+		return e.encode(v, p, e.depth), 0
+	}
+	end := offset + e.limit
+	if end > length {
+		end = length
+	}
+	if offset > end {
+		offset = end
+	}
+
+	switch v := v.(type) {
+	case types.Blob:
+		r := v.Reader()
+		_, err := r.Seek(int64(offset), 0)
+		d.PanicIfError(err)
+		data := make([]byte, end-offset)
+		_, err = io.ReadFull(r, data)
+		d.PanicIfError(err)
+		return base64.StdEncoding.EncodeToString(data), length
+	case types.List:
+		result := make([]interface{}, 0, end-offset)
+		if offset < end {
+			v.IterFrom(offset, func(elem types.Value, i uint64) bool {
+				result = append(result, e.encode(elem, p.append(types.NewIndexPath(types.Number(i))), e.depth-1))
+				return i+1 == end
+			})
+		}
+		return result, length
+	case types.Set:
+		result := make([]interface{}, 0, end-offset)
+		it := v.IteratorAtIndex(offset)
+		for i := offset; i < end; i++ {
+			elem := it.Next()
+			result = append(result, e.encode(elem, p.append(types.NewHashIndexPath(elem.Hash())), e.depth-1))
+		}
+		return result, length
+	case types.Map:
+		it := v.IteratorAtIndex(offset)
+		if isStringKeyed(v) {
+			result := make(map[string]interface{}, end-offset)
+			for i := offset; i < end; i++ {
+				k, val := it.Next()
+				result[string(k.(types.String))] = e.encode(val, p.append(types.NewIndexPath(k)), e.depth-1)
+			}
+			return result, length
+		}
+		result := make([]interface{}, 0, end-offset)
+		for i := offset; i < end; i++ {
+			k, val := it.Next()
+			result = append(result, e.encodeEntry(k, val, p))
+		}
+		return result, length
+	}
+	panic("unreachable")
+}
+
+// encode encodes v, the value at p, inlining values nested up to depth levels below it.
+func (e valueEncoder) encode(v types.Value, p AbsolutePath, depth int) interface{} {
+	switch v := v.(type) {
+	case types.Bool:
+		return bool(v)
+	case types.Number:
+		return float64(v)
+	case types.String:
+		return string(v)
+	case types.Ref:
+		return map[string]interface{}{
+			"@ref":  v.TargetHash().String(),
+			"@href": valueURL(AbsolutePath{hash: v.TargetHash()}),
+		}
+	case *types.Type:
+		return v.Describe()
+	}
+
+	if length, ok := valueLength(v); ok && (depth < 0 || length > e.limit) {
+		return map[string]interface{}{"@href": valueURL(p), "@length": length}
+	}
+	if depth < 0 {
+		return map[string]interface{}{"@href": valueURL(p)}
+	}
+
+	if s, ok := v.(types.Struct); ok {
+		result := map[string]interface{}{}
+		s.Type().Desc.(types.StructDesc).IterFields(func(name string, t *types.Type) {
+			result[name] = e.encode(s.Get(name), p.append(types.NewFieldPath(name)), depth-1)
+		})
+		return result
+	}
+	// Collections that are small enough are encoded whole, just as at the top level.
+	result, _ := valueEncoder{e.limit, depth}.encodePage(v, p, 0)
+	return result
+}
+
+// encodeEntry encodes the entry k: v of a Map, at p, whose keys aren't Strings.
+func (e valueEncoder) encodeEntry(k, v types.Value, p AbsolutePath) interface{} {
+	if types.IsPrimitiveKind(k.Type().Kind()) {
+		return []interface{}{e.encode(k, p, e.depth-1), e.encode(v, p.append(types.NewIndexPath(k)), e.depth-1)}
+	}
+	return []interface{}{
+		e.encode(k, p.append(types.NewHashIndexIntoKeyPath(k.Hash())), e.depth-1),
+		e.encode(v, p.append(types.NewHashIndexPath(k.Hash())), e.depth-1),
+	}
+}
+
+func isStringKeyed(m types.Map) bool {
+	return m.Empty() || m.Type().Desc.(types.CompoundDesc).ElemTypes[0].Kind() == types.StringKind
+}
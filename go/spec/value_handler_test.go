@@ -0,0 +1,155 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package spec
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/attic-labs/noms/go/chunks"
+	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/types"
+	"github.com/attic-labs/testify/suite"
+)
+
+func TestValueHandler(t *testing.T) {
+	suite.Run(t, &valueHandlerSuite{})
+}
+
+type valueHandlerSuite struct {
+	suite.Suite
+	stop    func()
+	baseURL string
+	head    types.Struct
+	list    types.List
+}
+
+func (s *valueHandlerSuite) SetupTest() {
+	cs := chunks.NewMemoryStore()
+	db := datas.NewDatabase(cs)
+	s.list = types.NewList()
+	for i := 0; i < 5; i++ {
+		s.list = s.list.Append(types.NewStruct("Photo", types.StructData{
+			"title": types.String(fmt.Sprintf("photo %d", i)),
+			"tags":  types.NewSet(types.String("a"), types.String("b")),
+		}))
+	}
+	listRef := db.WriteValue(s.list)
+	value := types.NewStruct("Album", types.StructData{
+		"photos": listRef,
+		"counts": types.NewMap(types.Number(1), types.String("one")),
+		"name":   types.String("holiday"),
+	})
+	db, err := db.Commit("album", datas.NewCommit(value, types.NewSet(), types.EmptyStruct))
+	s.NoError(err)
+	s.head = db.Head("album")
+
+	server := datas.NewRemoteDatabaseServer(cs, 0)
+	server.ValueHandler = HandleValue
+	ready := make(chan struct{})
+	server.Ready = func() { close(ready) }
+	go server.Run()
+	<-ready
+	s.stop = server.Stop
+	s.baseURL = fmt.Sprintf("http://localhost:%d", server.Port())
+}
+
+func (s *valueHandlerSuite) TearDownTest() {
+	s.stop()
+}
+
+// get fetches the value at path, which may include a query, and decodes the response into a map, if it's OK.
+func (s *valueHandlerSuite) get(path string, header http.Header) (*http.Response, map[string]interface{}) {
+	req, err := http.NewRequest("GET", s.baseURL+path, nil)
+	s.NoError(err)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	res, err := http.DefaultClient.Do(req)
+	s.NoError(err)
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return res, nil
+	}
+	body := map[string]interface{}{}
+	s.NoError(json.NewDecoder(res.Body).Decode(&body))
+	return res, body
+}
+
+func (s *valueHandlerSuite) TestDataset() {
+	res, body := s.get("/value/album.value", nil)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal("no-cache", res.Header.Get("Cache-Control"))
+	s.Equal("application/json", res.Header.Get("Content-Type"))
+	s.Equal("album.value", body["path"])
+
+	value := body["value"].(map[string]interface{})
+	s.Equal("holiday", value["name"])
+	// Refs are links.
+	photosHash := s.list.Hash().String()
+	s.Equal(map[string]interface{}{"@ref": photosHash, "@href": "/value/%23" + photosHash}, value["photos"])
+	s.Equal([]interface{}{[]interface{}{float64(1), "one"}}, value["counts"])
+
+	_, body = s.get("/value/album.value?depth=0", nil)
+	value = body["value"].(map[string]interface{})
+	s.Equal(map[string]interface{}{"@href": "/value/album.value.counts", "@length": float64(1)}, value["counts"])
+
+	res, body = s.get("/value/album.value.counts", nil)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal([]interface{}{[]interface{}{float64(1), "one"}}, body["value"])
+}
+
+func (s *valueHandlerSuite) TestPaging() {
+	photos := "/value/" + url.PathEscape("#"+s.list.Hash().String())
+	res, body := s.get(photos+"?limit=2&offset=1", nil)
+	s.Equal(http.StatusOK, res.StatusCode)
+	s.Equal("max-age=31536000, immutable", res.Header.Get("Cache-Control"))
+	s.Equal(float64(5), body["length"])
+	s.Equal(float64(1), body["offset"])
+	page := body["value"].([]interface{})
+	s.Len(page, 2)
+	s.Equal("photo 1", page[0].(map[string]interface{})["title"])
+	s.Equal(map[string]interface{}{"@href": photos + url.PathEscape("[1].tags"), "@length": float64(2)}, page[0].(map[string]interface{})["tags"])
+
+	// Follow the cursors to the end.
+	titles := []string{}
+	for next := photos + "?limit=2"; next != ""; {
+		res, body = s.get(next, nil)
+		s.Equal(http.StatusOK, res.StatusCode)
+		for _, p := range body["value"].([]interface{}) {
+			titles = append(titles, p.(map[string]interface{})["title"].(string))
+		}
+		next, _ = body["next"].(string)
+	}
+	s.Equal([]string{"photo 0", "photo 1", "photo 2", "photo 3", "photo 4"}, titles)
+
+	// Deeper values can be inlined.
+	_, body = s.get(photos+"?limit=2&depth=2", nil)
+	s.Equal([]interface{}{"a", "b"}, body["value"].([]interface{})[0].(map[string]interface{})["tags"])
+}
+
+func (s *valueHandlerSuite) TestETag() {
+	res, _ := s.get("/value/album", nil)
+	etag := res.Header.Get("ETag")
+	s.Equal(fmt.Sprintf("%q", s.head.Hash().String()), etag)
+	res, _ = s.get("/value/album", http.Header{"If-None-Match": {etag}})
+	s.Equal(http.StatusNotModified, res.StatusCode)
+}
+
+func (s *valueHandlerSuite) TestErrors() {
+	res, _ := s.get("/value/missing", nil)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+	res, _ = s.get("/value/album.value.nope", nil)
+	s.Equal(http.StatusNotFound, res.StatusCode)
+	res, _ = s.get("/value/album.value[", nil)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	res, _ = s.get("/value/album?limit=0", nil)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+	res, _ = s.get("/value/album?depth=x", nil)
+	s.Equal(http.StatusBadRequest, res.StatusCode)
+}
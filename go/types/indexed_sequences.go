@@ -91,6 +91,17 @@ func advanceCursorToOffset(cur *sequenceCursor, idx uint64) uint64 {
 	return seq.cumulativeNumberOfLeaves(cur.idx - 1)
 }
 
+// cursorIndex returns the position of cur among all the leaf items of its tree. If cur is past the end of the tree, this is the total number of leaf items.
+func cursorIndex(cur *sequenceCursor) uint64 {
+	idx := uint64(0)
+	for ; cur != nil; cur = cur.parent {
+		if cur.idx > 0 {
+			idx += cur.seq.(indexedSequence).cumulativeNumberOfLeaves(cur.idx - 1)
+		}
+	}
+	return idx
+}
+
 // If |sink| is not nil, chunks will be eagerly written as they're created. Otherwise they are
 // written when the root is written.
 func newIndexedMetaSequenceChunkFn(kind NomsKind, source ValueReader) makeChunkFn {
@@ -162,7 +162,11 @@ func (l List) RemoveAt(idx uint64) List {
 type listIterFunc func(v Value, index uint64) (stop bool)
 
 func (l List) Iter(f listIterFunc) {
-	idx := uint64(0)
+	l.IterFrom(0, f)
+}
+
+// IterFrom calls f with the values of l from index idx onwards, along with their indices, until f returns true.
+func (l List) IterFrom(idx uint64, f listIterFunc) {
 	cur := newCursorAtIndex(l.seq, idx)
 	cur.iter(func(v interface{}) bool {
 		if f(v.(Value), uint64(idx)) {
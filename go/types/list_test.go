@@ -139,6 +139,23 @@ func (suite *listTestSuite) TestIter() {
 	suite.Equal(endAt, expectIdx)
 }
 
+func (suite *listTestSuite) TestIterFrom() {
+	list := suite.col.(List)
+	expectIdx := suite.expectLen / 2
+	list.IterFrom(expectIdx, func(v Value, idx uint64) bool {
+		suite.Equal(expectIdx, idx)
+		expectIdx++
+		suite.Equal(suite.elems[idx], v)
+		return false
+	})
+	suite.Equal(suite.expectLen, expectIdx)
+
+	list.IterFrom(suite.expectLen, func(v Value, idx uint64) bool {
+		suite.Fail("Iterated past the end", "%d", idx)
+		return true
+	})
+}
+
 func (suite *listTestSuite) TestMap() {
 	list := suite.col.(List)
 	l := list.Map(func(v Value, i uint64) interface{} {
@@ -206,6 +206,19 @@ func (m Map) Get(key Value) Value {
 	return v
 }
 
+// At returns the entry at position idx in key order. It panics if idx is out of range.
+func (m Map) At(idx uint64) (key, value Value) {
+	d.Chk.True(idx < m.Len())
+	entry := newCursorAtIndex(m.seq, idx).current().(mapEntry)
+	return entry.key, entry.value
+}
+
+// IndexOf returns the position of key in key order and whether key is in m. If it is not, idx is the position at which key would be inserted, which is also the number of keys less than key.
+func (m Map) IndexOf(key Value) (idx uint64, found bool) {
+	cur := newCursorAtValue(m.seq, key, false, false)
+	return cursorIndex(cur), cur.valid() && cur.current().(mapEntry).key.Equals(key)
+}
+
 type mapIterCallback func(key, value Value) (stop bool)
 
 func (m Map) Iter(cb mapIterCallback) {
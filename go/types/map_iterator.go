@@ -0,0 +1,76 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import "github.com/attic-labs/noms/go/d"
+
+// MapIterator iterates over the entries of a Map in key order, in either direction. It sits between two entries: Next() returns the entry after the current position and moves past it, Prev() returns the entry before the current position and moves back over it. Both return nil, nil when there is no entry in that direction.
+type MapIterator struct {
+	cursor *sequenceCursor
+}
+
+// Iterator returns a MapIterator positioned before the first entry of m.
+func (m Map) Iterator() *MapIterator {
+	return &MapIterator{newCursorAt(m.seq, emptyKey, false, false)}
+}
+
+// IteratorAt returns a MapIterator positioned right before key, so that the first call to Next() returns the entry for key. If key is not in m, the iterator is exhausted in both directions.
+func (m Map) IteratorAt(key Value) *MapIterator {
+	cur := newCursorAtValue(m.seq, key, false, false)
+	if !cur.valid() || !cur.current().(mapEntry).key.Equals(key) {
+		return &MapIterator{}
+	}
+	return &MapIterator{cur}
+}
+
+// IteratorFrom returns a MapIterator positioned before the first entry whose key is not less than lo. Next() therefore returns the entries with keys >= lo in ascending order, and Prev() those with keys < lo in descending order.
+func (m Map) IteratorFrom(lo Value) *MapIterator {
+	return &MapIterator{newCursorAtValue(m.seq, lo, false, false)}
+}
+
+// IteratorAtIndex returns a MapIterator positioned before the entry at position idx, so that Next() returns the entries from idx onwards. idx may be m.Len(), which positions the iterator after the last entry. It panics if idx is greater than that.
+func (m Map) IteratorAtIndex(idx uint64) *MapIterator {
+	d.Chk.True(idx <= m.Len())
+	return &MapIterator{newCursorAtIndex(m.seq, idx)}
+}
+
+// IteratorAtEnd returns a MapIterator positioned after the last entry of m, for iterating in reverse with Prev().
+func (m Map) IteratorAtEnd() *MapIterator {
+	if m.Empty() {
+		return m.Iterator()
+	}
+	cur := newCursorAt(m.seq, emptyKey, false, true)
+	cur.advance()
+	return &MapIterator{cur}
+}
+
+// Next returns the entry after the current position and advances past it, or nil, nil if there are no more entries.
+func (mi *MapIterator) Next() (k, v Value) {
+	if mi.cursor == nil || !mi.cursor.valid() {
+		return nil, nil
+	}
+	entry := mi.cursor.current().(mapEntry)
+	mi.cursor.advance()
+	return entry.key, entry.value
+}
+
+// Prev returns the entry before the current position and moves back over it, or nil, nil if there are no earlier entries.
+func (mi *MapIterator) Prev() (k, v Value) {
+	if mi.cursor == nil || !retreatCursor(mi.cursor) {
+		return nil, nil
+	}
+	entry := mi.cursor.current().(mapEntry)
+	return entry.key, entry.value
+}
+
+// retreatCursor moves cur back by one item. If there is no previous item, cur is left where it was and false is returned.
+func retreatCursor(cur *sequenceCursor) bool {
+	if cur.retreat() {
+		return true
+	}
+	// retreat() leaves the cursor before the start, so put it back on the first item.
+	cur.advance()
+	return false
+}
@@ -0,0 +1,170 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestMapIterator(t *testing.T) {
+	assert := assert.New(t)
+
+	me := newSortedTestMap(64*16, newNumber)
+	m := me.toMap()
+	assert.True(m.Len() == uint64(len(me.entries)))
+	assert.IsType(orderedMetaSequence{}, m.seq) // make sure we cross chunk boundaries
+
+	it := m.Iterator()
+	for _, entry := range me.entries {
+		k, v := it.Next()
+		assert.True(entry.key.Equals(k))
+		assert.True(entry.value.Equals(v))
+	}
+	k, v := it.Next()
+	assert.Nil(k)
+	assert.Nil(v)
+
+	// Iterate backwards from the end.
+	for i := len(me.entries) - 1; i >= 0; i-- {
+		k, v := it.Prev()
+		assert.True(me.entries[i].key.Equals(k))
+		assert.True(me.entries[i].value.Equals(v))
+	}
+	k, _ = it.Prev()
+	assert.Nil(k)
+	k, _ = it.Next()
+	assert.True(me.entries[0].key.Equals(k))
+
+	it = m.IteratorAtEnd()
+	k, _ = it.Prev()
+	assert.True(me.entries[len(me.entries)-1].key.Equals(k))
+}
+
+func TestMapIteratorAtAndFrom(t *testing.T) {
+	assert := assert.New(t)
+
+	me := newSortedTestMap(64*16, newNumber)
+	m := me.toMap()
+
+	for _, i := range []int{0, 1, len(me.entries) / 2, len(me.entries) - 1} {
+		entry := me.entries[i]
+		it := m.IteratorAt(entry.key)
+		k, v := it.Next()
+		assert.True(entry.key.Equals(k))
+		assert.True(entry.value.Equals(v))
+		if i+1 < len(me.entries) {
+			k, _ = it.Next()
+			assert.True(me.entries[i+1].key.Equals(k))
+		}
+
+		it = m.IteratorFrom(entry.key)
+		if i > 0 {
+			k, _ = it.Prev()
+			assert.True(me.entries[i-1].key.Equals(k))
+			it.Next()
+		}
+		k, _ = it.Next()
+		assert.True(entry.key.Equals(k))
+	}
+
+	k, _ := m.IteratorAt(me.knownBadKey).Next()
+	assert.Nil(k)
+
+	// Keys are even numbers, so an odd key seeks to the next one.
+	nm := NewMap(Number(0), String("a"), Number(2), String("b"), Number(4), String("c"))
+	k, _ = nm.IteratorFrom(Number(1)).Next()
+	assert.True(Number(2).Equals(k))
+	k, _ = nm.IteratorFrom(Number(5)).Next()
+	assert.Nil(k)
+	k, _ = nm.IteratorFrom(Number(5)).Prev()
+	assert.True(Number(4).Equals(k))
+}
+
+func TestMapIteratorEmpty(t *testing.T) {
+	assert := assert.New(t)
+	m := NewMap()
+	for _, it := range []*MapIterator{m.Iterator(), m.IteratorAtEnd(), m.IteratorFrom(Number(1)), m.IteratorAt(Number(1))} {
+		k, _ := it.Next()
+		assert.Nil(k)
+		k, _ = it.Prev()
+		assert.Nil(k)
+	}
+}
+
+func TestMapAtAndIndexOf(t *testing.T) {
+	assert := assert.New(t)
+
+	me := newSortedTestMap(64*16, newNumber)
+	m := me.toMap()
+	assert.IsType(orderedMetaSequence{}, m.seq)
+
+	vs := NewTestValueStore()
+	m2 := vs.ReadValue(vs.WriteValue(m).TargetHash()).(Map)
+
+	for _, m := range []Map{m, m2} {
+		for i, entry := range me.entries {
+			k, v := m.At(uint64(i))
+			assert.True(entry.key.Equals(k))
+			assert.True(entry.value.Equals(v))
+
+			idx, found := m.IndexOf(entry.key)
+			assert.True(found)
+			assert.Equal(uint64(i), idx)
+		}
+		assert.Panics(func() { m.At(m.Len()) })
+	}
+
+	// Absent keys report their insertion position.
+	idx, found := m.IndexOf(Number(-1))
+	assert.False(found)
+	assert.Equal(uint64(0), idx)
+	idx, found = m.IndexOf(Number(10.5))
+	assert.False(found)
+	assert.Equal(uint64(11), idx)
+	idx, found = m.IndexOf(Number(len(me.entries)))
+	assert.False(found)
+	assert.Equal(m.Len(), idx)
+
+	idx, found = NewMap().IndexOf(Number(1))
+	assert.False(found)
+	assert.Equal(uint64(0), idx)
+}
+
+func TestMapIteratorAtIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	me := newSortedTestMap(64*16, newNumber)
+	m := me.toMap()
+
+	for _, i := range []int{0, 1, 500, len(me.entries) - 1} {
+		it := m.IteratorAtIndex(uint64(i))
+		for j := i; j < len(me.entries); j++ {
+			k, _ := it.Next()
+			assert.True(me.entries[j].key.Equals(k))
+		}
+		k, _ := it.Next()
+		assert.Nil(k)
+
+		it = m.IteratorAtIndex(uint64(i))
+		for j := i - 1; j >= 0; j-- {
+			k, _ := it.Prev()
+			assert.True(me.entries[j].key.Equals(k))
+		}
+		k, _ = it.Prev()
+		assert.Nil(k)
+	}
+
+	it := m.IteratorAtIndex(m.Len())
+	k, _ := it.Next()
+	assert.Nil(k)
+	k, _ = it.Prev()
+	assert.True(me.entries[len(me.entries)-1].key.Equals(k))
+
+	k, _ = NewMap().IteratorAtIndex(0).Next()
+	assert.Nil(k)
+	assert.Panics(func() { m.IteratorAtIndex(m.Len() + 1) })
+}
@@ -79,6 +79,10 @@ func (ml mapLeafSequence) getKey(idx int) orderedKey {
 	return newOrderedKey(ml.data[idx].key)
 }
 
+func (ml mapLeafSequence) cumulativeNumberOfLeaves(idx int) uint64 {
+	return uint64(idx) + 1
+}
+
 func (ml mapLeafSequence) getCompareFn(other sequence) compareFn {
 	oml := other.(mapLeafSequence)
 	return func(idx, otherIdx int) bool {
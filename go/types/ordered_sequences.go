@@ -13,10 +13,12 @@ import (
 type orderedSequence interface {
 	sequence
 	getKey(idx int) orderedKey
+	cumulativeNumberOfLeaves(idx int) uint64 // returns the total number of leaf values reachable from this sequence for all sub-trees from 0 to |idx|
 }
 
 type orderedMetaSequence struct {
 	metaSequenceObject
+	offsets []uint64
 }
 
 func newSetMetaSequence(tuples metaSequenceData, vr ValueReader) orderedMetaSequence {
@@ -43,13 +45,16 @@ func newMapMetaSequence(tuples metaSequenceData, vr ValueReader) orderedMetaSequ
 }
 
 func newOrderedMetaSequence(tuples metaSequenceData, t *Type, vr ValueReader) orderedMetaSequence {
+	offsets := make([]uint64, len(tuples))
 	leafCount := uint64(0)
-	for _, mt := range tuples {
+	for i, mt := range tuples {
 		leafCount += mt.numLeaves
+		offsets[i] = leafCount
 	}
 
 	return orderedMetaSequence{
 		metaSequenceObject{tuples, t, vr, leafCount},
+		offsets,
 	}
 }
 
@@ -57,6 +62,10 @@ func (oms orderedMetaSequence) getKey(idx int) orderedKey {
 	return oms.tuples[idx].key
 }
 
+func (oms orderedMetaSequence) cumulativeNumberOfLeaves(idx int) uint64 {
+	return oms.offsets[idx]
+}
+
 func (oms orderedMetaSequence) getCompareFn(other sequence) compareFn {
 	ooms := other.(orderedMetaSequence)
 	return func(idx, otherIdx int) bool {
@@ -18,9 +18,12 @@ import (
 )
 
 var annotationRe = regexp.MustCompile("^@([a-z]+)")
+var atAnnotationArgRe = regexp.MustCompile(`^\((-?[0-9]+)\)`)
 
 // A Path is an address to a Noms value - and unlike hashes (i.e. #abcd...) they can address inlined values.
 // See https://github.com/attic-labs/noms/blob/master/doc/spelling.md.
+//
+// Some parts, such as slices and wildcards, can fan out to many values, so a Path may address any number of values. Use ResolveEach or ResolveAll to get all of them.
 type Path []PathPart
 
 type PathPart interface {
@@ -28,6 +31,13 @@ type PathPart interface {
 	String() string
 }
 
+// A MultiPathPart is a PathPart that can resolve to any number of values, such as a slice or a wildcard. Its Resolve method returns the first of them, or nil if there are none.
+type MultiPathPart interface {
+	PathPart
+	// ResolveEach calls cb with each value this part resolves to from v, in order, until cb returns true. It returns true if cb did. vr is used to read values that are not contained in v, such as the targets of Refs, and may be nil.
+	ResolveEach(v Value, vr ValueReader, cb func(v Value) (stop bool)) (stopped bool)
+}
+
 func ParsePath(str string) (Path, error) {
 	if str == "" {
 		return Path{}, errors.New("Empty path")
@@ -44,6 +54,10 @@ func constructPath(p Path, str string) (Path, error) {
 
 	switch op {
 	case '.':
+		if strings.HasPrefix(tail, "*") {
+			p = append(p, WildcardFieldPath{})
+			return constructPath(p, tail[1:])
+		}
 		idx := fieldNameComponentRe.FindIndex([]byte(tail))
 		if idx == nil {
 			return Path{}, errors.New("Invalid field: " + tail)
@@ -56,56 +70,151 @@ func constructPath(p Path, str string) (Path, error) {
 			return Path{}, errors.New("Path ends in [")
 		}
 
-		idx, h, rem, err := parsePathIndex(tail)
+		part, rem, err := parseIndexPart(tail)
 		if err != nil {
 			return Path{}, err
 		}
+		p = append(p, part)
+		return constructPath(p, rem)
 
-		intoKey := false
-		if ann, rem2 := getAnnotation(rem); ann != "" {
-			if ann != "key" {
-				return Path{}, fmt.Errorf("Unsupported annotation: @%s", ann)
-			}
-			intoKey = true
-			rem = rem2
-		}
-
-		d.Chk.NotEqual(idx == nil, h.IsEmpty())
+	case ']':
+		return Path{}, errors.New("] is missing opening [")
 
+	case '@':
+		ann, rem := getAnnotation(str)
 		var part PathPart
-		switch {
-		case idx != nil && intoKey:
-			part = NewIndexIntoKeyPath(idx)
-		case idx != nil:
-			part = NewIndexPath(idx)
-		case intoKey:
-			part = NewHashIndexIntoKeyPath(h)
+		switch ann {
+		case "":
+			return Path{}, fmt.Errorf("Invalid operator: %c", op)
+		case "type":
+			part = TypeAnnotation{}
+		case "target":
+			part = TargetAnnotation{}
+		case "at":
+			args := atAnnotationArgRe.FindStringSubmatch(rem)
+			if args == nil {
+				return Path{}, errors.New("@at annotation requires an index, e.g. @at(42)")
+			}
+			idx, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				return Path{}, errors.New("Invalid @at index: " + args[1])
+			}
+			var intoKey bool
+			intoKey, rem = parseKeyAnnotation(rem[len(args[0]):])
+			part = AtAnnotation{idx, intoKey}
+		case "key":
+			return Path{}, errors.New("@key must follow an index")
 		default:
-			part = NewHashIndexPath(h)
+			return Path{}, fmt.Errorf("Unsupported annotation: @%s", ann)
 		}
-		p = append(p, Path{part})
+		p = append(p, part)
 		return constructPath(p, rem)
 
-	case ']':
-		return Path{}, errors.New("] is missing opening [")
-
 	default:
 		return Path{}, fmt.Errorf("Invalid operator: %c", op)
 	}
 }
 
-func (p Path) Resolve(v Value) (resolved Value) {
-	resolved = v
-	for _, part := range p {
-		if resolved == nil {
-			break
+// parseIndexPart parses the part of a path following a [, i.e. an index, a hash, a slice or a wildcard, and any @key annotation that follows it.
+func parseIndexPart(str string) (part PathPart, rem string, err error) {
+	if strings.HasPrefix(str, "*]") {
+		intoKey, rem := parseKeyAnnotation(str[2:])
+		return WildcardIndexPath{intoKey}, rem, nil
+	}
+
+	var start, end Value
+	var h hash.Hash
+	if str[0] != ':' {
+		start, h, rem, err = parsePathIndex(str)
+		if err != nil {
+			return nil, "", err
 		}
-		resolved = part.Resolve(resolved)
+	} else {
+		rem = str
 	}
 
+	isSlice := rem[0] == ':'
+	if isSlice {
+		if !h.IsEmpty() {
+			return nil, "", errors.New("Slices cannot be bounded by a hash")
+		}
+		if len(rem) == 1 {
+			return nil, "", errors.New("[ is missing closing ]")
+		}
+		if rem[1] != ']' {
+			end, h, rem, err = parsePathIndex(rem[1:])
+			if err != nil {
+				return nil, "", err
+			}
+			if !h.IsEmpty() {
+				return nil, "", errors.New("Slices cannot be bounded by a hash")
+			}
+			if rem[0] != ']' {
+				return nil, "", errors.New("Invalid slice: too many bounds")
+			}
+		} else {
+			rem = rem[1:]
+		}
+	}
+	rem = rem[1:] // Skip the closing ].
+
+	intoKey, rem := parseKeyAnnotation(rem)
+	switch {
+	case isSlice:
+		part = SlicePath{start, end, intoKey}
+	case start != nil:
+		part = newIndexPath(start, intoKey)
+	default:
+		part = newHashIndexPath(h, intoKey)
+	}
+	return part, rem, nil
+}
+
+// parseKeyAnnotation consumes an @key annotation at the start of str, if there is one.
+func parseKeyAnnotation(str string) (intoKey bool, rem string) {
+	if ann, rem := getAnnotation(str); ann == "key" {
+		return true, rem
+	}
+	return false, str
+}
+
+// Resolve returns the value at p, starting from v. If p addresses several values, Resolve returns the first of them. Since Resolve has no ValueReader, @target annotations resolve to nil; use ResolveEach for those.
+func (p Path) Resolve(v Value) (resolved Value) {
+	p.ResolveEach(v, nil, func(v Value) bool {
+		resolved = v
+		return true
+	})
 	return
 }
 
+// ResolveEach calls cb with each value p addresses, starting from v, in order, until cb returns true. It returns true if cb did. vr is used to read the targets of Refs for @target annotations and may be nil, in which case they resolve to nothing.
+func (p Path) ResolveEach(v Value, vr ValueReader, cb func(v Value) (stop bool)) (stopped bool) {
+	if v == nil {
+		return false
+	}
+	if len(p) == 0 {
+		return cb(v)
+	}
+
+	part, rest := p[0], p[1:]
+	if mp, ok := part.(MultiPathPart); ok {
+		return mp.ResolveEach(v, vr, func(v Value) bool {
+			return rest.ResolveEach(v, vr, cb)
+		})
+	}
+	return rest.ResolveEach(part.Resolve(v), vr, cb)
+}
+
+// ResolveAll returns all the values p addresses, starting from v, in order. See ResolveEach.
+func (p Path) ResolveAll(v Value, vr ValueReader) []Value {
+	values := []Value{}
+	p.ResolveEach(v, vr, func(v Value) bool {
+		values = append(values, v)
+		return false
+	})
+	return values
+}
+
 func (p Path) String() string {
 	strs := make([]string, 0, len(p))
 	for _, part := range p {
@@ -261,6 +370,7 @@ func (hip HashIndexPath) String() string {
 	return fmt.Sprintf("[#%s]%s", hip.Hash.String(), ann)
 }
 
+// parsePathIndex parses a single index value or hash. rem starts at the ] or : that ends it.
 func parsePathIndex(str string) (idx Value, h hash.Hash, rem string, err error) {
 Switch:
 	switch str[0] {
@@ -285,22 +395,22 @@ Switch:
 			stringBuf.WriteByte(c)
 		}
 
-		if i == len(str) {
+		if i >= len(str)-1 || (str[i+1] != ']' && str[i+1] != ':') {
 			err = errors.New("[ is missing closing ]")
 		} else {
 			idx = String(stringBuf.String())
-			rem = str[i+2:]
+			rem = str[i+1:]
 		}
 
 	default:
-		split := strings.SplitN(str, "]", 2)
-		if len(split) < 2 {
+		end := strings.IndexAny(str, "]:")
+		if end == -1 {
 			err = errors.New("[ is missing closing ]")
 			break Switch
 		}
 
-		idxStr := split[0]
-		rem = split[1]
+		idxStr := str[:end]
+		rem = str[end:]
 
 		if len(idxStr) == 0 {
 			err = errors.New("Empty index value")
@@ -0,0 +1,283 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// Gets the values of all the fields of a Struct, in field name order, given by `.*`.
+type WildcardFieldPath struct{}
+
+func (wfp WildcardFieldPath) Resolve(v Value) Value {
+	return resolveFirst(wfp, v)
+}
+
+func (wfp WildcardFieldPath) ResolveEach(v Value, vr ValueReader, cb func(v Value) bool) (stopped bool) {
+	s, ok := v.(Struct)
+	if !ok {
+		return false
+	}
+	s.Type().Desc.(StructDesc).IterFields(func(name string, t *Type) {
+		if !stopped {
+			stopped = cb(s.Get(name))
+		}
+	})
+	return
+}
+
+func (wfp WildcardFieldPath) String() string {
+	return ".*"
+}
+
+// Gets all the elements of a List or Set, or all the values of a Map, given by `[*]`. With an `@key` annotation it gets the keys of a Map instead.
+type WildcardIndexPath struct {
+	IntoKey bool
+}
+
+func (wip WildcardIndexPath) Resolve(v Value) Value {
+	return resolveFirst(wip, v)
+}
+
+func (wip WildcardIndexPath) ResolveEach(v Value, vr ValueReader, cb func(v Value) bool) (stopped bool) {
+	switch v := v.(type) {
+	case List:
+		v.Iter(func(v Value, _ uint64) bool {
+			stopped = cb(v)
+			return stopped
+		})
+	case Set:
+		v.Iter(func(v Value) bool {
+			stopped = cb(v)
+			return stopped
+		})
+	case Map:
+		v.Iter(func(k, v Value) bool {
+			if wip.IntoKey {
+				stopped = cb(k)
+			} else {
+				stopped = cb(v)
+			}
+			return stopped
+		})
+	}
+	return
+}
+
+func (wip WildcardIndexPath) String() string {
+	return "[*]" + keyAnnotation(wip.IntoKey)
+}
+
+// Gets a range of elements, given by `[start:end]`. Either bound may be omitted, and End is exclusive.
+//
+// On a List the bounds are indices, and the path resolves to each element in the range. On a Blob they are byte offsets, and the path resolves to a single Blob holding those bytes. On a Map or Set the bounds are keys, and the path resolves to each value (or with an `@key` annotation, each key) of a Map, or each element of a Set, that is not less than Start and less than End.
+type SlicePath struct {
+	// The first index or key of the range, or nil to start at the beginning.
+	Start Value
+	// The index or key the range stops before, or nil to continue to the end.
+	End     Value
+	IntoKey bool
+}
+
+func (sp SlicePath) Resolve(v Value) Value {
+	return resolveFirst(sp, v)
+}
+
+func (sp SlicePath) ResolveEach(v Value, vr ValueReader, cb func(v Value) bool) bool {
+	switch v := v.(type) {
+	case List:
+		start, end, ok := sp.indexBounds(v.Len())
+		if !ok {
+			return false
+		}
+		cur := newCursorAtIndex(v.seq, start)
+		for i := start; i < end; i++ {
+			if cb(cur.current().(Value)) {
+				return true
+			}
+			cur.advance()
+		}
+
+	case Blob:
+		start, end, ok := sp.indexBounds(v.Len())
+		if !ok {
+			return false
+		}
+		r := v.Reader()
+		_, err := r.Seek(int64(start), 0)
+		if err != nil {
+			return false
+		}
+		return cb(NewBlob(io.LimitReader(r, int64(end-start))))
+
+	case Set:
+		it := v.Iterator()
+		if sp.Start != nil {
+			it = v.IteratorFrom(sp.Start)
+		}
+		for elem := it.Next(); elem != nil && sp.beforeEnd(elem); elem = it.Next() {
+			if cb(elem) {
+				return true
+			}
+		}
+
+	case Map:
+		it := v.Iterator()
+		if sp.Start != nil {
+			it = v.IteratorFrom(sp.Start)
+		}
+		for k, mv := it.Next(); k != nil && sp.beforeEnd(k); k, mv = it.Next() {
+			if sp.IntoKey {
+				mv = k
+			}
+			if cb(mv) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// indexBounds returns the bounds of sp as offsets into a sequence of length l, clamped to l. ok is false if the bounds aren't non-negative integers.
+func (sp SlicePath) indexBounds(l uint64) (start, end uint64, ok bool) {
+	end = l
+	if sp.Start != nil {
+		if start, ok = indexValue(sp.Start); !ok {
+			return
+		}
+	}
+	if sp.End != nil {
+		if end, ok = indexValue(sp.End); !ok {
+			return
+		}
+		if end > l {
+			end = l
+		}
+	}
+	if start > end {
+		start = end
+	}
+	return start, end, true
+}
+
+func (sp SlicePath) beforeEnd(k Value) bool {
+	return sp.End == nil || k.Less(sp.End)
+}
+
+func (sp SlicePath) String() string {
+	start, end := "", ""
+	if sp.Start != nil {
+		start = EncodedIndexValue(sp.Start)
+	}
+	if sp.End != nil {
+		end = EncodedIndexValue(sp.End)
+	}
+	return fmt.Sprintf("[%s:%s]%s", start, end, keyAnnotation(sp.IntoKey))
+}
+
+// Gets the Type of a value, given by `@type`.
+type TypeAnnotation struct{}
+
+func (ta TypeAnnotation) Resolve(v Value) Value {
+	return v.Type()
+}
+
+func (ta TypeAnnotation) String() string {
+	return "@type"
+}
+
+// Gets the target of a Ref, given by `@target`. Reading the target needs a ValueReader, so Resolve always returns nil; use Path.ResolveEach instead.
+type TargetAnnotation struct{}
+
+func (ta TargetAnnotation) Resolve(v Value) Value {
+	return nil
+}
+
+func (ta TargetAnnotation) ResolveEach(v Value, vr ValueReader, cb func(v Value) bool) bool {
+	r, ok := v.(Ref)
+	if !ok || vr == nil {
+		return false
+	}
+	if target := r.TargetValue(vr); target != nil {
+		return cb(target)
+	}
+	return false
+}
+
+func (ta TargetAnnotation) String() string {
+	return "@target"
+}
+
+// Gets the element at a position in a List or Set, or the value at a position in a Map, given by `@at(n)`. Maps and Sets are ordered, so this is the n'th smallest key. Negative positions count from the end. With an `@key` annotation it gets the key of a Map instead.
+type AtAnnotation struct {
+	Index   int64
+	IntoKey bool
+}
+
+func (aa AtAnnotation) Resolve(v Value) Value {
+	switch v := v.(type) {
+	case List:
+		if idx, ok := aa.position(v.Len()); ok {
+			return v.Get(idx)
+		}
+	case Set:
+		if idx, ok := aa.position(v.Len()); ok {
+			return v.At(idx)
+		}
+	case Map:
+		if idx, ok := aa.position(v.Len()); ok {
+			k, mv := v.At(idx)
+			if aa.IntoKey {
+				return k
+			}
+			return mv
+		}
+	}
+	return nil
+}
+
+func (aa AtAnnotation) position(l uint64) (uint64, bool) {
+	idx := aa.Index
+	if idx < 0 {
+		idx += int64(l)
+	}
+	if idx < 0 || uint64(idx) >= l {
+		return 0, false
+	}
+	return uint64(idx), true
+}
+
+func (aa AtAnnotation) String() string {
+	return fmt.Sprintf("@at(%d)%s", aa.Index, keyAnnotation(aa.IntoKey))
+}
+
+func resolveFirst(part MultiPathPart, v Value) (first Value) {
+	part.ResolveEach(v, nil, func(v Value) bool {
+		first = v
+		return true
+	})
+	return
+}
+
+func indexValue(v Value) (uint64, bool) {
+	n, ok := v.(Number)
+	if !ok {
+		return 0, false
+	}
+	f := float64(n)
+	if f != math.Trunc(f) || f < 0 {
+		return 0, false
+	}
+	return uint64(f), true
+}
+
+func keyAnnotation(intoKey bool) string {
+	if intoKey {
+		return "@key"
+	}
+	return ""
+}
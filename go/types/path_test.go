@@ -5,6 +5,7 @@
 package types
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
@@ -235,6 +236,20 @@ func TestPathParseSuccess(t *testing.T) {
 	test(".foo[0].bar[4.5][false]")
 	test(fmt.Sprintf(".foo[#%s]", h.String()))
 	test(fmt.Sprintf(".bar[#%s]@key", h.String()))
+	test("[1:2]")
+	test("[1:]")
+	test("[:2]")
+	test("[:]")
+	test(`["a":"m"]@key`)
+	test("[*]")
+	test("[*]@key")
+	test(".*")
+	test(".foo.*.bar")
+	test("@type")
+	test(".foo@target.bar")
+	test("@at(3)")
+	test("@at(-1)@key")
+	test(".rows[*].name@type")
 }
 
 func TestPathParseErrors(t *testing.T) {
@@ -287,7 +302,110 @@ func TestPathParseErrors(t *testing.T) {
 	test(".foo[42]bar", "Invalid operator: b")
 	test("#foo", "Invalid operator: #")
 	test("!foo", "Invalid operator: !")
-	test("@foo", "Invalid operator: @")
-	test("@key", "Invalid operator: @")
+	test("@foo", "Unsupported annotation: @foo")
+	test("@key", "@key must follow an index")
+	test(".foo@key", "@key must follow an index")
+	test("@", "Invalid operator: @")
+	test(".foo@at", "@at annotation requires an index, e.g. @at(42)")
+	test(".foo@at(x)", "@at annotation requires an index, e.g. @at(42)")
+	test(".foo[1:", "[ is missing closing ]")
+	test(".foo[1:2", "[ is missing closing ]")
+	test(".foo[1:2:3]", "Invalid slice: too many bounds")
+	test(fmt.Sprintf(".foo[#%s:]", hash.FromData([]byte{42}).String()), "Slices cannot be bounded by a hash")
+	test(".foo[*", "[ is missing closing ]")
+	test(".foo.*bar", "Invalid operator: b")
 	test(fmt.Sprintf(".foo[#%s]@soup", hash.FromData([]byte{42}).String()), "Unsupported annotation: @soup")
 }
+
+func assertResolvesToAll(assert *assert.Assertions, expect []Value, ref Value, vr ValueReader, str string) {
+	p, err := ParsePath(str)
+	assert.NoError(err)
+	actual := p.ResolveAll(ref, vr)
+	if assert.Equal(len(expect), len(actual), str) {
+		for i, v := range expect {
+			assert.True(v.Equals(actual[i]), "%s: expected %s, but got %s", str, EncodedValue(v), EncodedValue(actual[i]))
+		}
+	}
+}
+
+func TestPathSlice(t *testing.T) {
+	assert := assert.New(t)
+
+	l := NewList(Number(0), Number(1), Number(2), Number(3))
+	assertResolvesToAll(assert, []Value{Number(1), Number(2)}, l, nil, "[1:3]")
+	assertResolvesToAll(assert, []Value{Number(2), Number(3)}, l, nil, "[2:]")
+	assertResolvesToAll(assert, []Value{Number(0)}, l, nil, "[:1]")
+	assertResolvesToAll(assert, []Value{Number(3)}, l, nil, "[3:100]")
+	assertResolvesToAll(assert, []Value{}, l, nil, "[3:1]")
+	assertResolvesToAll(assert, []Value{}, l, nil, "[0.5:2]")
+	assertResolvesTo(assert, Number(1), l, "[1:3]")
+
+	b := NewBlob(bytes.NewReader([]byte("hello world")))
+	assertResolvesToAll(assert, []Value{NewBlob(bytes.NewReader([]byte("world")))}, b, nil, "[6:]")
+	assertResolvesToAll(assert, []Value{NewBlob(bytes.NewReader([]byte("hello")))}, b, nil, "[:5]")
+
+	m := NewMap(String("a"), Number(1), String("b"), Number(2), String("c"), Number(3), String("d"), Number(4))
+	assertResolvesToAll(assert, []Value{Number(2), Number(3)}, m, nil, `["b":"d"]`)
+	assertResolvesToAll(assert, []Value{String("b"), String("c")}, m, nil, `["az":"d"]@key`)
+	assertResolvesToAll(assert, []Value{Number(3), Number(4)}, m, nil, `["c":]`)
+	assertResolvesToAll(assert, []Value{Number(1)}, m, nil, `[:"b"]`)
+
+	s := NewSet(Number(1), Number(5), Number(10), Number(15))
+	assertResolvesToAll(assert, []Value{Number(5), Number(10)}, s, nil, `[2:15]`)
+
+	assertResolvesToAll(assert, []Value{}, Number(1), nil, `[1:2]`)
+}
+
+func TestPathWildcards(t *testing.T) {
+	assert := assert.New(t)
+
+	row := func(name string, age int) Value {
+		return NewStruct("Row", StructData{"name": String(name), "age": Number(age)})
+	}
+	v := NewStruct("", StructData{
+		"rows":   NewList(row("a", 1), row("b", 2)),
+		"byName": NewMap(String("a"), row("a", 1), String("b"), row("b", 2)),
+		"set":    NewSet(Number(1), Number(2)),
+	})
+
+	assertResolvesToAll(assert, []Value{String("a"), String("b")}, v, nil, ".rows[*].name")
+	assertResolvesToAll(assert, []Value{Number(1), Number(2)}, v, nil, ".byName[*].age")
+	assertResolvesToAll(assert, []Value{String("a"), String("b")}, v, nil, ".byName[*]@key")
+	assertResolvesToAll(assert, []Value{Number(1), Number(2)}, v, nil, ".set[*]")
+	assertResolvesToAll(assert, []Value{Number(1), String("a")}, v, nil, ".rows[0].*")
+	assertResolvesToAll(assert, []Value{Number(1), String("a"), Number(2), String("b")}, v, nil, ".rows[*].*")
+	assertResolvesToAll(assert, []Value{String("b")}, v, nil, ".rows[1:].name")
+	assertResolvesToAll(assert, []Value{}, v, nil, ".rows[*].notHere")
+	assertResolvesTo(assert, String("a"), v, ".rows[*].name")
+}
+
+func TestPathAnnotations(t *testing.T) {
+	assert := assert.New(t)
+	vs := NewTestValueStore()
+
+	target := NewList(Number(1), Number(2))
+	r := vs.WriteValue(target)
+	v := NewStruct("", StructData{
+		"ref":  r,
+		"refs": NewSet(r),
+		"map":  NewMap(String("a"), Number(1), String("b"), Number(2)),
+		"set":  NewSet(Number(10), Number(20), Number(30)),
+	})
+
+	assertResolvesTo(assert, NumberType, v, ".map[\"a\"]@type")
+	assertResolvesTo(assert, MakeSetType(NumberType), v, ".set@type")
+
+	assertResolvesToAll(assert, []Value{target}, v, vs, ".ref@target")
+	assertResolvesToAll(assert, []Value{Number(2)}, v, vs, ".ref@target[1]")
+	assertResolvesToAll(assert, []Value{Number(1), Number(2)}, v, vs, ".refs[*]@target[*]")
+	assertResolvesToAll(assert, []Value{}, v, nil, ".ref@target")
+	assertResolvesTo(assert, nil, v, ".ref@target")
+
+	assertResolvesTo(assert, Number(2), v, ".map@at(1)")
+	assertResolvesTo(assert, String("b"), v, ".map@at(1)@key")
+	assertResolvesTo(assert, String("a"), v, ".map@at(-2)@key")
+	assertResolvesTo(assert, nil, v, ".map@at(2)")
+	assertResolvesTo(assert, nil, v, ".map@at(-3)")
+	assertResolvesTo(assert, Number(10), v, ".set@at(0)")
+	assertResolvesTo(assert, Number(30), v, ".set@at(-1)")
+}
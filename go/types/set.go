@@ -7,6 +7,7 @@ package types
 import (
 	"sort"
 
+	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/hash"
 )
 
@@ -163,6 +164,18 @@ func (s Set) Has(v Value) bool {
 	return cur.valid() && cur.current().(Value).Equals(v)
 }
 
+// At returns the value at position idx in s. It panics if idx is out of range.
+func (s Set) At(idx uint64) Value {
+	d.Chk.True(idx < s.Len())
+	return newCursorAtIndex(s.seq, idx).current().(Value)
+}
+
+// IndexOf returns the position of v in s and whether v is in s. If it is not, idx is the position at which v would be inserted, which is also the number of values less than v.
+func (s Set) IndexOf(v Value) (idx uint64, found bool) {
+	cur := newCursorAtValue(s.seq, v, false, false)
+	return cursorIndex(cur), cur.valid() && cur.current().(Value).Equals(v)
+}
+
 type setIterCallback func(v Value) bool
 
 func (s Set) Iter(cb setIterCallback) {
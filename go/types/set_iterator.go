@@ -0,0 +1,65 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import "github.com/attic-labs/noms/go/d"
+
+// SetIterator iterates over the values of a Set in order, in either direction. It sits between two values: Next() returns the value after the current position and moves past it, Prev() returns the value before the current position and moves back over it. Both return nil when there is no value in that direction.
+type SetIterator struct {
+	cursor *sequenceCursor
+}
+
+// Iterator returns a SetIterator positioned before the first value of s.
+func (s Set) Iterator() *SetIterator {
+	return &SetIterator{newCursorAt(s.seq, emptyKey, false, false)}
+}
+
+// IteratorAt returns a SetIterator positioned right before v, so that the first call to Next() returns v. If v is not in s, the iterator is exhausted in both directions.
+func (s Set) IteratorAt(v Value) *SetIterator {
+	cur := newCursorAtValue(s.seq, v, false, false)
+	if !cur.valid() || !cur.current().(Value).Equals(v) {
+		return &SetIterator{}
+	}
+	return &SetIterator{cur}
+}
+
+// IteratorFrom returns a SetIterator positioned before the first value that is not less than lo. Next() therefore returns the values >= lo in ascending order, and Prev() those < lo in descending order.
+func (s Set) IteratorFrom(lo Value) *SetIterator {
+	return &SetIterator{newCursorAtValue(s.seq, lo, false, false)}
+}
+
+// IteratorAtIndex returns a SetIterator positioned before the value at position idx, so that Next() returns the values from idx onwards. idx may be s.Len(), which positions the iterator after the last value. It panics if idx is greater than that.
+func (s Set) IteratorAtIndex(idx uint64) *SetIterator {
+	d.Chk.True(idx <= s.Len())
+	return &SetIterator{newCursorAtIndex(s.seq, idx)}
+}
+
+// IteratorAtEnd returns a SetIterator positioned after the last value of s, for iterating in reverse with Prev().
+func (s Set) IteratorAtEnd() *SetIterator {
+	if s.Empty() {
+		return s.Iterator()
+	}
+	cur := newCursorAt(s.seq, emptyKey, false, true)
+	cur.advance()
+	return &SetIterator{cur}
+}
+
+// Next returns the value after the current position and advances past it, or nil if there are no more values.
+func (si *SetIterator) Next() Value {
+	if si.cursor == nil || !si.cursor.valid() {
+		return nil
+	}
+	v := si.cursor.current().(Value)
+	si.cursor.advance()
+	return v
+}
+
+// Prev returns the value before the current position and moves back over it, or nil if there are no earlier values.
+func (si *SetIterator) Prev() Value {
+	if si.cursor == nil || !retreatCursor(si.cursor) {
+		return nil
+	}
+	return si.cursor.current().(Value)
+}
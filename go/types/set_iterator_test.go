@@ -0,0 +1,126 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package types
+
+import (
+	"testing"
+
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestSetIterator(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := newSortedTestSet(64*128, newNumber)
+	s := ts.toSet()
+	assert.IsType(orderedMetaSequence{}, s.seq)
+
+	it := s.Iterator()
+	for _, v := range ts {
+		assert.True(v.Equals(it.Next()))
+	}
+	assert.Nil(it.Next())
+
+	for i := len(ts) - 1; i >= 0; i-- {
+		assert.True(ts[i].Equals(it.Prev()))
+	}
+	assert.Nil(it.Prev())
+	assert.True(ts[0].Equals(it.Next()))
+
+	assert.True(ts[len(ts)-1].Equals(s.IteratorAtEnd().Prev()))
+}
+
+func TestSetIteratorAtAndFrom(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := newSortedTestSet(64*128, newNumber)
+	s := ts.toSet()
+
+	for _, i := range []int{0, len(ts) / 2, len(ts) - 1} {
+		it := s.IteratorAt(ts[i])
+		assert.True(ts[i].Equals(it.Next()))
+
+		it = s.IteratorFrom(ts[i])
+		if i > 0 {
+			assert.True(ts[i-1].Equals(it.Prev()))
+			it.Next()
+		}
+		assert.True(ts[i].Equals(it.Next()))
+	}
+
+	ns := NewSet(Number(0), Number(2), Number(4))
+	assert.Nil(ns.IteratorAt(Number(1)).Next())
+	assert.True(Number(2).Equals(ns.IteratorFrom(Number(1)).Next()))
+	assert.Nil(ns.IteratorFrom(Number(5)).Next())
+}
+
+func TestSetIteratorEmpty(t *testing.T) {
+	assert := assert.New(t)
+	s := NewSet()
+	for _, it := range []*SetIterator{s.Iterator(), s.IteratorAtEnd(), s.IteratorFrom(Number(1)), s.IteratorAt(Number(1))} {
+		assert.Nil(it.Next())
+		assert.Nil(it.Prev())
+	}
+}
+
+func TestSetAtAndIndexOf(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := newSortedTestSet(64*128, newNumber)
+	s := ts.toSet()
+	assert.IsType(orderedMetaSequence{}, s.seq)
+
+	vs := NewTestValueStore()
+	s2 := vs.ReadValue(vs.WriteValue(s).TargetHash()).(Set)
+
+	for _, s := range []Set{s, s2} {
+		for i, v := range ts {
+			assert.True(v.Equals(s.At(uint64(i))))
+
+			idx, found := s.IndexOf(v)
+			assert.True(found)
+			assert.Equal(uint64(i), idx)
+		}
+		assert.Panics(func() { s.At(s.Len()) })
+	}
+
+	idx, found := s.IndexOf(Number(-1))
+	assert.False(found)
+	assert.Equal(uint64(0), idx)
+	idx, found = s.IndexOf(Number(1000.5))
+	assert.False(found)
+	assert.Equal(uint64(1001), idx)
+	idx, found = s.IndexOf(Number(len(ts)))
+	assert.False(found)
+	assert.Equal(s.Len(), idx)
+}
+
+func TestSetIteratorAtIndex(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := newSortedTestSet(64*128, newNumber)
+	s := ts.toSet()
+
+	for _, i := range []int{0, 1, 5000, len(ts) - 1} {
+		it := s.IteratorAtIndex(uint64(i))
+		for j := i; j < len(ts); j++ {
+			assert.True(ts[j].Equals(it.Next()))
+		}
+		assert.Nil(it.Next())
+
+		it = s.IteratorAtIndex(uint64(i))
+		for j := i - 1; j >= 0; j-- {
+			assert.True(ts[j].Equals(it.Prev()))
+		}
+		assert.Nil(it.Prev())
+	}
+
+	it := s.IteratorAtIndex(s.Len())
+	assert.Nil(it.Next())
+	assert.True(ts[len(ts)-1].Equals(it.Prev()))
+
+	assert.Nil(NewSet().IteratorAtIndex(0).Next())
+	assert.Panics(func() { s.IteratorAtIndex(s.Len() + 1) })
+}
@@ -52,6 +52,10 @@ func (sl setLeafSequence) getKey(idx int) orderedKey {
 	return newOrderedKey(sl.data[idx])
 }
 
+func (sl setLeafSequence) cumulativeNumberOfLeaves(idx int) uint64 {
+	return uint64(idx) + 1
+}
+
 func (sl setLeafSequence) getCompareFn(other sequence) compareFn {
 	osl := other.(setLeafSequence)
 	return func(idx, otherIdx int) bool {
@@ -168,6 +168,11 @@ func verifyFieldNames(names []string) {
 	}
 }
 
+// IsValidStructFieldName returns whether name can be used as the name of a struct field.
+func IsValidStructFieldName(name string) bool {
+	return fieldNameRe.MatchString(name)
+}
+
 func verifyName(name, kind string) {
 	d.PanicIfTrue(!fieldNameRe.MatchString(name), `Invalid struct%s name: "%s"`, kind, name)
 }
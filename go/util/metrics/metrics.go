@@ -0,0 +1,203 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Package metrics keeps counters and histograms, and serves them in the Prometheus text format (https://prometheus.io/docs/instrumenting/exposition_formats/) so that a Prometheus server can scrape them.
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/attic-labs/noms/go/d"
+)
+
+// DurationBuckets are the upper bounds, in seconds, of the buckets of histograms of how long things take.
+var DurationBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// SizeBuckets are the upper bounds of the buckets of histograms of the sizes of batches.
+var SizeBuckets = []float64{1, 4, 16, 64, 256, 1024, 4096, 16384}
+
+// Registry holds a set of metrics. It's safe to use from several goroutines.
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+func NewRegistry() *Registry {
+	return &Registry{families: map[string]*family{}}
+}
+
+// family is a metric and all its series, i.e. the values it has for each combination of label values.
+type family struct {
+	name, help, kind string
+	labels           []string
+	buckets          []float64
+	series           map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64
+	// For histograms, the number of observations in each bucket, and the sum of the observations. The count of all observations is value.
+	bucketCounts []uint64
+	sum          float64
+}
+
+func (r *Registry) family(name, help, kind string, labels []string, buckets []float64) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.families[name]; ok {
+		d.PanicIfTrue(f.kind != kind || strings.Join(f.labels, ",") != strings.Join(labels, ","), "Metric %s is already registered with different labels or type", name)
+		return f
+	}
+	f := &family{name, help, kind, labels, buckets, map[string]*series{}}
+	r.families[name] = f
+	return f
+}
+
+// update calls cb with the series of f for labelValues, creating it if need be.
+func (r *Registry) update(f *family, labelValues []string, cb func(s *series)) {
+	d.PanicIfTrue(len(labelValues) != len(f.labels), "Metric %s needs %d label values, not %d", f.name, len(f.labels), len(labelValues))
+	key := strings.Join(labelValues, "\xff")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: labelValues, bucketCounts: make([]uint64, len(f.buckets))}
+		f.series[key] = s
+	}
+	cb(s)
+}
+
+// Counter is a metric whose value only goes up, such as a number of requests.
+type Counter struct {
+	r *Registry
+	f *family
+}
+
+// Counter returns the counter called name, which has the given labels, registering it if need be.
+func (r *Registry) Counter(name, help string, labels ...string) Counter {
+	return Counter{r, r.family(name, help, "counter", labels, nil)}
+}
+
+// Add adds delta to the value of c for the given label values, which must be in the order its labels were registered in.
+func (c Counter) Add(delta float64, labelValues ...string) {
+	c.r.update(c.f, labelValues, func(s *series) { s.value += delta })
+}
+
+// Inc adds 1 to the value of c for the given label values.
+func (c Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Histogram is a metric that counts observations, such as request latencies, in buckets.
+type Histogram struct {
+	r *Registry
+	f *family
+}
+
+// Histogram returns the histogram called name, which has the given labels and buckets, registering it if need be. buckets are the upper bounds of the buckets, in increasing order; a bucket for everything else is added.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) Histogram {
+	return Histogram{r, r.family(name, help, "histogram", labels, buckets)}
+}
+
+// Observe adds v to h for the given label values.
+func (h Histogram) Observe(v float64, labelValues ...string) {
+	h.r.update(h.f, labelValues, func(s *series) {
+		s.value++
+		s.sum += v
+		if i := sort.SearchFloat64s(h.f.buckets, v); i < len(s.bucketCounts) {
+			s.bucketCounts[i]++
+		}
+	})
+}
+
+// ObserveSince adds the number of seconds since start to h for the given label values.
+func (h Histogram) ObserveSince(start time.Time, labelValues ...string) {
+	h.Observe(time.Since(start).Seconds(), labelValues...)
+}
+
+// WriteText writes every metric in r to w in the Prometheus text format.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	buf := &bytes.Buffer{}
+	names := make([]string, 0, len(r.families))
+	for name := range r.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.families[name].writeText(buf)
+	}
+	r.mu.Unlock()
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (f *family) writeText(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.kind)
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := f.series[k]
+		if f.kind != "histogram" {
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelText(s.labelValues, ""), formatValue(s.value))
+			continue
+		}
+		var cumulative uint64
+		for i, b := range f.buckets {
+			cumulative += s.bucketCounts[i]
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelText(s.labelValues, formatValue(b)), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %s\n", f.name, f.labelText(s.labelValues, "+Inf"), formatValue(s.value))
+		fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelText(s.labelValues, ""), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %s\n", f.name, f.labelText(s.labelValues, ""), formatValue(s.value))
+	}
+}
+
+// labelText returns the {name="value",...} part of a sample, including the le label of a histogram bucket if le isn't empty.
+func (f *family) labelText(values []string, le string) string {
+	pairs := []string{}
+	for i, l := range f.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, l, escapeLabelValue(values[i])))
+	}
+	if le != "" {
+		pairs = append(pairs, fmt.Sprintf(`le="%s"`, le))
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(v string) string {
+	return labelValueReplacer.Replace(v)
+}
+
+func formatValue(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// ServeHTTP serves the metrics in r in the Prometheus text format.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.WriteText(w)
+}
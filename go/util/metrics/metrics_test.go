@@ -0,0 +1,77 @@
+// Copyright 2016 Attic Labs, Inc. All rights reserved.
+// Licensed under the Apache License, version 2.0:
+// http://www.apache.org/licenses/LICENSE-2.0
+
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRegistry()
+	c := r.Counter("requests_total", "Number of requests.", "handler", "code")
+	c.Inc("root", "200")
+	c.Add(2, "root", "200")
+	c.Inc("write", "400")
+	c.Inc("root", `a "quoted"\value`)
+	r.Counter("empty_total", "Nothing\nto see.")
+
+	buf := &bytes.Buffer{}
+	assert.NoError(r.WriteText(buf))
+	assert.Equal(`# HELP empty_total Nothing\nto see.
+# TYPE empty_total counter
+# HELP requests_total Number of requests.
+# TYPE requests_total counter
+requests_total{handler="root",code="200"} 3
+requests_total{handler="root",code="a \"quoted\"\\value"} 1
+requests_total{handler="write",code="400"} 1
+`, buf.String())
+
+	// Registering the same metric again returns it, but only if it matches.
+	r.Counter("requests_total", "Number of requests.", "handler", "code").Inc("write", "400")
+	assert.Contains(textOf(r), `requests_total{handler="write",code="400"} 2`)
+	assert.Error(d.Try(func() { r.Counter("requests_total", "", "handler") }))
+	assert.Error(d.Try(func() { c.Inc("root") }))
+}
+
+func TestHistogram(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRegistry()
+	h := r.Histogram("size", "Sizes.", []float64{1, 10}, "op")
+	for _, v := range []float64{0.5, 1, 5, 100} {
+		h.Observe(v, "get")
+	}
+
+	assert.Equal(`# HELP size Sizes.
+# TYPE size histogram
+size_bucket{op="get",le="1"} 2
+size_bucket{op="get",le="10"} 3
+size_bucket{op="get",le="+Inf"} 4
+size_sum{op="get"} 106.5
+size_count{op="get"} 4
+`, textOf(r))
+}
+
+func TestServeHTTP(t *testing.T) {
+	assert := assert.New(t)
+	r := NewRegistry()
+	r.Counter("hits_total", "Hits.").Inc()
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal("text/plain; version=0.0.4", w.Header().Get("Content-Type"))
+	assert.Contains(w.Body.String(), "\nhits_total 1\n")
+}
+
+func textOf(r *Registry) string {
+	buf := &bytes.Buffer{}
+	d.PanicIfError(r.WriteText(buf))
+	return buf.String()
+}
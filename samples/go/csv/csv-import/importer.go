@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/datas"
 	"github.com/attic-labs/noms/go/dataset"
 	"github.com/attic-labs/noms/go/spec"
 	"github.com/attic-labs/noms/go/types"
@@ -27,23 +28,22 @@ import (
 )
 
 const (
-	dateFormat = "2006-01-02T15:04:05-0700"
-	destList   = iota
-	destMap    = iota
+	destList = iota
+	destMap  = iota
 )
 
 func main() {
 	// Actually the delimiter uses runes, which can be multiple characters long.
 	// https://blog.golang.org/strings
 	delimiter := flag.String("delimiter", ",", "field delimiter for csv file, must be exactly one character long.")
-	comment := flag.String("comment", "", "comment to add to commit's meta data")
+	comment := flag.String("comment", "", "message to add to commit's meta data")
 	header := flag.String("header", "", "header row. If empty, we'll use the first row of the file")
 	name := flag.String("name", "Row", "struct name. The user-visible name to give to the struct type that will hold each row of data.")
 	columnTypes := flag.String("column-types", "", "a comma-separated list of types representing the desired type of each column. if absent all types default to be String")
 	pathDescription := "noms path to blob to import"
 	path := flag.String("path", "", pathDescription)
 	flag.StringVar(path, "p", "", pathDescription)
-	dateFlag := flag.String("date", "", fmt.Sprintf(`date of commit in ISO 8601 format ("%s"). By default, the current date is used.`, dateFormat))
+	dateFlag := flag.String("date", "", fmt.Sprintf(`date of commit in ISO 8601 format ("%s"). By default, the current date is used.`, datas.MetaDateFormat))
 	noProgress := flag.Bool("no-progress", false, "prevents progress from being output if true")
 	destType := flag.String("dest-type", "list", "the destination type to import to. can be 'list' or 'map:<pk>', where <pk> is the index position (0-based) of the column that is a the unique identifier for the column")
 	skipRecords := flag.Uint("skip-records", 0, "number of records to skip at beginning of file")
@@ -72,11 +72,9 @@ func main() {
 	}
 	d.CheckError(err)
 
-	var date = *dateFlag
-	if date == "" {
-		date = time.Now().UTC().Format(dateFormat)
-	} else {
-		_, err := time.Parse(dateFormat, date)
+	var date time.Time
+	if *dateFlag != "" {
+		date, err = datas.ParseMetaDate(*dateFlag)
 		d.CheckErrorNoUsage(err)
 	}
 
@@ -162,28 +160,25 @@ func main() {
 		value = csv.ReadToMap(cr, *name, headers, pk, kinds, ds.Database())
 	}
 	mi := metaInfoForCommit(date, filePath, *path, *comment)
-	_, err = ds.Commit(value, dataset.CommitOptions{Meta: mi})
+	_, err = ds.Commit(value, dataset.CommitOptions{}.WithMeta(mi))
 	if !*noProgress {
 		status.Clear()
 	}
 	d.PanicIfError(err)
 }
 
-func metaInfoForCommit(date, filePath, nomsPath, comment string) types.Struct {
+func metaInfoForCommit(date time.Time, filePath, nomsPath, comment string) datas.CommitMeta {
 	fileOrNomsPath := "inputPath"
 	path := nomsPath
 	if path == "" {
 		path = filePath
 		fileOrNomsPath = "inputFile"
 	}
-	metaValues := types.StructData{
-		"date":         types.String(date),
-		fileOrNomsPath: types.String(path),
-	}
-	if comment != "" {
-		metaValues["comment"] = types.String(comment)
+	return datas.CommitMeta{
+		Date:    date,
+		Message: comment,
+		Extra:   types.StructData{fileOrNomsPath: types.String(path)},
 	}
-	return types.NewStruct("Meta", metaValues)
 }
 
 func getStatusPrinter(expected uint64) progressreader.Callback {
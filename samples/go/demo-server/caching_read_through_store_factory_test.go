@@ -15,7 +15,7 @@ func TestCaching(t *testing.T) {
 	assert := assert.New(t)
 
 	tFactory := chunks.NewTestStoreFactory()
-	factory := &cachingReadThroughStoreFactory{chunks.NewMemoryStore(), tFactory}
+	factory := &cachingReadThroughStoreFactory{chunks.NewMemoryStore(), tFactory, nil}
 	defer factory.Shutter()
 
 	chunk := chunks.NewChunk([]byte("abc"))
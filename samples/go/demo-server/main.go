@@ -9,6 +9,7 @@ import (
 
 	"github.com/attic-labs/noms/go/chunks"
 	"github.com/attic-labs/noms/go/d"
+	"github.com/attic-labs/noms/go/util/metrics"
 	flag "github.com/tsuru/gnuflag"
 )
 
@@ -16,6 +17,7 @@ var (
 	portFlag    = flag.Int("port", 8000, "port to listen on")
 	ldbDir      = flag.String("ldb-dir", "", "directory for ldb database")
 	authKeyFlag = flag.String("authkey", "", "token to use for authenticating write operations")
+	metricsFlag = flag.Bool("metrics", false, "record metrics of requests and of the stores, and serve them at /metrics in the Prometheus text format")
 )
 
 func usage() {
@@ -45,7 +47,10 @@ func main() {
 		factory = chunks.NewMemoryStoreFactory()
 		fmt.Printf("Using mem ...\n")
 	}
-	factory = &cachingReadThroughStoreFactory{chunks.NewMemoryStore(), factory}
+	if *metricsFlag {
+		registry = metrics.NewRegistry()
+	}
+	factory = &cachingReadThroughStoreFactory{chunks.NewMemoryStore(), factory, registry}
 	defer factory.Shutter()
 
 	startWebServer(factory, *authKeyFlag)
@@ -54,11 +59,18 @@ func main() {
 type cachingReadThroughStoreFactory struct {
 	cache   *chunks.MemoryStore
 	factory chunks.Factory
+	// If set, calls to the cache and the backing stores are recorded in metrics.
+	metrics *metrics.Registry
 }
 
 func (f *cachingReadThroughStoreFactory) CreateStore(ns string) chunks.ChunkStore {
 	d.Chk.True(f.factory != nil, "Cannot use cachingReadThroughStoreFactory after Shutter().")
-	return chunks.NewReadThroughStore(f.cache, f.factory.CreateStore(ns))
+	var cache, backing chunks.ChunkStore = f.cache, f.factory.CreateStore(ns)
+	if f.metrics != nil {
+		cache = chunks.NewInstrumentedStore(cache, f.metrics, "cache")
+		backing = chunks.NewInstrumentedStore(backing, f.metrics, "backing")
+	}
+	return chunks.NewReadThroughStore(cache, backing)
 }
 
 func (f *cachingReadThroughStoreFactory) Shutter() {
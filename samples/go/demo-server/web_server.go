@@ -19,6 +19,7 @@ import (
 	"github.com/attic-labs/noms/go/constants"
 	"github.com/attic-labs/noms/go/d"
 	"github.com/attic-labs/noms/go/datas"
+	"github.com/attic-labs/noms/go/util/metrics"
 	"github.com/julienschmidt/httprouter"
 )
 
@@ -30,6 +31,8 @@ var (
 	authRegexp = regexp.MustCompile("^Bearer\\s+(\\S*)$")
 	router     *httprouter.Router
 	authKey    = ""
+	// If set, requests are recorded in registry, which is served at /metrics.
+	registry *metrics.Registry
 )
 
 func setupWebServer(factory chunks.Factory) *httprouter.Router {
@@ -46,19 +49,23 @@ func setupWebServer(factory chunks.Factory) *httprouter.Router {
 	// e.g. http://localhost:8000/dan/root/ doesn't match any of these routes. h.NotFound(), will
 	// pull out "dan" and lookup up the "/root/" route, and then invoke it.
 
-	router.GET(constants.RootPath, corsHandle(storeHandle(factory, datas.HandleRootGet)))
-	router.POST(constants.RootPath, corsHandle(authorizeHandle(storeHandle(factory, datas.HandleRootPost))))
+	router.GET(constants.RootPath, corsHandle(storeHandle(factory, "root_get", datas.HandleRootGet)))
+	router.POST(constants.RootPath, corsHandle(authorizeHandle(storeHandle(factory, "root_post", datas.HandleRootPost))))
 	router.OPTIONS(constants.RootPath, corsHandle(noopHandle))
 
-	router.POST(constants.GetRefsPath, corsHandle(storeHandle(factory, datas.HandleGetRefs)))
+	router.POST(constants.GetRefsPath, corsHandle(storeHandle(factory, "get_refs", datas.HandleGetRefs)))
 	router.OPTIONS(constants.GetRefsPath, corsHandle(noopHandle))
 
-	router.POST(constants.HasRefsPath, corsHandle(storeHandle(factory, datas.HandleHasRefs)))
+	router.POST(constants.HasRefsPath, corsHandle(storeHandle(factory, "has_refs", datas.HandleHasRefs)))
 	router.OPTIONS(constants.HasRefsPath, corsHandle(noopHandle))
 
-	router.POST(constants.WriteValuePath, corsHandle(authorizeHandle(storeHandle(factory, datas.HandleWriteValue))))
+	router.POST(constants.WriteValuePath, corsHandle(authorizeHandle(storeHandle(factory, "write_value", datas.HandleWriteValue))))
 	router.OPTIONS(constants.WriteValuePath, corsHandle(noopHandle))
 
+	if registry != nil {
+		router.Handler("GET", constants.MetricsPath, registry)
+	}
+
 	return router
 }
 
@@ -79,8 +86,9 @@ func startWebServer(factory chunks.Factory, key string) {
 	log.Fatal(srv.Serve(l))
 }
 
-// Attach handlers that provide the Database API
-func storeHandle(factory chunks.Factory, hndlr datas.Handler) httprouter.Handle {
+// Attach handlers that provide the Database API. If metrics are being recorded, requests are recorded as those of the handler called name.
+func storeHandle(factory chunks.Factory, name string, hndlr datas.Handler) httprouter.Handle {
+	hndlr = datas.InstrumentHandler(registry, name, hndlr)
 	return func(w http.ResponseWriter, req *http.Request, params httprouter.Params) {
 		cs := factory.CreateStore(params.ByName(dbParam))
 		defer cs.Close()
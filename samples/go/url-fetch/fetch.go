@@ -28,7 +28,7 @@ var (
 )
 
 func main() {
-	comment := flag.String("comment", "", "comment to add to commit's meta data")
+	comment := flag.String("comment", "", "message to add to commit's meta data")
 	stdin := flag.Bool("stdin", false, "read blob from stdin")
 
 	spec.RegisterDatabaseFlags(flag.CommandLine)
@@ -95,7 +95,7 @@ func main() {
 	pr := progressreader.New(r, getStatusPrinter(contentLength))
 	b := types.NewStreamingBlob(pr, ds.Database())
 	mi := metaInfoForCommit(sourceType, sourceVal, *comment)
-	ds, err = ds.Commit(b, dataset.CommitOptions{Meta: mi})
+	ds, err = ds.Commit(b, dataset.CommitOptions{}.WithMeta(mi))
 	if err != nil {
 		d.Chk.Equal(datas.ErrMergeNeeded, err)
 		fmt.Fprintf(os.Stderr, "Could not commit, optimistic concurrency failed.")
@@ -106,18 +106,12 @@ func main() {
 	fmt.Println("Done")
 }
 
-func metaInfoForCommit(sourceType, sourceVal, comment string) types.Struct {
-	date := time.Now().UTC().Format("2006-01-02T15:04:05-0700")
-	metaValues := map[string]types.Value{
-		"date": types.String(date),
-	}
+func metaInfoForCommit(sourceType, sourceVal, comment string) datas.CommitMeta {
+	extra := types.StructData{}
 	if sourceType != "" {
-		metaValues[sourceType] = types.String(sourceVal)
-	}
-	if comment != "" {
-		metaValues["comment"] = types.String(comment)
+		extra[sourceType] = types.String(sourceVal)
 	}
-	return types.NewStruct("Meta", metaValues)
+	return datas.CommitMeta{Message: comment, Extra: extra}
 }
 
 func getStatusPrinter(expectedLen int64) progressreader.Callback {